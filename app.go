@@ -6,14 +6,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"Akashic/docxexport"
+	"Akashic/markdown"
 	"Akashic/pdfexport"
 
 	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
@@ -38,21 +43,41 @@ type App struct {
 	SettingsManager *SettingsManager
 	EventBus        *EventBus
 	ChatDB          *ChatDB
-	activeRequests  map[string]context.CancelFunc
+	Recovery        *RecoveryService
+	Sessions        *SessionStore
+	History         *FileHistory
+	Search          *ProjectSearch
+	Git             *GitClient
+	activeRequests  map[string]context.CancelFunc // guarded by requestsMu
+	requestsMu      sync.Mutex
 	ollamaProcess   *exec.Cmd
 	ollamaMutex     sync.Mutex
+
+	// Launches from the command line and from later instances
+	launchMu         sync.Mutex
+	launches         []LaunchRequest
+	waits            map[string]chan struct{} // by wait ID, closed by FinishWait
+	nextWaitID       int
+	quitAfterWait    string // this instance was started with --wait
+	instanceListener net.Listener
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	app := &App{
 		activeRequests: make(map[string]context.CancelFunc),
+		waits:          make(map[string]chan struct{}),
 	}
 
 	// Initialize modules
 	app.EventBus = NewEventBus()
 	app.SettingsManager = NewSettingsManager()
 	app.FileManager = NewFileManager(app)
+	app.Recovery = NewRecoveryService(app)
+	app.Sessions = NewSessionStore()
+	app.History = NewFileHistory(app)
+	app.Search = NewProjectSearch(app)
+	app.Git = NewGitClient(app)
 
 	// Initialize chat database
 	var err error
@@ -70,6 +95,11 @@ func NewApp() *App {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 
+	// Forward emitted events to the frontend
+	a.EventBus.SetFrontendEmitter(func(event string, data interface{}) {
+		wailsRuntime.EventsEmit(ctx, event, data)
+	})
+
 	// Initialize settings
 	if err := a.SettingsManager.Load(); err != nil {
 		fmt.Printf("Failed to load settings: %v\n", err)
@@ -78,6 +108,54 @@ func (a *App) startup(ctx context.Context) {
 	// Initialize file manager
 	a.FileManager.Startup()
 
+	// Keep snapshots of unsaved buffers for crash recovery
+	a.Recovery.Start()
+
+	// Restore the last session and follow the editor's tabs
+	if err := a.Sessions.Load(); err != nil {
+		fmt.Printf("Failed to load sessions: %v\n", err)
+	}
+	for _, event := range []string{EventEditorCursor, EventEditorSelection, EventEditorScroll, EventEditorFocus} {
+		a.EventBus.Subscribe(event, func(data interface{}) {
+			if editorData, ok := data.(EditorEventData); ok {
+				a.Sessions.applyEditorEvent(event, editorData)
+			}
+		})
+	}
+
+	// Keep git gutter markers and the branch indicator up to date
+	a.EventBus.Subscribe(EventFileSave, func(data interface{}) {
+		if fileData, ok := data.(FileEventData); ok && fileData.FileInfo != nil && fileData.FileInfo.Path != "" {
+			a.Git.refresh(fileData.FileInfo.Path)
+		}
+	})
+
+	// Check the chat database in the background, then start auto-lock,
+	// scheduled backups and retention
+	if a.ChatDB != nil {
+		// Replies still streaming when the app last exited can never finish
+		if marked, err := a.ChatDB.MarkInterruptedMessages(); err != nil {
+			fmt.Printf("Failed to mark interrupted replies: %v\n", err)
+		} else if marked > 0 {
+			fmt.Printf("Marked %d interrupted replies\n", marked)
+		}
+
+		go a.runChatMaintenance()
+
+		a.applyChatSettings(a.SettingsManager.Get())
+		a.ChatDB.StartAutoLock(func() {
+			a.EventBus.Emit(EventChatLocked, nil)
+		})
+		a.ChatDB.StartScheduler(
+			func(backup *BackupInfo) {
+				a.EventBus.Emit(EventChatBackup, backup)
+			},
+			func(result *RetentionResult) {
+				a.EventBus.Emit(EventChatRetention, result)
+			},
+		)
+	}
+
 	// Publish startup event
 	a.EventBus.Publish("app.startup", nil)
 }
@@ -110,6 +188,90 @@ func (a *App) GetChatMessages(chatID int64) ([]Message, error) {
 	return a.ChatDB.GetChatMessages(chatID)
 }
 
+// GetChatsPage returns one page of chat sessions, newest first.
+// Pass the previous page's NextCursor to continue; a zero cursor starts at the top.
+func (a *App) GetChatsPage(cursor ChatCursor, limit int) (*ChatPage, error) {
+	if a.ChatDB == nil {
+		return &ChatPage{Chats: []Chat{}}, nil
+	}
+	return a.ChatDB.GetChatsPage(cursor, limit)
+}
+
+// GetChatMessagesPage returns up to limit messages older than beforeID.
+// Pass 0 to open a chat at its newest messages.
+func (a *App) GetChatMessagesPage(chatID int64, beforeID int64, limit int) (*MessagePage, error) {
+	if a.ChatDB == nil {
+		return &MessagePage{Messages: []Message{}}, nil
+	}
+	return a.ChatDB.GetChatMessagesPage(chatID, beforeID, limit)
+}
+
+// GetChatCount returns the total number of chat sessions
+func (a *App) GetChatCount() (int, error) {
+	if a.ChatDB == nil {
+		return 0, nil
+	}
+	return a.ChatDB.GetChatCount()
+}
+
+// GetMessageCount returns the number of messages in a chat
+func (a *App) GetMessageCount(chatID int64) (int, error) {
+	if a.ChatDB == nil {
+		return 0, nil
+	}
+	return a.ChatDB.GetMessageCount(chatID)
+}
+
+// AttachSelection links a message to the selected lines of a file
+func (a *App) AttachSelection(messageID int64, filePath string, startLine, endLine int, excerpt string) (*Attachment, error) {
+	if a.ChatDB == nil {
+		return nil, fmt.Errorf("chat database not initialized")
+	}
+	if a.FileManager.isEncrypted(filePath) {
+		return nil, fmt.Errorf("%s is an %w and can't be attached to a chat", filepath.Base(filePath), ErrEncryptedNote)
+	}
+	return a.ChatDB.AddAttachment(messageID, filePath, startLine, endLine, excerpt)
+}
+
+// AttachFile links a message to the whole contents of a file
+func (a *App) AttachFile(messageID int64, filePath string) (*Attachment, error) {
+	if a.ChatDB == nil {
+		return nil, fmt.Errorf("chat database not initialized")
+	}
+	if a.FileManager.isEncrypted(filePath) {
+		return nil, fmt.Errorf("%s is an %w and can't be attached to a chat", filepath.Base(filePath), ErrEncryptedNote)
+	}
+	content, lines, err := readFileExcerpt(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return a.ChatDB.AddAttachment(messageID, filePath, 1, lines, content)
+}
+
+// GetMessageAttachments returns the file excerpts attached to a message
+func (a *App) GetMessageAttachments(messageID int64) ([]Attachment, error) {
+	if a.ChatDB == nil {
+		return []Attachment{}, nil
+	}
+	return a.ChatDB.GetMessageAttachments(messageID)
+}
+
+// GetChatAttachments returns the file excerpts attached to a chat's messages
+func (a *App) GetChatAttachments(chatID int64) ([]Attachment, error) {
+	if a.ChatDB == nil {
+		return []Attachment{}, nil
+	}
+	return a.ChatDB.GetChatAttachments(chatID)
+}
+
+// LocateAttachment finds an attachment's excerpt in its file for jump to source
+func (a *App) LocateAttachment(attachmentID int64) (*AttachmentLocation, error) {
+	if a.ChatDB == nil {
+		return nil, fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.LocateAttachment(attachmentID)
+}
+
 // DeleteChat deletes a chat and all its messages
 func (a *App) DeleteChat(chatID int64) error {
 	if a.ChatDB == nil {
@@ -134,12 +296,23 @@ func (a *App) UpdateChatTitle(chatID int64, title string) error {
 	return a.ChatDB.UpdateChatTitle(chatID, title)
 }
 
-// AddMessage adds a message to a chat
+// AddMessage adds a message to a chat. An assistant reply to a chat that
+// still has its placeholder title starts background title generation.
 func (a *App) AddMessage(chatID int64, role, content string) (*Message, error) {
 	if a.ChatDB == nil {
 		return nil, fmt.Errorf("chat database not initialized")
 	}
-	return a.ChatDB.AddMessage(chatID, role, content)
+	msg, err := a.ChatDB.AddMessage(chatID, role, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == "assistant" {
+		if untitled, err := a.ChatDB.needsTitle(chatID); err == nil && untitled {
+			go a.generateChatTitle(chatID)
+		}
+	}
+	return msg, nil
 }
 
 // GetChatContext builds context from recent messages for AI memory
@@ -174,6 +347,159 @@ func (a *App) ExportChat(chatID int64) (string, error) {
 	return a.ChatDB.ExportChat(chatID)
 }
 
+// runChatMaintenance runs the startup integrity pass and reports the result
+func (a *App) runChatMaintenance() {
+	report, err := a.ChatDB.RunMaintenance()
+	if err != nil {
+		fmt.Printf("Chat database maintenance failed: %v\n", err)
+	}
+	if report.OrphansRemoved > 0 {
+		fmt.Printf("Removed %d orphaned chat messages\n", report.OrphansRemoved)
+	}
+	a.EventBus.Emit(EventChatIntegrity, report)
+}
+
+// applyChatSettings pushes security and history settings to the chat database
+func (a *App) applyChatSettings(settings *Settings) {
+	a.ChatDB.SetAutoLockTimeout(time.Duration(settings.Security.AutoLockMinutes) * time.Minute)
+	a.ChatDB.SetBackupPolicy(time.Duration(settings.History.BackupIntervalHours)*time.Hour, settings.History.MaxBackups)
+	a.ChatDB.SetRetentionPolicy(retentionPolicyFromSettings(settings.History))
+}
+
+// retentionPolicyFromSettings converts history settings to a retention policy
+func retentionPolicyFromSettings(history HistorySettings) RetentionPolicy {
+	return RetentionPolicy{
+		MaxAgeDays: history.RetentionDays,
+		MaxChats:   history.RetentionMaxChats,
+		Action:     history.RetentionAction,
+	}
+}
+
+// ListChatBackups returns the available chat history backups, newest first
+func (a *App) ListChatBackups() ([]BackupInfo, error) {
+	if a.ChatDB == nil {
+		return []BackupInfo{}, nil
+	}
+	return a.ChatDB.ListBackups()
+}
+
+// CreateChatBackup takes a manual backup of the chat history
+func (a *App) CreateChatBackup() (*BackupInfo, error) {
+	if a.ChatDB == nil {
+		return nil, fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.CreateBackup(BackupManual)
+}
+
+// RestoreChatBackup replaces the chat history with the named backup.
+// Encrypted history is locked afterwards and must be unlocked again.
+func (a *App) RestoreChatBackup(name string) error {
+	if a.ChatDB == nil {
+		return fmt.Errorf("chat database not initialized")
+	}
+	if err := a.ChatDB.RestoreBackup(name); err != nil {
+		return err
+	}
+	if a.ChatDB.EncryptionStatus().Locked {
+		a.EventBus.Emit(EventChatLocked, nil)
+	}
+	return nil
+}
+
+// PreviewChatRetention reports which chats the retention settings would affect
+func (a *App) PreviewChatRetention() (*RetentionResult, error) {
+	if a.ChatDB == nil {
+		return nil, fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.ApplyRetention(retentionPolicyFromSettings(a.SettingsManager.Get().History), true)
+}
+
+// ApplyChatRetention archives or deletes chats according to the retention settings
+func (a *App) ApplyChatRetention() (*RetentionResult, error) {
+	if a.ChatDB == nil {
+		return nil, fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.ApplyRetention(retentionPolicyFromSettings(a.SettingsManager.Get().History), false)
+}
+
+// GetArchivedChats returns chats archived by retention rules
+func (a *App) GetArchivedChats() ([]Chat, error) {
+	if a.ChatDB == nil {
+		return []Chat{}, nil
+	}
+	return a.ChatDB.GetArchivedChats()
+}
+
+// UnarchiveChat returns an archived chat to the chat list
+func (a *App) UnarchiveChat(chatID int64) error {
+	if a.ChatDB == nil {
+		return fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.UnarchiveChat(chatID)
+}
+
+// GetChatIntegrityReport returns the result of the startup integrity check,
+// or nil while it is still running
+func (a *App) GetChatIntegrityReport() *IntegrityReport {
+	if a.ChatDB == nil {
+		return nil
+	}
+	return a.ChatDB.LastIntegrityReport()
+}
+
+// GetChatEncryptionStatus reports whether chat history is encrypted and locked
+func (a *App) GetChatEncryptionStatus() ChatEncryptionStatus {
+	if a.ChatDB == nil {
+		return ChatEncryptionStatus{}
+	}
+	return a.ChatDB.EncryptionStatus()
+}
+
+// EnableChatEncryption encrypts chat titles and messages with a passphrase
+func (a *App) EnableChatEncryption(passphrase string) error {
+	if a.ChatDB == nil {
+		return fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.EnableEncryption(passphrase)
+}
+
+// DisableChatEncryption decrypts chat history back to plaintext
+func (a *App) DisableChatEncryption(passphrase string) error {
+	if a.ChatDB == nil {
+		return fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.DisableEncryption(passphrase)
+}
+
+// UnlockChats unlocks encrypted chat history with the passphrase
+func (a *App) UnlockChats(passphrase string) error {
+	if a.ChatDB == nil {
+		return fmt.Errorf("chat database not initialized")
+	}
+	if err := a.ChatDB.Unlock(passphrase); err != nil {
+		return err
+	}
+	a.EventBus.Emit(EventChatUnlocked, nil)
+	return nil
+}
+
+// LockChats locks encrypted chat history until it is unlocked again
+func (a *App) LockChats() {
+	if a.ChatDB == nil {
+		return
+	}
+	a.ChatDB.Lock()
+	a.EventBus.Emit(EventChatLocked, nil)
+}
+
+// ChangeChatPassphrase re-encrypts chat history under a new passphrase
+func (a *App) ChangeChatPassphrase(oldPassphrase, newPassphrase string) error {
+	if a.ChatDB == nil {
+		return fmt.Errorf("chat database not initialized")
+	}
+	return a.ChatDB.ChangePassphrase(oldPassphrase, newPassphrase)
+}
+
 // GetSettings returns the current application settings
 func (a *App) GetSettings() *Settings {
 	return a.SettingsManager.Get()
@@ -181,7 +507,14 @@ func (a *App) GetSettings() *Settings {
 
 // UpdateSettings updates the application settings
 func (a *App) UpdateSettings(settings *Settings) error {
-	return a.SettingsManager.Update(settings)
+	if err := a.SettingsManager.Update(settings); err != nil {
+		return err
+	}
+
+	if a.ChatDB != nil {
+		a.applyChatSettings(settings)
+	}
+	return nil
 }
 
 // NewFile creates a new empty file
@@ -207,6 +540,51 @@ func (a *App) OpenFile() (*FileOpenResult, error) {
 		return nil, nil // User cancelled
 	}
 
+	return a.OpenFileByPath(filePath)
+}
+
+// OpenFileByPath opens a specific file path. Binary files open read-only
+// in the hex view and files over the large-file threshold read-only in
+// large-file mode, both with no content; their bytes are fetched with
+// ReadHex and their lines with ReadLines. Archives and gzip files are
+// read whole, as ReadFile explains.
+func (a *App) OpenFileByPath(filePath string) (*FileOpenResult, error) {
+	if _, _, ok := splitArchivePath(filePath); ok {
+		return a.OpenFileForEditing(filePath)
+	}
+	if kind, err := sniffArchive(filePath); err == nil && kind != "" {
+		return a.OpenFileForEditing(filePath)
+	}
+	if sniffEncryptedNote(filePath) {
+		return a.OpenFileForEditing(filePath)
+	}
+
+	if detection, err := sniffBinary(filePath); err == nil && detection.Binary {
+		fileInfo, err := a.FileManager.OpenBinaryFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		a.EventBus.Publish(EventFileOpen, FileEventData{FileInfo: fileInfo})
+		return &FileOpenResult{FileInfo: fileInfo}, nil
+	}
+
+	if a.FileManager.isLargeFile(filePath) {
+		fileInfo, err := a.FileManager.OpenLargeFile(filePath)
+		if err == nil {
+			a.EventBus.Publish(EventFileOpen, FileEventData{FileInfo: fileInfo})
+			return &FileOpenResult{FileInfo: fileInfo}, nil
+		}
+		fmt.Printf("Opening %s whole: %v\n", filePath, err)
+	}
+
+	return a.OpenFileForEditing(filePath)
+}
+
+// OpenFileForEditing reads a whole file into the editor, even one over the
+// large-file threshold
+func (a *App) OpenFileForEditing(filePath string) (*FileOpenResult, error) {
+	a.FileManager.closeLargeFile(filePath)
+
 	fileInfo, content, err := a.FileManager.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -216,9 +594,10 @@ func (a *App) OpenFile() (*FileOpenResult, error) {
 	return &FileOpenResult{FileInfo: fileInfo, Content: content}, nil
 }
 
-// OpenFileByPath opens a specific file path
-func (a *App) OpenFileByPath(filePath string) (*FileOpenResult, error) {
-	fileInfo, content, err := a.FileManager.ReadFile(filePath)
+// ReopenWithEncoding reads a file again using the named encoding instead of
+// the detected one
+func (a *App) ReopenWithEncoding(filePath string, encoding string) (*FileOpenResult, error) {
+	fileInfo, content, err := a.FileManager.ReadFileWithEncoding(filePath, encoding)
 	if err != nil {
 		return nil, err
 	}
@@ -227,9 +606,170 @@ func (a *App) OpenFileByPath(filePath string) (*FileOpenResult, error) {
 	return &FileOpenResult{FileInfo: fileInfo, Content: content}, nil
 }
 
+// ConvertToEncoding checks that content can be saved in the named encoding.
+// The conversion itself happens on the next save.
+func (a *App) ConvertToEncoding(content string, encoding string) (*EncodingCheck, error) {
+	return CheckEncoding(content, encoding)
+}
+
+// GetSupportedEncodings returns the encodings files can be read and saved in
+func (a *App) GetSupportedEncodings() []string {
+	return SupportedEncodings()
+}
+
+// TakeLaunchRequests returns the files and folders launches have asked to
+// open since it was last called, and forgets them
+func (a *App) TakeLaunchRequests() []LaunchRequest {
+	a.launchMu.Lock()
+	defer a.launchMu.Unlock()
+
+	launches := a.launches
+	a.launches = nil
+	if launches == nil {
+		return []LaunchRequest{}
+	}
+	return launches
+}
+
+// FinishWait tells a launch made with --wait that its files have been closed
+func (a *App) FinishWait(waitID string) {
+	a.finishWait(waitID)
+}
+
+// DiffFiles compares two files as a unified diff
+func (a *App) DiffFiles(pathA string, pathB string) (string, error) {
+	var contents [2]string
+	for i, path := range []string{pathA, pathB} {
+		data, err := a.FileManager.readPlain(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+		contents[i], _, _, err = a.FileManager.readWithDetection(data, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+	}
+	return unifiedDiff(contents[0], contents[1], pathA, pathB), nil
+}
+
+// AnswerPassphrase answers a "file.passphrase" request for the passphrase
+// of an encrypted note, or cancels it
+func (a *App) AnswerPassphrase(requestID string, passphrase string, cancelled bool) {
+	a.FileManager.AnswerPassphrase(requestID, passphrase, cancelled)
+}
+
+// CheckAIAccess refuses to let an encrypted note's text go to an AI
+// endpoint that isn't on this machine
+func (a *App) CheckAIAccess(filePath string) error {
+	if a.FileManager.isEncrypted(filePath) && !a.aiEndpointIsLocal() {
+		return fmt.Errorf("%s is an %w; its text is only sent to a local AI endpoint", filepath.Base(filePath), ErrEncryptedNote)
+	}
+	return nil
+}
+
+// checkAIPrompt refuses to send any prompt to a remote AI endpoint while
+// an encrypted note is open, since the prompt may hold its text
+func (a *App) checkAIPrompt() error {
+	if a.aiEndpointIsLocal() {
+		return nil
+	}
+	if notePath := a.FileManager.openEncryptedNote(); notePath != "" {
+		return fmt.Errorf("%s is an open %w; close it to use a remote AI endpoint", filepath.Base(notePath), ErrEncryptedNote)
+	}
+	return nil
+}
+
+// GitFileChanges returns the line ranges of a file that differ from
+// HEAD, for gutter markers
+func (a *App) GitFileChanges(filePath string) (*GitFileChanges, error) {
+	return a.Git.FileChanges(filePath)
+}
+
+// GitDiff returns the full diff of a file against HEAD
+func (a *App) GitDiff(filePath string) (string, error) {
+	return a.Git.Diff(filePath)
+}
+
+// GitBlame returns the commit that last changed each line of a file
+func (a *App) GitBlame(filePath string) ([]GitBlameLine, error) {
+	return a.Git.Blame(filePath)
+}
+
+// GitStatus returns the branch and changed files of the repository a
+// file or folder is in
+func (a *App) GitStatus(path string) (*GitRepoStatus, error) {
+	return a.Git.Status(path)
+}
+
+// GitStage stages a file's changes
+func (a *App) GitStage(filePath string) error {
+	if err := a.Git.Stage(filePath); err != nil {
+		return err
+	}
+	go a.Git.refresh(filePath)
+	return nil
+}
+
+// GitUnstage unstages a file's changes
+func (a *App) GitUnstage(filePath string) error {
+	if err := a.Git.Unstage(filePath); err != nil {
+		return err
+	}
+	go a.Git.refresh(filePath)
+	return nil
+}
+
+// GitCommit commits what is staged in the repository a file or folder is
+// in and returns the new commit's abbreviated hash
+func (a *App) GitCommit(path string, message string) (string, error) {
+	commit, err := a.Git.Commit(path, message)
+	if err != nil {
+		return "", err
+	}
+	go a.Git.refresh(path)
+	return commit, nil
+}
+
+// GitDiscardHunk reverts one changed hunk of a saved file to HEAD
+func (a *App) GitDiscardHunk(filePath string, hunk GitHunk) error {
+	if err := a.Git.DiscardHunk(filePath, hunk); err != nil {
+		return err
+	}
+	go a.Git.refresh(filePath)
+	return nil
+}
+
+// ListArchive returns the files inside a zip or tar archive. Each opens
+// read-only by its path inside the archive, such as "bundle.zip!/app.yaml".
+func (a *App) ListArchive(archivePath string) (*ArchiveListing, error) {
+	return a.FileManager.ListArchive(archivePath)
+}
+
+// ReadHex returns a hex dump of length bytes of a binary file from offset
+func (a *App) ReadHex(filePath string, offset int64, length int) (*HexPage, error) {
+	return a.FileManager.ReadHex(filePath, offset, length)
+}
+
+// FindBytes searches a file for bytes, given as hex pairs or as text, and
+// returns the offset of the first match at or after from, or -1
+func (a *App) FindBytes(filePath string, query string, isHex bool, from int64) (int64, error) {
+	return a.FileManager.FindBytes(filePath, query, isHex, from)
+}
+
+// GetLanguages returns the language registry, with each language's comment
+// syntax and default indentation
+func (a *App) GetLanguages() []Language {
+	return languages
+}
+
+// DetectLanguage works out the language ID of a file from its name and content
+func (a *App) DetectLanguage(filePath string, content string) string {
+	return detectLanguage(filePath, content)
+}
+
 // SaveFile saves content to an existing file path
-func (a *App) SaveFile(filePath string, content string, lineEnding string) (*FileInfo, error) {
-	fileInfo, err := a.FileManager.WriteFile(filePath, content, lineEnding)
+func (a *App) SaveFile(filePath string, content string, lineEnding string, encoding string) (*FileInfo, error) {
+	fileInfo, err := a.FileManager.WriteFile(filePath, content, lineEnding, encoding)
 	if err != nil {
 		return nil, err
 	}
@@ -239,8 +779,8 @@ func (a *App) SaveFile(filePath string, content string, lineEnding string) (*Fil
 }
 
 // SaveFileAs shows save dialog and writes file
-func (a *App) SaveFileAs(defaultName string, content string, lineEnding string) (*FileInfo, error) {
-	filePath, err := a.FileManager.SaveFileDialog(defaultName)
+func (a *App) SaveFileAs(defaultName string, content string, lineEnding string, encoding string) (*FileInfo, error) {
+	filePath, err := a.FileManager.SaveFileDialog(defaultName, detectLanguage(defaultName, content))
 	if err != nil {
 		return nil, err
 	}
@@ -248,7 +788,8 @@ func (a *App) SaveFileAs(defaultName string, content string, lineEnding string)
 		return nil, nil // User cancelled
 	}
 
-	fileInfo, err := a.FileManager.WriteFile(filePath, content, lineEnding)
+	// The save dialog already confirmed replacing an existing file
+	fileInfo, err := a.FileManager.OverwriteFile(filePath, content, lineEnding, encoding)
 	if err != nil {
 		return nil, err
 	}
@@ -257,6 +798,52 @@ func (a *App) SaveFileAs(defaultName string, content string, lineEnding string)
 	return fileInfo, nil
 }
 
+// OverwriteFile saves content to a file that changed on disk since it was
+// opened, discarding the other program's changes
+func (a *App) OverwriteFile(filePath string, content string, lineEnding string, encoding string) (*FileInfo, error) {
+	fileInfo, err := a.FileManager.OverwriteFile(filePath, content, lineEnding, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	a.EventBus.Publish(EventFileSave, FileEventData{FileInfo: fileInfo})
+	return fileInfo, nil
+}
+
+// MergeExternalChanges merges the version of a file now on disk into the
+// editor's unsaved content. Conflicting regions are wrapped in markers.
+func (a *App) MergeExternalChanges(filePath string, content string) (*MergeResult, error) {
+	return a.FileManager.MergeExternalChanges(filePath, content)
+}
+
+// NormalizeLineEndings converts every line break in content to one style:
+// "CRLF", "LF" or "CR"
+func (a *App) NormalizeLineEndings(content string, lineEnding string) (string, error) {
+	return normalizeLineEndings(content, lineEnding)
+}
+
+// ReadLines returns up to count lines, starting at the 0-based line start,
+// of a file open in large-file mode
+func (a *App) ReadLines(filePath string, start int, count int) (*LineRange, error) {
+	return a.FileManager.ReadLines(filePath, start, count)
+}
+
+// SearchLargeFile starts searching a file open in large-file mode. Matches
+// arrive in "file.searchResults" events tagged with requestID.
+func (a *App) SearchLargeFile(requestID string, filePath string, query string, caseSensitive bool, useRegex bool) error {
+	return a.FileManager.SearchLargeFile(requestID, filePath, query, caseSensitive, useRegex)
+}
+
+// CancelLargeFileSearch stops a running large-file search
+func (a *App) CancelLargeFileSearch(requestID string) {
+	a.FileManager.CancelLargeFileSearch(requestID)
+}
+
+// CloseFile releases what is remembered about a file once its last tab closes
+func (a *App) CloseFile(filePath string) {
+	a.FileManager.ForgetFile(filePath)
+}
+
 // GetRecentFiles returns the list of recent files
 func (a *App) GetRecentFiles() []string {
 	return a.FileManager.GetRecentFiles()
@@ -275,6 +862,220 @@ func (a *App) OnFileChange(filePath string, isDirty bool) {
 	})
 }
 
+// UpdateRecoveryBuffer records the content of an unsaved buffer for crash
+// recovery, and for AutoSave when it is on. revision increases with every
+// edit; "file.autoSave" events report the revision that was saved.
+func (a *App) UpdateRecoveryBuffer(info RecoveryInfo, content string, revision int) error {
+	return a.Recovery.UpdateBuffer(info, content, revision)
+}
+
+// DiscardRecoveryBuffer deletes a buffer's recovery snapshot once it is
+// saved, closed or recovered
+func (a *App) DiscardRecoveryBuffer(bufferID string) error {
+	return a.Recovery.DiscardBuffer(bufferID)
+}
+
+// GetRecoverableBuffers lists unsaved buffers left by a previous run that
+// ended without saving them
+func (a *App) GetRecoverableBuffers() ([]RecoverableBuffer, error) {
+	return a.Recovery.Recoverable()
+}
+
+// GetRecoveryBuffers returns the unsaved content kept for the given
+// buffers, for restoring a session's tabs
+func (a *App) GetRecoveryBuffers(bufferIDs []string) ([]RecoverableBuffer, error) {
+	return a.Recovery.Buffers(bufferIDs)
+}
+
+// GetCurrentSession returns the active session's tabs, for restoring them
+func (a *App) GetCurrentSession() *Session {
+	return a.Sessions.Current()
+}
+
+// UpdateSessionTabs records the open tabs, in order, and the active tab's buffer ID
+func (a *App) UpdateSessionTabs(tabs []SessionTab, activeTab string) {
+	a.Sessions.UpdateTabs(tabs, activeTab)
+}
+
+// SetSessionChat records the chat open in the active session
+func (a *App) SetSessionChat(chatID int64) {
+	a.Sessions.SetChat(chatID)
+}
+
+// GetSessions lists the saved sessions
+func (a *App) GetSessions() []SessionSummary {
+	return a.Sessions.List()
+}
+
+// SaveSessionAs copies the active session under a new name and switches to it
+func (a *App) SaveSessionAs(name string) error {
+	return a.Sessions.SaveAs(name)
+}
+
+// SwitchSession makes another session active and returns its tabs. A new
+// name starts an empty session.
+func (a *App) SwitchSession(name string) (*Session, error) {
+	return a.Sessions.Switch(name)
+}
+
+// DeleteSession removes a saved session other than the active one
+func (a *App) DeleteSession(name string) error {
+	return a.Sessions.Delete(name)
+}
+
+// OpenFolder shows a folder picker and opens the chosen folder as the workspace
+func (a *App) OpenFolder() (*WorkspaceInfo, error) {
+	folder, err := a.FileManager.OpenFolderDialog()
+	if err != nil {
+		return nil, err
+	}
+	if folder == "" {
+		return nil, nil // User cancelled
+	}
+	return a.OpenFolderPath(folder)
+}
+
+// OpenFolderPath opens a folder as the workspace. "workspace.index"
+// reports when its file index is ready for FindFiles.
+func (a *App) OpenFolderPath(folder string) (*WorkspaceInfo, error) {
+	info, err := a.FileManager.OpenFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+	a.Sessions.SetFolder(info.Root)
+	return info, nil
+}
+
+// CloseFolder closes the workspace
+func (a *App) CloseFolder() {
+	a.FileManager.CloseFolder()
+	a.Sessions.SetFolder("")
+}
+
+// GetWorkspace describes the open workspace, or returns nil if there is none
+func (a *App) GetWorkspace() *WorkspaceInfo {
+	ws, err := a.FileManager.Workspace()
+	if err != nil {
+		return nil
+	}
+	info := ws.Info()
+	return &info
+}
+
+// RefreshWorkspace reindexes the workspace
+func (a *App) RefreshWorkspace() error {
+	return a.FileManager.RefreshWorkspace()
+}
+
+// ListWorkspaceDir lists a folder in the workspace for the file tree,
+// leaving out ignored entries
+func (a *App) ListWorkspaceDir(dir string) ([]WorkspaceEntry, error) {
+	ws, err := a.FileManager.Workspace()
+	if err != nil {
+		return nil, err
+	}
+	return ws.List(dir)
+}
+
+// FindFiles fuzzy-matches query against the workspace's file paths and
+// returns the best matches first
+func (a *App) FindFiles(query string, limit int) ([]FileMatch, error) {
+	ws, err := a.FileManager.Workspace()
+	if err != nil {
+		return nil, err
+	}
+	return ws.FindFiles(query, limit), nil
+}
+
+// CreateWorkspaceFile creates an empty file in a workspace folder and returns its path
+func (a *App) CreateWorkspaceFile(dir string, name string) (string, error) {
+	return a.FileManager.CreateWorkspaceEntry(dir, name, false)
+}
+
+// CreateWorkspaceFolder creates a folder in a workspace folder and returns its path
+func (a *App) CreateWorkspaceFolder(dir string, name string) (string, error) {
+	return a.FileManager.CreateWorkspaceEntry(dir, name, true)
+}
+
+// RenameWorkspaceEntry renames a file or folder in place and returns its new path
+func (a *App) RenameWorkspaceEntry(path string, newName string) (string, error) {
+	newName, err := validEntryName(newName)
+	if err != nil {
+		return "", err
+	}
+	newPath := filepath.Join(filepath.Dir(path), newName)
+	if err := a.FileManager.MoveWorkspaceEntry(path, newPath); err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
+
+// MoveWorkspaceEntry moves a file or folder into another workspace folder and returns its new path
+func (a *App) MoveWorkspaceEntry(path string, destDir string) (string, error) {
+	newPath := filepath.Join(destDir, filepath.Base(path))
+	if err := a.FileManager.MoveWorkspaceEntry(path, newPath); err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
+
+// DeleteWorkspaceEntry deletes a file, or a folder and its contents
+func (a *App) DeleteWorkspaceEntry(path string) error {
+	return a.FileManager.DeleteWorkspaceEntry(path)
+}
+
+// SearchWorkspace searches the files of the open folder. Results arrive
+// in batches as workspace.searchResults events tagged with requestID.
+func (a *App) SearchWorkspace(requestID string, options ProjectSearchOptions) error {
+	return a.Search.Search(requestID, options)
+}
+
+// CancelWorkspaceSearch stops a running workspace search
+func (a *App) CancelWorkspaceSearch(requestID string) {
+	a.Search.CancelSearch(requestID)
+}
+
+// PrepareReplace previews replacing every match of a workspace search
+func (a *App) PrepareReplace(options ProjectSearchOptions, replacement string) (*ReplacePlan, error) {
+	return a.Search.PrepareReplace(options, replacement)
+}
+
+// ApplyReplace writes a previewed replace to the chosen files, or all of them if paths is empty
+func (a *App) ApplyReplace(planID string, paths []string) (*ReplaceResult, error) {
+	return a.Search.ApplyReplace(planID, paths)
+}
+
+// DiscardReplace drops a previewed replace that won't be applied
+func (a *App) DiscardReplace(planID string) {
+	a.Search.DiscardReplace(planID)
+}
+
+// UndoReplace puts back every file changed by the last workspace replace
+func (a *App) UndoReplace() (*ReplaceResult, error) {
+	return a.Search.UndoReplace()
+}
+
+// CanUndoReplace reports whether there is a workspace replace to undo
+func (a *App) CanUndoReplace() bool {
+	return a.Search.CanUndoReplace()
+}
+
+// GetFileHistory lists the saved versions of a file kept in the local history, newest first
+func (a *App) GetFileHistory(filePath string) ([]FileRevision, error) {
+	return a.History.Revisions(filePath)
+}
+
+// DiffFileRevisions returns a unified diff between two saved versions of a
+// file. An empty toID compares with the file on disk.
+func (a *App) DiffFileRevisions(filePath string, fromID string, toID string) (string, error) {
+	return a.History.Diff(filePath, fromID, toID)
+}
+
+// RestoreFileRevision returns a saved version of a file to open in a new tab
+func (a *App) RestoreFileRevision(filePath string, revisionID string) (*FileOpenResult, error) {
+	return a.History.Restore(filePath, revisionID)
+}
+
 // OnEditorEvent publishes editor events (selection change, cursor move, etc.)
 func (a *App) OnEditorEvent(eventType string, data EditorEventData) {
 	a.EventBus.Publish(eventType, data)
@@ -327,10 +1128,26 @@ func (a *App) CheckOllamaInstalled() OllamaStatus {
 	}
 }
 
+// ollamaURL returns the address of an Ollama API path on the configured
+// AI endpoint
+func (a *App) ollamaURL(path string) string {
+	endpoint := defaultAIEndpoint
+	if a.SettingsManager != nil {
+		if configured := a.SettingsManager.Get().AI.Endpoint; configured != "" {
+			endpoint = configured
+		}
+	}
+	return strings.TrimRight(endpoint, "/") + path
+}
+
 // CheckOllamaServerRunning checks if the Ollama server is running via HTTP API
 func (a *App) CheckOllamaServerRunning() bool {
-	_, err := http.Get("http://localhost:11434/api/tags")
-	return err == nil
+	resp, err := http.Get(a.ollamaURL("/api/tags"))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
 }
 
 // GetInstalledModels returns list of installed Ollama models
@@ -350,7 +1167,7 @@ func (a *App) GetInstalledModels() []OllamaModel {
 
 // getModelsFromAPI fetches models from Ollama HTTP API
 func (a *App) getModelsFromAPI() ([]OllamaModel, error) {
-	resp, err := http.Get("http://localhost:11434/api/tags")
+	resp, err := http.Get(a.ollamaURL("/api/tags"))
 	if err != nil {
 		return nil, err
 	}
@@ -492,7 +1309,7 @@ func (a *App) StartOllamaServer() error {
 	checkInterval := 500 * time.Millisecond
 
 	for time.Since(startTime) < maxWait {
-		resp, err := client.Get("http://localhost:11434/api/tags")
+		resp, err := client.Get(a.ollamaURL("/api/tags"))
 		if err == nil {
 			resp.Body.Close()
 			return nil // Server is ready
@@ -547,14 +1364,35 @@ func (a *App) StopOllamaServer() error {
 // Shutdown performs cleanup when the app is closing
 func (a *App) Shutdown(ctx context.Context) {
 	// Stop any active AI generation requests
+	a.requestsMu.Lock()
 	for requestID, cancel := range a.activeRequests {
 		cancel()
 		delete(a.activeRequests, requestID)
 	}
+	a.requestsMu.Unlock()
 
 	// Stop Ollama server if we started it
 	a.StopOllamaServer()
 
+	// Write the last snapshots of unsaved buffers and the session
+	if a.Recovery != nil {
+		a.Recovery.Stop()
+	}
+	if a.Sessions != nil {
+		if err := a.Sessions.Flush(); err != nil {
+			fmt.Printf("Failed to save session: %v\n", err)
+		}
+	}
+
+	// Stop watching open files
+	if a.FileManager != nil {
+		a.FileManager.Shutdown()
+	}
+
+	// Let later launches start their own instance, and stop any --wait
+	// launches waiting on this one
+	a.stopInstances()
+
 	// Close chat database
 	if a.ChatDB != nil {
 		a.ChatDB.Close()
@@ -582,9 +1420,12 @@ type OllamaGenerateResponse struct {
 
 // GenerateWithOllama sends a prompt to Ollama and returns the response (non-streaming)
 func (a *App) GenerateWithOllama(model string, prompt string) (string, error) {
+	if err := a.checkAIPrompt(); err != nil {
+		return "", err
+	}
+
 	// First check if server is running
-	_, err := http.Get("http://localhost:11434/api/tags")
-	if err != nil {
+	if !a.CheckOllamaServerRunning() {
 		return "", fmt.Errorf("Ollama server is not running. Please start it first.")
 	}
 
@@ -601,7 +1442,7 @@ func (a *App) GenerateWithOllama(model string, prompt string) (string, error) {
 	}
 
 	// Make request to Ollama API
-	resp, err := http.Post("http://localhost:11434/api/generate",
+	resp, err := http.Post(a.ollamaURL("/api/generate"),
 		"application/json",
 		bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -630,11 +1471,16 @@ func (a *App) GenerateWithOllama(model string, prompt string) (string, error) {
 }
 
 // GenerateWithOllamaStream sends a prompt to Ollama and streams the response via events
-// The frontend listens for "ai.stream.chunk" and "ai.stream.done" events
-func (a *App) GenerateWithOllamaStream(requestID string, model string, prompt string, promptContext string) error {
+// The frontend listens for "ai.stream.chunk", "ai.stream.done" and "ai.stream.error" events.
+// When chatID is non-zero the reply is saved to that chat as it arrives, so a
+// crash or dropped connection keeps the partial answer.
+func (a *App) GenerateWithOllamaStream(requestID string, chatID int64, model string, prompt string, promptContext string) error {
+	if err := a.checkAIPrompt(); err != nil {
+		return err
+	}
+
 	// First check if server is running
-	_, err := http.Get("http://localhost:11434/api/tags")
-	if err != nil {
+	if !a.CheckOllamaServerRunning() {
 		return fmt.Errorf("Ollama server is not running. Please start it first.")
 	}
 
@@ -656,9 +1502,21 @@ func (a *App) GenerateWithOllamaStream(requestID string, model string, prompt st
 		return fmt.Errorf("failed to marshal request: %v", err)
 	}
 
+	// Save the reply row up front so it survives a crash mid-stream
+	var messageID int64
+	if chatID != 0 && a.ChatDB != nil {
+		msg, err := a.ChatDB.BeginStreamingMessage(chatID)
+		if err != nil {
+			return err
+		}
+		messageID = msg.ID
+	}
+
 	// Create cancellable context
 	ctx, cancel := context.WithCancel(context.Background())
+	a.requestsMu.Lock()
 	a.activeRequests[requestID] = cancel
+	a.requestsMu.Unlock()
 
 	// Create HTTP client with timeout
 	client := &http.Client{
@@ -666,30 +1524,62 @@ func (a *App) GenerateWithOllamaStream(requestID string, model string, prompt st
 	}
 
 	// Create request with cancellable context
-	req, err := http.NewRequestWithContext(ctx, "POST", "http://localhost:11434/api/generate", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", a.ollamaURL("/api/generate"), bytes.NewBuffer(jsonData))
 	if err != nil {
-		delete(a.activeRequests, requestID)
+		a.StopGeneration(requestID)
+		if messageID != 0 {
+			a.ChatDB.FinishStreamingMessage(messageID, "", MessageStatusError, err.Error())
+		}
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	// Execute request in goroutine
 	go func() {
-		defer delete(a.activeRequests, requestID)
+		defer a.StopGeneration(requestID)
+
+		var content strings.Builder
+		lastFlush := time.Now()
+
+		// finish saves the reply with its final status and notifies the frontend
+		finish := func(status, errText string) {
+			var saveErr error
+			if messageID != 0 {
+				if saveErr = a.ChatDB.FinishStreamingMessage(messageID, content.String(), status, errText); saveErr != nil {
+					fmt.Printf("Failed to save streamed reply: %v\n", saveErr)
+				} else if status == MessageStatusComplete {
+					if untitled, err := a.ChatDB.needsTitle(chatID); err == nil && untitled {
+						go a.generateChatTitle(chatID)
+					}
+				}
+			}
+
+			data := map[string]string{"requestID": requestID}
+			if messageID != 0 {
+				data["messageID"] = strconv.FormatInt(messageID, 10)
+			}
+			if saveErr != nil {
+				data["saveError"] = saveErr.Error()
+			}
+			switch status {
+			case MessageStatusCancelled:
+				data["reason"] = "cancelled"
+				a.EventBus.Emit(EventAIStreamDone, data)
+			case MessageStatusError:
+				data["error"] = errText
+				a.EventBus.Emit(EventAIStreamError, data)
+			default:
+				a.EventBus.Emit(EventAIStreamDone, data)
+			}
+		}
 
 		resp, err := client.Do(req)
 		if err != nil {
 			if ctx.Err() == context.Canceled {
-				a.EventBus.Publish("ai.stream.done", map[string]string{
-					"requestID": requestID,
-					"reason":    "cancelled",
-				})
+				finish(MessageStatusCancelled, "")
 				return
 			}
-			a.EventBus.Publish("ai.stream.error", map[string]string{
-				"requestID": requestID,
-				"error":     fmt.Sprintf("Error: %v", err),
-			})
+			finish(MessageStatusError, fmt.Sprintf("Error: %v", err))
 			return
 		}
 		defer resp.Body.Close()
@@ -700,56 +1590,49 @@ func (a *App) GenerateWithOllamaStream(requestID string, model string, prompt st
 			select {
 			case <-ctx.Done():
 				// Request was cancelled
-				a.EventBus.Publish("ai.stream.done", map[string]string{
-					"requestID": requestID,
-					"reason":    "cancelled",
-				})
+				finish(MessageStatusCancelled, "")
 				return
 			default:
 				var chunk OllamaGenerateResponse
 				if err := decoder.Decode(&chunk); err != nil {
 					if err == io.EOF {
 						// Stream completed successfully
-						a.EventBus.Publish("ai.stream.done", map[string]string{
-							"requestID": requestID,
-						})
+						finish(MessageStatusComplete, "")
 						return
 					}
 					if ctx.Err() == context.Canceled {
-						a.EventBus.Publish("ai.stream.done", map[string]string{
-							"requestID": requestID,
-							"reason":    "cancelled",
-						})
+						finish(MessageStatusCancelled, "")
 						return
 					}
-					a.EventBus.Publish("ai.stream.error", map[string]string{
-						"requestID": requestID,
-						"error":     fmt.Sprintf("[Error reading response: %v]", err),
-					})
+					finish(MessageStatusError, fmt.Sprintf("[Error reading response: %v]", err))
 					return
 				}
 
 				if chunk.Error != "" {
-					a.EventBus.Publish("ai.stream.error", map[string]string{
-						"requestID": requestID,
-						"error":     fmt.Sprintf("[Ollama error: %s]", chunk.Error),
-					})
+					finish(MessageStatusError, fmt.Sprintf("[Ollama error: %s]", chunk.Error))
 					return
 				}
 
 				// Publish chunk
-				a.EventBus.Publish("ai.stream.chunk", map[string]string{
+				content.WriteString(chunk.Response)
+				a.EventBus.Emit(EventAIStreamChunk, map[string]string{
 					"requestID": requestID,
 					"chunk":     chunk.Response,
 				})
 
 				if chunk.Done {
 					// Stream completed
-					a.EventBus.Publish("ai.stream.done", map[string]string{
-						"requestID": requestID,
-					})
+					finish(MessageStatusComplete, "")
 					return
 				}
+
+				// Persist progress periodically
+				if messageID != 0 && time.Since(lastFlush) >= streamFlushInterval {
+					if err := a.ChatDB.UpdateStreamingMessage(messageID, content.String()); err != nil {
+						fmt.Printf("Failed to save streamed reply: %v\n", err)
+					}
+					lastFlush = time.Now()
+				}
 			}
 		}
 	}()
@@ -759,6 +1642,9 @@ func (a *App) GenerateWithOllamaStream(requestID string, model string, prompt st
 
 // StopGeneration cancels an active generation request
 func (a *App) StopGeneration(requestID string) {
+	a.requestsMu.Lock()
+	defer a.requestsMu.Unlock()
+
 	if cancel, exists := a.activeRequests[requestID]; exists {
 		cancel()
 		delete(a.activeRequests, requestID)
@@ -801,3 +1687,33 @@ func (a *App) ExportAsPDF(content string, defaultName string) error {
 
 	return nil
 }
+
+// ExportAsDOCX exports content as a Word document using the docxexport package
+func (a *App) ExportAsDOCX(content string, defaultName string) error {
+	filePath, err := wailsRuntime.SaveFileDialog(a.ctx, wailsRuntime.SaveDialogOptions{
+		Title:           "Export as Word Document",
+		DefaultFilename: defaultName + ".docx",
+		Filters: []wailsRuntime.FileFilter{
+			{DisplayName: "Word Documents (*.docx)", Pattern: "*.docx"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to show save dialog: %w", err)
+	}
+	if filePath == "" {
+		return nil // User cancelled
+	}
+
+	exporter := docxexport.NewExporter()
+	if err := exporter.Export(content, filePath); err != nil {
+		return fmt.Errorf("failed to export DOCX: %w", err)
+	}
+
+	return nil
+}
+
+// RenderMarkdown renders Markdown as sanitized HTML for the preview pane
+// and AI answers. Blocks carry the source line they start on.
+func (a *App) RenderMarkdown(content string) string {
+	return markdown.RenderHTML(markdown.Parse(content))
+}
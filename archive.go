@@ -0,0 +1,388 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveSeparator joins an archive's path to a member inside it, as in
+// "bundle.zip!/config/app.yaml". Member names always use forward slashes.
+const archiveSeparator = "!/"
+
+// Archive kinds, which open as a listing of their members
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+// CompressionGzip marks a file that is decompressed on open and
+// recompressed on save
+const CompressionGzip = "gzip"
+
+// tarHeaderSize is enough of a tar file to find its "ustar" magic
+const tarHeaderSize = 512
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ErrArchiveMember is returned when saving a file opened from inside an archive
+var ErrArchiveMember = errors.New("files inside archives are read-only")
+
+// ArchiveEntry is a file inside an archive
+type ArchiveEntry struct {
+	Name    string `json:"name"` // slash-separated path inside the archive
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+// ArchiveListing is the files inside an archive
+type ArchiveListing struct {
+	Path    string         `json:"path"`
+	Kind    string         `json:"kind"` // ArchiveZip, ArchiveTar or ArchiveTarGz
+	Entries []ArchiveEntry `json:"entries"`
+}
+
+// archiveMemberPath returns the virtual path of a file inside an archive
+func archiveMemberPath(archivePath string, member string) string {
+	return archivePath + archiveSeparator + member
+}
+
+// splitArchivePath splits a virtual path into the archive on disk and the
+// member inside it. ok is false for an ordinary path.
+func splitArchivePath(filePath string) (archivePath string, member string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(filePath[i:], archiveSeparator)
+		if j < 0 {
+			return "", "", false
+		}
+		j += i
+		// A folder may end in "!" too, so the archive must be a file
+		if stat, err := os.Stat(filePath[:j]); err == nil && stat.Mode().IsRegular() {
+			member = cleanMemberName(filePath[j+len(archiveSeparator):])
+			return filePath[:j], member, member != ""
+		}
+		i = j + 1
+	}
+}
+
+// cleanMemberName normalizes a name inside an archive, dropping "./" and
+// leading slashes
+func cleanMemberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// detectArchive recognizes archives and gzip-compressed files from the
+// start of their data. It returns an archive kind, CompressionGzip or "".
+func detectArchive(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return ArchiveZip
+	case isTar(data):
+		return ArchiveTar
+	case bytes.HasPrefix(data, gzipMagic):
+		// A sample may stop mid-stream, which is fine once the header is out
+		if header, _ := gunzip(data, tarHeaderSize); isTar(header) {
+			return ArchiveTarGz
+		}
+		return CompressionGzip
+	}
+	return ""
+}
+
+// isTar reports whether data starts with a POSIX tar header
+func isTar(data []byte) bool {
+	return len(data) >= 262 && string(data[257:262]) == "ustar"
+}
+
+// sniffArchive reads the start of a file to tell whether it is an archive
+// or compressed
+func sniffArchive(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	sample := make([]byte, binarySampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return detectArchive(sample[:n]), nil
+}
+
+// errTooLarge is returned by gunzip when data decompresses to more than
+// its limit; what fit is returned with it
+var errTooLarge = errors.New("too large")
+
+// gunzip decompresses up to limit bytes of gzip data. On error, what was
+// decompressed before it is returned too.
+func gunzip(data []byte, limit int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	plain, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if int64(len(plain)) > limit {
+		return plain[:limit], errTooLarge
+	}
+	return plain, err
+}
+
+// gzipBytes compresses data for saving back to a gzip file
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress unpacks the data of a gzip file, refusing any that would not
+// fit under the large-file threshold
+func (fm *FileManager) decompress(filePath string, data []byte) ([]byte, error) {
+	limit := fm.largeFileThreshold()
+	plain, err := gunzip(data, limit)
+	if err == errTooLarge {
+		return nil, fmt.Errorf("%s decompresses to over %d MB, too large to open", filepath.Base(filePath), limit>>20)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", filepath.Base(filePath), err)
+	}
+	return plain, nil
+}
+
+// compressionFor returns how a file is compressed when saved: as it was
+// when opened, or for a new file, gzip if its name ends in .gz
+func compressionFor(filePath string, open *openFileState) string {
+	if open != nil {
+		return open.compression
+	}
+	name := strings.ToLower(filePath)
+	if strings.HasSuffix(name, ".gz") && !strings.HasSuffix(name, ".tar.gz") {
+		return CompressionGzip
+	}
+	return ""
+}
+
+// readPlain reads the text bytes of a file: a member for an archive path,
+// decompressed for a gzip file, and as it is otherwise
+func (fm *FileManager) readPlain(filePath string) ([]byte, error) {
+	if archivePath, member, ok := splitArchivePath(filePath); ok {
+		data, _, err := fm.readArchiveMember(archivePath, member)
+		return data, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if isEncryptedNote(data) {
+		return nil, fmt.Errorf("%s is an %w; open it to read it", filepath.Base(filePath), ErrEncryptedNote)
+	}
+	if detectArchive(data) == CompressionGzip {
+		return fm.decompress(filePath, data)
+	}
+	return data, nil
+}
+
+// archiveFileInfo describes an archive, whose members are listed with
+// ListArchive rather than opened in a tab
+func archiveFileInfo(filePath string, stat os.FileInfo, kind string) *FileInfo {
+	return &FileInfo{
+		Path:      filePath,
+		Name:      filepath.Base(filePath),
+		Archive:   kind,
+		ReadOnly:  true,
+		Size:      stat.Size(),
+		LastSaved: stat.ModTime().Unix(),
+	}
+}
+
+// openArchiveMember reads a file inside an archive, read-only
+func (fm *FileManager) openArchiveMember(archivePath string, member string, encodingName string) (*FileInfo, string, error) {
+	data, modTime, err := fm.readArchiveMember(archivePath, member)
+	if err != nil {
+		return nil, "", err
+	}
+	if detectBinary(data).Binary {
+		return nil, "", fmt.Errorf("%s: %w; extract it to view it", member, ErrBinaryFile)
+	}
+
+	content, detection, layout, err := fm.readWithDetection(data, encodingName)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", member, err)
+	}
+
+	filePath := archiveMemberPath(archivePath, member)
+	fm.addToRecentFiles(filePath)
+	return &FileInfo{
+		Path:               filePath,
+		Name:               path.Base(member),
+		Encoding:           detection.Encoding,
+		EncodingConfidence: detection.Confidence,
+		LineEnding:         layoutStyle(layout),
+		LineEndingCounts:   layout.counts,
+		TrailingNewline:    strings.HasSuffix(content, "\n"),
+		Language:           detectLanguage(member, content),
+		ReadOnly:           true,
+		Size:               int64(len(data)),
+		LastSaved:          modTime.Unix(),
+	}, content, nil
+}
+
+// ListArchive returns the files inside a zip or tar archive, by name
+func (fm *FileManager) ListArchive(archivePath string) (*ArchiveListing, error) {
+	listing := &ArchiveListing{Path: archivePath, Entries: []ArchiveEntry{}}
+	kind, err := eachArchiveMember(archivePath, func(entry ArchiveEntry, open func() (io.ReadCloser, error)) (bool, error) {
+		listing.Entries = append(listing.Entries, entry)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	listing.Kind = kind
+	sort.Slice(listing.Entries, func(i, j int) bool {
+		return listing.Entries[i].Name < listing.Entries[j].Name
+	})
+	return listing, nil
+}
+
+// readArchiveMember returns the bytes and modification time of a file
+// inside an archive
+func (fm *FileManager) readArchiveMember(archivePath string, member string) ([]byte, time.Time, error) {
+	limit := fm.largeFileThreshold()
+	var data []byte
+	var modTime time.Time
+	found := false
+	_, err := eachArchiveMember(archivePath, func(entry ArchiveEntry, open func() (io.ReadCloser, error)) (bool, error) {
+		if entry.Name != member {
+			return false, nil
+		}
+		found = true
+		if entry.Size > limit {
+			return true, errTooLarge
+		}
+		reader, err := open()
+		if err != nil {
+			return true, err
+		}
+		defer reader.Close()
+		// The header's size may be wrong, so check what actually comes out
+		data, err = io.ReadAll(io.LimitReader(reader, limit+1))
+		if int64(len(data)) > limit {
+			return true, errTooLarge
+		}
+		modTime = time.Unix(entry.ModTime, 0)
+		return true, err
+	})
+	if err == errTooLarge {
+		return nil, time.Time{}, fmt.Errorf("%s is over %d MB, too large to open from inside an archive", member, limit>>20)
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read %s: %w", member, err)
+	}
+	if !found {
+		return nil, time.Time{}, fmt.Errorf("%s is not in %s: %w", member, filepath.Base(archivePath), os.ErrNotExist)
+	}
+	return data, modTime, nil
+}
+
+// archiveHasMember reports whether an archive holds a file
+func archiveHasMember(archivePath string, member string) bool {
+	found := false
+	eachArchiveMember(archivePath, func(entry ArchiveEntry, open func() (io.ReadCloser, error)) (bool, error) {
+		found = entry.Name == member
+		return found, nil
+	})
+	return found
+}
+
+// eachArchiveMember calls visit for each file in an archive until it
+// returns true. open reads the file's bytes and is only valid during the
+// call. The archive's kind is returned.
+func eachArchiveMember(archivePath string, visit func(entry ArchiveEntry, open func() (io.ReadCloser, error)) (bool, error)) (string, error) {
+	kind, err := sniffArchive(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	switch kind {
+	case ArchiveZip:
+		archive, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return kind, fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer archive.Close()
+		for _, file := range archive.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			entry := ArchiveEntry{
+				Name:    cleanMemberName(file.Name),
+				Size:    int64(file.UncompressedSize64),
+				ModTime: file.Modified.Unix(),
+			}
+			if stop, err := visit(entry, file.Open); stop || err != nil {
+				return kind, err
+			}
+		}
+		return kind, nil
+
+	case ArchiveTar, ArchiveTarGz:
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return kind, fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer file.Close()
+		var stream io.Reader = file
+		if kind == ArchiveTarGz {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				return kind, fmt.Errorf("failed to decompress archive: %w", err)
+			}
+			defer gz.Close()
+			stream = gz
+		}
+
+		archive := tar.NewReader(stream)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				return kind, nil
+			}
+			if err != nil {
+				return kind, fmt.Errorf("failed to read archive: %w", err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			entry := ArchiveEntry{
+				Name:    cleanMemberName(header.Name),
+				Size:    header.Size,
+				ModTime: header.ModTime.Unix(),
+			}
+			open := func() (io.ReadCloser, error) { return io.NopCloser(archive), nil }
+			if stop, err := visit(entry, open); stop || err != nil {
+				return kind, err
+			}
+		}
+	}
+	return kind, fmt.Errorf("%s is not a zip or tar archive", filepath.Base(archivePath))
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveFiles are the members written into test archives, by name
+var archiveFiles = map[string]string{
+	"README.md":           "# Bundle\n",
+	"config/app.yaml":     "name: akashic\nport: 8080\n",
+	"config/empty/.keep":  "",
+	"./scripts/run.sh":    "#!/bin/sh\necho run\n",
+	"/absolute/notes.txt": "leading slashes are dropped\n",
+}
+
+// archiveListing is archiveFiles' names as ListArchive returns them, in
+// order, with their sizes
+var archiveListing = []struct {
+	name string
+	size int
+}{
+	{"README.md", 9},
+	{"absolute/notes.txt", 28},
+	{"config/app.yaml", 25},
+	{"config/empty/.keep", 0},
+	{"scripts/run.sh", 19},
+}
+
+// newTestFileManager returns a file manager whose settings live in a
+// temporary home folder
+func newTestFileManager(t *testing.T) *FileManager {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	app := &App{EventBus: NewEventBus(), SettingsManager: NewSettingsManager()}
+	app.FileManager = NewFileManager(app)
+	return app.FileManager
+}
+
+// writeZip writes files into a zip archive, with a folder entry too
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	if _, err := archive.Create("config/"); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tarBytes writes files into a tar archive, with a folder and a symlink
+// entry too
+func tarBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	others := []*tar.Header{
+		{Name: "config/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime},
+		{Name: "latest", Typeflag: tar.TypeSymlink, Linkname: "README.md", ModTime: modTime},
+	}
+	for _, header := range others {
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		header := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: modTime}
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testArchives writes the same files as a zip, a tar and a tar.gz
+func testArchives(t *testing.T) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+	archives := map[string]string{
+		ArchiveZip:   filepath.Join(dir, "bundle.zip"),
+		ArchiveTar:   filepath.Join(dir, "bundle.tar"),
+		ArchiveTarGz: filepath.Join(dir, "bundle.tar.gz"),
+	}
+	writeZip(t, archives[ArchiveZip], archiveFiles)
+	plain := tarBytes(t, archiveFiles)
+	if err := os.WriteFile(archives[ArchiveTar], plain, 0644); err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := gzipBytes(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archives[ArchiveTarGz], compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return archives
+}
+
+func TestGzipFileRoundTrip(t *testing.T) {
+	fm := newTestFileManager(t)
+	path := filepath.Join(t.TempDir(), "notes.md.gz")
+	compressed, err := gzipBytes([]byte("first line\r\nsecond line\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, content, err := fm.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "first line\nsecond line\n" {
+		t.Errorf("content = %q", content)
+	}
+	if info.Compression != CompressionGzip || info.LineEnding != LineEndingCRLF || info.Language != "markdown" {
+		t.Errorf("info = %+v", info)
+	}
+
+	if _, err := fm.WriteFile(path, content+"third line\n", info.LineEnding, info.Encoding); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detectArchive(saved) != CompressionGzip {
+		t.Fatalf("saved file isn't gzip: %q", saved)
+	}
+	plain, err := gunzip(saved, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first line\r\nsecond line\r\nthird line\r\n"; string(plain) != want {
+		t.Errorf("saved content = %q, want %q", plain, want)
+	}
+
+	if _, content, err := fm.ReadFile(path); err != nil || content != "first line\nsecond line\nthird line\n" {
+		t.Errorf("reopened content = %q, %v", content, err)
+	}
+}
+
+func TestNewGzipFileIsCompressed(t *testing.T) {
+	fm := newTestFileManager(t)
+	path := filepath.Join(t.TempDir(), "notes.txt.gz")
+
+	if _, err := fm.WriteFile(path, "new\n", LineEndingLF, "UTF-8"); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := gunzip(saved, 1<<20); err != nil || string(plain) != "new\n" {
+		t.Errorf("saved file decompresses to %q, %v", plain, err)
+	}
+}
+
+func TestGunzipLimit(t *testing.T) {
+	compressed, err := gzipBytes([]byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		limit   int64
+		want    string
+		wantErr error
+	}{
+		{20, "0123456789", nil},
+		{10, "0123456789", nil},
+		{9, "012345678", errTooLarge},
+	}
+	for _, tt := range tests {
+		plain, err := gunzip(compressed, tt.limit)
+		if string(plain) != tt.want || err != tt.wantErr {
+			t.Errorf("gunzip(limit %d) = %q, %v, want %q, %v", tt.limit, plain, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestListArchive(t *testing.T) {
+	fm := newTestFileManager(t)
+
+	for kind, path := range testArchives(t) {
+		info, content, err := fm.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if info.Archive != kind || !info.ReadOnly || content != "" {
+			t.Errorf("%s: opened as %+v with content %q", kind, info, content)
+		}
+
+		listing, err := fm.ListArchive(path)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if listing.Kind != kind {
+			t.Errorf("%s: listed as %s", kind, listing.Kind)
+		}
+		if len(listing.Entries) != len(archiveListing) {
+			t.Fatalf("%s: listed %+v", kind, listing.Entries)
+		}
+		for i, want := range archiveListing {
+			if got := listing.Entries[i]; got.Name != want.name || got.Size != int64(want.size) {
+				t.Errorf("%s: entry %d = %s, %d bytes, want %s, %d bytes", kind, i, got.Name, got.Size, want.name, want.size)
+			}
+		}
+	}
+}
+
+func TestReadArchiveMember(t *testing.T) {
+	fm := newTestFileManager(t)
+
+	for kind, path := range testArchives(t) {
+		info, content, err := fm.ReadFile(archiveMemberPath(path, "config/app.yaml"))
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if content != archiveFiles["config/app.yaml"] || info.Name != "app.yaml" || !info.ReadOnly || info.Language != "yaml" {
+			t.Errorf("%s: read %+v with content %q", kind, info, content)
+		}
+
+		// Names are matched as the listing shows them
+		if _, content, err := fm.ReadFile(archiveMemberPath(path, "scripts/run.sh")); err != nil || content != archiveFiles["./scripts/run.sh"] {
+			t.Errorf("%s: scripts/run.sh = %q, %v", kind, content, err)
+		}
+		if _, _, err := fm.ReadFile(archiveMemberPath(path, "missing.txt")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: reading a missing member = %v, want os.ErrNotExist", kind, err)
+		}
+		if !archiveHasMember(path, "README.md") || archiveHasMember(path, "config") {
+			t.Errorf("%s: archiveHasMember doesn't match the listing", kind)
+		}
+	}
+}
+
+func TestReadArchiveMemberTooLarge(t *testing.T) {
+	fm := newTestFileManager(t)
+	editor := fm.app.SettingsManager.Get().Editor
+	editor.LargeFileThresholdMB = 1
+	if err := fm.app.SettingsManager.UpdateEditor(editor); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "big.tar")
+	files := map[string]string{
+		"fits.txt": strings.Repeat("x", 1<<20),
+		"big.txt":  strings.Repeat("x", 1<<20+1),
+	}
+	if err := os.WriteFile(path, tarBytes(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _, err := fm.readArchiveMember(path, "fits.txt"); err != nil || len(data) != 1<<20 {
+		t.Errorf("fits.txt = %d bytes, %v", len(data), err)
+	}
+	if data, _, err := fm.readArchiveMember(path, "big.txt"); err == nil || data != nil {
+		t.Errorf("big.txt = %d bytes, %v, want it refused", len(data), err)
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "bundle.zip")
+	writeZip(t, bundle, archiveFiles)
+	// A folder whose name ends in "!" holding an archive
+	loud := filepath.Join(dir, "loud!")
+	if err := os.Mkdir(loud, 0755); err != nil {
+		t.Fatal(err)
+	}
+	inner := filepath.Join(loud, "inner.zip")
+	writeZip(t, inner, archiveFiles)
+
+	tests := []struct {
+		path        string
+		wantArchive string
+		wantMember  string
+		wantOK      bool
+	}{
+		{bundle + "!/config/app.yaml", bundle, "config/app.yaml", true},
+		{bundle + "!/./config//app.yaml", bundle, "config/app.yaml", true},
+		{bundle + "!/a/../README.md", bundle, "README.md", true},
+		{inner + "!/README.md", inner, "README.md", true},
+		{bundle + "!/", bundle, "", false}, // the archive itself, not a member
+		{bundle, "", "", false},
+		{filepath.Join(loud, "notes.txt"), "", "", false},
+		{filepath.Join(dir, "missing.zip") + "!/README.md", "", "", false},
+	}
+	for _, tt := range tests {
+		archive, member, ok := splitArchivePath(tt.path)
+		if archive != tt.wantArchive || member != tt.wantMember || ok != tt.wantOK {
+			t.Errorf("splitArchivePath(%q) = %q, %q, %v, want %q, %q, %v", tt.path, archive, member, ok, tt.wantArchive, tt.wantMember, tt.wantOK)
+		}
+	}
+
+	if got := archiveMemberPath(bundle, "config/app.yaml"); got != tests[0].path {
+		t.Errorf("archiveMemberPath = %q, want %q", got, tests[0].path)
+	}
+}
+
+func TestWriteArchiveMemberRefused(t *testing.T) {
+	fm := newTestFileManager(t)
+
+	for kind, path := range testArchives(t) {
+		before, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		member := archiveMemberPath(path, "README.md")
+		if _, _, err := fm.ReadFile(member); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+
+		if _, err := fm.WriteFile(member, "# Edited\n", LineEndingLF, "UTF-8"); !errors.Is(err, ErrArchiveMember) {
+			t.Errorf("%s: WriteFile = %v, want ErrArchiveMember", kind, err)
+		}
+		if _, err := fm.OverwriteFile(member, "# Edited\n", LineEndingLF, "UTF-8"); !errors.Is(err, ErrArchiveMember) {
+			t.Errorf("%s: OverwriteFile = %v, want ErrArchiveMember", kind, err)
+		}
+		after, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(before, after) {
+			t.Errorf("%s: refused save changed the archive", kind)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backup modes for EditorSettings.BackupOnSave
+const (
+	BackupOnSaveNone        = "none"        // no backup
+	BackupOnSaveBak         = "bak"         // keep the previous version as file.bak
+	BackupOnSaveTimestamped = "timestamped" // keep every version as file.20060102-150405.bak
+)
+
+// ErrReadOnly is returned when saving over a file that can't be written.
+// The frontend offers Save As instead.
+var ErrReadOnly = errors.New("file is read-only")
+
+// saveBackupTimeFormat names timestamped backups
+const saveBackupTimeFormat = "20060102-150405"
+
+// preservedModeBits are the parts of a file's mode a save keeps
+const preservedModeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// writeFileAtomic replaces a file without ever leaving it half-written. The
+// data goes to a temporary file in the same directory, is synced, takes
+// over the original's mode and owner, and is renamed over it. Symlinks are
+// followed so the link itself survives. If the directory doesn't allow new
+// files the save fails with ErrReadOnly rather than risk truncating the
+// original.
+func writeFileAtomic(path string, data []byte, backupMode string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		target = path // new file
+	}
+
+	mode := os.FileMode(0644)
+	info, err := os.Stat(target)
+	switch {
+	case err == nil:
+		mode = info.Mode() & preservedModeBits
+		if err := checkWritable(target); err != nil {
+			return err
+		}
+		if err := backupBeforeSave(target, backupMode); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("%s: %w; its folder doesn't allow the temporary file a safe save needs, so use Save As to save it elsewhere", filepath.Base(target), ErrReadOnly)
+		}
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	// Changing the owner clears setuid and setgid, so it goes first
+	if info != nil {
+		preserveOwner(tmpPath, info)
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, target); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	committed = true
+
+	syncDir(dir)
+	return nil
+}
+
+// checkWritable reports ErrReadOnly if an existing file can't be opened for
+// writing. Opening without O_TRUNC leaves the contents alone.
+func checkWritable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("%s: %w; use Save As to save a copy", filepath.Base(path), ErrReadOnly)
+		}
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	return f.Close()
+}
+
+// backupBeforeSave copies the current version of a file aside according to
+// the backup mode. The backup gets the original's mode and owner before any
+// content is written, so it is never more readable than the file.
+func backupBeforeSave(path string, backupMode string) error {
+	var backupPath string
+	switch backupMode {
+	case "", BackupOnSaveNone:
+		return nil
+	case BackupOnSaveBak:
+		backupPath = path + ".bak"
+	case BackupOnSaveTimestamped:
+		backupPath = fmt.Sprintf("%s.%s.bak", path, time.Now().Format(saveBackupTimeFormat))
+	default:
+		return fmt.Errorf("unknown backup mode: %s", backupMode)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file for backup: %w", err)
+	}
+	defer src.Close()
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file for backup: %w", err)
+	}
+
+	mode := srcInfo.Mode() & preservedModeBits
+	dst, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	// An existing backup keeps its old mode and a new one is masked by the
+	// umask, so set it explicitly as writeFileAtomic does for its temp file,
+	// after the owner since changing that clears setuid and setgid
+	preserveOwner(backupPath, srcInfo)
+	if err := os.Chmod(backupPath, mode); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to set backup permissions: %w", err)
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
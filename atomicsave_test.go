@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// tempFiles returns the leftover temporary files in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// readString returns a file's content, failing the test if it can't be read
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriteFileAtomicKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+	dir := t.TempDir()
+
+	for _, mode := range []os.FileMode{0755, 0600, 0755 | os.ModeSetuid} {
+		path := filepath.Join(dir, "script.sh")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writeFileAtomic(path, []byte("#!/bin/sh\necho hi\n"), BackupOnSaveNone); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode() & preservedModeBits; got != mode {
+			t.Errorf("mode after save = %v, want %v", got, mode)
+		}
+		if got := readString(t, path); got != "#!/bin/sh\necho hi\n" {
+			t.Errorf("content after save = %q", got)
+		}
+	}
+}
+
+func TestWriteFileAtomicThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("can't create symlinks here: %v", err)
+	}
+
+	if err := writeFileAtomic(link, []byte("new"), BackupOnSaveNone); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("saving through the link replaced it with a regular file")
+	}
+	if got := readString(t, target); got != "new" {
+		t.Errorf("link target content = %q, want %q", got, "new")
+	}
+}
+
+func TestWriteFileAtomicReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "locked.txt")
+	if err := os.WriteFile(path, []byte("keep me"), 0444); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(path, 0644) })
+	if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		f.Close()
+		t.Skip("file modes are not enforced for this user")
+	}
+
+	err := writeFileAtomic(path, []byte("overwritten"), BackupOnSaveBak)
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("writeFileAtomic = %v, want ErrReadOnly", err)
+	}
+	if got := readString(t, path); got != "keep me" {
+		t.Errorf("read-only file was changed to %q", got)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Error("a backup was made for a save that was refused")
+	}
+	if leftovers := tempFiles(t, dir); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestWriteFileAtomicReadOnlyFolder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows folders have no Unix permission bits")
+	}
+	dir := filepath.Join(t.TempDir(), "locked")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+	if f, err := os.CreateTemp(dir, "probe"); err == nil {
+		f.Close()
+		os.Remove(f.Name())
+		t.Skip("folder modes are not enforced for this user")
+	}
+
+	// The file itself is writable, but without a temporary file the save
+	// can't be atomic, so it is refused rather than done in place
+	err := writeFileAtomic(path, []byte("overwritten"), BackupOnSaveNone)
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("writeFileAtomic = %v, want ErrReadOnly", err)
+	}
+	if got := readString(t, path); got != "keep me" {
+		t.Errorf("file was changed to %q", got)
+	}
+}
+
+func TestWriteFileAtomicBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("v2"), BackupOnSaveBak); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, path+".bak"); got != "v1" {
+		t.Errorf(".bak holds %q, want %q", got, "v1")
+	}
+	if err := writeFileAtomic(path, []byte("v3"), BackupOnSaveBak); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, path+".bak"); got != "v2" {
+		t.Errorf(".bak after a second save holds %q, want %q", got, "v2")
+	}
+
+	if err := writeFileAtomic(path, []byte("v4"), BackupOnSaveTimestamped); err != nil {
+		t.Fatal(err)
+	}
+	stamped, err := filepath.Glob(filepath.Join(dir, "notes.txt.*.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stamped) != 1 {
+		t.Fatalf("timestamped backups = %v, want one", stamped)
+	}
+	if got := readString(t, stamped[0]); got != "v3" {
+		t.Errorf("timestamped backup holds %q, want %q", got, "v3")
+	}
+
+	if got := readString(t, path); got != "v4" {
+		t.Errorf("file holds %q, want %q", got, "v4")
+	}
+	if leftovers := tempFiles(t, dir); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestWriteFileAtomicFailureLeavesNoTemp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		backupMode string
+	}{
+		{"unknown backup mode", path, "weekly"},
+		{"directory", dir, BackupOnSaveNone},
+		{"missing folder", filepath.Join(dir, "missing", "new.txt"), BackupOnSaveNone},
+	}
+
+	for _, tt := range tests {
+		if err := writeFileAtomic(tt.path, []byte("replacement"), tt.backupMode); err == nil {
+			t.Errorf("%s: writeFileAtomic succeeded", tt.name)
+		}
+		if leftovers := tempFiles(t, dir); len(leftovers) > 0 {
+			t.Errorf("%s: temporary files left behind: %v", tt.name, leftovers)
+		}
+	}
+	if got := readString(t, path); got != "original" {
+		t.Errorf("file holds %q after failed saves", got)
+	}
+}
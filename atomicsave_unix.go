@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// preserveOwner gives a replacement file the owner and group of the
+// original. It only succeeds when permitted, so failure is ignored.
+func preserveOwner(path string, original os.FileInfo) {
+	if stat, ok := original.Sys().(*syscall.Stat_t); ok {
+		os.Chown(path, int(stat.Uid), int(stat.Gid))
+	}
+}
+
+// syncDir flushes a directory entry so a rename survives a crash
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteFileAtomicFailedWriteLeavesNoTemp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cap the size of files this process may write, so the temporary file
+	// is created but can't be filled
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Skipf("can't read the file size limit: %v", err)
+	}
+	capped := limit
+	capped.Cur = 1024
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &capped); err != nil {
+		t.Skipf("can't limit file sizes: %v", err)
+	}
+	err := writeFileAtomic(path, bytes.Repeat([]byte("x"), 64<<10), BackupOnSaveNone)
+	syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit)
+
+	if err == nil {
+		t.Fatal("writeFileAtomic succeeded past the file size limit")
+	}
+	if leftovers := tempFiles(t, dir); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+	if got := readString(t, path); got != "original" {
+		t.Errorf("file holds %q after a failed save", got)
+	}
+}
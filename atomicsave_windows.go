@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// preserveOwner is a no-op on Windows, where a renamed file keeps the
+// directory's inherited permissions
+func preserveOwner(path string, original os.FileInfo) {}
+
+// syncDir is a no-op on Windows, which can't open a directory for syncing
+func syncDir(dir string) {}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Binary detection and hex dump limits
+const (
+	binarySampleSize  = 8 * 1024 // bytes looked at to decide if a file is binary
+	hexRowBytes       = 16
+	maxHexPageBytes   = 64 * 1024
+	binarySearchChunk = 1 << 20
+)
+
+// ErrBinaryFile is returned when saving text over a binary file
+var ErrBinaryFile = errors.New("file is binary")
+
+// BinaryDetection says whether data looks like a binary file
+type BinaryDetection struct {
+	Binary bool   `json:"binary"`
+	Kind   string `json:"kind"` // such as "PNG image", or "" if not known
+}
+
+// binarySignatures are the magic numbers of common binary formats
+var binarySignatures = []struct {
+	offset int
+	magic  string
+	kind   string
+}{
+	{0, "\x89PNG\r\n\x1a\n", "PNG image"},
+	{0, "\xff\xd8\xff", "JPEG image"},
+	{0, "GIF87a", "GIF image"},
+	{0, "GIF89a", "GIF image"},
+	{0, "BM", ""}, // checked with NUL bytes below, "BM" starts words too
+	{0, "II*\x00", "TIFF image"},
+	{0, "MM\x00*", "TIFF image"},
+	{0, "\x00\x00\x01\x00", "Windows icon"},
+	{8, "WEBP", "WebP image"},
+	{0, "%PDF-", "PDF document"},
+	{0, "PK\x03\x04", "ZIP archive"},
+	{0, "PK\x05\x06", "ZIP archive"},
+	{0, "\x1f\x8b", "gzip archive"},
+	{0, "BZh", ""},
+	{0, "\xfd7zXZ\x00", "xz archive"},
+	{0, "7z\xbc\xaf\x27\x1c", "7-Zip archive"},
+	{0, "Rar!\x1a\x07", "RAR archive"},
+	{0, "\x28\xb5\x2f\xfd", "Zstandard archive"},
+	{257, "ustar", "tar archive"},
+	{0, "SQLite format 3\x00", "SQLite database"},
+	{0, "\x7fELF", "ELF executable"},
+	{0, "\xfe\xed\xfa\xce", "Mach-O executable"},
+	{0, "\xfe\xed\xfa\xcf", "Mach-O executable"},
+	{0, "\xce\xfa\xed\xfe", "Mach-O executable"},
+	{0, "\xcf\xfa\xed\xfe", "Mach-O executable"},
+	{0, "\xca\xfe\xba\xbe", "Java class or Mach-O universal binary"},
+	{0, "\x00asm", "WebAssembly module"},
+	{0, "ID3", "MP3 audio"},
+	{0, "OggS", "Ogg media"},
+	{0, "fLaC", "FLAC audio"},
+	{4, "ftyp", "MP4 media"},
+	{0, "\x1aE\xdf\xa3", "Matroska media"},
+	{0, "wOFF", "WOFF font"},
+	{0, "wOF2", "WOFF2 font"},
+	{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "Microsoft Office document"},
+}
+
+// detectBinary decides from the start of a file whether it is binary,
+// by magic number, NUL bytes that aren't UTF-16 or UTF-32, control
+// characters, or mostly invalid UTF-8 with some control characters
+func detectBinary(sample []byte) BinaryDetection {
+	if len(sample) > binarySampleSize {
+		sample = sample[:binarySampleSize]
+	}
+	if len(sample) == 0 {
+		return BinaryDetection{}
+	}
+	for _, bom := range [][]byte{bomUTF8, bomUTF16LE, bomUTF16BE, bomUTF32LE, bomUTF32BE} {
+		if bytes.HasPrefix(sample, bom) {
+			return BinaryDetection{}
+		}
+	}
+
+	hasNUL := bytes.IndexByte(sample, 0) >= 0
+	for _, sig := range binarySignatures {
+		if len(sample) < sig.offset+len(sig.magic) || string(sample[sig.offset:sig.offset+len(sig.magic)]) != sig.magic {
+			continue
+		}
+		switch {
+		case sig.kind != "":
+			return BinaryDetection{Binary: true, Kind: sig.kind}
+		case hasNUL && sig.magic == "BM":
+			return BinaryDetection{Binary: true, Kind: "BMP image"}
+		case sig.magic == "BZh" && len(sample) > 3 && sample[3] >= '1' && sample[3] <= '9':
+			return BinaryDetection{Binary: true, Kind: "bzip2 archive"}
+		}
+	}
+	if hasNUL && len(sample) >= 2 && sample[0] == 'M' && sample[1] == 'Z' {
+		return BinaryDetection{Binary: true, Kind: "Windows executable"}
+	}
+
+	if hasNUL {
+		if _, wide := detectWideUnicode(sample); !wide {
+			return BinaryDetection{Binary: true}
+		}
+		return BinaryDetection{}
+	}
+
+	controls, invalid := 0, 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			if len(sample)-i >= utf8.UTFMax {
+				invalid++ // not a character cut off by the sample
+			}
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != '\v' && r != 0x1b:
+			controls++
+		case r == 0x7f:
+			controls++
+		}
+		i += size
+	}
+	if controls*10 > len(sample) {
+		return BinaryDetection{Binary: true}
+	}
+	// Text in a legacy code page is mostly invalid UTF-8 too, but has next
+	// to no control characters
+	if invalid*10 > len(sample)*3 && controls*100 > len(sample) {
+		return BinaryDetection{Binary: true}
+	}
+	return BinaryDetection{}
+}
+
+// sniffBinary reads the start of a file to tell whether it is binary
+func sniffBinary(filePath string) (BinaryDetection, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return BinaryDetection{}, err
+	}
+	defer file.Close()
+
+	sample := make([]byte, binarySampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return BinaryDetection{}, err
+	}
+	return detectBinary(sample[:n]), nil
+}
+
+// binaryFileInfo describes a binary file, which opens read-only in the hex view
+func binaryFileInfo(filePath string, stat os.FileInfo, detection BinaryDetection) *FileInfo {
+	return &FileInfo{
+		Path:       filePath,
+		Name:       filepath.Base(filePath),
+		Binary:     true,
+		BinaryKind: detection.Kind,
+		ReadOnly:   true,
+		Size:       stat.Size(),
+		LastSaved:  stat.ModTime().Unix(),
+	}
+}
+
+// OpenBinaryFile opens a file in the read-only hex view. Its bytes are
+// read a page at a time with ReadHex.
+func (fm *FileManager) OpenBinaryFile(filePath string) (*FileInfo, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	detection, err := sniffBinary(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	detection.Binary = true
+
+	fm.addToRecentFiles(filePath)
+	return binaryFileInfo(filePath, stat, detection), nil
+}
+
+// checkNotBinary refuses to let text replace a binary file
+func checkNotBinary(filePath string) error {
+	detection, err := sniffBinary(filePath)
+	if err != nil {
+		return nil // missing or unreadable; writing reports any problem
+	}
+	if detection.Binary {
+		return fmt.Errorf("%s: %w and can't be saved as text", filepath.Base(filePath), ErrBinaryFile)
+	}
+	return nil
+}
+
+// HexRow is one row of a hex dump
+type HexRow struct {
+	Offset int64  `json:"offset"`
+	Hex    string `json:"hex"`  // the bytes as hex pairs, with a wider gap after the eighth
+	Text   string `json:"text"` // printable ASCII, with dots for other bytes
+}
+
+// HexPage is a run of rows from a binary file
+type HexPage struct {
+	Offset int64    `json:"offset"`
+	Size   int64    `json:"size"` // of the whole file
+	Rows   []HexRow `json:"rows"`
+}
+
+// ReadHex dumps length bytes of a file from offset, rounded down to the
+// start of a row
+func (fm *FileManager) ReadHex(filePath string, offset int64, length int) (*HexPage, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := stat.Size()
+	offset = max(0, min(offset, size)) / hexRowBytes * hexRowBytes
+	length = max(hexRowBytes, min(length, maxHexPageBytes))
+
+	buf := make([]byte, min(int64(length), size-offset))
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	buf = buf[:n]
+
+	page := &HexPage{Offset: offset, Size: size, Rows: make([]HexRow, 0, (n+hexRowBytes-1)/hexRowBytes)}
+	for start := 0; start < n; start += hexRowBytes {
+		page.Rows = append(page.Rows, hexRow(offset+int64(start), buf[start:min(start+hexRowBytes, n)]))
+	}
+	return page, nil
+}
+
+// hexRow formats up to hexRowBytes bytes as a dump row
+func hexRow(offset int64, data []byte) HexRow {
+	var hexCol, textCol strings.Builder
+	for i, b := range data {
+		if i > 0 {
+			hexCol.WriteByte(' ')
+		}
+		if i == hexRowBytes/2 {
+			hexCol.WriteByte(' ')
+		}
+		hexCol.WriteString(hex.EncodeToString([]byte{b}))
+		if b >= 0x20 && b < 0x7f {
+			textCol.WriteByte(b)
+		} else {
+			textCol.WriteByte('.')
+		}
+	}
+	return HexRow{Offset: offset, Hex: hexCol.String(), Text: textCol.String()}
+}
+
+// parseBytePattern reads a byte search: hex pairs such as "de ad be ef",
+// or text searched for as UTF-8
+func parseBytePattern(query string, isHex bool) ([]byte, error) {
+	if !isHex {
+		if query == "" {
+			return nil, fmt.Errorf("empty search")
+		}
+		return []byte(query), nil
+	}
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == ',' {
+			return -1
+		}
+		return r
+	}, strings.TrimPrefix(strings.ToLower(query), "0x"))
+	if digits == "" {
+		return nil, fmt.Errorf("empty search")
+	}
+	pattern, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex bytes: %w", err)
+	}
+	return pattern, nil
+}
+
+// FindBytes returns the offset of the first match of query at or after
+// from, or -1 if there is none
+func (fm *FileManager) FindBytes(filePath string, query string, isHex bool, from int64) (int64, error) {
+	pattern, err := parseBytePattern(query, isHex)
+	if err != nil {
+		return -1, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Consecutive chunks overlap so matches across a boundary are found
+	overlap := len(pattern) - 1
+	buf := make([]byte, binarySearchChunk+overlap)
+	offset := max(from, 0)
+	for {
+		n, err := file.ReadAt(buf, offset)
+		if i := bytes.Index(buf[:n], pattern); i >= 0 {
+			return offset + int64(i), nil
+		}
+		if err == io.EOF || n <= overlap {
+			return -1, nil
+		}
+		if err != nil {
+			return -1, fmt.Errorf("failed to read file: %w", err)
+		}
+		offset += int64(n - overlap)
+	}
+}
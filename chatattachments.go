@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxAttachmentBytes caps the excerpt stored for a single attachment
+const maxAttachmentBytes = 256 * 1024
+
+// minLocateScore is the fraction of excerpt lines that must match for a
+// fuzzy relocation to count as found
+const minLocateScore = 0.5
+
+// maxFuzzyWork bounds the line comparisons of a fuzzy relocation; larger
+// searches are narrowed to a window around the original position
+const maxFuzzyWork = 5_000_000
+
+// Attachment links a message to the file excerpt it discussed
+type Attachment struct {
+	ID          int64  `json:"id"`
+	MessageID   int64  `json:"messageId"`
+	FilePath    string `json:"filePath"`
+	StartLine   int    `json:"startLine"` // 1-based, inclusive
+	EndLine     int    `json:"endLine"`   // 1-based, inclusive
+	ContentHash string `json:"contentHash"`
+	Excerpt     string `json:"excerpt"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// AttachmentLocation is where an attachment's excerpt is found in the file today
+type AttachmentLocation struct {
+	FilePath  string  `json:"filePath"`
+	Found     bool    `json:"found"`
+	Exact     bool    `json:"exact"`     // the excerpt is present unchanged
+	Moved     bool    `json:"moved"`     // it no longer starts at the recorded line
+	StartLine int     `json:"startLine"` // 1-based, inclusive
+	EndLine   int     `json:"endLine"`   // 1-based, inclusive
+	Score     float64 `json:"score"`     // fraction of excerpt lines matched
+}
+
+// initAttachmentsTable creates the message_attachments table
+func (c *ChatDB) initAttachmentsTable() error {
+	_, err := c.db.Exec(`
+		CREATE TABLE IF NOT EXISTS message_attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id INTEGER NOT NULL,
+			file_path TEXT NOT NULL,
+			start_line INTEGER NOT NULL,
+			end_line INTEGER NOT NULL,
+			content_hash TEXT NOT NULL,
+			excerpt TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create attachments table: %v", err)
+	}
+
+	_, err = c.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON message_attachments(message_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %v", err)
+	}
+
+	return nil
+}
+
+// splitExcerptLines normalizes line endings and splits text into lines
+func splitExcerptLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// hashExcerpt hashes an excerpt independent of its line endings
+func hashExcerpt(excerpt string) string {
+	return hashLines(splitExcerptLines(excerpt))
+}
+
+// hashLines hashes lines joined with \n, as hashExcerpt stores them
+func hashLines(lines []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddAttachment records a file excerpt against a message
+func (c *ChatDB) AddAttachment(messageID int64, filePath string, startLine, endLine int, excerpt string) (*Attachment, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+	if len(excerpt) > maxAttachmentBytes {
+		return nil, fmt.Errorf("excerpt is larger than %s", formatBytes(maxAttachmentBytes))
+	}
+	if startLine < 1 || endLine < startLine {
+		return nil, fmt.Errorf("invalid line range %d-%d", startLine, endLine)
+	}
+
+	var id int64
+	err := c.withSealed(func(seal func(string) (string, error)) error {
+		storedPath, err := seal(filePath)
+		if err != nil {
+			return err
+		}
+		storedExcerpt, err := seal(excerpt)
+		if err != nil {
+			return err
+		}
+
+		result, err := c.db.Exec(
+			`INSERT INTO message_attachments (message_id, file_path, start_line, end_line, content_hash, excerpt)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			messageID, storedPath, startLine, endLine, hashExcerpt(excerpt), storedExcerpt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add attachment: %v", err)
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get attachment ID: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetAttachment(id)
+}
+
+// GetAttachment retrieves an attachment by ID
+func (c *ChatDB) GetAttachment(id int64) (*Attachment, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.Query(
+		`SELECT id, message_id, file_path, start_line, end_line, content_hash, excerpt, created_at
+		FROM message_attachments WHERE id = ?`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %v", err)
+	}
+	attachments, err := c.scanAttachments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, fmt.Errorf("attachment not found")
+	}
+	return &attachments[0], nil
+}
+
+// GetMessageAttachments retrieves the attachments of a message
+func (c *ChatDB) GetMessageAttachments(messageID int64) ([]Attachment, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.Query(
+		`SELECT id, message_id, file_path, start_line, end_line, content_hash, excerpt, created_at
+		FROM message_attachments WHERE message_id = ? ORDER BY id ASC`,
+		messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %v", err)
+	}
+	return c.scanAttachments(rows)
+}
+
+// GetChatAttachments retrieves every attachment in a chat, in message order
+func (c *ChatDB) GetChatAttachments(chatID int64) ([]Attachment, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.Query(
+		`SELECT a.id, a.message_id, a.file_path, a.start_line, a.end_line, a.content_hash, a.excerpt, a.created_at
+		FROM message_attachments a
+		JOIN messages m ON m.id = a.message_id
+		WHERE m.chat_id = ?
+		ORDER BY a.message_id ASC, a.id ASC`,
+		chatID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %v", err)
+	}
+	return c.scanAttachments(rows)
+}
+
+// scanAttachments reads and decrypts attachment rows, closing rows
+func (c *ChatDB) scanAttachments(rows *sql.Rows) ([]Attachment, error) {
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		var a Attachment
+		err := rows.Scan(&a.ID, &a.MessageID, &a.FilePath, &a.StartLine, &a.EndLine, &a.ContentHash, &a.Excerpt, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %v", err)
+		}
+		if a.FilePath, err = c.openText(a.FilePath); err != nil {
+			return nil, err
+		}
+		if a.Excerpt, err = c.openText(a.Excerpt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// LocateAttachment finds where an attachment's excerpt is in its file now.
+// The recorded range is checked against the stored content hash first,
+// then every other range of the same length, then the closest fuzzy match
+// by matching lines.
+func (c *ChatDB) LocateAttachment(id int64) (*AttachmentLocation, error) {
+	attachment, err := c.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(attachment.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", attachment.FilePath, err)
+	}
+
+	location := locateExcerpt(splitExcerptLines(string(data)), splitExcerptLines(attachment.Excerpt), attachment.ContentHash, attachment.StartLine-1)
+	location.FilePath = attachment.FilePath
+	location.Moved = location.Found && location.StartLine != attachment.StartLine
+	return location, nil
+}
+
+// locateExcerpt searches fileLines for the lines hashing to contentHash,
+// then for a fuzzy match of excerptLines, preferring matches nearest to
+// hint (a 0-based line index)
+func locateExcerpt(fileLines, excerptLines []string, contentHash string, hint int) *AttachmentLocation {
+	n := len(excerptLines)
+	location := &AttachmentLocation{}
+	if n == 0 || n > len(fileLines) {
+		return location
+	}
+
+	found := func(start int, score float64, exact bool) *AttachmentLocation {
+		location.Found = true
+		location.Exact = exact
+		location.Score = score
+		location.StartLine = start + 1
+		location.EndLine = start + n
+		return location
+	}
+
+	// Windows whose first line differs can't match, so only those that
+	// agree on it are hashed
+	matchesAt := func(start int) bool {
+		return fileLines[start] == excerptLines[0] && hashLines(fileLines[start:start+n]) == contentHash
+	}
+
+	distance := func(start int) int {
+		if start > hint {
+			return start - hint
+		}
+		return hint - start
+	}
+
+	// Unchanged at the recorded position
+	last := len(fileLines) - n
+	if hint >= 0 && hint <= last && matchesAt(hint) {
+		return found(hint, 1, true)
+	}
+
+	// Exact match elsewhere, nearest to the recorded position
+	best := -1
+	for start := 0; start <= last; start++ {
+		if matchesAt(start) && (best == -1 || distance(start) < distance(best)) {
+			best = start
+		}
+	}
+	if best != -1 {
+		return found(best, 1, true)
+	}
+
+	// Fuzzy match by trimmed lines, narrowed around the hint for big searches
+	trimmed := make([]string, n)
+	for i, line := range excerptLines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	from, to := 0, last
+	if (last+1)*n > maxFuzzyWork {
+		radius := maxFuzzyWork / n / 2
+		from, to = max(0, hint-radius), min(last, hint+radius)
+	}
+
+	bestScore := 0.0
+	for start := from; start <= to; start++ {
+		matched := 0
+		for i := range trimmed {
+			if trimmed[i] != "" && strings.TrimSpace(fileLines[start+i]) == trimmed[i] {
+				matched++
+			}
+		}
+		score := float64(matched) / float64(n)
+		if score > bestScore || (score == bestScore && best != -1 && distance(start) < distance(best)) {
+			best, bestScore = start, score
+		}
+	}
+	if best != -1 && bestScore >= minLocateScore {
+		return found(best, bestScore, false)
+	}
+
+	location.Score = bestScore
+	return location
+}
+
+// readFileExcerpt reads a whole file as an attachment excerpt
+func readFileExcerpt(filePath string) (string, int, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if stat.Size() > maxAttachmentBytes {
+		return "", 0, fmt.Errorf("%s is larger than %s; attach a selection instead", stat.Name(), formatBytes(maxAttachmentBytes))
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	content := string(data)
+	return content, len(splitExcerptLines(content)), nil
+}
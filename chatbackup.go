@@ -0,0 +1,497 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup reasons recorded in backup file names
+const (
+	BackupScheduled       = "scheduled"
+	BackupManual          = "manual"
+	BackupBeforeDeleteAll = "before-delete-all"
+	BackupBeforeDelete    = "before-delete"
+	BackupBeforeRetention = "before-retention"
+	BackupBeforeRestore   = "before-restore"
+	BackupAfterEncryption = "after-encryption"
+)
+
+// Retention actions
+const (
+	RetentionArchive = "archive"
+	RetentionDelete  = "delete"
+)
+
+// backupTimeFormat is the timestamp embedded in backup file names. The
+// fraction keeps backups taken within the same second apart.
+const backupTimeFormat = "20060102-150405.000000000"
+
+// legacyBackupTimeFormat is the whole-second timestamp of older backups
+const legacyBackupTimeFormat = "20060102-150405"
+
+// BackupInfo describes a backup file in the backups directory
+type BackupInfo struct {
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	CreatedAt string `json:"createdAt"`
+	Size      int64  `json:"size"`
+}
+
+// RetentionPolicy decides which chats are archived or deleted automatically
+type RetentionPolicy struct {
+	MaxAgeDays int    `json:"maxAgeDays"` // 0 disables the age rule
+	MaxChats   int    `json:"maxChats"`   // 0 disables the count rule
+	Action     string `json:"action"`     // RetentionArchive or RetentionDelete
+}
+
+// enabled reports whether the policy would ever select a chat
+func (p RetentionPolicy) enabled() bool {
+	return p.MaxAgeDays > 0 || p.MaxChats > 0
+}
+
+// RetentionResult lists the chats a retention pass affected, or would affect
+type RetentionResult struct {
+	Action string `json:"action"`
+	DryRun bool   `json:"dryRun"`
+	Chats  []Chat `json:"chats"`
+	Backup string `json:"backup,omitempty"`
+}
+
+// backupDir returns the directory holding chat history backups
+func (c *ChatDB) backupDir() string {
+	return filepath.Join(filepath.Dir(c.path), "backups")
+}
+
+// SetBackupPolicy sets the scheduled backup interval and how many backups to keep.
+// A zero interval disables scheduled backups.
+func (c *ChatDB) SetBackupPolicy(interval time.Duration, maxBackups int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.backupInterval = interval
+	c.maxBackups = maxBackups
+}
+
+// SetRetentionPolicy sets the rules applied by the scheduled retention pass
+func (c *ChatDB) SetRetentionPolicy(policy RetentionPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.retention = policy
+}
+
+// CreateBackup writes an online snapshot of the database to the backups
+// directory and prunes old backups beyond the configured count
+func (c *ChatDB) CreateBackup(reason string) (*BackupInfo, error) {
+	return c.createBackup(reason, true)
+}
+
+// createBackup writes a snapshot, pruning old backups only if prune is set
+func (c *ChatDB) createBackup(reason string, prune bool) (*BackupInfo, error) {
+	dir := c.backupDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	name, createdAt, err := reserveBackupName(dir, time.Now(), reason)
+	if err != nil {
+		return nil, err
+	}
+	finalPath := filepath.Join(dir, name)
+	tmpPath := finalPath + ".tmp"
+
+	dest, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %v", err)
+	}
+	err = sqliteBackup(dest, c.db)
+	dest.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to save backup: %v", err)
+	}
+	if err := c.setMeta("last_backup", createdAt.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	if prune {
+		c.mu.RLock()
+		maxBackups := c.maxBackups
+		c.mu.RUnlock()
+		if err := c.pruneBackups(maxBackups); err != nil {
+			fmt.Printf("Failed to prune chat backups: %v\n", err)
+		}
+	}
+
+	stat, err := os.Stat(finalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %v", err)
+	}
+	return &BackupInfo{
+		Name:      name,
+		Reason:    reason,
+		CreatedAt: createdAt.Format(time.RFC3339),
+		Size:      stat.Size(),
+	}, nil
+}
+
+// reserveBackupName picks a backup name no other backup has and creates
+// its temporary file, so backups taken at the same moment can't overwrite
+// each other. A taken name moves the timestamp on by a nanosecond.
+func reserveBackupName(dir string, createdAt time.Time, reason string) (string, time.Time, error) {
+	for {
+		name := fmt.Sprintf("chat_history-%s-%s.db", createdAt.Format(backupTimeFormat), reason)
+		tmpPath := filepath.Join(dir, name) + ".tmp"
+
+		file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
+			if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+				return name, createdAt, nil
+			}
+			os.Remove(tmpPath)
+		} else if !os.IsExist(err) {
+			return "", time.Time{}, fmt.Errorf("failed to create backup file: %v", err)
+		}
+		createdAt = createdAt.Add(time.Nanosecond)
+	}
+}
+
+// parseBackupName extracts the timestamp and reason from a backup file name
+func parseBackupName(name string) (time.Time, string, bool) {
+	if !strings.HasPrefix(name, "chat_history-") || !strings.HasSuffix(name, ".db") {
+		return time.Time{}, "", false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(name, "chat_history-"), ".db")
+
+	layout := backupTimeFormat
+	if len(rest) > len(legacyBackupTimeFormat) && rest[len(legacyBackupTimeFormat)] == '-' {
+		layout = legacyBackupTimeFormat
+	}
+	if len(rest) < len(layout)+2 || rest[len(layout)] != '-' {
+		return time.Time{}, "", false
+	}
+
+	createdAt, err := time.ParseInLocation(layout, rest[:len(layout)], time.Local)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return createdAt, rest[len(layout)+1:], true
+}
+
+// ListBackups returns the available backups, newest first
+func (c *ChatDB) ListBackups() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(c.backupDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []BackupInfo{}, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %v", err)
+	}
+
+	backups := make([]BackupInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		createdAt, reason, ok := parseBackupName(entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:      entry.Name(),
+			Reason:    reason,
+			CreatedAt: createdAt.Format(time.RFC3339),
+			Size:      info.Size(),
+		})
+	}
+
+	// Names embed a sortable timestamp
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// LatestBackup returns the newest backup, or nil if there are none
+func (c *ChatDB) LatestBackup() *BackupInfo {
+	backups, err := c.ListBackups()
+	if err != nil || len(backups) == 0 {
+		return nil
+	}
+	return &backups[0]
+}
+
+// pruneBackups deletes the oldest backups beyond keep. keep <= 0 keeps everything.
+func (c *ChatDB) pruneBackups(keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := c.ListBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(c.backupDir(), backup.Name)); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %v", backup.Name, err)
+		}
+	}
+	return nil
+}
+
+// RestoreBackup replaces the live database with a backup. The current state
+// is snapshotted first so a restore can itself be undone.
+func (c *ChatDB) RestoreBackup(name string) error {
+	if name != filepath.Base(name) {
+		return fmt.Errorf("invalid backup name")
+	}
+	if _, _, ok := parseBackupName(name); !ok {
+		return fmt.Errorf("invalid backup name")
+	}
+	backupPath := filepath.Join(c.backupDir(), name)
+	if _, err := os.Stat(backupPath); err != nil {
+		return fmt.Errorf("backup not found: %v", err)
+	}
+
+	src, err := sql.Open("sqlite3", backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer src.Close()
+
+	// Refuse to restore a damaged backup over the live database
+	var check string
+	if err := src.QueryRow("PRAGMA integrity_check").Scan(&check); err != nil {
+		return fmt.Errorf("failed to check backup: %v", err)
+	}
+	if check != "ok" {
+		return fmt.Errorf("backup is damaged: %s", check)
+	}
+
+	// Don't prune here, or the backup being restored could be removed
+	if _, err := c.createBackup(BackupBeforeRestore, false); err != nil {
+		// A damaged live database may not be readable; restore anyway
+		fmt.Printf("Failed to snapshot chat history before restore: %v\n", err)
+	}
+
+	if err := sqliteBackup(c.db, src); err != nil {
+		return err
+	}
+
+	// The restored file may use a different passphrase or an older schema
+	c.Lock()
+	return c.initSchema()
+}
+
+// retentionCandidates returns the unarchived chats selected by a policy
+func (c *ChatDB) retentionCandidates(policy RetentionPolicy) ([]Chat, error) {
+	if !policy.enabled() {
+		return []Chat{}, nil
+	}
+
+	rows, err := c.db.Query(
+		`SELECT id, title, model_name, created_at, updated_at,
+			updated_at < datetime('now', ?)
+		FROM chats
+		WHERE archived = 0
+		ORDER BY updated_at DESC, id DESC`,
+		fmt.Sprintf("-%d days", policy.MaxAgeDays),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chats: %v", err)
+	}
+	defer rows.Close()
+
+	chats := []Chat{}
+	for i := 0; rows.Next(); i++ {
+		var chat Chat
+		var expired bool
+		err := rows.Scan(&chat.ID, &chat.Title, &chat.ModelName, &chat.CreatedAt, &chat.UpdatedAt, &expired)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chat: %v", err)
+		}
+
+		tooOld := policy.MaxAgeDays > 0 && expired
+		overLimit := policy.MaxChats > 0 && i >= policy.MaxChats
+		if !tooOld && !overLimit {
+			continue
+		}
+		if err := c.openChat(&chat); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	return chats, nil
+}
+
+// ApplyRetention archives or deletes the chats selected by policy. With
+// dryRun set it only reports what would change.
+func (c *ChatDB) ApplyRetention(policy RetentionPolicy, dryRun bool) (*RetentionResult, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+	if policy.Action != RetentionArchive && policy.Action != RetentionDelete {
+		return nil, fmt.Errorf("unknown retention action %q", policy.Action)
+	}
+
+	chats, err := c.retentionCandidates(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RetentionResult{Action: policy.Action, DryRun: dryRun, Chats: chats}
+	if dryRun || len(chats) == 0 {
+		return result, nil
+	}
+
+	backup, err := c.CreateBackup(BackupBeforeRetention)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to apply retention without a backup: %v", err)
+	}
+	result.Backup = backup.Name
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE chats SET archived = 1 WHERE id = ?"
+	if policy.Action == RetentionDelete {
+		query = "DELETE FROM chats WHERE id = ?"
+	}
+	for _, chat := range chats {
+		if _, err := tx.Exec(query, chat.ID); err != nil {
+			return nil, fmt.Errorf("failed to %s chat: %v", policy.Action, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit retention: %v", err)
+	}
+
+	return result, nil
+}
+
+// GetArchivedChats retrieves chats hidden by retention, most recent first
+func (c *ChatDB) GetArchivedChats() ([]Chat, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.Query(
+		"SELECT id, title, model_name, created_at, updated_at FROM chats WHERE archived = 1 ORDER BY updated_at DESC",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archived chats: %v", err)
+	}
+	defer rows.Close()
+
+	chats := []Chat{}
+	for rows.Next() {
+		var chat Chat
+		err := rows.Scan(&chat.ID, &chat.Title, &chat.ModelName, &chat.CreatedAt, &chat.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chat: %v", err)
+		}
+		if err := c.openChat(&chat); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	return chats, nil
+}
+
+// UnarchiveChat returns an archived chat to the chat list
+func (c *ChatDB) UnarchiveChat(id int64) error {
+	if err := c.ensureUnlocked(); err != nil {
+		return err
+	}
+
+	_, err := c.db.Exec("UPDATE chats SET archived = 0 WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to unarchive chat: %v", err)
+	}
+	return nil
+}
+
+// StartScheduler takes scheduled backups and applies the retention policy
+// once a day, calling the callbacks after each. It stops when the database
+// is closed. Retention is skipped while encrypted history is locked.
+func (c *ChatDB) StartScheduler(onBackup func(*BackupInfo), onRetention func(*RetentionResult)) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			c.runScheduledTasks(onBackup, onRetention)
+
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runScheduledTasks performs whichever scheduled tasks are due
+func (c *ChatDB) runScheduledTasks(onBackup func(*BackupInfo), onRetention func(*RetentionResult)) {
+	c.mu.RLock()
+	interval := c.backupInterval
+	policy := c.retention
+	locked := c.encrypted && c.cipher == nil
+	c.mu.RUnlock()
+
+	if interval > 0 && c.isDue("last_backup", interval) {
+		backup, err := c.CreateBackup(BackupScheduled)
+		if err != nil {
+			fmt.Printf("Scheduled chat backup failed: %v\n", err)
+		} else if onBackup != nil {
+			onBackup(backup)
+		}
+	}
+
+	if policy.enabled() && !locked && c.isDue("last_retention", 24*time.Hour) {
+		result, err := c.ApplyRetention(policy, false)
+		if err != nil {
+			fmt.Printf("Chat retention failed: %v\n", err)
+			return
+		}
+		if err := c.setMeta("last_retention", time.Now().Format(time.RFC3339)); err != nil {
+			fmt.Printf("Chat retention failed: %v\n", err)
+		}
+		if len(result.Chats) > 0 && onRetention != nil {
+			onRetention(result)
+		}
+	}
+}
+
+// isDue reports whether the time stored under key is at least interval ago
+func (c *ChatDB) isDue(key string, interval time.Duration) bool {
+	last, err := c.getMeta(key)
+	if err != nil || last == "" {
+		return err == nil
+	}
+	lastRun, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		return true
+	}
+	return time.Since(lastRun) >= interval
+}
@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// sqliteBackup needs the cgo build of go-sqlite3 for the online backup API
+func sqliteBackup(dest, src *sql.DB) error {
+	return fmt.Errorf("chat backups require a cgo build")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBackupName(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantTime   time.Time
+		wantReason string
+		wantOK     bool
+	}{
+		{"chat_history-20260102-030405.123456789-manual.db", time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.Local), "manual", true},
+		{"chat_history-20260102-030405.000000000-before-delete-all.db", time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local), "before-delete-all", true},
+		{"chat_history-20260102-030405-scheduled.db", time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local), "scheduled", true}, // before sub-second names
+		{"chat_history-20260102-030405.123456789-manual.db.tmp", time.Time{}, "", false},
+		{"chat_history-20260102-030405.db", time.Time{}, "", false},
+		{"chat_history-2026-manual.db", time.Time{}, "", false},
+		{"other.db", time.Time{}, "", false},
+	}
+
+	for _, tt := range tests {
+		createdAt, reason, ok := parseBackupName(tt.name)
+		if ok != tt.wantOK || reason != tt.wantReason || !createdAt.Equal(tt.wantTime) {
+			t.Errorf("parseBackupName(%q) = %v, %q, %v, want %v, %q, %v", tt.name, createdAt, reason, ok, tt.wantTime, tt.wantReason, tt.wantOK)
+		}
+	}
+}
+
+func TestReserveBackupName(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		name, _, err := reserveBackupName(dir, now, BackupManual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[name] {
+			t.Fatalf("backup name %s was handed out twice", name)
+		}
+		seen[name] = true
+		if _, reason, ok := parseBackupName(name); !ok || reason != BackupManual {
+			t.Errorf("reserved name %s doesn't parse back", name)
+		}
+	}
+}
+
+func TestBackupsInTheSameSecond(t *testing.T) {
+	c := newTestChatDB(t)
+	if _, err := c.CreateChat("first", "mistral"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.CreateBackup(BackupManual); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chat, err := c.CreateChat("second", "mistral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteChat(chat.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := c.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 4 {
+		t.Fatalf("got %d backups, want 4", len(backups))
+	}
+	if backups[0].Reason != BackupBeforeDelete {
+		t.Errorf("newest backup is for %q, want %q", backups[0].Reason, BackupBeforeDelete)
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrChatLocked is returned by chat APIs while encrypted history is locked
+var ErrChatLocked = errors.New("chat history is locked")
+
+// Ciphertext stored in a TEXT column is prefixed so it can't be mistaken for plaintext
+const encryptedPrefix = "enc1:"
+
+// verifierPlaintext is sealed with the derived key to check passphrases on unlock
+const verifierPlaintext = "akashic-chat-history"
+
+// kdfParams holds the Argon2id parameters used to derive the history key
+type kdfParams struct {
+	Salt      []byte
+	TimeCost  uint32
+	MemoryKiB uint32
+	Threads   uint8
+}
+
+// defaultKDFParams returns fresh Argon2id parameters with a random salt
+func defaultKDFParams() (kdfParams, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return kdfParams{}, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	return kdfParams{
+		Salt:      salt,
+		TimeCost:  3,
+		MemoryKiB: 64 * 1024,
+		Threads:   4,
+	}, nil
+}
+
+// chatCipher seals and opens individual column values with AES-256-GCM
+type chatCipher struct {
+	key  []byte
+	aead cipher.AEAD
+}
+
+// newChatCipher derives a key from the passphrase and builds the AEAD
+func newChatCipher(passphrase string, params kdfParams) (*chatCipher, error) {
+	key := argon2.IDKey([]byte(passphrase), params.Salt, params.TimeCost, params.MemoryKiB, params.Threads, 32)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+
+	return &chatCipher{key: key, aead: aead}, nil
+}
+
+// seal encrypts a value with a random nonce
+func (cc *chatCipher) seal(plaintext string) (string, error) {
+	nonce := make([]byte, cc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := cc.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value produced by seal
+func (cc *chatCipher) open(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %v", err)
+	}
+
+	nonceSize := cc.aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plaintext, err := cc.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// wipe zeroes the key and drops the AEAD. This is best effort: the AES key
+// schedule inside the AEAD and any copies the runtime made can't be cleared,
+// and are only freed once garbage collected.
+func (cc *chatCipher) wipe() {
+	for i := range cc.key {
+		cc.key[i] = 0
+	}
+	cc.aead = nil
+}
+
+// ChatEncryptionStatus describes whether history is encrypted and unlocked
+type ChatEncryptionStatus struct {
+	Enabled         bool `json:"enabled"`
+	Locked          bool `json:"locked"`
+	AutoLockMinutes int  `json:"autoLockMinutes"`
+}
+
+// initEncryptionTable creates the table holding KDF parameters and the verifier
+func (c *ChatDB) initEncryptionTable() error {
+	_, err := c.db.Exec(`
+		CREATE TABLE IF NOT EXISTS encryption_meta (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			salt BLOB NOT NULL,
+			time_cost INTEGER NOT NULL,
+			memory_kib INTEGER NOT NULL,
+			threads INTEGER NOT NULL,
+			verifier TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create encryption table: %v", err)
+	}
+
+	var count int
+	if err := c.db.QueryRow("SELECT COUNT(*) FROM encryption_meta").Scan(&count); err != nil {
+		return fmt.Errorf("failed to read encryption state: %v", err)
+	}
+	c.mu.Lock()
+	c.encrypted = count > 0
+	c.mu.Unlock()
+
+	return nil
+}
+
+// loadKDFParams reads the stored KDF parameters and verifier
+func (c *ChatDB) loadKDFParams() (kdfParams, string, error) {
+	var params kdfParams
+	var verifier string
+	err := c.db.QueryRow(
+		"SELECT salt, time_cost, memory_kib, threads, verifier FROM encryption_meta WHERE id = 1",
+	).Scan(&params.Salt, &params.TimeCost, &params.MemoryKiB, &params.Threads, &verifier)
+	if err != nil {
+		return kdfParams{}, "", fmt.Errorf("failed to read encryption settings: %v", err)
+	}
+	return params, verifier, nil
+}
+
+// unlockCipher derives the key from a passphrase and checks it against the verifier
+func (c *ChatDB) unlockCipher(passphrase string) (*chatCipher, error) {
+	params, verifier, err := c.loadKDFParams()
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := newChatCipher(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := cc.open(verifier)
+	if err != nil || subtle.ConstantTimeCompare([]byte(plaintext), []byte(verifierPlaintext)) != 1 {
+		cc.wipe()
+		return nil, fmt.Errorf("incorrect passphrase")
+	}
+	return cc, nil
+}
+
+// ensureUnlocked fails with ErrChatLocked while encrypted history is locked
+// and records activity for the idle auto-lock
+func (c *ChatDB) ensureUnlocked() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.encrypted && c.cipher == nil {
+		return ErrChatLocked
+	}
+	c.lastActivity = time.Now()
+	return nil
+}
+
+// withSealed runs write with a function that encrypts values for storage
+// when encryption is enabled. The read lock is held until write returns, so
+// enabling, disabling or changing the passphrase can't commit between
+// sealing a value and storing it. write must not take c.mu.
+func (c *ChatDB) withSealed(write func(seal func(string) (string, error)) error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	seal := passthrough
+	if c.encrypted {
+		if c.cipher == nil {
+			return ErrChatLocked
+		}
+		seal = c.cipher.seal
+	}
+	return write(seal)
+}
+
+// openText decrypts a stored value when encryption is enabled
+func (c *ChatDB) openText(value string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.encrypted {
+		return value, nil
+	}
+	if c.cipher == nil {
+		return "", ErrChatLocked
+	}
+	return c.cipher.open(value)
+}
+
+// openChat decrypts a chat's title in place
+func (c *ChatDB) openChat(chat *Chat) error {
+	title, err := c.openText(chat.Title)
+	if err != nil {
+		return err
+	}
+	chat.Title = title
+	return nil
+}
+
+// openMessage decrypts a message's content in place
+func (c *ChatDB) openMessage(msg *Message) error {
+	content, err := c.openText(msg.Content)
+	if err != nil {
+		return err
+	}
+	msg.Content = content
+	return nil
+}
+
+// EncryptionStatus reports the current encryption state
+func (c *ChatDB) EncryptionStatus() ChatEncryptionStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return ChatEncryptionStatus{
+		Enabled:         c.encrypted,
+		Locked:          c.encrypted && c.cipher == nil,
+		AutoLockMinutes: int(c.autoLockAfter / time.Minute),
+	}
+}
+
+// EnableEncryption encrypts all existing titles and messages with a key
+// derived from the passphrase and leaves the history unlocked. Backups
+// taken before then hold plaintext, so they are replaced with a fresh
+// encrypted one.
+func (c *ChatDB) EnableEncryption(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase must not be empty")
+	}
+
+	if err := c.enableEncryption(passphrase); err != nil {
+		return err
+	}
+	return c.replacePlaintextBackups()
+}
+
+// enableEncryption re-encrypts the history and scrubs the plaintext it replaced
+func (c *ChatDB) enableEncryption(passphrase string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.encrypted {
+		return fmt.Errorf("chat history is already encrypted")
+	}
+
+	cc, err := c.setPassphrase(nil, passphrase)
+	if err != nil {
+		return err
+	}
+
+	c.encrypted = true
+	c.cipher = cc
+	c.lastActivity = time.Now()
+	return c.scrubFreePages()
+}
+
+// scrubFreePages rebuilds the database file and empties the write-ahead
+// log, so values overwritten by a re-encryption can't be recovered from
+// free pages or old log frames
+func (c *ChatDB) scrubFreePages() error {
+	if _, err := c.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %v", err)
+	}
+	if _, err := c.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %v", err)
+	}
+	return nil
+}
+
+// replacePlaintextBackups deletes every existing backup and takes a new,
+// encrypted one
+func (c *ChatDB) replacePlaintextBackups() error {
+	backups, err := c.ListBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if err := os.Remove(filepath.Join(c.backupDir(), backup.Name)); err != nil {
+			return fmt.Errorf("failed to remove plaintext backup %s: %v", backup.Name, err)
+		}
+	}
+	if len(backups) > 0 {
+		fmt.Printf("Removed %d chat backups made before encryption was enabled\n", len(backups))
+	}
+
+	if _, err := c.createBackup(BackupAfterEncryption, false); err != nil {
+		return fmt.Errorf("failed to back up encrypted history: %v", err)
+	}
+	return nil
+}
+
+// DisableEncryption decrypts all history back to plaintext
+func (c *ChatDB) DisableEncryption(passphrase string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.encrypted {
+		return nil
+	}
+
+	current, err := c.unlockCipher(passphrase)
+	if err != nil {
+		return err
+	}
+	defer current.wipe()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := reencryptRows(tx, current.open, passthrough); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM encryption_meta"); err != nil {
+		return fmt.Errorf("failed to clear encryption settings: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit decryption: %v", err)
+	}
+
+	c.dropCipher()
+	c.encrypted = false
+	return nil
+}
+
+// ChangePassphrase re-encrypts all history under a new passphrase
+func (c *ChatDB) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if newPassphrase == "" {
+		return fmt.Errorf("passphrase must not be empty")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.encrypted {
+		return fmt.Errorf("chat history is not encrypted")
+	}
+
+	current, err := c.unlockCipher(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	defer current.wipe()
+
+	cc, err := c.setPassphrase(current, newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	c.dropCipher()
+	c.cipher = cc
+	c.lastActivity = time.Now()
+	return c.scrubFreePages()
+}
+
+// setPassphrase stores new KDF parameters and re-encrypts every row from
+// the current cipher (nil for plaintext) to one derived from passphrase.
+// The caller must hold c.mu.
+func (c *ChatDB) setPassphrase(current *chatCipher, passphrase string) (*chatCipher, error) {
+	params, err := defaultKDFParams()
+	if err != nil {
+		return nil, err
+	}
+	next, err := newChatCipher(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := next.seal(verifierPlaintext)
+	if err != nil {
+		next.wipe()
+		return nil, err
+	}
+
+	decode := passthrough
+	if current != nil {
+		decode = current.open
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		next.wipe()
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := reencryptRows(tx, decode, next.seal); err != nil {
+		next.wipe()
+		return nil, err
+	}
+	_, err = tx.Exec(
+		`INSERT OR REPLACE INTO encryption_meta (id, salt, time_cost, memory_kib, threads, verifier)
+		VALUES (1, ?, ?, ?, ?, ?)`,
+		params.Salt, params.TimeCost, params.MemoryKiB, params.Threads, verifier,
+	)
+	if err != nil {
+		next.wipe()
+		return nil, fmt.Errorf("failed to save encryption settings: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		next.wipe()
+		return nil, fmt.Errorf("failed to commit re-encryption: %v", err)
+	}
+
+	return next, nil
+}
+
+// passthrough leaves a value unchanged, standing in for a plaintext cipher
+func passthrough(value string) (string, error) {
+	return value, nil
+}
+
+// reencryptRows rewrites every chat title and message body through decode then encode
+func reencryptRows(tx *sql.Tx, decode, encode func(string) (string, error)) error {
+	type row struct {
+		id    int64
+		value string
+	}
+
+	rewrite := func(selectQuery, updateQuery string) error {
+		rows, err := tx.Query(selectQuery)
+		if err != nil {
+			return fmt.Errorf("failed to read rows for re-encryption: %v", err)
+		}
+		var pending []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.value); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan row for re-encryption: %v", err)
+			}
+			pending = append(pending, r)
+		}
+		rows.Close()
+
+		for _, r := range pending {
+			plaintext, err := decode(r.value)
+			if err != nil {
+				return err
+			}
+			value, err := encode(plaintext)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(updateQuery, value, r.id); err != nil {
+				return fmt.Errorf("failed to write re-encrypted row: %v", err)
+			}
+		}
+		return nil
+	}
+
+	if err := rewrite("SELECT id, title FROM chats", "UPDATE chats SET title = ? WHERE id = ?"); err != nil {
+		return err
+	}
+	if err := rewrite("SELECT id, content FROM messages", "UPDATE messages SET content = ? WHERE id = ?"); err != nil {
+		return err
+	}
+	if err := rewrite("SELECT id, file_path FROM message_attachments", "UPDATE message_attachments SET file_path = ? WHERE id = ?"); err != nil {
+		return err
+	}
+	return rewrite("SELECT id, excerpt FROM message_attachments", "UPDATE message_attachments SET excerpt = ? WHERE id = ?")
+}
+
+// Unlock derives the key from the passphrase and unlocks the history
+func (c *ChatDB) Unlock(passphrase string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.encrypted {
+		return nil
+	}
+	if c.cipher != nil {
+		c.lastActivity = time.Now()
+		return nil
+	}
+
+	cc, err := c.unlockCipher(passphrase)
+	if err != nil {
+		return err
+	}
+	c.cipher = cc
+	c.lastActivity = time.Now()
+	return nil
+}
+
+// Lock discards the key so chat APIs return ErrChatLocked until unlocked
+func (c *ChatDB) Lock() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropCipher()
+}
+
+// dropCipher wipes and forgets the key. The caller must hold c.mu.
+func (c *ChatDB) dropCipher() {
+	if c.cipher != nil {
+		c.cipher.wipe()
+		c.cipher = nil
+	}
+}
+
+// SetAutoLockTimeout sets how long history may sit idle before it locks itself.
+// Zero disables auto-lock.
+func (c *ChatDB) SetAutoLockTimeout(idle time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.autoLockAfter = idle
+}
+
+// StartAutoLock watches for idle unlocked history and locks it, calling
+// onLock after each automatic lock. It stops when the database is closed.
+func (c *ChatDB) StartAutoLock(onLock func()) {
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				c.mu.Lock()
+				idle := c.autoLockAfter > 0 && c.cipher != nil && time.Since(c.lastActivity) >= c.autoLockAfter
+				if idle {
+					c.dropCipher()
+				}
+				c.mu.Unlock()
+
+				if idle && onLock != nil {
+					onLock()
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestChatDB opens a chat database in a temporary home directory
+func newTestChatDB(t *testing.T) *ChatDB {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	c, err := NewChatDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// rawValues returns every stored title, message body, attachment path and
+// excerpt as they are on disk
+func rawValues(t *testing.T, c *ChatDB) []string {
+	t.Helper()
+	var values []string
+	for _, query := range []string{
+		"SELECT title FROM chats",
+		"SELECT content FROM messages",
+		"SELECT file_path FROM message_attachments",
+		"SELECT excerpt FROM message_attachments",
+	} {
+		rows, err := c.db.Query(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, value)
+		}
+		rows.Close()
+	}
+	return values
+}
+
+// addTestHistory stores a chat with a message and an attachment
+func addTestHistory(t *testing.T, c *ChatDB, secret string) int64 {
+	t.Helper()
+	chat, err := c.CreateChat("title "+secret, "mistral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := c.AddMessage(chat.ID, "user", "message "+secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddAttachment(msg.ID, filepath.Join("notes", secret+".txt"), 1, 1, "excerpt "+secret); err != nil {
+		t.Fatal(err)
+	}
+	return chat.ID
+}
+
+func TestChatEncryptionLockUnlock(t *testing.T) {
+	c := newTestChatDB(t)
+	chatID := addTestHistory(t, c, "alpha")
+
+	if err := c.EnableEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if status := c.EncryptionStatus(); !status.Enabled || status.Locked {
+		t.Fatalf("after enabling, status = %+v", status)
+	}
+	for _, value := range rawValues(t, c) {
+		if !strings.HasPrefix(value, encryptedPrefix) {
+			t.Errorf("stored value %q is not encrypted", value)
+		}
+	}
+
+	// New rows are sealed too, and everything reads back as plaintext
+	if _, err := c.AddMessage(chatID, "assistant", "reply alpha"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := c.GetChatMessages(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Content != "message alpha" || messages[1].Content != "reply alpha" {
+		t.Fatalf("messages = %+v", messages)
+	}
+
+	c.Lock()
+	if status := c.EncryptionStatus(); !status.Locked {
+		t.Fatalf("after locking, status = %+v", status)
+	}
+	if _, err := c.GetChat(chatID); !errors.Is(err, ErrChatLocked) {
+		t.Errorf("GetChat while locked = %v, want ErrChatLocked", err)
+	}
+	if _, err := c.AddMessage(chatID, "user", "more"); !errors.Is(err, ErrChatLocked) {
+		t.Errorf("AddMessage while locked = %v, want ErrChatLocked", err)
+	}
+
+	if err := c.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	chat, err := c.GetChat(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chat.Title != "title alpha" {
+		t.Errorf("title after unlock = %q", chat.Title)
+	}
+
+	// Turning encryption off leaves plaintext behind
+	if err := c.DisableEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range rawValues(t, c) {
+		if strings.HasPrefix(value, encryptedPrefix) {
+			t.Errorf("stored value %q is still encrypted", value)
+		}
+	}
+}
+
+func TestChatEncryptionWrongPassphrase(t *testing.T) {
+	c := newTestChatDB(t)
+	chatID := addTestHistory(t, c, "alpha")
+	if err := c.EnableEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	c.Lock()
+
+	if err := c.Unlock("battery staple"); err == nil {
+		t.Fatal("Unlock accepted the wrong passphrase")
+	}
+	if status := c.EncryptionStatus(); !status.Locked {
+		t.Errorf("after a wrong passphrase, status = %+v", status)
+	}
+	if _, err := c.GetChat(chatID); !errors.Is(err, ErrChatLocked) {
+		t.Errorf("GetChat after a wrong passphrase = %v, want ErrChatLocked", err)
+	}
+	if err := c.ChangePassphrase("battery staple", "new"); err == nil {
+		t.Error("ChangePassphrase accepted the wrong passphrase")
+	}
+	if err := c.DisableEncryption("battery staple"); err == nil {
+		t.Error("DisableEncryption accepted the wrong passphrase")
+	}
+}
+
+func TestChatEncryptionChangePassphrase(t *testing.T) {
+	c := newTestChatDB(t)
+	chatID := addTestHistory(t, c, "alpha")
+	addTestHistory(t, c, "beta")
+	if err := c.EnableEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	before := rawValues(t, c)
+	_, verifierBefore, err := c.loadKDFParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ChangePassphrase("correct horse", "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	after := rawValues(t, c)
+	if len(after) != len(before) {
+		t.Fatalf("%d values before the change, %d after", len(before), len(after))
+	}
+	for i := range after {
+		if !strings.HasPrefix(after[i], encryptedPrefix) || after[i] == before[i] {
+			t.Errorf("value %d was not re-encrypted: %q", i, after[i])
+		}
+	}
+	if _, verifier, _ := c.loadKDFParams(); verifier == verifierBefore {
+		t.Error("the verifier was not replaced")
+	}
+
+	c.Lock()
+	if err := c.Unlock("correct horse"); err == nil {
+		t.Error("Unlock accepted the old passphrase")
+	}
+	if err := c.Unlock("battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := c.GetChatMessages(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Content != "message alpha" {
+		t.Errorf("messages after the change = %+v", messages)
+	}
+}
+
+func TestChatEncryptionLeavesNoPlaintext(t *testing.T) {
+	c := newTestChatDB(t)
+	const secret = "zanzibar-7c1f"
+	for i := 0; i < 20; i++ {
+		addTestHistory(t, c, secret)
+	}
+	if _, err := c.CreateBackup(BackupManual); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.EnableEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []string{c.path, c.path + "-wal"}
+	backups, err := filepath.Glob(filepath.Join(c.backupDir(), "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) == 0 {
+		t.Error("no backup was taken after encrypting")
+	}
+	files = append(files, backups...)
+
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("%s still holds plaintext", filepath.Base(name))
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -24,12 +27,76 @@ type Message struct {
 	ChatID    int64  `json:"chatId"`
 	Role      string `json:"role"` // "user" or "assistant"
 	Content   string `json:"content"`
+	Status    string `json:"status"` // see MessageStatusComplete and friends
+	Error     string `json:"error,omitempty"`
 	CreatedAt string `json:"createdAt"`
 }
 
+// Page size limits for paginated queries
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// ChatCursor marks a position in the chat list, which is ordered by
+// updated_at then id, both descending. The zero value starts at the newest chat.
+// UpdatedAt is passed through datetime() since the driver hands timestamps
+// back as RFC 3339 rather than SQLite's stored format.
+type ChatCursor struct {
+	UpdatedAt string `json:"updatedAt"`
+	ID        int64  `json:"id"`
+}
+
+// ChatPage is one page of chats plus the cursor for the next page
+type ChatPage struct {
+	Chats      []Chat     `json:"chats"`
+	NextCursor ChatCursor `json:"nextCursor"`
+	HasMore    bool       `json:"hasMore"`
+}
+
+// MessagePage is one page of messages in chronological order.
+// BeforeID is the cursor for loading the next (older) page.
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	BeforeID int64     `json:"beforeId"`
+	HasMore  bool      `json:"hasMore"`
+}
+
+// clampPageSize keeps a requested page size within sane bounds
+func clampPageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
+
+// chatDBOptions are the go-sqlite3 connection parameters applied to every connection.
+// secure_delete zeroes deleted content so replaced plaintext doesn't linger.
+const chatDBOptions = "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_secure_delete=on"
+
 // ChatDB manages the SQLite database for chat history
 type ChatDB struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
+
+	// Encryption and maintenance state, guarded by mu
+	mu            sync.RWMutex
+	encrypted     bool
+	cipher        *chatCipher
+	lastActivity  time.Time
+	autoLockAfter time.Duration
+	lastReport    *IntegrityReport
+
+	// Backup and retention policy, guarded by mu
+	backupInterval time.Duration
+	maxBackups     int
+	retention      RetentionPolicy
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // NewChatDB creates a new ChatDB instance
@@ -46,15 +113,16 @@ func NewChatDB() (*ChatDB, error) {
 		return nil, fmt.Errorf("failed to create app directory: %v", err)
 	}
 
-	// Open database
+	// Open database with foreign keys enforced (so ON DELETE CASCADE works),
+	// WAL journaling, and a busy timeout for concurrent writers
 	dbPath := filepath.Join(appDir, "chat_history.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", dbPath+chatDBOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
-	chatDB := &ChatDB{db: db}
-	if err := chatDB.initTables(); err != nil {
+	chatDB := &ChatDB{db: db, path: dbPath, done: make(chan struct{})}
+	if err := chatDB.initSchema(); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -62,6 +130,50 @@ func NewChatDB() (*ChatDB, error) {
 	return chatDB, nil
 }
 
+// initSchema creates or migrates every table and loads the encryption state.
+// It is also re-run after a backup is restored.
+func (c *ChatDB) initSchema() error {
+	if err := c.initTables(); err != nil {
+		return err
+	}
+	if err := c.initAttachmentsTable(); err != nil {
+		return err
+	}
+	if err := c.initEncryptionTable(); err != nil {
+		return err
+	}
+	return c.initMetaTable()
+}
+
+// addColumnIfMissing migrates older databases by adding a column to a table.
+// It reports whether the column was added.
+func (c *ChatDB) addColumnIfMissing(table, column, definition string) (bool, error) {
+	rows, err := c.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s columns: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan %s columns: %v", table, err)
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	rows.Close()
+
+	_, err = c.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return false, fmt.Errorf("failed to add %s.%s: %v", table, column, err)
+	}
+	return true, nil
+}
+
 // initTables creates the necessary tables
 func (c *ChatDB) initTables() error {
 	// Create chats table
@@ -101,22 +213,72 @@ func (c *ChatDB) initTables() error {
 		return fmt.Errorf("failed to create index: %v", err)
 	}
 
+	// Archived chats are hidden from the chat list by retention rules
+	if _, err := c.addColumnIfMissing("chats", "archived", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Track where a title came from so generated titles never replace a manual rename
+	added, err := c.addColumnIfMissing("chats", "title_source", "TEXT NOT NULL DEFAULT 'default'")
+	if err != nil {
+		return err
+	}
+	if added {
+		// Older chats may have been renamed by hand; protect any non-default title
+		_, err = c.db.Exec("UPDATE chats SET title_source = ? WHERE title != 'New Chat'", titleSourceManual)
+		if err != nil {
+			return fmt.Errorf("failed to migrate chat titles: %v", err)
+		}
+	}
+
+	// Streamed replies are saved while they arrive and finalized with a status
+	if _, err := c.addColumnIfMissing("messages", "status", "TEXT NOT NULL DEFAULT 'complete'"); err != nil {
+		return err
+	}
+	if _, err := c.addColumnIfMissing("messages", "error_text", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Index backing the chat list cursor
+	_, err = c.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_chats_updated_at ON chats(updated_at DESC, id DESC)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %v", err)
+	}
+
 	return nil
 }
 
 // CreateChat creates a new chat session
 func (c *ChatDB) CreateChat(title, modelName string) (*Chat, error) {
-	result, err := c.db.Exec(
-		"INSERT INTO chats (title, model_name) VALUES (?, ?)",
-		title, modelName,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create chat: %v", err)
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	var id int64
+	err := c.withSealed(func(seal func(string) (string, error)) error {
+		storedTitle, err := seal(title)
+		if err != nil {
+			return err
+		}
+
+		result, err := c.db.Exec(
+			"INSERT INTO chats (title, model_name) VALUES (?, ?)",
+			storedTitle, modelName,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create chat: %v", err)
+		}
+
+		id, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get chat ID: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get chat ID: %v", err)
+		return nil, err
 	}
 
 	return c.GetChat(id)
@@ -124,6 +286,10 @@ func (c *ChatDB) CreateChat(title, modelName string) (*Chat, error) {
 
 // GetChat retrieves a chat by ID
 func (c *ChatDB) GetChat(id int64) (*Chat, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
 	var chat Chat
 	err := c.db.QueryRow(
 		"SELECT id, title, model_name, created_at, updated_at FROM chats WHERE id = ?",
@@ -136,14 +302,21 @@ func (c *ChatDB) GetChat(id int64) (*Chat, error) {
 		}
 		return nil, fmt.Errorf("failed to get chat: %v", err)
 	}
+	if err := c.openChat(&chat); err != nil {
+		return nil, err
+	}
 
 	return &chat, nil
 }
 
-// GetAllChats retrieves all chat sessions ordered by most recent
+// GetAllChats retrieves all unarchived chat sessions ordered by most recent
 func (c *ChatDB) GetAllChats() ([]Chat, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
 	rows, err := c.db.Query(
-		"SELECT id, title, model_name, created_at, updated_at FROM chats ORDER BY updated_at DESC",
+		"SELECT id, title, model_name, created_at, updated_at FROM chats WHERE archived = 0 ORDER BY updated_at DESC",
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query chats: %v", err)
@@ -157,26 +330,143 @@ func (c *ChatDB) GetAllChats() ([]Chat, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan chat: %v", err)
 		}
+		if err := c.openChat(&chat); err != nil {
+			return nil, err
+		}
 		chats = append(chats, chat)
 	}
 
 	return chats, nil
 }
 
-// UpdateChatTitle updates the title of a chat
+// GetChatsPage retrieves one page of chats ordered by most recent,
+// starting after the given cursor
+func (c *ChatDB) GetChatsPage(cursor ChatCursor, limit int) (*ChatPage, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+	limit = clampPageSize(limit)
+
+	var rows *sql.Rows
+	var err error
+	if cursor.ID == 0 {
+		rows, err = c.db.Query(
+			`SELECT id, title, model_name, created_at, updated_at FROM chats
+			WHERE archived = 0
+			ORDER BY updated_at DESC, id DESC
+			LIMIT ?`,
+			limit+1,
+		)
+	} else {
+		rows, err = c.db.Query(
+			`SELECT id, title, model_name, created_at, updated_at FROM chats
+			WHERE archived = 0 AND (updated_at < datetime(?) OR (updated_at = datetime(?) AND id < ?))
+			ORDER BY updated_at DESC, id DESC
+			LIMIT ?`,
+			cursor.UpdatedAt, cursor.UpdatedAt, cursor.ID, limit+1,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chats: %v", err)
+	}
+	defer rows.Close()
+
+	chats := make([]Chat, 0, limit)
+	for rows.Next() {
+		var chat Chat
+		err := rows.Scan(&chat.ID, &chat.Title, &chat.ModelName, &chat.CreatedAt, &chat.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chat: %v", err)
+		}
+		if err := c.openChat(&chat); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	page := &ChatPage{Chats: chats}
+	if len(chats) > limit {
+		page.Chats = chats[:limit]
+		page.HasMore = true
+	}
+	if len(page.Chats) > 0 {
+		last := page.Chats[len(page.Chats)-1]
+		page.NextCursor = ChatCursor{UpdatedAt: last.UpdatedAt, ID: last.ID}
+	}
+
+	return page, nil
+}
+
+// UpdateChatTitle renames a chat. The title is marked manual, so generated
+// titles will no longer replace it.
 func (c *ChatDB) UpdateChatTitle(id int64, title string) error {
-	_, err := c.db.Exec(
-		"UPDATE chats SET title = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		title, id,
-	)
+	if err := c.ensureUnlocked(); err != nil {
+		return err
+	}
+
+	return c.withSealed(func(seal func(string) (string, error)) error {
+		storedTitle, err := seal(title)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.db.Exec(
+			"UPDATE chats SET title = ?, title_source = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			storedTitle, titleSourceManual, id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update chat title: %v", err)
+		}
+		return nil
+	})
+}
+
+// setGeneratedTitle sets a generated title, but only while the chat's
+// current title came from one of the replaceable sources. It reports
+// whether the title was changed.
+func (c *ChatDB) setGeneratedTitle(id int64, title, source string, replaceable ...string) (bool, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return false, err
+	}
+
+	var updated int64
+	err := c.withSealed(func(seal func(string) (string, error)) error {
+		storedTitle, err := seal(title)
+		if err != nil {
+			return err
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(replaceable)), ", ")
+		args := []interface{}{storedTitle, source, id}
+		for _, r := range replaceable {
+			args = append(args, r)
+		}
+
+		result, err := c.db.Exec(
+			fmt.Sprintf("UPDATE chats SET title = ?, title_source = ? WHERE id = ? AND title_source IN (%s)", placeholders),
+			args...,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update chat title: %v", err)
+		}
+		updated, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to update chat title: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update chat title: %v", err)
+		return false, err
 	}
-	return nil
+	return updated > 0, nil
 }
 
 // UpdateChatModel updates the model of a chat
 func (c *ChatDB) UpdateChatModel(id int64, modelName string) error {
+	if err := c.ensureUnlocked(); err != nil {
+		return err
+	}
+
 	_, err := c.db.Exec(
 		"UPDATE chats SET model_name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
 		modelName, id,
@@ -189,6 +479,13 @@ func (c *ChatDB) UpdateChatModel(id int64, modelName string) error {
 
 // DeleteChat deletes a chat and all its messages
 func (c *ChatDB) DeleteChat(id int64) error {
+	if err := c.ensureUnlocked(); err != nil {
+		return err
+	}
+	if _, err := c.CreateBackup(BackupBeforeDelete); err != nil {
+		return fmt.Errorf("refusing to delete the chat without a backup: %v", err)
+	}
+
 	_, err := c.db.Exec("DELETE FROM chats WHERE id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete chat: %v", err)
@@ -198,6 +495,13 @@ func (c *ChatDB) DeleteChat(id int64) error {
 
 // DeleteAllChats deletes all chats and messages
 func (c *ChatDB) DeleteAllChats() error {
+	if err := c.ensureUnlocked(); err != nil {
+		return err
+	}
+	if _, err := c.CreateBackup(BackupBeforeDeleteAll); err != nil {
+		return fmt.Errorf("refusing to delete chats without a backup: %v", err)
+	}
+
 	_, err := c.db.Exec("DELETE FROM chats")
 	if err != nil {
 		return fmt.Errorf("failed to delete all chats: %v", err)
@@ -207,12 +511,32 @@ func (c *ChatDB) DeleteAllChats() error {
 
 // AddMessage adds a message to a chat
 func (c *ChatDB) AddMessage(chatID int64, role, content string) (*Message, error) {
-	result, err := c.db.Exec(
-		"INSERT INTO messages (chat_id, role, content) VALUES (?, ?, ?)",
-		chatID, role, content,
-	)
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
+	var id int64
+	err := c.withSealed(func(seal func(string) (string, error)) error {
+		storedContent, err := seal(content)
+		if err != nil {
+			return err
+		}
+
+		result, err := c.db.Exec(
+			"INSERT INTO messages (chat_id, role, content) VALUES (?, ?, ?)",
+			chatID, role, storedContent,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add message: %v", err)
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get message ID: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to add message: %v", err)
+		return nil, err
 	}
 
 	// Update chat's updated_at timestamp
@@ -224,21 +548,20 @@ func (c *ChatDB) AddMessage(chatID int64, role, content string) (*Message, error
 		return nil, fmt.Errorf("failed to update chat timestamp: %v", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get message ID: %v", err)
-	}
-
 	return c.GetMessage(id)
 }
 
 // GetMessage retrieves a message by ID
 func (c *ChatDB) GetMessage(id int64) (*Message, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
 	var msg Message
 	err := c.db.QueryRow(
-		"SELECT id, chat_id, role, content, created_at FROM messages WHERE id = ?",
+		"SELECT id, chat_id, role, content, status, error_text, created_at FROM messages WHERE id = ?",
 		id,
-	).Scan(&msg.ID, &msg.ChatID, &msg.Role, &msg.Content, &msg.CreatedAt)
+	).Scan(&msg.ID, &msg.ChatID, &msg.Role, &msg.Content, &msg.Status, &msg.Error, &msg.CreatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -246,14 +569,21 @@ func (c *ChatDB) GetMessage(id int64) (*Message, error) {
 		}
 		return nil, fmt.Errorf("failed to get message: %v", err)
 	}
+	if err := c.openMessage(&msg); err != nil {
+		return nil, err
+	}
 
 	return &msg, nil
 }
 
 // GetChatMessages retrieves all messages for a chat
 func (c *ChatDB) GetChatMessages(chatID int64) ([]Message, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
 	rows, err := c.db.Query(
-		"SELECT id, chat_id, role, content, created_at FROM messages WHERE chat_id = ? ORDER BY created_at ASC",
+		"SELECT id, chat_id, role, content, status, error_text, created_at FROM messages WHERE chat_id = ? ORDER BY created_at ASC",
 		chatID,
 	)
 	if err != nil {
@@ -264,20 +594,91 @@ func (c *ChatDB) GetChatMessages(chatID int64) ([]Message, error) {
 	var messages []Message
 	for rows.Next() {
 		var msg Message
-		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Role, &msg.Content, &msg.CreatedAt)
+		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Role, &msg.Content, &msg.Status, &msg.Error, &msg.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan message: %v", err)
 		}
+		if err := c.openMessage(&msg); err != nil {
+			return nil, err
+		}
 		messages = append(messages, msg)
 	}
 
 	return messages, nil
 }
 
+// GetChatMessagesPage retrieves up to limit messages older than beforeID.
+// A beforeID of 0 returns the newest messages. The page is returned in
+// chronological order so it can be prepended as-is.
+func (c *ChatDB) GetChatMessagesPage(chatID int64, beforeID int64, limit int) (*MessagePage, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+	limit = clampPageSize(limit)
+
+	var rows *sql.Rows
+	var err error
+	if beforeID <= 0 {
+		rows, err = c.db.Query(
+			`SELECT id, chat_id, role, content, status, error_text, created_at FROM messages
+			WHERE chat_id = ?
+			ORDER BY id DESC
+			LIMIT ?`,
+			chatID, limit+1,
+		)
+	} else {
+		rows, err = c.db.Query(
+			`SELECT id, chat_id, role, content, status, error_text, created_at FROM messages
+			WHERE chat_id = ? AND id < ?
+			ORDER BY id DESC
+			LIMIT ?`,
+			chatID, beforeID, limit+1,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %v", err)
+	}
+	defer rows.Close()
+
+	messages := make([]Message, 0, limit)
+	for rows.Next() {
+		var msg Message
+		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Role, &msg.Content, &msg.Status, &msg.Error, &msg.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+		if err := c.openMessage(&msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	page := &MessagePage{}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		page.HasMore = true
+	}
+
+	// Reverse to get chronological order
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	page.Messages = messages
+	if len(messages) > 0 {
+		page.BeforeID = messages[0].ID
+	}
+
+	return page, nil
+}
+
 // GetRecentMessages retrieves the last N messages for context
 func (c *ChatDB) GetRecentMessages(chatID int64, limit int) ([]Message, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
 	rows, err := c.db.Query(
-		`SELECT id, chat_id, role, content, created_at FROM messages 
+		`SELECT id, chat_id, role, content, status, error_text, created_at FROM messages 
 		WHERE chat_id = ? 
 		ORDER BY created_at DESC 
 		LIMIT ?`,
@@ -291,10 +692,13 @@ func (c *ChatDB) GetRecentMessages(chatID int64, limit int) ([]Message, error) {
 	var messages []Message
 	for rows.Next() {
 		var msg Message
-		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Role, &msg.Content, &msg.CreatedAt)
+		err := rows.Scan(&msg.ID, &msg.ChatID, &msg.Role, &msg.Content, &msg.Status, &msg.Error, &msg.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan message: %v", err)
 		}
+		if err := c.openMessage(&msg); err != nil {
+			return nil, err
+		}
 		messages = append(messages, msg)
 	}
 
@@ -331,14 +735,27 @@ func (c *ChatDB) BuildContext(chatID int64, maxMessages int) (string, error) {
 
 // Close closes the database connection
 func (c *ChatDB) Close() error {
-	return c.db.Close()
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.Lock()
+		err = c.db.Close()
+	})
+	return err
 }
 
 // SearchChats searches chats by title
 func (c *ChatDB) SearchChats(query string) ([]Chat, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+	if c.EncryptionStatus().Enabled {
+		return c.searchEncryptedChats(query)
+	}
+
 	rows, err := c.db.Query(
 		`SELECT id, title, model_name, created_at, updated_at FROM chats 
-		WHERE title LIKE ? 
+		WHERE title LIKE ? AND archived = 0
 		ORDER BY updated_at DESC`,
 		"%"+query+"%",
 	)
@@ -354,14 +771,47 @@ func (c *ChatDB) SearchChats(query string) ([]Chat, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan chat: %v", err)
 		}
+		if err := c.openChat(&chat); err != nil {
+			return nil, err
+		}
 		chats = append(chats, chat)
 	}
 
 	return chats, nil
 }
 
+// searchEncryptedChats filters decrypted titles in memory, since
+// encrypted titles can't be matched with LIKE
+func (c *ChatDB) searchEncryptedChats(query string) ([]Chat, error) {
+	chats, err := c.GetAllChats()
+	if err != nil {
+		return nil, err
+	}
+
+	needle := strings.ToLower(query)
+	var matches []Chat
+	for _, chat := range chats {
+		if strings.Contains(strings.ToLower(chat.Title), needle) {
+			matches = append(matches, chat)
+		}
+	}
+
+	return matches, nil
+}
+
 // RenameChat renames a chat based on first message content
 func (c *ChatDB) RenameChatFromFirstMessage(chatID int64) error {
+	_, err := c.titleFromFirstMessage(chatID)
+	return err
+}
+
+// titleFromFirstMessage replaces the default title with the truncated first
+// user message and reports whether the title changed
+func (c *ChatDB) titleFromFirstMessage(chatID int64) (bool, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return false, err
+	}
+
 	// Get first user message
 	var firstMessage string
 	err := c.db.QueryRow(
@@ -374,24 +824,56 @@ func (c *ChatDB) RenameChatFromFirstMessage(chatID int64) error {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil // No messages yet, keep default title
+			return false, nil // No messages yet, keep default title
 		}
-		return fmt.Errorf("failed to get first message: %v", err)
+		return false, fmt.Errorf("failed to get first message: %v", err)
+	}
+	firstMessage, err = c.openText(firstMessage)
+	if err != nil {
+		return false, err
 	}
 
-	// Truncate to create title (max 50 chars)
-	title := firstMessage
-	if len(title) > 50 {
-		title = title[:47] + "..."
+	// Only replace the default title; a model title or manual rename wins
+	return c.setGeneratedTitle(chatID, truncateTitle(firstMessage, maxTitleRunes), titleSourceAuto, titleSourceDefault)
+}
+
+// FirstExchange returns the first user message and the first assistant
+// reply that completed, skipping replies that failed or were stopped
+func (c *ChatDB) FirstExchange(chatID int64) (string, string, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return "", "", err
 	}
 
-	return c.UpdateChatTitle(chatID, title)
+	first := func(role string) (string, error) {
+		var content string
+		err := c.db.QueryRow(
+			"SELECT content FROM messages WHERE chat_id = ? AND role = ? AND status = ? ORDER BY id ASC LIMIT 1",
+			chatID, role, MessageStatusComplete,
+		).Scan(&content)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to get first %s message: %v", role, err)
+		}
+		return c.openText(content)
+	}
+
+	user, err := first("user")
+	if err != nil {
+		return "", "", err
+	}
+	assistant, err := first("assistant")
+	if err != nil {
+		return "", "", err
+	}
+	return user, assistant, nil
 }
 
-// GetChatCount returns the total number of chats
+// GetChatCount returns the total number of unarchived chats
 func (c *ChatDB) GetChatCount() (int, error) {
 	var count int
-	err := c.db.QueryRow("SELECT COUNT(*) FROM chats").Scan(&count)
+	err := c.db.QueryRow("SELECT COUNT(*) FROM chats WHERE archived = 0").Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count chats: %v", err)
 	}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// vacuumInterval is how often the startup pass rebuilds the database file
+const vacuumInterval = 7 * 24 * time.Hour
+
+// IntegrityReport summarizes the startup health check of the chat database
+type IntegrityReport struct {
+	OK             bool     `json:"ok"`
+	Problems       []string `json:"problems"`
+	OrphansRemoved int64    `json:"orphansRemoved"`
+	Vacuumed       bool     `json:"vacuumed"`
+	CheckedAt      string   `json:"checkedAt"`
+	LatestBackup   string   `json:"latestBackup,omitempty"` // offered for restore when !OK
+}
+
+// initMetaTable creates the key/value table used for maintenance bookkeeping
+func (c *ChatDB) initMetaTable() error {
+	_, err := c.db.Exec(`
+		CREATE TABLE IF NOT EXISTS db_meta (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create meta table: %v", err)
+	}
+	return nil
+}
+
+// getMeta reads a bookkeeping value, returning "" if it is unset
+func (c *ChatDB) getMeta(key string) (string, error) {
+	var value string
+	err := c.db.QueryRow("SELECT value FROM db_meta WHERE key = ?", key).Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// setMeta stores a bookkeeping value
+func (c *ChatDB) setMeta(key, value string) error {
+	_, err := c.db.Exec("INSERT OR REPLACE INTO db_meta (key, value) VALUES (?, ?)", key, value)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", key, err)
+	}
+	return nil
+}
+
+// CheckIntegrity runs PRAGMA integrity_check and returns any problems found
+func (c *ChatDB) CheckIntegrity() ([]string, error) {
+	rows, err := c.db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %v", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to read integrity check: %v", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+
+	return problems, rows.Err()
+}
+
+// RemoveOrphans deletes messages whose chat no longer exists. These were
+// left behind by deletes made before foreign keys were enforced.
+func (c *ChatDB) RemoveOrphans() (int64, error) {
+	result, err := c.db.Exec("DELETE FROM messages WHERE chat_id NOT IN (SELECT id FROM chats)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove orphaned messages: %v", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count orphaned messages: %v", err)
+	}
+	return removed, nil
+}
+
+// Optimize refreshes query planner statistics and vacuums the database
+// if it hasn't been vacuumed within vacuumInterval
+func (c *ChatDB) Optimize() (bool, error) {
+	if _, err := c.db.Exec("PRAGMA optimize"); err != nil {
+		return false, fmt.Errorf("failed to optimize database: %v", err)
+	}
+
+	last, err := c.getMeta("last_vacuum")
+	if err != nil {
+		return false, err
+	}
+	if last != "" {
+		if lastVacuum, err := time.Parse(time.RFC3339, last); err == nil && time.Since(lastVacuum) < vacuumInterval {
+			return false, nil
+		}
+	}
+
+	if _, err := c.db.Exec("VACUUM"); err != nil {
+		return false, fmt.Errorf("failed to vacuum database: %v", err)
+	}
+	if err := c.setMeta("last_vacuum", time.Now().Format(time.RFC3339)); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// RunMaintenance checks integrity and, if the database is healthy,
+// removes orphaned rows and optimizes it
+func (c *ChatDB) RunMaintenance() (*IntegrityReport, error) {
+	report := &IntegrityReport{
+		Problems:  []string{},
+		CheckedAt: time.Now().Format(time.RFC3339),
+	}
+
+	problems, err := c.CheckIntegrity()
+	if err != nil {
+		report.Problems = append(report.Problems, err.Error())
+		report.LatestBackup = c.latestBackupName()
+		c.setLastReport(report)
+		return report, err
+	}
+	if len(problems) > 0 {
+		// Don't write to a damaged database; offer the newest backup instead
+		report.Problems = problems
+		report.LatestBackup = c.latestBackupName()
+		c.setLastReport(report)
+		return report, nil
+	}
+	report.OK = true
+
+	report.OrphansRemoved, err = c.RemoveOrphans()
+	if err != nil {
+		c.setLastReport(report)
+		return report, err
+	}
+
+	report.Vacuumed, err = c.Optimize()
+	c.setLastReport(report)
+	return report, err
+}
+
+// latestBackupName returns the newest backup's name, or "" if there are none
+func (c *ChatDB) latestBackupName() string {
+	if latest := c.LatestBackup(); latest != nil {
+		return latest.Name
+	}
+	return ""
+}
+
+// setLastReport records the most recent maintenance report
+func (c *ChatDB) setLastReport(report *IntegrityReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastReport = report
+}
+
+// LastIntegrityReport returns the most recent maintenance report, or nil
+// if the startup pass hasn't finished yet
+func (c *ChatDB) LastIntegrityReport() *IntegrityReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lastReport
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Message statuses, stored in messages.status
+const (
+	MessageStatusComplete    = "complete"    // fully received
+	MessageStatusStreaming   = "streaming"   // still arriving
+	MessageStatusCancelled   = "cancelled"   // stopped by the user
+	MessageStatusError       = "error"       // ended by an Ollama or network error
+	MessageStatusInterrupted = "interrupted" // left streaming when the app exited
+)
+
+// streamFlushInterval is how often a streaming reply is written to the database
+const streamFlushInterval = 2 * time.Second
+
+// BeginStreamingMessage inserts an empty assistant message that a stream
+// will fill in
+func (c *ChatDB) BeginStreamingMessage(chatID int64) (*Message, error) {
+	if err := c.ensureUnlocked(); err != nil {
+		return nil, err
+	}
+
+	var id int64
+	err := c.withSealed(func(seal func(string) (string, error)) error {
+		storedContent, err := seal("")
+		if err != nil {
+			return err
+		}
+
+		result, err := c.db.Exec(
+			"INSERT INTO messages (chat_id, role, content, status) VALUES (?, 'assistant', ?, ?)",
+			chatID, storedContent, MessageStatusStreaming,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add message: %v", err)
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get message ID: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.db.Exec("UPDATE chats SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update chat timestamp: %v", err)
+	}
+
+	return c.GetMessage(id)
+}
+
+// UpdateStreamingMessage saves the content received so far
+func (c *ChatDB) UpdateStreamingMessage(id int64, content string) error {
+	return c.writeStreamingMessage(id, content, MessageStatusStreaming, "")
+}
+
+// FinishStreamingMessage saves the final content and status of a stream.
+// If history was locked while the reply streamed, the content can't be
+// sealed, so the message keeps what was last saved and is marked
+// interrupted instead of being left streaming.
+func (c *ChatDB) FinishStreamingMessage(id int64, content, status, errText string) error {
+	err := c.writeStreamingMessage(id, content, status, errText)
+	if !errors.Is(err, ErrChatLocked) {
+		return err
+	}
+
+	_, markErr := c.db.Exec(
+		"UPDATE messages SET status = ?, error_text = ? WHERE id = ? AND status = ?",
+		MessageStatusInterrupted, "Chat history was locked before the reply finished", id, MessageStatusStreaming,
+	)
+	if markErr != nil {
+		return fmt.Errorf("failed to mark interrupted message: %v", markErr)
+	}
+	return fmt.Errorf("%w before the reply finished; only the part saved before then was kept", err)
+}
+
+// writeStreamingMessage updates a message that is still streaming
+func (c *ChatDB) writeStreamingMessage(id int64, content, status, errText string) error {
+	if err := c.ensureUnlocked(); err != nil {
+		return err
+	}
+
+	return c.withSealed(func(seal func(string) (string, error)) error {
+		storedContent, err := seal(content)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.db.Exec(
+			"UPDATE messages SET content = ?, status = ?, error_text = ? WHERE id = ? AND status = ?",
+			storedContent, status, errText, id, MessageStatusStreaming,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save streaming message: %v", err)
+		}
+		return nil
+	})
+}
+
+// MarkInterruptedMessages flags replies that were still streaming when the
+// app last exited. It must run before any new stream starts.
+func (c *ChatDB) MarkInterruptedMessages() (int64, error) {
+	result, err := c.db.Exec(
+		"UPDATE messages SET status = ?, error_text = ? WHERE status = ?",
+		MessageStatusInterrupted, "Akashic closed before the reply finished", MessageStatusStreaming,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark interrupted messages: %v", err)
+	}
+
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count interrupted messages: %v", err)
+	}
+	return marked, nil
+}
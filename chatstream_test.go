@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFinishStreamingMessageWhileLocked(t *testing.T) {
+	c := newTestChatDB(t)
+	chat, err := c.CreateChat("streaming", "mistral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnableEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := c.BeginStreamingMessage(chat.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateStreamingMessage(msg.ID, "The first half"); err != nil {
+		t.Fatal(err)
+	}
+
+	// History locks itself while the reply is still arriving
+	c.Lock()
+	err = c.FinishStreamingMessage(msg.ID, "The first half and the second half", MessageStatusComplete, "")
+	if !errors.Is(err, ErrChatLocked) {
+		t.Fatalf("FinishStreamingMessage while locked = %v, want ErrChatLocked", err)
+	}
+
+	if err := c.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := c.GetMessage(msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != MessageStatusInterrupted {
+		t.Errorf("status = %q, want %q", saved.Status, MessageStatusInterrupted)
+	}
+	if saved.Content != "The first half" {
+		t.Errorf("content = %q, want the last saved part", saved.Content)
+	}
+
+	// Once finished, a late write can't bring the message back to life
+	if err := c.FinishStreamingMessage(msg.ID, "late", MessageStatusComplete, ""); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := c.GetMessage(msg.ID); saved.Content != "The first half" {
+		t.Errorf("content after a late finish = %q", saved.Content)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Title sources, stored in chats.title_source
+const (
+	titleSourceDefault = "default" // placeholder title from CreateChat
+	titleSourceAuto    = "auto"    // truncated first message
+	titleSourceModel   = "model"   // generated by the title model
+	titleSourceManual  = "manual"  // renamed by the user
+)
+
+// maxTitleRunes is the longest title kept, including the ellipsis
+const maxTitleRunes = 50
+
+// maxTitlePromptRunes caps how much of each message is sent to the title model
+const maxTitlePromptRunes = 2000
+
+// ChatTitleEventData is published when a chat receives a generated title
+type ChatTitleEventData struct {
+	ChatID int64  `json:"chatId"`
+	Title  string `json:"title"`
+}
+
+// truncateTitle collapses whitespace and shortens text to at most maxRunes
+// runes, cutting at a word boundary where possible so neither multi-byte
+// characters nor words are split
+func truncateTitle(text string, maxRunes int) string {
+	title := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(title) <= maxRunes {
+		return title
+	}
+
+	const ellipsis = "..."
+	runes := []rune(title)
+	if maxRunes <= len(ellipsis) {
+		return string(runes[:max(maxRunes, 0)]) // no room for an ellipsis
+	}
+	cut := maxRunes - len(ellipsis)
+
+	// Back up to the last space, unless that would leave too little
+	end := cut
+	for i := cut; i > cut/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			end = i
+			break
+		}
+	}
+
+	return strings.TrimRightFunc(string(runes[:end]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + ellipsis
+}
+
+// cleanGeneratedTitle tidies a model response into a short title: first
+// line only, without quotes, labels or trailing punctuation, and at most six words
+func cleanGeneratedTitle(response string) string {
+	line := strings.TrimSpace(response)
+	if i := strings.IndexAny(line, "\r\n"); i != -1 {
+		line = line[:i]
+	}
+	if i := strings.Index(line, ":"); i != -1 && strings.EqualFold(strings.TrimSpace(line[:i]), "title") {
+		line = line[i+1:]
+	}
+	line = strings.Trim(line, " \t\"'`*#“”‘’")
+	line = strings.TrimRightFunc(line, unicode.IsPunct)
+
+	words := strings.Fields(line)
+	if len(words) > 6 {
+		words = words[:6]
+	}
+	return truncateTitle(strings.Join(words, " "), maxTitleRunes)
+}
+
+// buildTitlePrompt asks the model for a short title for the first exchange
+func buildTitlePrompt(user, assistant string) string {
+	clip := func(s string) string {
+		runes := []rune(s)
+		if len(runes) > maxTitlePromptRunes {
+			return string(runes[:maxTitlePromptRunes])
+		}
+		return s
+	}
+
+	return fmt.Sprintf("Write a title of 3 to 6 words for the conversation below. "+
+		"Reply with the title only, without quotes or punctuation.\n\n"+
+		"User: %s\n\nAssistant: %s\n\nTitle:", clip(user), clip(assistant))
+}
+
+// needsTitle reports whether a chat still has the placeholder title it was
+// created with
+func (c *ChatDB) needsTitle(chatID int64) (bool, error) {
+	var source string
+	err := c.db.QueryRow("SELECT title_source FROM chats WHERE id = ?", chatID).Scan(&source)
+	if err != nil {
+		return false, fmt.Errorf("failed to read title source: %v", err)
+	}
+	return source == titleSourceDefault, nil
+}
+
+// generateChatTitle titles a chat after its first successful exchange. The truncated
+// first message is applied at once; if a title model is configured it is
+// then asked for a better one. Neither replaces a manual rename.
+func (a *App) generateChatTitle(chatID int64) {
+	changed, err := a.ChatDB.titleFromFirstMessage(chatID)
+	if err != nil {
+		fmt.Printf("Failed to title chat %d: %v\n", chatID, err)
+		return
+	}
+
+	if model := a.SettingsManager.Get().AI.TitleModel; model != "" {
+		user, assistant, err := a.ChatDB.FirstExchange(chatID)
+		if err == nil && user != "" {
+			response, genErr := a.GenerateWithOllama(model, buildTitlePrompt(user, assistant))
+			if genErr != nil {
+				fmt.Printf("Title model failed for chat %d: %v\n", chatID, genErr)
+			} else if title := cleanGeneratedTitle(response); title != "" {
+				updated, err := a.ChatDB.setGeneratedTitle(chatID, title, titleSourceModel, titleSourceDefault, titleSourceAuto)
+				if err != nil {
+					fmt.Printf("Failed to title chat %d: %v\n", chatID, err)
+				}
+				changed = changed || updated
+			}
+		}
+	}
+
+	if !changed {
+		return
+	}
+	chat, err := a.ChatDB.GetChat(chatID)
+	if err != nil {
+		return
+	}
+	a.EventBus.Emit(EventChatTitle, ChatTitleEventData{ChatID: chatID, Title: chat.Title})
+}
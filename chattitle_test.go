@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateTitle(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxRunes int
+		want     string
+	}{
+		{"", 50, ""},
+		{"short title", 50, "short title"},
+		{"  lots   of\n\twhitespace  ", 50, "lots of whitespace"},
+		{"exactly10!", 10, "exactly10!"},
+		{"one two three four five", 12, "one two..."},
+		{"abcdefghijklmnop", 10, "abcdefg..."},             // no space to cut at
+		{"a bcdefghijklmnop", 10, "a bcdef..."},            // the only space is too early
+		{"Hello, world and more", 12, "Hello..."},          // punctuation before the cut goes
+		{"日本語のテキストです", 8, "日本語のテ..."},                      // runes, not bytes
+		{"naïve café résumé déjà vu", 16, "naïve café..."}, // multi-byte Latin
+		{"日本語のテキスト", 4, "日..."},
+		{"日本語のテキスト", 3, "日本語"}, // too short for an ellipsis
+		{"abcdef", 1, "a"},
+		{"abcdef", 0, ""},
+		{"abcdef", -1, ""},
+	}
+
+	for _, tt := range tests {
+		got := truncateTitle(tt.text, tt.maxRunes)
+		if got != tt.want {
+			t.Errorf("truncateTitle(%q, %d) = %q, want %q", tt.text, tt.maxRunes, got, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); n > max(tt.maxRunes, 0) {
+			t.Errorf("truncateTitle(%q, %d) is %d runes long", tt.text, tt.maxRunes, n)
+		}
+	}
+}
+
+func TestCleanGeneratedTitle(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+	}{
+		{"Debugging a Go Race", "Debugging a Go Race"},
+		{"  \"Setting Up Ollama Locally.\"  ", "Setting Up Ollama Locally"},
+		{"Title: Fixing CSS Grid Layout", "Fixing CSS Grid Layout"},
+		{"**Rust Lifetimes Explained**\nThis title sums it up.", "Rust Lifetimes Explained"},
+		{"one two three four five six seven eight", "one two three four five six"},
+	}
+
+	for _, tt := range tests {
+		if got := cleanGeneratedTitle(tt.response); got != tt.want {
+			t.Errorf("cleanGeneratedTitle(%q) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsTitleAfterFailedReply(t *testing.T) {
+	c := newTestChatDB(t)
+	chat, err := c.CreateChat("New Chat", "mistral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddMessage(chat.ID, "user", "How do I reverse a slice in Go?"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first reply fails, so the chat still wants a title
+	failed, err := c.BeginStreamingMessage(chat.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FinishStreamingMessage(failed.ID, "Partial", MessageStatusError, "connection reset"); err != nil {
+		t.Fatal(err)
+	}
+	if untitled, err := c.needsTitle(chat.ID); err != nil || !untitled {
+		t.Fatalf("needsTitle after a failed reply = %v, %v, want true", untitled, err)
+	}
+
+	// The retry succeeds and is the reply the title model sees
+	if _, err := c.AddMessage(chat.ID, "assistant", "Swap elements from both ends."); err != nil {
+		t.Fatal(err)
+	}
+	user, assistant, err := c.FirstExchange(chat.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "How do I reverse a slice in Go?" || assistant != "Swap elements from both ends." {
+		t.Errorf("FirstExchange = %q, %q", user, assistant)
+	}
+
+	if _, err := c.titleFromFirstMessage(chat.ID); err != nil {
+		t.Fatal(err)
+	}
+	if untitled, err := c.needsTitle(chat.ID); err != nil || untitled {
+		t.Errorf("needsTitle after titling = %v, %v, want false", untitled, err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// usage is printed for --help and bad arguments
+const usage = `Usage: akashic [options] [file[:line[:column]] | folder]...
+
+Options:
+  -n, --new-window   open a new window instead of using the running one
+  -w, --wait         wait until the files are closed (for use as $EDITOR)
+  -d, --diff A B     compare two files
+  -h, --help         show this help
+`
+
+// FileTarget is a file to open, with an optional position
+type FileTarget struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`   // 1-based; 0 if not given
+	Column int    `json:"column"` // 1-based; 0 if not given
+	New    bool   `json:"new"`    // the file doesn't exist yet; saving creates it
+}
+
+// LaunchRequest is what a launch of the editor asks it to open
+type LaunchRequest struct {
+	Files  []FileTarget `json:"files"`
+	Folder string       `json:"folder,omitempty"` // opened as the workspace
+	Diff   []string     `json:"diff,omitempty"`   // two files to compare
+	WaitID string       `json:"waitId,omitempty"` // pass to FinishWait once the files are closed
+}
+
+// LaunchArgs are the parsed command line
+type LaunchArgs struct {
+	Request   LaunchRequest
+	NewWindow bool
+	Wait      bool
+	Help      bool
+}
+
+// parseArgs reads the command line, resolving paths against cwd
+func parseArgs(args []string, cwd string) (*LaunchArgs, error) {
+	launch := &LaunchArgs{}
+	var paths []string
+	flags := true
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !flags || !strings.HasPrefix(arg, "-") || arg == "-" {
+			paths = append(paths, arg)
+			continue
+		}
+		switch arg {
+		case "--":
+			flags = false
+		case "-n", "--new-window":
+			launch.NewWindow = true
+		case "-w", "--wait":
+			launch.Wait = true
+		case "-h", "--help":
+			launch.Help = true
+		case "-d", "--diff":
+			if i+2 >= len(args) {
+				return nil, fmt.Errorf("%s needs two files", arg)
+			}
+			launch.Request.Diff = []string{absolutePath(args[i+1], cwd), absolutePath(args[i+2], cwd)}
+			i += 2
+		default:
+			if strings.HasPrefix(arg, "-psn_") {
+				continue // process serial number macOS adds to Finder launches
+			}
+			return nil, fmt.Errorf("unknown option %s", arg)
+		}
+	}
+
+	for _, arg := range paths {
+		target := parseFileTarget(arg, cwd)
+		if stat, err := os.Stat(target.Path); err == nil && stat.IsDir() {
+			if launch.Request.Folder != "" {
+				return nil, fmt.Errorf("only one folder can be opened at a time")
+			}
+			launch.Request.Folder = target.Path
+			continue
+		}
+		launch.Request.Files = append(launch.Request.Files, target)
+	}
+
+	if launch.Wait && len(launch.Request.Files) == 0 {
+		return nil, fmt.Errorf("--wait needs a file to open")
+	}
+	return launch, nil
+}
+
+// parseFileTarget reads a path with an optional ":line" or ":line:column"
+// suffix. A file whose name really ends that way is taken as it is.
+func parseFileTarget(arg string, cwd string) FileTarget {
+	target := FileTarget{Path: absolutePath(arg, cwd)}
+	if fileExists(target.Path) {
+		return target
+	}
+
+	path, last, ok := cutPosition(arg)
+	if ok {
+		if rest, line, ok := cutPosition(path); ok {
+			path, target.Line, target.Column = rest, line, last
+		} else {
+			target.Line = last
+		}
+		target.Path = absolutePath(path, cwd)
+	}
+	// Only files on disk can be created by saving, not ones inside archives
+	if _, _, inArchive := splitArchivePath(target.Path); !inArchive {
+		if _, err := os.Stat(target.Path); os.IsNotExist(err) {
+			target.New = true
+		}
+	}
+	return target
+}
+
+// fileExists reports whether a path names a file on disk or one inside an archive
+func fileExists(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	archivePath, member, ok := splitArchivePath(path)
+	return ok && archiveHasMember(archivePath, member)
+}
+
+// cutPosition splits a trailing ":number" off a path
+func cutPosition(arg string) (string, int, bool) {
+	i := strings.LastIndexByte(arg, ':')
+	if i <= 0 {
+		return arg, 0, false
+	}
+	n, err := strconv.Atoi(arg[i+1:])
+	if err != nil || n <= 0 {
+		return arg, 0, false
+	}
+	// "C:5" is more likely a drive than a position
+	if i == 1 && filepath.VolumeName(arg[:2]) != "" {
+		return arg, 0, false
+	}
+	return arg[:i], n, true
+}
+
+// absolutePath resolves a command-line path against the launch directory
+func absolutePath(path string, cwd string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestCutPosition(t *testing.T) {
+	type cutCase struct {
+		arg      string
+		wantPath string
+		wantN    int
+		wantOK   bool
+	}
+	tests := []cutCase{
+		{"notes.txt:12", "notes.txt", 12, true},
+		{"notes.txt:12:4", "notes.txt:12", 4, true},
+		{"notes.txt", "notes.txt", 0, false},
+		{"notes.txt:", "notes.txt:", 0, false},
+		{"notes.txt:0", "notes.txt:0", 0, false},
+		{"notes.txt:-3", "notes.txt:-3", 0, false},
+		{"notes.txt:abc", "notes.txt:abc", 0, false},
+		{":5", ":5", 0, false},
+		{`C:\notes.txt:12`, `C:\notes.txt`, 12, true},
+		{`C:\notes.txt`, `C:\notes.txt`, 0, false},
+	}
+	// A bare drive letter is only a volume on Windows
+	if runtime.GOOS == "windows" {
+		tests = append(tests, cutCase{"C:5", "C:5", 0, false})
+	} else {
+		tests = append(tests, cutCase{"C:5", "C", 5, true})
+	}
+
+	for _, tt := range tests {
+		path, n, ok := cutPosition(tt.arg)
+		if path != tt.wantPath || n != tt.wantN || ok != tt.wantOK {
+			t.Errorf("cutPosition(%q) = (%q, %d, %v), want (%q, %d, %v)",
+				tt.arg, path, n, ok, tt.wantPath, tt.wantN, tt.wantOK)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	cwd := t.TempDir()
+	existing := filepath.Join(cwd, "exists.txt")
+	if err := os.WriteFile(existing, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	folder := filepath.Join(cwd, "folder")
+	if err := os.Mkdir(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	notes := filepath.Join(cwd, "notes.txt")
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    *LaunchArgs
+		wantErr bool
+	}{
+		{
+			name: "line and column",
+			args: []string{"notes.txt:12:4"},
+			want: &LaunchArgs{Request: LaunchRequest{Files: []FileTarget{{Path: notes, Line: 12, Column: 4, New: true}}}},
+		},
+		{
+			name: "line only",
+			args: []string{"exists.txt:7"},
+			want: &LaunchArgs{Request: LaunchRequest{Files: []FileTarget{{Path: existing, Line: 7}}}},
+		},
+		{
+			name: "folder and file",
+			args: []string{"folder", "exists.txt"},
+			want: &LaunchArgs{Request: LaunchRequest{Folder: folder, Files: []FileTarget{{Path: existing}}}},
+		},
+		{
+			name: "flags",
+			args: []string{"-n", "--wait", "exists.txt", "-psn_0_12345"},
+			want: &LaunchArgs{NewWindow: true, Wait: true, Request: LaunchRequest{Files: []FileTarget{{Path: existing}}}},
+		},
+		{
+			name: "diff",
+			args: []string{"--diff", "exists.txt", "notes.txt"},
+			want: &LaunchArgs{Request: LaunchRequest{Diff: []string{existing, notes}}},
+		},
+		{
+			name: "double dash ends options",
+			args: []string{"--", "-n"},
+			want: &LaunchArgs{Request: LaunchRequest{Files: []FileTarget{{Path: filepath.Join(cwd, "-n"), New: true}}}},
+		},
+		{name: "wait without a file", args: []string{"--wait"}, wantErr: true},
+		{name: "diff needs two files", args: []string{"-d", "exists.txt"}, wantErr: true},
+		{name: "unknown option", args: []string{"--bogus"}, wantErr: true},
+		{name: "two folders", args: []string{"folder", "folder"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseArgs(tt.args, cwd)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseArgs(%q) = %+v, want %+v", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseFileTargetExistingName(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file names can't contain a colon on Windows")
+	}
+	cwd := t.TempDir()
+	odd := filepath.Join(cwd, "odd:3")
+	if err := os.WriteFile(odd, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file whose name looks like a position is opened as it is
+	if got := parseFileTarget("odd:3", cwd); got.Path != odd || got.Line != 0 {
+		t.Errorf("parseFileTarget(%q) = %+v, want the file itself", "odd:3", got)
+	}
+}
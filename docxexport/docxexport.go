@@ -0,0 +1,335 @@
+// Package docxexport writes Word documents (OOXML) from scratch, laid out
+// from the same blocks as the PDF export
+package docxexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"Akashic/pdfexport"
+)
+
+// Page layout in twentieths of a point, matching the PDF's A4 margins
+const (
+	pageWidth    = 11906
+	pageHeight   = 16838
+	marginLeft   = 1417 // 25mm
+	marginRight  = 1134 // 20mm
+	marginTop    = 1417
+	marginBottom = 1417
+)
+
+// bulletNumID is the numbering instance every bulleted list shares;
+// numbered lists each get their own so they start from their first number
+const bulletNumID = 1
+
+// Exporter handles DOCX export operations
+type Exporter struct{}
+
+// NewExporter creates a new DOCX exporter instance
+func NewExporter() *Exporter {
+	return &Exporter{}
+}
+
+// Export writes content as a Word document with the title, headings,
+// lists, quotes and code in Word's own styles and numbering, and tables
+// as Word tables
+func (e *Exporter) Export(content string, filePath string) error {
+	blocks := pdfexport.NewExporter().Blocks(content)
+
+	body, numbered := e.buildBody(blocks)
+	title := ""
+	if len(blocks) > 0 && blocks[0].Kind == pdfexport.BlockTitle {
+		title = blocks[0].Text
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := []struct {
+		name string
+		data string
+	}{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"docProps/core.xml", corePropertiesXML(title, time.Now())},
+		{"docProps/app.xml", appPropertiesXML},
+		{"word/_rels/document.xml.rels", documentRelsXML},
+		{"word/document.xml", body},
+		{"word/styles.xml", stylesXML},
+		{"word/numbering.xml", numberingXML(numbered)},
+	}
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", part.name, err)
+		}
+		if _, err := w.Write([]byte(part.data)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish document: %w", err)
+	}
+
+	return os.WriteFile(filePath, buf.Bytes(), 0644)
+}
+
+// numberedList is a run of numbered items that counts on from its first number
+type numberedList struct {
+	numID int
+	start int
+}
+
+// buildBody writes word/document.xml and returns it with the numbered
+// lists it refers to
+func (e *Exporter) buildBody(blocks []pdfexport.Block) (string, []numberedList) {
+	var body strings.Builder
+	body.WriteString(xml.Header)
+	body.WriteString(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+
+	var lists []numberedList
+	inList := false // the last block was part of the current numbered list
+	endsWithTable := false
+	for i := 0; i < len(blocks); i++ {
+		block := blocks[i]
+		endsWithTable = false
+		switch block.Kind {
+		case pdfexport.BlockBlank:
+			// Styles space the paragraphs
+			inList = false
+			continue
+		case pdfexport.BlockNumbered:
+			if !inList {
+				lists = append(lists, numberedList{numID: bulletNumID + 1 + len(lists), start: max(block.Number, 1)})
+			}
+			inList = true
+			writeParagraph(&body, "ListParagraph", lists[len(lists)-1].numID, block.Level, block.Text)
+			continue
+		case pdfexport.BlockBullet:
+			writeParagraph(&body, "ListParagraph", bulletNumID, block.Level, block.Text)
+			if block.Level == 0 {
+				inList = false
+			}
+			continue
+		case pdfexport.BlockTableRow:
+			end := i + 1
+			for end < len(blocks) && blocks[end].Kind == pdfexport.BlockTableRow {
+				end++
+			}
+			writeTable(&body, blocks[i:end])
+			i = end - 1
+			inList = false
+			endsWithTable = true
+			continue
+		}
+
+		inList = false
+		style := map[pdfexport.BlockKind]string{
+			pdfexport.BlockTitle:    "Title",
+			pdfexport.BlockHeading1: "Heading1",
+			pdfexport.BlockHeading2: "Heading2",
+			pdfexport.BlockHeading3: "Heading3",
+			pdfexport.BlockQuote:    "Quote",
+			pdfexport.BlockCode:     "Code",
+		}[block.Kind]
+		writeParagraph(&body, style, 0, 0, block.Text)
+	}
+	// Word wants a paragraph between the last table and the section properties
+	if endsWithTable {
+		body.WriteString(`<w:p/>`)
+	}
+
+	fmt.Fprintf(&body, `<w:sectPr><w:pgSz w:w="%d" w:h="%d"/><w:pgMar w:top="%d" w:right="%d" w:bottom="%d" w:left="%d" w:header="709" w:footer="709" w:gutter="0"/></w:sectPr>`,
+		pageWidth, pageHeight, marginTop, marginRight, marginBottom, marginLeft)
+	body.WriteString(`</w:body></w:document>`)
+	return body.String(), lists
+}
+
+// writeParagraph writes one paragraph in a style, or the default Normal
+// style if style is "", as an item of a list if numID isn't 0
+func writeParagraph(w *strings.Builder, style string, numID int, level int, text string) {
+	w.WriteString(`<w:p>`)
+	if style != "" || numID != 0 {
+		w.WriteString(`<w:pPr>`)
+		if style != "" {
+			fmt.Fprintf(w, `<w:pStyle w:val="%s"/>`, style)
+		}
+		if numID != 0 {
+			fmt.Fprintf(w, `<w:numPr><w:ilvl w:val="%d"/><w:numId w:val="%d"/></w:numPr>`, level, numID)
+		}
+		w.WriteString(`</w:pPr>`)
+	}
+
+	// Tabs are their own run elements in WordprocessingML
+	w.WriteString(`<w:r>`)
+	for i, part := range strings.Split(text, "\t") {
+		if i > 0 {
+			w.WriteString(`<w:tab/>`)
+		}
+		if part != "" {
+			w.WriteString(`<w:t xml:space="preserve">`)
+			w.WriteString(escapeXML(part))
+			w.WriteString(`</w:t>`)
+		}
+	}
+	w.WriteString(`</w:r></w:p>`)
+}
+
+// writeTable writes table rows as a grid across the page, the header row
+// repeating at the top of each page it continues onto
+func writeTable(w *strings.Builder, rows []pdfexport.Block) {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row.Cells))
+	}
+	if columns == 0 {
+		return
+	}
+	width := (pageWidth - marginLeft - marginRight) / columns
+
+	w.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="0" w:type="auto"/></w:tblPr><w:tblGrid>`)
+	for range columns {
+		fmt.Fprintf(w, `<w:gridCol w:w="%d"/>`, width)
+	}
+	w.WriteString(`</w:tblGrid>`)
+	for _, row := range rows {
+		w.WriteString(`<w:tr>`)
+		style := "TableText"
+		if row.Header {
+			w.WriteString(`<w:trPr><w:tblHeader/></w:trPr>`)
+			style = "TableHeading"
+		}
+		for column := range columns {
+			text := ""
+			if column < len(row.Cells) {
+				text = row.Cells[column]
+			}
+			fmt.Fprintf(w, `<w:tc><w:tcPr><w:tcW w:w="%d" w:type="dxa"/></w:tcPr>`, width)
+			writeParagraph(w, style, 0, 0, text)
+			w.WriteString(`</w:tc>`)
+		}
+		w.WriteString(`</w:tr>`)
+	}
+	w.WriteString(`</w:tbl>`)
+}
+
+// escapeXML escapes text for an element or attribute. Characters XML
+// can't hold, such as most control characters, become U+FFFD.
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// numberingXML defines a bulleted list and a decimal list, two levels
+// deep, and a numbering instance for each numbered list
+func numberingXML(lists []numberedList) string {
+	var w strings.Builder
+	w.WriteString(xml.Header)
+	w.WriteString(`<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">`)
+
+	w.WriteString(`<w:abstractNum w:abstractNumId="0"><w:multiLevelType w:val="hybridMultilevel"/>`)
+	for level, bullet := range []string{"•", "◦"} {
+		fmt.Fprintf(&w, `<w:lvl w:ilvl="%d"><w:start w:val="1"/><w:numFmt w:val="bullet"/><w:lvlText w:val="%s"/><w:lvlJc w:val="left"/>`+
+			`<w:pPr><w:ind w:left="%d" w:hanging="360"/></w:pPr></w:lvl>`, level, bullet, 720*(level+1))
+	}
+	w.WriteString(`</w:abstractNum>`)
+
+	w.WriteString(`<w:abstractNum w:abstractNumId="1"><w:multiLevelType w:val="hybridMultilevel"/>`)
+	for level, format := range []string{"decimal", "lowerLetter"} {
+		fmt.Fprintf(&w, `<w:lvl w:ilvl="%d"><w:start w:val="1"/><w:numFmt w:val="%s"/><w:lvlText w:val="%%%d."/><w:lvlJc w:val="left"/>`+
+			`<w:pPr><w:ind w:left="%d" w:hanging="360"/></w:pPr></w:lvl>`, level, format, level+1, 720*(level+1))
+	}
+	w.WriteString(`</w:abstractNum>`)
+
+	fmt.Fprintf(&w, `<w:num w:numId="%d"><w:abstractNumId w:val="0"/></w:num>`, bulletNumID)
+	for _, list := range lists {
+		fmt.Fprintf(&w, `<w:num w:numId="%d"><w:abstractNumId w:val="1"/>`+
+			`<w:lvlOverride w:ilvl="0"><w:startOverride w:val="%d"/></w:lvlOverride></w:num>`, list.numID, list.start)
+	}
+	w.WriteString(`</w:numbering>`)
+	return w.String()
+}
+
+// corePropertiesXML records the document's title and when it was written
+func corePropertiesXML(title string, created time.Time) string {
+	stamp := created.UTC().Format(time.RFC3339)
+	return xml.Header +
+		`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<dc:title>` + escapeXML(title) + `</dc:title>` +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + stamp + `</dcterms:created>` +
+		`<dcterms:modified xsi:type="dcterms:W3CDTF">` + stamp + `</dcterms:modified>` +
+		`</cp:coreProperties>`
+}
+
+const contentTypesXML = xml.Header +
+	`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`<Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>` +
+	`</Types>`
+
+const rootRelsXML = xml.Header +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/>` +
+	`</Relationships>`
+
+const documentRelsXML = xml.Header +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>` +
+	`</Relationships>`
+
+const appPropertiesXML = xml.Header +
+	`<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">` +
+	`<Application>Akashic</Application>` +
+	`</Properties>`
+
+// stylesXML uses Word's built-in style names so the headings show in the
+// navigation pane and a table of contents. Sizes are in half-points and
+// match the PDF's.
+const stylesXML = xml.Header +
+	`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:docDefaults>` +
+	`<w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="Calibri" w:cs="Calibri"/><w:sz w:val="21"/><w:szCs w:val="21"/><w:lang w:val="en-US"/></w:rPr></w:rPrDefault>` +
+	`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="264" w:lineRule="auto"/></w:pPr></w:pPrDefault>` +
+	`</w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:after="240"/></w:pPr><w:rPr><w:b/><w:sz w:val="36"/><w:szCs w:val="36"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="340" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:sz w:val="32"/><w:szCs w:val="32"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="280" w:after="100"/><w:outlineLvl w:val="1"/></w:pPr><w:rPr><w:b/><w:sz w:val="28"/><w:szCs w:val="28"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading3"><w:name w:val="heading 3"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="220" w:after="80"/><w:outlineLvl w:val="2"/></w:pPr><w:rPr><w:b/><w:sz w:val="24"/><w:szCs w:val="24"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/><w:basedOn w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:after="60"/><w:ind w:left="720"/><w:contextualSpacing/></w:pPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:ind w:left="454"/></w:pPr><w:rPr><w:i/><w:color w:val="595959"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:customStyle="1" w:styleId="Code"><w:name w:val="Code"/><w:basedOn w:val="Normal"/>` +
+	`<w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/><w:ind w:left="227"/></w:pPr>` +
+	`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="19"/><w:szCs w:val="19"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:customStyle="1" w:styleId="TableText"><w:name w:val="Table Text"/><w:basedOn w:val="Normal"/>` +
+	`<w:pPr><w:spacing w:before="40" w:after="40"/></w:pPr></w:style>` +
+	`<w:style w:type="paragraph" w:customStyle="1" w:styleId="TableHeading"><w:name w:val="Table Heading"/><w:basedOn w:val="TableText"/>` +
+	`<w:rPr><w:b/></w:rPr></w:style>` +
+	`<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr><w:tblBorders>` +
+	`<w:top w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:left w:val="single" w:sz="4" w:space="0" w:color="auto"/>` +
+	`<w:bottom w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:right w:val="single" w:sz="4" w:space="0" w:color="auto"/>` +
+	`<w:insideH w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="auto"/>` +
+	`</w:tblBorders><w:tblCellMar><w:left w:w="108" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr></w:style>` +
+	`</w:styles>`
@@ -0,0 +1,98 @@
+package docxexport
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	exporter := NewExporter()
+
+	content := `Release Notes
+
+# Highlights
+
+Faster startup & smaller downloads.
+
+- Tabs remember their scroll position
+  - including split views
+- Encrypted notes
+
+1. Download the installer
+2. Run it
+
+> Back up your notes first
+
+| Platform | Size |
+|----------|------|
+| Windows  | 9 MB |
+
+## Known issues
+
+3. Printing <landscape> pages`
+
+	path := filepath.Join(t.TempDir(), "test_output.docx")
+	if err := exporter.Export(content, path); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("DOCX is not a valid zip: %v", err)
+	}
+	defer reader.Close()
+
+	parts := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[file.Name] = string(data)
+
+		// Every part must be well-formed XML
+		decoder := xml.NewDecoder(strings.NewReader(string(data)))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed: %v", file.Name, err)
+			}
+		}
+	}
+
+	document := parts["word/document.xml"]
+	for _, want := range []string{
+		`<w:pStyle w:val="Title"/></w:pPr><w:r><w:t xml:space="preserve">Release Notes`,
+		`<w:pStyle w:val="Heading1"/>`,
+		`<w:pStyle w:val="Heading2"/>`,
+		`Faster startup &amp; smaller downloads.`,
+		`<w:ilvl w:val="1"/><w:numId w:val="1"/>`,
+		`Printing &lt;landscape&gt; pages`,
+		`<w:pStyle w:val="Quote"/></w:pPr><w:r><w:t xml:space="preserve">Back up your notes first`,
+		`<w:trPr><w:tblHeader/></w:trPr>`,
+		`<w:pStyle w:val="TableText"/></w:pPr><w:r><w:t xml:space="preserve">9 MB`,
+	} {
+		if !strings.Contains(document, want) {
+			t.Errorf("document.xml is missing %q", want)
+		}
+	}
+
+	// The two numbered lists are numbered separately, the second from 3
+	numbering := parts["word/numbering.xml"]
+	if !strings.Contains(numbering, `<w:num w:numId="2"><w:abstractNumId w:val="1"/><w:lvlOverride w:ilvl="0"><w:startOverride w:val="1"/>`) ||
+		!strings.Contains(numbering, `<w:num w:numId="3"><w:abstractNumId w:val="1"/><w:lvlOverride w:ilvl="0"><w:startOverride w:val="3"/>`) {
+		t.Errorf("unexpected numbering.xml: %s", numbering)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal("DOCX file was not created")
+	}
+}
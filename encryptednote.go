@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncryptedNoteExtension marks files saved as encrypted notes
+const EncryptedNoteExtension = ".akx"
+
+// An encrypted note is a header followed by the AES-256-GCM sealed text,
+// with the header as additional data so it can't be altered unnoticed:
+//
+//	magic     "AKX\x00"
+//	version   1 byte
+//	kdf       1 byte, noteKDFArgon2id
+//	time      4 bytes, Argon2id passes
+//	memory    4 bytes, KiB
+//	threads   1 byte
+//	salt      1 byte length, then the salt
+//	cipher    1 byte, noteCipherAESGCM
+//	nonce     1 byte length, then the nonce
+const (
+	noteMagic        = "AKX\x00"
+	noteVersion      = 1
+	noteKDFArgon2id  = 1
+	noteCipherAESGCM = 1
+)
+
+// passphraseTimeout bounds how long opening or saving a note waits for
+// its passphrase
+const passphraseTimeout = 10 * time.Minute
+
+// ErrPassphraseCancelled is returned when the passphrase prompt is dismissed
+var ErrPassphraseCancelled = errors.New("passphrase entry cancelled")
+
+// ErrEncryptedNote is returned when an encrypted note's text would leave the editor
+var ErrEncryptedNote = errors.New("encrypted note")
+
+// PassphraseRequest asks the frontend for the passphrase of a note. It is
+// answered with AnswerPassphrase.
+type PassphraseRequest struct {
+	ID    string `json:"id"`
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	New   bool   `json:"new"`             // a passphrase is being chosen, so ask for it twice
+	Error string `json:"error,omitempty"` // why the last passphrase was refused
+}
+
+// passphraseReply is the frontend's answer to a PassphraseRequest
+type passphraseReply struct {
+	passphrase string
+	cancelled  bool
+}
+
+// noteHeader is the parsed header of an encrypted note
+type noteHeader struct {
+	params kdfParams
+	nonce  []byte
+	size   int // bytes before the ciphertext
+}
+
+// isEncryptedNote reports whether data is an encrypted note
+func isEncryptedNote(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(noteMagic))
+}
+
+// isEncryptedNotePath reports whether a file is named as an encrypted note
+func isEncryptedNotePath(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), EncryptedNoteExtension)
+}
+
+// sniffEncryptedNote reads the start of a file to tell whether it is an encrypted note
+func sniffEncryptedNote(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(noteMagic))
+	_, err = io.ReadFull(file, magic)
+	return err == nil && isEncryptedNote(magic)
+}
+
+// parseNoteHeader reads the header of an encrypted note
+func parseNoteHeader(data []byte) (*noteHeader, error) {
+	if !isEncryptedNote(data) {
+		return nil, fmt.Errorf("not an encrypted note")
+	}
+	r := bytes.NewReader(data[len(noteMagic):])
+	var fixed struct {
+		Version   uint8
+		KDF       uint8
+		TimeCost  uint32
+		MemoryKiB uint32
+		Threads   uint8
+		SaltLen   uint8
+	}
+	if err := binary.Read(r, binary.BigEndian, &fixed); err != nil {
+		return nil, fmt.Errorf("note header is truncated")
+	}
+	if fixed.Version != noteVersion {
+		return nil, fmt.Errorf("note format version %d is not supported", fixed.Version)
+	}
+	if fixed.KDF != noteKDFArgon2id {
+		return nil, fmt.Errorf("note key derivation %d is not supported", fixed.KDF)
+	}
+	// Refuse parameters that would take forever or exhaust memory
+	if fixed.TimeCost == 0 || fixed.TimeCost > 64 || fixed.MemoryKiB > 4<<20 || fixed.Threads == 0 {
+		return nil, fmt.Errorf("note key derivation parameters are out of range")
+	}
+
+	header := &noteHeader{params: kdfParams{
+		Salt:      make([]byte, fixed.SaltLen),
+		TimeCost:  fixed.TimeCost,
+		MemoryKiB: fixed.MemoryKiB,
+		Threads:   fixed.Threads,
+	}}
+	var cipherID, nonceLen uint8
+	if _, err := io.ReadFull(r, header.params.Salt); err != nil {
+		return nil, fmt.Errorf("note header is truncated")
+	}
+	if err := binary.Read(r, binary.BigEndian, &cipherID); err != nil {
+		return nil, fmt.Errorf("note header is truncated")
+	}
+	if cipherID != noteCipherAESGCM {
+		return nil, fmt.Errorf("note cipher %d is not supported", cipherID)
+	}
+	if err := binary.Read(r, binary.BigEndian, &nonceLen); err != nil {
+		return nil, fmt.Errorf("note header is truncated")
+	}
+	header.nonce = make([]byte, nonceLen)
+	if _, err := io.ReadFull(r, header.nonce); err != nil {
+		return nil, fmt.Errorf("note header is truncated")
+	}
+	header.size = len(data) - r.Len()
+	return header, nil
+}
+
+// sealNote encrypts text as an encrypted note with a fresh nonce
+func sealNote(cc *chatCipher, params kdfParams, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, cc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(noteMagic)
+	binary.Write(&buf, binary.BigEndian, struct {
+		Version   uint8
+		KDF       uint8
+		TimeCost  uint32
+		MemoryKiB uint32
+		Threads   uint8
+		SaltLen   uint8
+	}{noteVersion, noteKDFArgon2id, params.TimeCost, params.MemoryKiB, params.Threads, uint8(len(params.Salt))})
+	buf.Write(params.Salt)
+	buf.WriteByte(noteCipherAESGCM)
+	buf.WriteByte(byte(len(nonce)))
+	buf.Write(nonce)
+
+	header := buf.Bytes()
+	return cc.aead.Seal(header, nonce, plaintext, header), nil
+}
+
+// openNote decrypts an encrypted note with a cipher derived from its header
+func openNote(cc *chatCipher, header *noteHeader, data []byte) ([]byte, error) {
+	if len(header.nonce) != cc.aead.NonceSize() {
+		return nil, fmt.Errorf("note nonce has the wrong length")
+	}
+	plaintext, err := cc.aead.Open(nil, header.nonce, data[header.size:], data[:header.size])
+	if err != nil {
+		return nil, fmt.Errorf("incorrect passphrase or damaged note")
+	}
+	return plaintext, nil
+}
+
+// wipeBytes zeroes plaintext that is no longer needed
+func wipeBytes(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// noteKey is the key of an open encrypted note, kept in memory only until
+// its tab is closed so saving doesn't ask for the passphrase again
+type noteKey struct {
+	cipher *chatCipher
+	params kdfParams
+}
+
+// wipe zeroes the key
+func (k *noteKey) wipe() {
+	k.cipher.wipe()
+}
+
+// decryptNote asks for a note's passphrase until it opens the note or the
+// prompt is cancelled
+func (fm *FileManager) decryptNote(filePath string, data []byte) ([]byte, *noteKey, error) {
+	header, err := parseNoteHeader(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", filepath.Base(filePath), err)
+	}
+
+	problem := ""
+	for {
+		passphrase, err := fm.askPassphrase(filePath, false, problem)
+		if err != nil {
+			return nil, nil, err
+		}
+		cc, err := newChatCipher(passphrase, header.params)
+		if err != nil {
+			return nil, nil, err
+		}
+		plaintext, err := openNote(cc, header, data)
+		if err == nil {
+			return plaintext, &noteKey{cipher: cc, params: header.params}, nil
+		}
+		cc.wipe()
+		problem = err.Error()
+	}
+}
+
+// readEncryptedNote decrypts a note for the editor. Its text is returned
+// but not kept, so there is nothing to merge external changes with.
+func (fm *FileManager) readEncryptedNote(filePath string, stat os.FileInfo, raw []byte) (*FileInfo, string, error) {
+	plaintext, key, err := fm.decryptNote(filePath, raw)
+	if err != nil {
+		return nil, "", err
+	}
+	defer wipeBytes(plaintext)
+
+	content, detection, layout, err := fm.readWithDetection(plaintext, defaultEncoding)
+	if err != nil {
+		key.wipe()
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	fm.setOpenFile(filePath, &openFileState{
+		layout:   layout,
+		encoding: detection.Encoding,
+		note:     key,
+		disk:     newDiskState(stat, raw),
+	})
+	fm.addToRecentFiles(filePath)
+
+	return &FileInfo{
+		Path:               filePath,
+		Name:               filepath.Base(filePath),
+		Encoding:           detection.Encoding,
+		EncodingConfidence: 1,
+		LineEnding:         layoutStyle(layout),
+		LineEndingCounts:   layout.counts,
+		TrailingNewline:    strings.HasSuffix(content, "\n"),
+		Language:           detectLanguage(strings.TrimSuffix(filePath, filepath.Ext(filePath)), content),
+		Encrypted:          true,
+		LastSaved:          stat.ModTime().Unix(),
+	}, content, nil
+}
+
+// newNoteKey asks for the passphrase of a note being saved for the first time
+func (fm *FileManager) newNoteKey(filePath string) (*noteKey, error) {
+	passphrase, err := fm.askPassphrase(filePath, true, "")
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+	params, err := defaultKDFParams()
+	if err != nil {
+		return nil, err
+	}
+	cc, err := newChatCipher(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	return &noteKey{cipher: cc, params: params}, nil
+}
+
+// askPassphrase sends a PassphraseRequest to the frontend and waits for
+// AnswerPassphrase
+func (fm *FileManager) askPassphrase(filePath string, isNew bool, problem string) (string, error) {
+	if fm.app == nil || fm.app.EventBus == nil {
+		return "", fmt.Errorf("no window to ask for the passphrase of %s", filepath.Base(filePath))
+	}
+
+	fm.passphraseMu.Lock()
+	fm.nextPassphraseID++
+	id := strconv.Itoa(fm.nextPassphraseID)
+	reply := make(chan passphraseReply, 1)
+	fm.passphrases[id] = reply
+	fm.passphraseMu.Unlock()
+
+	defer func() {
+		fm.passphraseMu.Lock()
+		delete(fm.passphrases, id)
+		fm.passphraseMu.Unlock()
+	}()
+
+	fm.emit(EventFilePassphrase, PassphraseRequest{
+		ID:    id,
+		Path:  filePath,
+		Name:  filepath.Base(filePath),
+		New:   isNew,
+		Error: problem,
+	})
+	select {
+	case answer := <-reply:
+		if answer.cancelled {
+			return "", ErrPassphraseCancelled
+		}
+		return answer.passphrase, nil
+	case <-time.After(passphraseTimeout):
+		return "", ErrPassphraseCancelled
+	}
+}
+
+// AnswerPassphrase answers a PassphraseRequest, or cancels it
+func (fm *FileManager) AnswerPassphrase(id string, passphrase string, cancelled bool) {
+	fm.passphraseMu.Lock()
+	reply, ok := fm.passphrases[id]
+	fm.passphraseMu.Unlock()
+
+	if ok {
+		select {
+		case reply <- passphraseReply{passphrase: passphrase, cancelled: cancelled}:
+		default: // already answered
+		}
+	}
+}
+
+// isEncrypted reports whether a file is an encrypted note, open or not
+func (fm *FileManager) isEncrypted(filePath string) bool {
+	if filePath == "" {
+		return false
+	}
+	if open := fm.getOpenFile(filePath); open != nil && open.note != nil {
+		return true
+	}
+	return isEncryptedNotePath(filePath) || sniffEncryptedNote(filePath)
+}
+
+// openEncryptedNote returns the path of an open encrypted note, or ""
+// if none is open
+func (fm *FileManager) openEncryptedNote() string {
+	fm.openMu.Lock()
+	defer fm.openMu.Unlock()
+
+	for filePath, state := range fm.openFiles {
+		if state.note != nil {
+			return filePath
+		}
+	}
+	return ""
+}
+
+// aiEndpointIsLocal reports whether the configured AI endpoint runs on
+// this machine
+func (a *App) aiEndpointIsLocal() bool {
+	endpoint := a.SettingsManager.Get().AI.Endpoint
+	if endpoint == "" {
+		return true // the built-in default is local Ollama
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
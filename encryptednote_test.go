@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testNoteParams returns cheap Argon2id parameters so the tests run quickly
+func testNoteParams() kdfParams {
+	return kdfParams{Salt: bytes.Repeat([]byte{7}, 16), TimeCost: 1, MemoryKiB: 64, Threads: 1}
+}
+
+// sealTestNote seals text under passphrase and returns the note and its cipher
+func sealTestNote(t *testing.T, passphrase, text string) ([]byte, *chatCipher) {
+	t.Helper()
+	cc, err := newChatCipher(passphrase, testNoteParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := sealNote(cc, testNoteParams(), []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, cc
+}
+
+func TestNoteRoundTrip(t *testing.T) {
+	const text = "meeting notes: the vault code is 4711\n"
+	data, _ := sealTestNote(t, "correct horse", text)
+	if bytes.Contains(data, []byte("vault")) {
+		t.Fatal("sealed note contains plaintext")
+	}
+
+	header, err := parseNoteHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(header.params.Salt, testNoteParams().Salt) || header.params.TimeCost != 1 || header.params.MemoryKiB != 64 || header.params.Threads != 1 {
+		t.Errorf("header params = %+v, want %+v", header.params, testNoteParams())
+	}
+
+	cc, err := newChatCipher("correct horse", header.params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := openNote(cc, header, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != text {
+		t.Errorf("openNote = %q, want %q", plaintext, text)
+	}
+
+	// Each seal uses a fresh nonce
+	again, _ := sealTestNote(t, "correct horse", text)
+	if bytes.Equal(data, again) {
+		t.Error("sealing the same text twice gave identical notes")
+	}
+}
+
+func TestNoteWrongPassphrase(t *testing.T) {
+	data, _ := sealTestNote(t, "correct horse", "secret")
+	header, err := parseNoteHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc, err := newChatCipher("battery staple", header.params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := openNote(cc, header, data); err == nil {
+		t.Errorf("openNote with the wrong passphrase = %q, want an error", plaintext)
+	}
+}
+
+func TestNoteTamperedHeader(t *testing.T) {
+	data, cc := sealTestNote(t, "correct horse", "secret")
+	header, err := parseNoteHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every header byte is either refused by the parser or authenticated
+	// as additional data, even when the key is already known
+	for i := 0; i < header.size; i++ {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 0x01
+
+		tamperedHeader, err := parseNoteHeader(tampered)
+		if err != nil {
+			continue
+		}
+		if _, err := openNote(cc, tamperedHeader, tampered); err == nil {
+			t.Errorf("flipping header byte %d went unnoticed", i)
+		}
+	}
+
+	// So is the ciphertext
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 0x01
+	if _, err := openNote(cc, header, tampered); err == nil {
+		t.Error("flipping a ciphertext byte went unnoticed")
+	}
+}
+
+func TestNoteTruncatedHeader(t *testing.T) {
+	data, _ := sealTestNote(t, "correct horse", "secret")
+	header, err := parseNoteHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < header.size; n++ {
+		if _, err := parseNoteHeader(data[:n]); err == nil {
+			t.Errorf("parseNoteHeader accepted a header cut to %d of %d bytes", n, header.size)
+		}
+	}
+}
+
+func TestNoteKDFParamsOutOfRange(t *testing.T) {
+	cc, err := newChatCipher("correct horse", testNoteParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		params kdfParams
+	}{
+		{"no passes", kdfParams{Salt: []byte{1}, TimeCost: 0, MemoryKiB: 64, Threads: 1}},
+		{"too many passes", kdfParams{Salt: []byte{1}, TimeCost: 65, MemoryKiB: 64, Threads: 1}},
+		{"too much memory", kdfParams{Salt: []byte{1}, TimeCost: 1, MemoryKiB: 4<<20 + 1, Threads: 1}},
+		{"no threads", kdfParams{Salt: []byte{1}, TimeCost: 1, MemoryKiB: 64, Threads: 0}},
+	}
+
+	for _, tt := range tests {
+		data, err := sealNote(cc, tt.params, []byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseNoteHeader(data); err == nil {
+			t.Errorf("%s: parseNoteHeader accepted %+v", tt.name, tt.params)
+		}
+	}
+}
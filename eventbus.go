@@ -7,9 +7,13 @@ import (
 // EventHandler is a function that handles events
 type EventHandler func(data interface{})
 
+// FrontendEmitter forwards an event to the webview
+type FrontendEmitter func(event string, data interface{})
+
 // EventBus provides pub/sub functionality for decoupled communication
 type EventBus struct {
 	handlers map[string][]EventHandler
+	emitter  FrontendEmitter
 	mu       sync.RWMutex
 }
 
@@ -56,6 +60,27 @@ func (eb *EventBus) Publish(event string, data interface{}) {
 	}
 }
 
+// SetFrontendEmitter installs the bridge used by Emit to reach the frontend
+func (eb *EventBus) SetFrontendEmitter(emitter FrontendEmitter) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.emitter = emitter
+}
+
+// Emit publishes an event to Go subscribers and also forwards it to the frontend
+func (eb *EventBus) Emit(event string, data interface{}) {
+	eb.Publish(event, data)
+
+	eb.mu.RLock()
+	emitter := eb.emitter
+	eb.mu.RUnlock()
+
+	if emitter != nil {
+		emitter(event, data)
+	}
+}
+
 // Event types for Akashic
 const (
 	// File events
@@ -65,6 +90,25 @@ const (
 	EventFileClose  = "file.close"
 	EventFileChange = "file.change"
 
+	EventFileExternalChange = "file.externalChange"
+	EventFileDeleted        = "file.deleted"
+	EventFileIndexProgress  = "file.indexProgress"
+	EventFileSearchResults  = "file.searchResults"
+	EventFileAutoSave       = "file.autoSave"
+	EventFileCreated        = "file.created"
+	EventFileRenamed        = "file.renamed"    // also sent for moves
+	EventFilePassphrase     = "file.passphrase" // an encrypted note needs its passphrase; see AnswerPassphrase
+
+	// Git events
+	EventGitStatus = "git.status" // a repository's status after a save or a git operation; GitRepoStatus
+
+	// App events
+	EventAppLaunch = "app.launch" // a launch is waiting in TakeLaunchRequests
+
+	// Workspace events
+	EventWorkspaceIndex         = "workspace.index"
+	EventWorkspaceSearchResults = "workspace.searchResults"
+
 	// Editor events
 	EventEditorChange    = "editor.change"
 	EventEditorSelection = "editor.selection"
@@ -83,6 +127,17 @@ const (
 	EventAIResponse    = "ai.response"
 	EventAIError       = "ai.error"
 	EventAIModelChange = "ai.model.change"
+	EventAIStreamChunk = "ai.stream.chunk" // part of a streamed reply; requestID and chunk
+	EventAIStreamDone  = "ai.stream.done"  // a streamed reply ended or was cancelled
+	EventAIStreamError = "ai.stream.error" // a streamed reply failed; requestID and error
+
+	// Chat history events
+	EventChatLocked    = "chat.locked"
+	EventChatUnlocked  = "chat.unlocked"
+	EventChatIntegrity = "chat.integrity"
+	EventChatBackup    = "chat.backup"
+	EventChatRetention = "chat.retention"
+	EventChatTitle     = "chat.title"
 
 	// Extension events
 	EventExtensionLoad    = "extension.load"
@@ -98,10 +153,15 @@ type FileEventData struct {
 }
 
 type EditorEventData struct {
-	FilePath   string `json:"filePath"`
-	Selection  string `json:"selection,omitempty"`
-	CursorLine int    `json:"cursorLine"`
-	CursorCol  int    `json:"cursorCol"`
+	FilePath       string `json:"filePath"`
+	BufferID       string `json:"bufferId,omitempty"` // the tab, for session tracking
+	Selection      string `json:"selection,omitempty"`
+	CursorLine     int    `json:"cursorLine"`
+	CursorCol      int    `json:"cursorCol"`
+	SelectionStart int    `json:"selectionStart"`
+	SelectionEnd   int    `json:"selectionEnd"`
+	ScrollTop      int    `json:"scrollTop"`
+	ScrollLeft     int    `json:"scrollLeft"`
 }
 
 type AIEventData struct {
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	xunicode "golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+// defaultEncoding is used for new files and whenever detection has nothing to go on
+const defaultEncoding = "UTF-8"
+
+// detectionSampleSize caps how much of a file the heuristics look at
+const detectionSampleSize = 64 * 1024
+
+// textEncoding pairs an encoding name with its codec and byte order mark.
+// A nil codec means UTF-8, which needs no transcoding.
+type textEncoding struct {
+	name  string
+	codec encoding.Encoding
+	bom   []byte
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF32LE = []byte{0xFF, 0xFE, 0x00, 0x00}
+	bomUTF32BE = []byte{0x00, 0x00, 0xFE, 0xFF}
+)
+
+// textEncodings lists the supported encodings in menu order
+var textEncodings = []textEncoding{
+	{name: "UTF-8"},
+	{name: "UTF-8 BOM", bom: bomUTF8},
+	{name: "UTF-16LE BOM", codec: xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM), bom: bomUTF16LE},
+	{name: "UTF-16BE BOM", codec: xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM), bom: bomUTF16BE},
+	{name: "UTF-16LE", codec: xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM)},
+	{name: "UTF-16BE", codec: xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM)},
+	{name: "UTF-32LE BOM", codec: utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM), bom: bomUTF32LE},
+	{name: "UTF-32BE BOM", codec: utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), bom: bomUTF32BE},
+	{name: "UTF-32LE", codec: utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM)},
+	{name: "UTF-32BE", codec: utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM)},
+	{name: "Windows-1252", codec: charmap.Windows1252},
+	{name: "Windows-1250", codec: charmap.Windows1250},
+	{name: "Windows-1251", codec: charmap.Windows1251},
+	{name: "ISO-8859-1", codec: charmap.ISO8859_1},
+	{name: "ISO-8859-2", codec: charmap.ISO8859_2},
+	{name: "ISO-8859-5", codec: charmap.ISO8859_5},
+	{name: "ISO-8859-7", codec: charmap.ISO8859_7},
+	{name: "ISO-8859-9", codec: charmap.ISO8859_9},
+	{name: "ISO-8859-15", codec: charmap.ISO8859_15},
+	{name: "Shift-JIS", codec: japanese.ShiftJIS},
+}
+
+// legacyCandidates are the single-byte encodings tried, in order of
+// preference, when a file is not valid Unicode
+var legacyCandidates = []string{"Windows-1252", "Windows-1250", "Windows-1251", "ISO-8859-7"}
+
+// EncodingDetection is the result of guessing a file's encoding
+type EncodingDetection struct {
+	Encoding   string  `json:"encoding"`
+	Confidence float64 `json:"confidence"` // 0 to 1
+}
+
+// EncodingCheck reports whether text can be saved in an encoding
+type EncodingCheck struct {
+	Encoding       string   `json:"encoding"`
+	Representable  bool     `json:"representable"`
+	Unsupported    int      `json:"unsupported"`    // characters that would be lost
+	UnsupportedSet []string `json:"unsupportedSet"` // a few examples of them
+}
+
+// lookupEncoding finds a supported encoding by name
+func lookupEncoding(name string) (textEncoding, error) {
+	if name == "" {
+		name = defaultEncoding
+	}
+	for _, enc := range textEncodings {
+		if enc.name == name {
+			return enc, nil
+		}
+	}
+	return textEncoding{}, fmt.Errorf("unsupported encoding: %s", name)
+}
+
+// SupportedEncodings returns the names of all supported encodings
+func SupportedEncodings() []string {
+	names := make([]string, len(textEncodings))
+	for i, enc := range textEncodings {
+		names[i] = enc.name
+	}
+	return names
+}
+
+// detectEncoding guesses the encoding of raw file data. A byte order mark
+// is trusted outright; otherwise NUL byte patterns, UTF-8 validity and
+// per-encoding heuristics are tried in turn.
+func detectEncoding(data []byte) EncodingDetection {
+	switch {
+	case bytes.HasPrefix(data, bomUTF32LE):
+		return EncodingDetection{Encoding: "UTF-32LE BOM", Confidence: 1}
+	case bytes.HasPrefix(data, bomUTF32BE):
+		return EncodingDetection{Encoding: "UTF-32BE BOM", Confidence: 1}
+	case bytes.HasPrefix(data, bomUTF8):
+		return EncodingDetection{Encoding: "UTF-8 BOM", Confidence: 1}
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return EncodingDetection{Encoding: "UTF-16LE BOM", Confidence: 1}
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return EncodingDetection{Encoding: "UTF-16BE BOM", Confidence: 1}
+	}
+
+	sample := data
+	if len(sample) > detectionSampleSize {
+		sample = sample[:detectionSampleSize]
+	}
+
+	if detection, ok := detectWideUnicode(sample); ok {
+		return detection
+	}
+
+	if utf8.Valid(data) {
+		return EncodingDetection{Encoding: defaultEncoding, Confidence: 1}
+	}
+
+	if score := scoreShiftJIS(sample); score >= 0.5 {
+		return EncodingDetection{Encoding: "Shift-JIS", Confidence: 0.5 + 0.4*score}
+	}
+
+	best, bestScore, runnerUp := legacyCandidates[0], -2.0, -2.0
+	for _, name := range legacyCandidates {
+		enc, _ := lookupEncoding(name)
+		score := scoreSingleByte(sample, enc.codec)
+		if score > bestScore {
+			best, bestScore, runnerUp = name, score, bestScore
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+
+	confidence := 0.3 + 0.5*max(0, bestScore)
+	if bestScore-runnerUp < 0.05 {
+		confidence /= 2 // another code page fits as well
+	}
+	return EncodingDetection{Encoding: best, Confidence: confidence}
+}
+
+// detectWideUnicode recognizes BOM-less UTF-16 and UTF-32 from the
+// position of NUL bytes, which ASCII-heavy text produces in a fixed pattern
+func detectWideUnicode(sample []byte) (EncodingDetection, bool) {
+	if len(sample) < 4 || bytes.IndexByte(sample, 0) == -1 {
+		return EncodingDetection{}, false
+	}
+
+	var zeros [4]int
+	n := len(sample) &^ 3
+	for i := 0; i < n; i++ {
+		if sample[i] == 0 {
+			zeros[i%4]++
+		}
+	}
+	quarter := float64(n / 4)
+	ratio := func(pos int) float64 { return float64(zeros[pos]) / quarter }
+
+	switch {
+	case ratio(2) > 0.9 && ratio(3) > 0.9 && ratio(0) < 0.1:
+		return EncodingDetection{Encoding: "UTF-32LE", Confidence: 0.9}, true
+	case ratio(0) > 0.9 && ratio(1) > 0.9 && ratio(3) < 0.1:
+		return EncodingDetection{Encoding: "UTF-32BE", Confidence: 0.9}, true
+	}
+
+	even := (ratio(0) + ratio(2)) / 2
+	odd := (ratio(1) + ratio(3)) / 2
+	switch {
+	case odd > 0.3 && even < 0.05:
+		return EncodingDetection{Encoding: "UTF-16LE", Confidence: min(0.9, 0.5+odd/2)}, true
+	case even > 0.3 && odd < 0.05:
+		return EncodingDetection{Encoding: "UTF-16BE", Confidence: min(0.9, 0.5+even/2)}, true
+	}
+	return EncodingDetection{}, false
+}
+
+// scoreShiftJIS returns the share of double-byte characters with a high
+// trail byte, as in kana and kanji, or -1 if the data is not Shift-JIS
+func scoreShiftJIS(sample []byte) float64 {
+	pairs, highTrail := 0, 0
+	for i := 0; i < len(sample); {
+		b := sample[i]
+		switch {
+		case b < 0x80 || (b >= 0xA1 && b <= 0xDF):
+			i++
+		case (b >= 0x81 && b <= 0x9F) || (b >= 0xE0 && b <= 0xFC):
+			if i+1 == len(sample) {
+				i++ // cut off by the sample
+				continue
+			}
+			trail := sample[i+1]
+			if trail < 0x40 || trail == 0x7F || trail > 0xFC {
+				return -1
+			}
+			pairs++
+			if trail >= 0x80 {
+				highTrail++
+			}
+			i += 2
+		default:
+			return -1
+		}
+	}
+	if pairs == 0 {
+		return 0
+	}
+	return float64(highTrail) / float64(pairs)
+}
+
+// scoreSingleByte rates how plausible text decoded with a single-byte code
+// page looks, from -1 to 1. Letters score, control characters count
+// against, and so do words mixing scripts, capitals mid-word or runs of
+// accented Latin letters, which is what text in another code page decodes to.
+func scoreSingleByte(sample []byte, codec encoding.Encoding) float64 {
+	decoded, err := codec.NewDecoder().Bytes(sample)
+	if err != nil {
+		return -1
+	}
+	runes := []rune(string(decoded))
+
+	isHighLetter := func(i int) bool {
+		return i >= 0 && i < len(runes) && runes[i] >= 0x80 && unicode.IsLetter(runes[i])
+	}
+	isASCIILetter := func(i int) bool {
+		return i >= 0 && i < len(runes) && runes[i] < 0x80 && unicode.IsLetter(runes[i])
+	}
+
+	high, score := 0, 0.0
+	for i, r := range runes {
+		if r < 0x80 {
+			continue
+		}
+		high++
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r):
+			score--
+		case unicode.IsLetter(r):
+			score++
+			if unicode.Is(unicode.Latin, r) {
+				if isHighLetter(i-1) && isHighLetter(i+1) {
+					score -= 1.5
+				}
+			} else if isASCIILetter(i-1) || isASCIILetter(i+1) {
+				score -= 1.5
+			}
+			if unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]) {
+				score-- // capitals don't follow lowercase mid-word
+			}
+		}
+	}
+	if high == 0 {
+		return 1
+	}
+	return max(-1, score/float64(high))
+}
+
+// decodeText converts raw file data to a string. An empty name detects the
+// encoding; otherwise the named encoding is used as-is.
+func decodeText(data []byte, name string) (string, EncodingDetection, error) {
+	detection := EncodingDetection{Encoding: name, Confidence: 1}
+	if name == "" {
+		detection = detectEncoding(data)
+	}
+
+	enc, err := lookupEncoding(detection.Encoding)
+	if err != nil {
+		return "", detection, err
+	}
+
+	data = bytes.TrimPrefix(data, enc.bom)
+	if enc.codec == nil {
+		return string(data), detection, nil
+	}
+
+	decoded, err := enc.codec.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", detection, fmt.Errorf("failed to decode %s: %w", enc.name, err)
+	}
+	return string(decoded), detection, nil
+}
+
+// encodeText converts text to the named encoding, including its byte order
+// mark. It fails rather than silently dropping characters the encoding lacks.
+func encodeText(text string, name string) ([]byte, error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte(text)
+	if enc.codec != nil {
+		body, err = enc.codec.NewEncoder().Bytes(body)
+		if err != nil {
+			if check := checkEncoding(text, enc); !check.Representable {
+				return nil, fmt.Errorf("%d characters cannot be saved as %s (e.g. %q)", check.Unsupported, enc.name, check.UnsupportedSet[0])
+			}
+			return nil, fmt.Errorf("failed to encode %s: %w", enc.name, err)
+		}
+	}
+
+	return append(append([]byte{}, enc.bom...), body...), nil
+}
+
+// checkEncoding counts the characters of text that enc cannot represent
+func checkEncoding(text string, enc textEncoding) EncodingCheck {
+	check := EncodingCheck{Encoding: enc.name, Representable: true, UnsupportedSet: []string{}}
+	if enc.codec == nil {
+		return check
+	}
+
+	encoder := enc.codec.NewEncoder()
+	supported := make(map[rune]bool)
+	for _, r := range text {
+		ok, seen := supported[r]
+		if !seen {
+			_, err := encoder.String(string(r))
+			ok = err == nil
+			supported[r] = ok
+			if !ok && len(check.UnsupportedSet) < 10 {
+				check.UnsupportedSet = append(check.UnsupportedSet, string(r))
+			}
+		}
+		if !ok {
+			check.Unsupported++
+		}
+	}
+	check.Representable = check.Unsupported == 0
+	return check
+}
+
+// CheckEncoding reports whether text can be converted to the named encoding
+func CheckEncoding(text string, name string) (*EncodingCheck, error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	check := checkEncoding(text, enc)
+	return &check, nil
+}
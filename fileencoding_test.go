@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestDetectEncoding(t *testing.T) {
+	encode := func(name, text string) []byte {
+		enc, err := lookupEncoding(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := []byte(text)
+		if enc.codec != nil {
+			if data, err = enc.codec.NewEncoder().Bytes(data); err != nil {
+				t.Fatalf("failed to encode %q as %s: %v", text, name, err)
+			}
+		}
+		return append(append([]byte(nil), enc.bom...), data...)
+	}
+	const english = "The quick brown fox jumps over the lazy dog.\r\n"
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "UTF-8"},
+		{"ASCII", []byte(english), "UTF-8"},
+		{"UTF-8", []byte("Grüße aus Köln, 東京\n"), "UTF-8"},
+		{"UTF-8 BOM", encode("UTF-8 BOM", english), "UTF-8 BOM"},
+		{"UTF-16LE BOM", encode("UTF-16LE BOM", english), "UTF-16LE BOM"},
+		{"UTF-16BE BOM", encode("UTF-16BE BOM", english), "UTF-16BE BOM"},
+		{"UTF-32LE BOM", encode("UTF-32LE BOM", english), "UTF-32LE BOM"},
+		{"UTF-32BE BOM", encode("UTF-32BE BOM", english), "UTF-32BE BOM"},
+		{"UTF-16LE", encode("UTF-16LE", english), "UTF-16LE"},
+		{"UTF-16BE", encode("UTF-16BE", english), "UTF-16BE"},
+		{"UTF-32LE", encode("UTF-32LE", english), "UTF-32LE"},
+		{"UTF-32BE", encode("UTF-32BE", english), "UTF-32BE"},
+		{"Windows-1252", encode("Windows-1252", "Le café était déjà fermé, à côté de l'hôtel.\r\n"), "Windows-1252"},
+		{"Windows-1251", encode("Windows-1251", "Привет, как дела? Это простой тест.\r\n"), "Windows-1251"},
+		{"Windows-1250", encode("Windows-1250", "Příliš žluťoučký kůň úpěl ďábelské ódy.\r\n"), "Windows-1250"},
+		{"ISO-8859-7", encode("ISO-8859-7", "Καλημέρα κόσμε, αυτό είναι ένα απλό κείμενο.\r\n"), "ISO-8859-7"},
+		{"Shift-JIS", encode("Shift-JIS", "こんにちは、世界。日本語のテキストです。\r\n"), "Shift-JIS"},
+	}
+
+	for _, tt := range tests {
+		got := detectEncoding(tt.data)
+		if got.Encoding != tt.want {
+			t.Errorf("%s: detected %s, want %s", tt.name, got.Encoding, tt.want)
+		}
+		if got.Confidence <= 0 || got.Confidence > 1 {
+			t.Errorf("%s: confidence %v is out of range", tt.name, got.Confidence)
+		}
+	}
+}
@@ -1,36 +1,76 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // FileInfo represents metadata about an open file
 type FileInfo struct {
-	Path       string `json:"path"`
-	Name       string `json:"name"`
-	Encoding   string `json:"encoding"`
-	LineEnding string `json:"lineEnding"` // "CRLF" or "LF"
-	IsDirty    bool   `json:"isDirty"`
-	IsNewFile  bool   `json:"isNewFile"`
-	LastSaved  int64  `json:"lastSaved"`
+	Path               string           `json:"path"`
+	Name               string           `json:"name"`
+	Encoding           string           `json:"encoding"`           // see SupportedEncodings
+	EncodingConfidence float64          `json:"encodingConfidence"` // how sure detection was, 0 to 1
+	LineEnding         string           `json:"lineEnding"`         // "CRLF", "LF", "CR" or "Mixed"
+	LineEndingCounts   LineEndingCounts `json:"lineEndingCounts"`
+	TrailingNewline    bool             `json:"trailingNewline"` // the file ends with a line break
+	Language           string           `json:"language"`        // a Language ID from the registry
+	IsDirty            bool             `json:"isDirty"`
+	IsNewFile          bool             `json:"isNewFile"`
+	LastSaved          int64            `json:"lastSaved"`
+	LargeFile          bool             `json:"largeFile"` // opened in large-file mode; read with ReadLines
+	ReadOnly           bool             `json:"readOnly"`
+	Binary             bool             `json:"binary"`      // opened in the hex view; read with ReadHex
+	BinaryKind         string           `json:"binaryKind"`  // such as "PNG image", if known
+	Archive            string           `json:"archive"`     // the archive kind; its files are listed with ListArchive
+	Compression        string           `json:"compression"` // CompressionGzip if decompressed to open and recompressed to save
+	Encrypted          bool             `json:"encrypted"`   // an encrypted note, decrypted in memory only
+	Size               int64            `json:"size"`
 }
 
 // FileManager handles all file operations
 type FileManager struct {
-	app            *App
-	recentFiles    []string
-	maxRecentFiles int
-	settingsDir    string
+	app              *App
+	recentFiles      []string
+	maxRecentFiles   int
+	settingsDir      string
+	watcher          *FileWatcher
+	openMu           sync.Mutex
+	openFiles        map[string]*openFileState // by path
+	largeMu          sync.Mutex
+	largeFiles       map[string]*largeFile         // by path
+	searches         map[string]context.CancelFunc // large-file searches by request ID
+	workspaceMu      sync.Mutex
+	workspace        *Workspace // the open folder, if any
+	passphraseMu     sync.Mutex
+	passphrases      map[string]chan passphraseReply // waiting passphrase prompts by request ID
+	nextPassphraseID int
 }
 
+// openFileState is what the editor last read from or wrote to a file
+type openFileState struct {
+	layout      *lineLayout // original line endings
+	content     string      // decoded text, the base for merging external changes
+	encoding    string
+	compression string    // CompressionGzip if the file on disk is compressed
+	note        *noteKey  // the key of an encrypted note, whose content is not kept
+	disk        diskState // of the file as stored, compressed or not
+}
+
+// ErrExternalChange is returned when a file changed on disk since it was
+// last read or saved. Saving again requires OverwriteFile or a merge first.
+var ErrExternalChange = errors.New("file changed on disk")
+
 // NewFileManager creates a new FileManager instance
 func NewFileManager(app *App) *FileManager {
 	return &FileManager{
@@ -38,6 +78,10 @@ func NewFileManager(app *App) *FileManager {
 		recentFiles:    make([]string, 0),
 		maxRecentFiles: 10,
 		settingsDir:    getSettingsDir(),
+		openFiles:      make(map[string]*openFileState),
+		largeFiles:     make(map[string]*largeFile),
+		searches:       make(map[string]context.CancelFunc),
+		passphrases:    make(map[string]chan passphraseReply),
 	}
 }
 
@@ -57,6 +101,20 @@ func (fm *FileManager) ensureSettingsDir() error {
 
 // ReadFile opens and reads a file, returning content and metadata
 func (fm *FileManager) ReadFile(filePath string) (*FileInfo, string, error) {
+	return fm.ReadFileWithEncoding(filePath, "")
+}
+
+// ReadFileWithEncoding reads a file using the named encoding, or detects
+// the encoding when name is empty. Detecting a binary file instead returns
+// it as one, with no content. Gzip files are decompressed, archives are
+// returned with no content for ListArchive to list, and a path into an
+// archive such as "bundle.zip!/config/app.yaml" reads that file read-only.
+// Encrypted notes are decrypted after asking for their passphrase.
+func (fm *FileManager) ReadFileWithEncoding(filePath string, encodingName string) (*FileInfo, string, error) {
+	if archivePath, member, ok := splitArchivePath(filePath); ok {
+		return fm.openArchiveMember(archivePath, member, encodingName)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to open file: %w", err)
@@ -69,21 +127,60 @@ func (fm *FileManager) ReadFile(filePath string) (*FileInfo, string, error) {
 		return nil, "", fmt.Errorf("failed to stat file: %w", err)
 	}
 
+	raw, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if isEncryptedNote(raw) {
+		return fm.readEncryptedNote(filePath, stat, raw)
+	}
+	data, compression := raw, ""
+	switch kind := detectArchive(raw); kind {
+	case ArchiveZip, ArchiveTar, ArchiveTarGz:
+		fm.addToRecentFiles(filePath)
+		return archiveFileInfo(filePath, stat, kind), "", nil
+	case CompressionGzip:
+		if data, err = fm.decompress(filePath, raw); err != nil {
+			return nil, "", err
+		}
+		compression = CompressionGzip
+	}
+	if encodingName == "" {
+		if detection := detectBinary(data); detection.Binary {
+			if compression != "" {
+				detection = detectBinary(raw) // shown compressed, as it is on disk
+			}
+			fm.addToRecentFiles(filePath)
+			return binaryFileInfo(filePath, stat, detection), "", nil
+		}
+	}
+
 	// Detect encoding and read content
-	reader := bufio.NewReader(file)
-	content, encoding, lineEnding, err := fm.readWithDetection(reader)
+	content, detection, layout, err := fm.readWithDetection(data, encodingName)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to read file: %w", err)
 	}
+	fm.setOpenFile(filePath, &openFileState{
+		layout:      layout,
+		content:     content,
+		encoding:    detection.Encoding,
+		compression: compression,
+		disk:        newDiskState(stat, raw),
+	})
 
 	fileInfo := &FileInfo{
-		Path:       filePath,
-		Name:       filepath.Base(filePath),
-		Encoding:   encoding,
-		LineEnding: lineEnding,
-		IsDirty:    false,
-		IsNewFile:  false,
-		LastSaved:  stat.ModTime().Unix(),
+		Path:               filePath,
+		Name:               filepath.Base(filePath),
+		Encoding:           detection.Encoding,
+		EncodingConfidence: detection.Confidence,
+		LineEnding:         layoutStyle(layout),
+		LineEndingCounts:   layout.counts,
+		TrailingNewline:    strings.HasSuffix(content, "\n"),
+		Language:           detectLanguage(strings.TrimSuffix(filePath, ".gz"), content),
+		Compression:        compression,
+		IsDirty:            false,
+		IsNewFile:          false,
+		LastSaved:          stat.ModTime().Unix(),
 	}
 
 	// Add to recent files
@@ -92,67 +189,118 @@ func (fm *FileManager) ReadFile(filePath string) (*FileInfo, string, error) {
 	return fileInfo, content, nil
 }
 
-// readWithDetection reads content and detects encoding/line endings
-func (fm *FileManager) readWithDetection(reader *bufio.Reader) (string, string, string, error) {
-	var content strings.Builder
-	var lineEnding string
-	hasCRLF := false
-	hasLF := false
-
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil && err != io.EOF {
-			return "", "", "", err
-		}
+// readWithDetection decodes content and detects encoding/line endings.
+// Line breaks in the returned content are always "\n".
+func (fm *FileManager) readWithDetection(data []byte, encodingName string) (string, EncodingDetection, *lineLayout, error) {
+	text, detection, err := decodeText(data, encodingName)
+	if err != nil {
+		return "", detection, nil, err
+	}
 
-		// Detect line endings
-		if strings.HasSuffix(line, "\r\n") {
-			hasCRLF = true
-			line = strings.TrimSuffix(line, "\r\n")
-		} else if strings.HasSuffix(line, "\n") {
-			hasLF = true
-			line = strings.TrimSuffix(line, "\n")
-		}
+	content, layout := splitLineEndings(text)
+	return content, detection, layout, nil
+}
 
-		content.WriteString(line)
-		if err != io.EOF {
-			content.WriteString("\n")
-		}
+// WriteFile saves content to a file in the given encoding, including its
+// byte order mark if it has one. A lineEnding of Mixed keeps each line's
+// original ending where the file's layout is known; any other style
+// normalizes the whole file to it.
+func (fm *FileManager) WriteFile(filePath string, content string, lineEnding string, encodingName string) (*FileInfo, error) {
+	return fm.writeFile(filePath, content, lineEnding, encodingName, false)
+}
 
-		if err == io.EOF {
-			break
+// OverwriteFile saves like WriteFile, replacing the file even if it
+// changed on disk since it was opened
+func (fm *FileManager) OverwriteFile(filePath string, content string, lineEnding string, encodingName string) (*FileInfo, error) {
+	return fm.writeFile(filePath, content, lineEnding, encodingName, true)
+}
+
+// writeFile saves a file, refusing with ErrExternalChange unless force is
+// set if another program changed it since it was last read or saved. A
+// file opened compressed is compressed again, and an encrypted note, or
+// any file named like one, encrypted.
+func (fm *FileManager) writeFile(filePath string, content string, lineEnding string, encodingName string, force bool) (*FileInfo, error) {
+	if _, member, ok := splitArchivePath(filePath); ok {
+		return nil, fmt.Errorf("%s: %w", member, ErrArchiveMember)
+	}
+	open := fm.getOpenFile(filePath)
+	var key *noteKey
+	if open != nil {
+		key = open.note
+	}
+	encrypt := key != nil || isEncryptedNotePath(filePath)
+	compression := ""
+	if !encrypt {
+		compression = compressionFor(filePath, open)
+	}
+	if compression == "" && !encrypt {
+		if err := checkNotBinary(filePath); err != nil {
+			return nil, err
 		}
 	}
 
-	// Determine line ending type
-	if hasCRLF && !hasLF {
-		lineEnding = "CRLF"
-	} else if hasLF && !hasCRLF {
-		lineEnding = "LF"
-	} else {
-		lineEnding = "CRLF" // Default to CRLF on Windows
+	var layout *lineLayout
+	if open != nil {
+		if !force {
+			same, _, err := open.disk.sameFile(filePath, fm.largeFileThreshold())
+			if err != nil {
+				return nil, fmt.Errorf("failed to check file: %w", err)
+			}
+			if !same {
+				return nil, fmt.Errorf("%s: %w since it was opened", filepath.Base(filePath), ErrExternalChange)
+			}
+		}
+		layout = open.layout
 	}
 
-	// For now, assume UTF-8. Could be extended to detect BOM
-	encoding := "UTF-8"
-
-	return content.String(), encoding, lineEnding, nil
-}
-
-// WriteFile saves content to a file
-func (fm *FileManager) WriteFile(filePath string, content string, lineEnding string) (*FileInfo, error) {
 	// Convert line endings if needed
+	if lineEnding == "" || (lineEnding == LineEndingMixed && layout == nil) {
+		// A mixed file saved under a new name has no known layout
+		lineEnding = defaultLineEnding
+	}
+
 	var normalizedContent string
-	if lineEnding == "CRLF" {
-		normalizedContent = strings.ReplaceAll(content, "\n", "\r\n")
+	if lineEnding == LineEndingMixed {
+		normalizedContent = applyLineLayout(content, layout)
 	} else {
-		normalizedContent = strings.ReplaceAll(content, "\r\n", "\n")
+		var err error
+		normalizedContent, err = normalizeLineEndings(content, lineEnding)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if encodingName == "" || encrypt {
+		encodingName = defaultEncoding // notes are always UTF-8
+	}
+	data, err := encodeText(normalizedContent, encodingName)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := data
+	newKey := encrypt && key == nil
+	if newKey {
+		if key, err = fm.newNoteKey(filePath); err != nil {
+			return nil, err
+		}
+	}
+	if encrypt {
+		raw, err = sealNote(key.cipher, key.params, data)
+		wipeBytes(data)
+	} else if compression == CompressionGzip {
+		raw, err = gzipBytes(data)
 	}
 
 	// Write to file
-	err := os.WriteFile(filePath, []byte(normalizedContent), 0644)
+	if err == nil {
+		err = writeFileAtomic(filePath, raw, fm.backupMode())
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to write file: %w", err)
+		if newKey {
+			key.wipe()
+		}
+		return nil, err
 	}
 
 	// Get updated file info
@@ -161,14 +309,40 @@ func (fm *FileManager) WriteFile(filePath string, content string, lineEnding str
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
+	savedContent, savedLayout := splitLineEndings(normalizedContent)
+	state := &openFileState{
+		layout:      savedLayout,
+		content:     savedContent,
+		encoding:    encodingName,
+		compression: compression,
+		note:        key,
+		disk:        newDiskState(stat, raw),
+	}
+	if encrypt {
+		state.content = ""
+	}
+	fm.setOpenFile(filePath, state)
+	if lineEnding == LineEndingMixed {
+		lineEnding = layoutStyle(savedLayout) // edits may have left a single style
+	}
+	if !encrypt {
+		fm.recordHistory(filePath, data, encodingName, lineEnding, stat.ModTime())
+	}
+
 	fileInfo := &FileInfo{
-		Path:       filePath,
-		Name:       filepath.Base(filePath),
-		Encoding:   "UTF-8",
-		LineEnding: lineEnding,
-		IsDirty:    false,
-		IsNewFile:  false,
-		LastSaved:  stat.ModTime().Unix(),
+		Path:               filePath,
+		Name:               filepath.Base(filePath),
+		Encoding:           encodingName,
+		EncodingConfidence: 1,
+		LineEnding:         lineEnding,
+		LineEndingCounts:   savedLayout.counts,
+		TrailingNewline:    strings.HasSuffix(savedContent, "\n"),
+		Language:           detectLanguage(strings.TrimSuffix(strings.TrimSuffix(filePath, ".gz"), EncryptedNoteExtension), savedContent),
+		Compression:        compression,
+		Encrypted:          encrypt,
+		IsDirty:            false,
+		IsNewFile:          false,
+		LastSaved:          stat.ModTime().Unix(),
 	}
 
 	// Add to recent files
@@ -177,27 +351,139 @@ func (fm *FileManager) WriteFile(filePath string, content string, lineEnding str
 	return fileInfo, nil
 }
 
+// recordHistory adds a saved version to the local file history in the
+// background so large files don't hold up the save
+func (fm *FileManager) recordHistory(filePath string, data []byte, encodingName string, lineEnding string, savedAt time.Time) {
+	if fm.app == nil || fm.app.History == nil {
+		return
+	}
+	go func() {
+		if err := fm.app.History.Record(filePath, data, encodingName, lineEnding, savedAt); err != nil {
+			fmt.Printf("Failed to record history for %s: %v\n", filePath, err)
+		}
+	}()
+}
+
+// backupMode returns the configured backup-on-save mode
+func (fm *FileManager) backupMode() string {
+	if fm.app == nil || fm.app.SettingsManager == nil {
+		return BackupOnSaveNone
+	}
+	return fm.app.SettingsManager.Get().Editor.BackupOnSave
+}
+
+// setOpenFile remembers a file as last read or written and watches it
+// for changes by other programs
+func (fm *FileManager) setOpenFile(filePath string, state *openFileState) {
+	fm.openMu.Lock()
+	if previous := fm.openFiles[filePath]; previous != nil && previous.note != nil && previous.note != state.note {
+		previous.note.wipe()
+	}
+	fm.openFiles[filePath] = state
+	fm.openMu.Unlock()
+
+	if fm.watcher != nil {
+		fm.watcher.Watch(filePath, state.disk)
+	}
+}
+
+// getOpenFile returns what is remembered about a file, or nil
+func (fm *FileManager) getOpenFile(filePath string) *openFileState {
+	fm.openMu.Lock()
+	defer fm.openMu.Unlock()
+
+	return fm.openFiles[filePath]
+}
+
+// ForgetFile drops what is remembered about a file once its tab is
+// closed, wiping the key of an encrypted note
+func (fm *FileManager) ForgetFile(filePath string) {
+	fm.openMu.Lock()
+	if state := fm.openFiles[filePath]; state != nil && state.note != nil {
+		state.note.wipe()
+	}
+	delete(fm.openFiles, filePath)
+	fm.openMu.Unlock()
+
+	if fm.watcher != nil {
+		fm.watcher.Unwatch(filePath)
+	}
+	fm.closeLargeFile(filePath)
+}
+
+// MergeExternalChanges merges the version of a file now on disk into
+// content, the editor's unsaved buffer, using the version last read or
+// saved as the common base. The disk version becomes the new base, so the
+// merged buffer can then be saved normally.
+func (fm *FileManager) MergeExternalChanges(filePath string, content string) (*MergeResult, error) {
+	open := fm.getOpenFile(filePath)
+	if open == nil {
+		return nil, fmt.Errorf("%s is not open", filepath.Base(filePath))
+	}
+	if open.note != nil {
+		return nil, fmt.Errorf("%s is an %w and can't be merged; reload or overwrite it", filepath.Base(filePath), ErrEncryptedNote)
+	}
+
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	data := raw
+	if open.compression == CompressionGzip {
+		if data, err = fm.decompress(filePath, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	theirs, _, layout, err := fm.readWithDetection(data, open.encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	result := merge3(open.content, content, theirs)
+	fm.setOpenFile(filePath, &openFileState{
+		layout:      layout,
+		content:     theirs,
+		encoding:    open.encoding,
+		compression: open.compression,
+		disk:        newDiskState(stat, raw),
+	})
+	return &result, nil
+}
+
+// layoutStyle reports the line ending style of a layout, defaulting for
+// files without any line break
+func layoutStyle(layout *lineLayout) string {
+	if style := layout.counts.style(); style != "" {
+		return style
+	}
+	return defaultLineEnding
+}
+
 // NewFile creates a new empty file
 func (fm *FileManager) NewFile() *FileInfo {
 	return &FileInfo{
-		Path:       "",
-		Name:       "Untitled",
-		Encoding:   "UTF-8",
-		LineEnding: "CRLF",
-		IsDirty:    false,
-		IsNewFile:  true,
-		LastSaved:  0,
+		Path:               "",
+		Name:               "Untitled",
+		Encoding:           defaultEncoding,
+		EncodingConfidence: 1,
+		LineEnding:         defaultLineEnding,
+		Language:           PlainTextLanguage,
+		IsDirty:            false,
+		IsNewFile:          true,
+		LastSaved:          0,
 	}
 }
 
 // OpenFileDialog shows a file open dialog and returns selected path
 func (fm *FileManager) OpenFileDialog() (string, error) {
 	selection, err := runtime.OpenFileDialog(fm.app.ctx, runtime.OpenDialogOptions{
-		Title: "Open File",
-		Filters: []runtime.FileFilter{
-			{DisplayName: "Text Files (*.txt)", Pattern: "*.txt"},
-			{DisplayName: "All Files (*.*)", Pattern: "*.*"},
-		},
+		Title:   "Open File",
+		Filters: fileDialogFilters(""),
 	})
 	if err != nil {
 		return "", err
@@ -205,15 +491,20 @@ func (fm *FileManager) OpenFileDialog() (string, error) {
 	return selection, nil
 }
 
-// SaveFileDialog shows a save file dialog and returns selected path
-func (fm *FileManager) SaveFileDialog(defaultName string) (string, error) {
+// OpenFolderDialog shows a folder picker and returns the selected path
+func (fm *FileManager) OpenFolderDialog() (string, error) {
+	return runtime.OpenDirectoryDialog(fm.app.ctx, runtime.OpenDialogOptions{
+		Title: "Open Folder",
+	})
+}
+
+// SaveFileDialog shows a save file dialog and returns selected path. The
+// language's filter is offered first.
+func (fm *FileManager) SaveFileDialog(defaultName string, language string) (string, error) {
 	selection, err := runtime.SaveFileDialog(fm.app.ctx, runtime.SaveDialogOptions{
 		Title:           "Save File",
 		DefaultFilename: defaultName,
-		Filters: []runtime.FileFilter{
-			{DisplayName: "Text Files (*.txt)", Pattern: "*.txt"},
-			{DisplayName: "All Files (*.*)", Pattern: "*.*"},
-		},
+		Filters:         fileDialogFilters(language),
 	})
 	if err != nil {
 		return "", err
@@ -281,7 +572,26 @@ func (fm *FileManager) ClearRecentFiles() {
 	fm.saveRecentFiles()
 }
 
+// emit forwards an event to the frontend when the file manager is attached to an app
+func (fm *FileManager) emit(event string, data interface{}) {
+	if fm.app != nil && fm.app.EventBus != nil {
+		fm.app.EventBus.Emit(event, data)
+	}
+}
+
 // Startup initializes the file manager
 func (fm *FileManager) Startup() {
 	fm.loadRecentFiles()
+
+	fm.watcher = NewFileWatcher(fm.largeFileThreshold, func(event string, data FileChangeEventData) {
+		fm.emit(event, data)
+	})
+}
+
+// Shutdown stops watching open files and indexing the workspace
+func (fm *FileManager) Shutdown() {
+	if fm.watcher != nil {
+		fm.watcher.Close()
+	}
+	fm.CloseFolder()
 }
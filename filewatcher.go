@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce lets a burst of change notifications settle before the file is checked
+const watchDebounce = 200 * time.Millisecond
+
+// watchPollInterval is how often files are checked when notifications aren't available
+const watchPollInterval = 2 * time.Second
+
+// modTimeGranularity is the coarsest modification time resolution in use
+// (FAT's two seconds). A file read this soon after its last change could
+// change again without its modification time moving.
+const modTimeGranularity = 2 * time.Second
+
+// FileChangeEventData is published when an open file changes on disk
+type FileChangeEventData struct {
+	Path    string `json:"path"`
+	ModTime int64  `json:"modTime"`
+}
+
+// diskState identifies a version of a file on disk
+type diskState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	hash    string    // empty if the contents weren't hashed
+	seenAt  time.Time // when the state was read
+}
+
+// newDiskState describes a file just read as raw
+func newDiskState(info os.FileInfo, raw []byte) diskState {
+	return diskState{exists: true, modTime: info.ModTime(), size: info.Size(), hash: hashBytes(raw), seenAt: time.Now()}
+}
+
+// hashBytes returns the hex SHA-256 of data
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile returns the hex SHA-256 of a file's contents
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// racy reports whether the file could have changed within the same
+// modification time tick it was read in
+func (s diskState) racy() bool {
+	return s.seenAt.Sub(s.modTime) < modTimeGranularity
+}
+
+// sameFile reports whether a file still matches a known state. A new size
+// is a change without reading the file. The contents are only hashed when
+// the size is the same and the modification time moved, or stayed put but
+// too soon after the last read to be trusted; files over maxHashSize are
+// judged by size and modification time alone.
+func (s diskState) sameFile(path string, maxHashSize int64) (bool, diskState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return !s.exists, diskState{}, nil
+		}
+		return false, diskState{}, err
+	}
+
+	current := diskState{exists: true, modTime: info.ModTime(), size: info.Size(), seenAt: time.Now()}
+	if !s.exists || info.Size() != s.size {
+		return false, current, nil
+	}
+	sameTime := info.ModTime().Equal(s.modTime)
+	if sameTime && !s.racy() {
+		return true, s, nil
+	}
+	if s.hash == "" || info.Size() > maxHashSize {
+		if sameTime {
+			return true, s, nil
+		}
+		return false, current, nil
+	}
+
+	current.hash, err = hashFile(path)
+	if err != nil {
+		return false, diskState{}, err
+	}
+	return current.hash == s.hash, current, nil
+}
+
+// FileWatcher reports changes made by other programs to open files. It
+// watches each file's directory, so files replaced by rename are still
+// seen, and falls back to polling where notifications aren't available.
+type FileWatcher struct {
+	mu          sync.Mutex
+	fsw         *fsnotify.Watcher // nil if the platform watcher couldn't start
+	files       map[string]*watchedFile
+	dirs        map[string]int // watched directories and how many files use them
+	notify      func(event string, data FileChangeEventData)
+	maxHashSize func() int64 // files larger than this are never hashed
+	pending     map[string]*time.Timer
+	done        chan struct{}
+}
+
+// watchedFile is the last state of a file seen by the watcher
+type watchedFile struct {
+	state  diskState
+	polled bool
+}
+
+// NewFileWatcher creates a watcher that calls notify with
+// EventFileExternalChange or EventFileDeleted. Files over maxHashSize are
+// compared by size and modification time only.
+func NewFileWatcher(maxHashSize func() int64, notify func(event string, data FileChangeEventData)) *FileWatcher {
+	w := &FileWatcher{
+		files:       make(map[string]*watchedFile),
+		dirs:        make(map[string]int),
+		notify:      notify,
+		maxHashSize: maxHashSize,
+		pending:     make(map[string]*time.Timer),
+		done:        make(chan struct{}),
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("File notifications unavailable, polling instead: %v\n", err)
+	} else {
+		w.fsw = fsw
+		go w.listen()
+	}
+	go w.poll()
+
+	return w
+}
+
+// Watch starts watching a file, or refreshes the state an already watched
+// file is compared against. Call it after every read and write so the
+// editor's own saves aren't reported.
+func (w *FileWatcher) Watch(path string, state diskState) {
+	path = filepath.Clean(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if file, ok := w.files[path]; ok {
+		file.state = state
+		return
+	}
+
+	file := &watchedFile{state: state, polled: w.fsw == nil}
+	if w.fsw != nil {
+		dir := filepath.Dir(path)
+		if w.dirs[dir] == 0 {
+			if err := w.fsw.Add(dir); err != nil {
+				fmt.Printf("Failed to watch %s, polling instead: %v\n", dir, err)
+				file.polled = true
+			}
+		}
+		if !file.polled {
+			w.dirs[dir]++
+		}
+	}
+	w.files[path] = file
+}
+
+// Unwatch stops watching a file
+func (w *FileWatcher) Unwatch(path string) {
+	path = filepath.Clean(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, ok := w.files[path]
+	if !ok {
+		return
+	}
+	delete(w.files, path)
+	if timer, ok := w.pending[path]; ok {
+		timer.Stop()
+		delete(w.pending, path)
+	}
+
+	if w.fsw != nil && !file.polled {
+		dir := filepath.Dir(path)
+		w.dirs[dir]--
+		if w.dirs[dir] == 0 {
+			delete(w.dirs, dir)
+			w.fsw.Remove(dir)
+		}
+	}
+}
+
+// Close stops the watcher
+func (w *FileWatcher) Close() {
+	close(w.done)
+	if w.fsw != nil {
+		w.fsw.Close()
+	}
+}
+
+// listen schedules a check of each watched file a notification mentions
+func (w *FileWatcher) listen() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.schedule(filepath.Clean(event.Name))
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("File watcher error: %v\n", err)
+		}
+	}
+}
+
+// schedule checks a file once notifications about it have settled
+func (w *FileWatcher) schedule(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.files[path]; !ok {
+		return
+	}
+	if timer, ok := w.pending[path]; ok {
+		timer.Reset(watchDebounce)
+		return
+	}
+	w.pending[path] = time.AfterFunc(watchDebounce, func() {
+		w.mu.Lock()
+		delete(w.pending, path)
+		w.mu.Unlock()
+		w.check(path)
+	})
+}
+
+// poll periodically checks files that have no notifications
+func (w *FileWatcher) poll() {
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			var paths []string
+			for path, file := range w.files {
+				if file.polled {
+					paths = append(paths, path)
+				}
+			}
+			w.mu.Unlock()
+
+			for _, path := range paths {
+				w.check(path)
+			}
+		}
+	}
+}
+
+// check compares a file with its last known state and reports a change
+func (w *FileWatcher) check(path string) {
+	w.mu.Lock()
+	file, ok := w.files[path]
+	if !ok {
+		w.mu.Unlock()
+		return
+	}
+	known := file.state
+	w.mu.Unlock()
+
+	same, current, err := known.sameFile(path, w.maxHashSize())
+	if err != nil {
+		return
+	}
+
+	// Keep a rehashed state even when unchanged, so it isn't hashed again
+	w.mu.Lock()
+	if file, ok := w.files[path]; ok && file.state == known {
+		file.state = current
+	} else {
+		// Re-read or saved by the editor meanwhile
+		w.mu.Unlock()
+		return
+	}
+	w.mu.Unlock()
+
+	if same {
+		return
+	}
+
+	if !current.exists {
+		w.notify(EventFileDeleted, FileChangeEventData{Path: path})
+		return
+	}
+	w.notify(EventFileExternalChange, FileChangeEventData{Path: path, ModTime: current.modTime.Unix()})
+}
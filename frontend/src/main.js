@@ -7,6 +7,42 @@ import {
     OpenFile,
     SaveFile,
     SaveFileAs,
+    OverwriteFile,
+    MergeExternalChanges,
+    OpenFileForEditing,
+    ReadLines,
+    SearchLargeFile,
+    CancelLargeFileSearch,
+    UpdateRecoveryBuffer,
+    DiscardRecoveryBuffer,
+    GetRecoverableBuffers,
+    GetRecoveryBuffers,
+    OnEditorEvent,
+    GetCurrentSession,
+    UpdateSessionTabs,
+    SetSessionChat,
+    GetSessions,
+    SaveSessionAs,
+    SwitchSession,
+    DeleteSession,
+    GetFileHistory,
+    DiffFileRevisions,
+    RestoreFileRevision,
+    OpenFolder,
+    OpenFolderPath,
+    CloseFolder,
+    RefreshWorkspace,
+    ListWorkspaceDir,
+    FindFiles,
+    CreateWorkspaceFile,
+    CreateWorkspaceFolder,
+    RenameWorkspaceEntry,
+    MoveWorkspaceEntry,
+    DeleteWorkspaceEntry,
+    ReopenWithEncoding,
+    ConvertToEncoding,
+    GetSupportedEncodings,
+    CloseFile,
     GetSettings,
     OnFileChange,
     Greet,
@@ -14,27 +50,97 @@ import {
     CheckOllamaServerRunning,
     GetInstalledModels,
     StartOllamaServer,
-    GenerateWithOllama,
+    GenerateWithOllamaStream,
+    StopGeneration,
     CreateChat,
-    GetChats,
-    GetChatMessages,
+    GetChatsPage,
+    GetChatMessagesPage,
     DeleteChat,
     DeleteAllChats,
     UpdateChatTitle,
     AddMessage,
     GetChatContext,
-    RenameChatFromFirstMessage,
     SearchChats,
     ExportChat,
-    ExportAsPDF
+    ExportAsPDF,
+    ExportAsDOCX,
+    RestoreChatBackup,
+    AttachSelection,
+    SearchWorkspace,
+    CancelWorkspaceSearch,
+    PrepareReplace,
+    ApplyReplace,
+    DiscardReplace,
+    UndoReplace,
+    CanUndoReplace,
+    GetLanguages,
+    DetectLanguage,
+    TakeLaunchRequests,
+    FinishWait,
+    DiffFiles,
+    ReadHex,
+    FindBytes,
+    ListArchive,
+    GetRecentFiles,
+    ClearRecentFiles,
+    AnswerPassphrase,
+    CheckAIAccess,
+    GitFileChanges,
+    GitDiff,
+    GitBlame,
+    GitStatus,
+    GitStage,
+    GitUnstage,
+    GitCommit,
+    GitDiscardHunk,
+    RenderMarkdown
 } from '../wailsjs/go/main/App.js';
+import { EventsOn, BrowserOpenURL } from '../wailsjs/runtime/runtime.js';
 
 
 console.log('Akashic Editor Starting...');
 
+// Lines shown at a time for files opened in large-file mode
+const LARGE_FILE_PAGE_LINES = 1000;
+const HEX_PAGE_BYTES = 4096;
+const HEX_ROW_BYTES = 16;
+const ARCHIVE_SEPARATOR = '!/';
+// Search results listed for a large file; the rest are only counted
+const LARGE_FILE_MAX_LISTED_MATCHES = 2000;
+// How long edits settle before a buffer is sent for crash recovery
+const RECOVERY_UPDATE_DELAY = 1000;
+// How long tab changes and cursor moves settle before the session is updated
+const SESSION_UPDATE_DELAY = 500;
+// Most results listed by "go to file"
+const GO_TO_FILE_MAX_RESULTS = 50;
+// Matches listed by a project search; the rest are only counted
+const PROJECT_SEARCH_MAX_LISTED_MATCHES = 5000;
+
+// Prompts for the AI menu and context menu actions. {{language}} becomes the
+// active file's language and {{selection}} the selected text.
+const QUICK_ACTION_PROMPTS = {
+    'ai-rewrite': 'Rewrite the following {{language}} to be clearer and more professional:\n\n{{selection}}',
+    'ai-summarize': 'Summarize the following {{language}}:\n\n{{selection}}',
+    'ai-grammar': 'Fix the grammar and spelling in the following {{language}}, changing nothing else:\n\n{{selection}}',
+    'ai-expand': 'Expand these {{language}} notes into full prose:\n\n{{selection}}',
+    'ai-continue': 'Continue writing the following {{language}} in the same style:\n\n{{selection}}',
+    'ai-explain': 'Explain the following {{language}}:\n\n{{selection}}'
+};
+
+// parentDir returns the folder containing a path
+function parentDir(path) {
+    return path.slice(0, Math.max(path.lastIndexOf('/'), path.lastIndexOf('\\')));
+}
+
+// isPathUnder reports whether path is folder or inside it
+function isPathUnder(path, folder) {
+    return path === folder || path.startsWith(folder + '/') || path.startsWith(folder + '\\');
+}
+
 class AkashicEditor {
     constructor() {
         this.tabs = [];
+        this.waits = new Map(); // --wait launches: wait ID to the IDs of their open tabs
         this.activeTabId = null;
         this.tabCounter = 0;
         this.zoomLevel = 100;
@@ -42,19 +148,28 @@ class AkashicEditor {
         this.installedModels = [];
         this.selectedModel = '';
         this.serverRunning = false;
-        this.currentGeneration = null;
+        this.currentGeneration = null; // the streamed reply in progress
         this.lastAIResponse = '';
         
         // Chat history state
         this.currentChatId = null;
         this.chats = [];
         this.isLoadingChat = false;
+        this.chatCursor = null;
+        this.hasMoreChats = false;
+        this.isLoadingMoreChats = false;
+        this.messagesBeforeId = 0;
+        this.hasOlderMessages = false;
+        this.isLoadingOlderMessages = false;
         
         // Editor view state
         this.showLineNumbers = false;
         this.showMinimap = false;
+        this.showMarkdownPreview = false;
         this.lineNumbersEl = null;
         this.minimapEl = null;
+        this.markdownPreviewEl = null;
+        this.markdownPreviewTimer = null;
         this.wordWrap = true; // Enable word wrap by default for responsive text
 
         
@@ -76,11 +191,16 @@ class AkashicEditor {
             editorContainer: document.getElementById('editor-container'),
             aiSidebar: document.getElementById('ai-sidebar'),
             statusFile: document.getElementById('status-file'),
+            statusGit: document.getElementById('status-git'),
             statusEncoding: document.getElementById('status-encoding'),
+            statusLanguage: document.getElementById('status-language'),
             statusLineEnding: document.getElementById('status-line-ending'),
             statusPosition: document.getElementById('status-position'),
             statusZoom: document.getElementById('status-zoom'),
             contextMenu: document.getElementById('context-menu'),
+            explorer: document.getElementById('explorer'),
+            explorerTitle: document.getElementById('explorer-title'),
+            fileTree: document.getElementById('file-tree'),
             dialogOverlay: document.getElementById('dialog-overlay'),
             // Chat history elements
             chatHistoryPanel: document.getElementById('chat-history-panel'),
@@ -102,9 +222,22 @@ class AkashicEditor {
         this.setupEventListeners();
         this.setupAIEventListeners();
         this.setupChatHistoryListeners();
+        this.setupExplorerListeners();
         
-        // Create initial tab
-        this.createNewTab();
+        try {
+            this.languages = new Map((await GetLanguages()).map(lang => [lang.id, lang]));
+        } catch (err) {
+            console.error('Failed to load languages:', err);
+        }
+        
+        // Reopen the last session's tabs
+        let session = null;
+        try {
+            session = await GetCurrentSession();
+        } catch (err) {
+            console.error('Failed to load session:', err);
+        }
+        await this.restoreSession(session);
         
         // Test Wails connection
         if (Greet) {
@@ -116,8 +249,18 @@ class AkashicEditor {
             }
         }
         
-        // Load chat history
+        // Load chat history, reopening the session's chat
         await this.loadChatHistory();
+        if (session && session.chatId) {
+            await this.loadChat(session.chatId);
+        }
+        
+        // Offer unsaved buffers left by a crash
+        await this.offerRecovery();
+        
+        // Open what the command line, or a later launch, asked for
+        this.launchReady = true;
+        await this.openLaunchRequests();
         
         console.log('Editor initialized successfully');
     }
@@ -128,13 +271,33 @@ class AkashicEditor {
     
     async loadChatHistory() {
         try {
-            this.chats = await GetChats();
+            const page = await GetChatsPage({ updatedAt: '', id: 0 }, 50);
+            this.chats = page.chats || [];
+            this.chatCursor = page.nextCursor;
+            this.hasMoreChats = page.hasMore;
             this.renderChatList();
         } catch (err) {
             console.error('Failed to load chat history:', err);
         }
     }
     
+    async loadMoreChats() {
+        if (!this.hasMoreChats || this.isLoadingMoreChats) return;
+        this.isLoadingMoreChats = true;
+        
+        try {
+            const page = await GetChatsPage(this.chatCursor, 50);
+            this.chats = this.chats.concat(page.chats || []);
+            this.chatCursor = page.nextCursor;
+            this.hasMoreChats = page.hasMore;
+            this.renderChatList();
+        } catch (err) {
+            console.error('Failed to load more chats:', err);
+        } finally {
+            this.isLoadingMoreChats = false;
+        }
+    }
+    
     renderChatList() {
         if (!this.elements.chatList) return;
         
@@ -197,7 +360,10 @@ class AkashicEditor {
             const title = 'New Chat';
             const chat = await CreateChat(title, this.selectedModel || 'default');
             this.currentChatId = chat.id;
+            SetSessionChat(chat.id);
             this.chats.unshift(chat);
+            this.messagesBeforeId = 0;
+            this.hasOlderMessages = false;
             
             // Clear messages
             const messagesDiv = document.getElementById('ai-messages');
@@ -207,7 +373,7 @@ class AkashicEditor {
                     <h3>How can I help you today?</h3>
                     <p>I can help you write, edit, explain code, summarize text, and more.</p>
                     <div class="ai-suggestions">
-                        <button class="ai-suggestion" data-prompt="Explain this code">💡 Explain code</button>
+                        <button class="ai-suggestion" data-prompt="Explain this {{language}} code">💡 Explain code</button>
                         <button class="ai-suggestion" data-prompt="Rewrite this to be more professional">✍️ Rewrite professionally</button>
                         <button class="ai-suggestion" data-prompt="Summarize this text">📝 Summarize</button>
                         <button class="ai-suggestion" data-prompt="Fix grammar and spelling">🔧 Fix grammar</button>
@@ -219,7 +385,7 @@ class AkashicEditor {
             messagesDiv.querySelectorAll('.ai-suggestion').forEach(btn => {
                 btn.addEventListener('click', () => {
                     const prompt = btn.dataset.prompt;
-                    document.getElementById('ai-prompt').value = prompt;
+                    document.getElementById('ai-prompt').value = this.fillPromptTemplate(prompt);
                     this.generateWithAI();
                 });
             });
@@ -238,7 +404,11 @@ class AkashicEditor {
         
         try {
             this.currentChatId = chatId;
-            const messages = await GetChatMessages(chatId);
+            SetSessionChat(chatId);
+            const page = await GetChatMessagesPage(chatId, 0, 50);
+            const messages = page.messages || [];
+            this.messagesBeforeId = page.beforeId;
+            this.hasOlderMessages = page.hasMore;
             
             // Clear current messages
             const messagesDiv = document.getElementById('ai-messages');
@@ -256,10 +426,7 @@ class AkashicEditor {
             } else {
                 // Render messages
                 messages.forEach(msg => {
-                    const msgDiv = document.createElement('div');
-                    msgDiv.className = `ai-message ${msg.role}`;
-                    msgDiv.innerHTML = `<div class="ai-message-content">${this.escapeHtml(msg.content)}</div>`;
-                    messagesDiv.appendChild(msgDiv);
+                    messagesDiv.appendChild(this.createChatMessageElement(msg));
                 });
                 
                 // Scroll to bottom
@@ -283,6 +450,58 @@ class AkashicEditor {
         }
     }
     
+    async loadOlderMessages() {
+        if (!this.currentChatId || !this.hasOlderMessages || this.isLoadingOlderMessages) return;
+        this.isLoadingOlderMessages = true;
+        
+        const chatId = this.currentChatId;
+        try {
+            const page = await GetChatMessagesPage(chatId, this.messagesBeforeId, 50);
+            if (chatId !== this.currentChatId) return;
+            
+            this.messagesBeforeId = page.beforeId;
+            this.hasOlderMessages = page.hasMore;
+            
+            // Prepend while keeping the visible message in place
+            const messagesDiv = document.getElementById('ai-messages');
+            const previousHeight = messagesDiv.scrollHeight;
+            const fragment = document.createDocumentFragment();
+            (page.messages || []).forEach(msg => {
+                fragment.appendChild(this.createChatMessageElement(msg));
+            });
+            messagesDiv.insertBefore(fragment, messagesDiv.firstChild);
+            messagesDiv.scrollTop += messagesDiv.scrollHeight - previousHeight;
+        } catch (err) {
+            console.error('Failed to load older messages:', err);
+        } finally {
+            this.isLoadingOlderMessages = false;
+        }
+    }
+    
+    createChatMessageElement(msg) {
+        const msgDiv = document.createElement('div');
+        msgDiv.className = `ai-message ${msg.role}`;
+        msgDiv.innerHTML = `<div class="ai-message-content">${this.escapeHtml(msg.content)}</div>`;
+        if (msg.role === 'assistant') {
+            this.renderAIMarkdown(msgDiv.querySelector('.ai-message-content'), msg.content);
+        }
+        
+        // Replies that did not finish streaming keep their partial text
+        const statusLabels = {
+            streaming: 'Still generating...',
+            interrupted: 'Interrupted',
+            cancelled: 'Stopped',
+            error: 'Failed'
+        };
+        if (statusLabels[msg.status]) {
+            const statusDiv = document.createElement('div');
+            statusDiv.className = `ai-message-status ${msg.status}`;
+            statusDiv.textContent = msg.error ? `${statusLabels[msg.status]}: ${msg.error}` : statusLabels[msg.status];
+            msgDiv.appendChild(statusDiv);
+        }
+        return msgDiv;
+    }
+    
     async deleteChat(chatId) {
         const chat = this.chats.find(c => c.id === chatId);
         const chatTitle = chat ? chat.title : 'this chat';
@@ -299,6 +518,7 @@ class AkashicEditor {
                     
                     if (this.currentChatId === chatId) {
                         this.currentChatId = null;
+                        SetSessionChat(0);
                         // Clear messages
                         const messagesDiv = document.getElementById('ai-messages');
                         messagesDiv.innerHTML = `
@@ -323,7 +543,7 @@ class AkashicEditor {
     async clearAllChats() {
         this.showStyledConfirmDialog(
             'Clear All Chats',
-            `Are you sure you want to delete ALL ${this.chats.length} chat(s)? This action cannot be undone.`,
+            `Are you sure you want to delete ALL chats? A backup is saved first and can be restored later.`,
             'Delete All',
             'Cancel',
             async () => {
@@ -331,6 +551,7 @@ class AkashicEditor {
                     await DeleteAllChats();
                     this.chats = [];
                     this.currentChatId = null;
+                    SetSessionChat(0);
                     
                     // Clear messages
                     const messagesDiv = document.getElementById('ai-messages');
@@ -360,6 +581,7 @@ class AkashicEditor {
         
         try {
             this.chats = await SearchChats(query);
+            this.hasMoreChats = false;
             this.renderChatList();
         } catch (err) {
             console.error('Failed to search chats:', err);
@@ -500,6 +722,114 @@ class AkashicEditor {
         if (renameBtn) {
             renameBtn.addEventListener('click', () => this.renameCurrentChat());
         }
+        
+        // Load the next page of chats near the bottom of the list
+        if (this.elements.chatList) {
+            this.elements.chatList.addEventListener('scroll', () => {
+                const list = this.elements.chatList;
+                if (list.scrollTop + list.clientHeight >= list.scrollHeight - 40) {
+                    this.loadMoreChats();
+                }
+            });
+        }
+        
+        // Warn when the startup integrity check finds a damaged database
+        EventsOn('chat.integrity', (report) => {
+            if (!report || report.ok) return;
+            
+            if (!report.latestBackup) {
+                this.showNotification('Chat history database is damaged: ' + (report.problems || []).join('; '), 'error');
+                return;
+            }
+            
+            this.showStyledConfirmDialog(
+                'Chat History Damaged',
+                `The chat history database failed its integrity check. Restore the latest backup (${report.latestBackup})?`,
+                'Restore',
+                'Cancel',
+                async () => {
+                    try {
+                        await RestoreChatBackup(report.latestBackup);
+                        await this.loadChatHistory();
+                        this.showNotification('Chat history restored', 'success');
+                    } catch (err) {
+                        console.error('Failed to restore backup:', err);
+                        this.showNotification('Failed to restore backup', 'error');
+                    }
+                }
+            );
+        });
+        
+        // Apply titles generated in the background
+        EventsOn('chat.title', (data) => {
+            const chat = this.chats.find(c => c.id === data.chatId);
+            if (!chat) return;
+            chat.title = data.title;
+            this.renderChatList();
+            this.updateChatInfo();
+        });
+        
+        // Streamed AI replies, routed to the generation waiting for them
+        EventsOn('ai.stream.chunk', (data) => {
+            const generation = this.currentGeneration;
+            if (generation && generation.requestId === data.requestID) generation.onChunk(data.chunk);
+        });
+        EventsOn('ai.stream.done', (data) => {
+            const generation = this.currentGeneration;
+            if (data.saveError) this.showNotification('The reply was not fully saved: ' + data.saveError, 'warning');
+            if (generation && generation.requestId === data.requestID) generation.resolve();
+        });
+        EventsOn('ai.stream.error', (data) => {
+            const generation = this.currentGeneration;
+            if (data.saveError) this.showNotification('The reply was not fully saved: ' + data.saveError, 'warning');
+            if (generation && generation.requestId === data.requestID) generation.reject(new Error(data.error));
+        });
+        
+        EventsOn('app.launch', () => this.openLaunchRequests());
+        
+        // Open files changed or deleted by another program
+        EventsOn('file.externalChange', (data) => this.onExternalChange(data.path));
+        EventsOn('file.deleted', (data) => {
+            if (this.workspace) this.refreshTreeDir(parentDir(data.path));
+            const deleted = this.tabs.filter(t => isPathUnder(t.fileInfo.Path, data.path));
+            for (const tab of deleted) {
+                this.markTabDirty(tab);
+            }
+            if (deleted.length === 1) {
+                this.showNotification(`${deleted[0].fileInfo.Name} was deleted from disk; save to keep it`, 'warning');
+            } else if (deleted.length > 1) {
+                this.showNotification(`${deleted.length} open files were deleted from disk; save them to keep them`, 'warning');
+            }
+        });
+        
+        // Files created, renamed or moved in the workspace
+        EventsOn('file.created', (data) => this.refreshTreeDir(parentDir(data.path)));
+        EventsOn('file.renamed', (data) => this.onFileRenamed(data));
+        EventsOn('workspace.index', (info) => this.onWorkspaceIndexed(info));
+        EventsOn('workspace.searchResults', (results) => this.onProjectSearchResults(results));
+        
+        // Buffers written through by AutoSave
+        EventsOn('file.autoSave', (data) => this.onAutoSave(data));
+        
+        // Large-file indexing and search progress
+        EventsOn('file.indexProgress', (progress) => this.onLargeFileProgress(progress));
+        EventsOn('file.searchResults', (results) => this.onLargeFileSearchResults(results));
+        
+        // Opening or saving an encrypted note needs its passphrase
+        EventsOn('file.passphrase', (request) => this.showPassphraseDialog(request));
+        
+        // Repository status after a save or a git operation
+        EventsOn('git.status', (status) => this.onGitStatus(status));
+        
+        // Load older messages when scrolled to the top of a chat
+        const messagesDiv = document.getElementById('ai-messages');
+        if (messagesDiv) {
+            messagesDiv.addEventListener('scroll', () => {
+                if (messagesDiv.scrollTop < 40) {
+                    this.loadOlderMessages();
+                }
+            });
+        }
     }
     
     // ============================================
@@ -517,20 +847,73 @@ class AkashicEditor {
                 Name: 'Untitled',
                 Encoding: 'UTF-8',
                 LineEnding: 'CRLF',
+                Language: 'plaintext',
                 IsDirty: false,
                 IsNewFile: true
             },
             content: content || '',
-            textarea: null
+            textarea: null,
+            bufferId: `buf-${Date.now().toString(36)}-${this.tabCounter}`,
+            revision: 0,
+            selectionStart: 0,
+            selectionEnd: 0,
+            scrollTop: 0,
+            scrollLeft: 0
         };
         
+        if (tab.fileInfo.LargeFile) {
+            tab.largeFile = {
+                pageStart: 0,
+                totalLines: 0,
+                indexed: false,
+                progress: 0,
+                searchId: null,
+                matchCount: 0
+            };
+        }
+        if (tab.fileInfo.Binary) {
+            tab.hex = {
+                offset: 0,
+                pageLength: 0,
+                size: tab.fileInfo.Size || 0,
+                lastMatch: -1
+            };
+        }
+        
         this.tabs.push(tab);
         this.renderTab(tab);
         this.switchToTab(tabId);
+        this.scheduleSessionUpdate();
+        if (!tab.fileInfo.Language) this.detectTabLanguage(tab);
         
         return tab;
     }
     
+    // Work out the language of a tab opened without one, such as a recovered buffer
+    async detectTabLanguage(tab) {
+        tab.fileInfo.Language = 'plaintext';
+        try {
+            tab.fileInfo.Language = await DetectLanguage(tab.fileInfo.Path || tab.fileInfo.Name, tab.content);
+        } catch (err) {
+            console.error('Failed to detect language:', err);
+        }
+        if (tab.id === this.activeTabId) this.updateStatusBar();
+    }
+    
+    // The registry entry for a tab's language, falling back to plain text
+    languageOf(tab) {
+        const languages = this.languages || new Map();
+        return languages.get(tab.fileInfo.Language) || languages.get('plaintext') ||
+            { id: 'plaintext', name: 'Plain Text', indentSize: 0, indentWithTabs: false };
+    }
+    
+    // The text the Tab key inserts: a tab, or the language's indent in spaces
+    indentFor(tab) {
+        const lang = this.languageOf(tab);
+        if (!lang.indentSize || lang.indentWithTabs) return '\t';
+        return ' '.repeat(lang.indentSize);
+    }
+    
     renderTab(tab) {
         const tabElement = document.createElement('div');
         tabElement.className = 'tab';
@@ -579,6 +962,9 @@ class AkashicEditor {
             this.createTextareaEditor(tab);
             
             this.updateStatusBar();
+            this.reportEditorEvent(tab, 'editor.focus');
+            this.scheduleSessionUpdate();
+            this.refreshGit();
         }
     }
     
@@ -617,47 +1003,73 @@ class AkashicEditor {
         });
         
         textarea.addEventListener('scroll', () => {
+            tab.scrollTop = Math.round(textarea.scrollTop);
+            tab.scrollLeft = Math.round(textarea.scrollLeft);
+            this.reportEditorEvent(tab, 'editor.scroll');
             if (this.showLineNumbers) this.updateLineNumbers(tab);
             if (this.showMinimap) this.updateMinimap(tab);
         });
         
         textarea.addEventListener('keydown', (e) => {
             // Handle Tab key
-            if (e.key === 'Tab') {
+            if (e.key === 'Tab' && !textarea.readOnly) {
                 e.preventDefault();
                 const start = textarea.selectionStart;
                 const end = textarea.selectionEnd;
-                textarea.value = textarea.value.substring(0, start) + '\t' + textarea.value.substring(end);
-                textarea.selectionStart = textarea.selectionEnd = start + 1;
+                const indent = this.indentFor(tab);
+                textarea.value = textarea.value.substring(0, start) + indent + textarea.value.substring(end);
+                textarea.selectionStart = textarea.selectionEnd = start + indent.length;
                 this.onEditorChange(tab);
             }
         });
         
         wrapper.appendChild(textarea);
-        this.elements.editorContainer.appendChild(wrapper);
+        if (tab.largeFile) {
+            // Large files show one page of lines at a time under a navigation bar
+            textarea.readOnly = true;
+            const view = document.createElement('div');
+            view.className = 'large-file-view';
+            view.appendChild(this.createLargeFileBar(tab));
+            view.appendChild(wrapper);
+            this.elements.editorContainer.appendChild(view);
+        } else if (tab.hex) {
+            // Binary files show a read-only hex dump, a page at a time
+            textarea.readOnly = true;
+            textarea.wrap = 'off';
+            textarea.classList.add('hex-dump');
+            const view = document.createElement('div');
+            view.className = 'large-file-view';
+            view.appendChild(this.createHexBar(tab));
+            view.appendChild(wrapper);
+            this.elements.editorContainer.appendChild(view);
+        } else {
+            this.elements.editorContainer.appendChild(wrapper);
+        }
         tab.textarea = textarea;
         tab.wrapper = wrapper;
+        if (!tab.largeFile && !tab.hex) {
+            textarea.setSelectionRange(tab.selectionStart, tab.selectionEnd);
+        }
         
         // Apply current view settings
-        if (this.showLineNumbers || this.showMinimap) {
+        if (this.showLineNumbers || this.showMinimap || this.showMarkdownPreview) {
             this.updateEditorView(tab);
         }
         
-        // Focus the textarea
+        // Focus the textarea where the tab was left
         setTimeout(() => {
             textarea.focus();
+            if (!tab.largeFile && !tab.hex) {
+                textarea.scrollTop = tab.scrollTop;
+                textarea.scrollLeft = tab.scrollLeft;
+            }
             console.log('Textarea focused');
         }, 10);
     }
     
     onEditorChange(tab) {
         tab.content = tab.textarea.value;
-        
-        if (!tab.fileInfo.IsDirty) {
-            tab.fileInfo.IsDirty = true;
-            const dirtyIndicator = tab.element.querySelector('.tab-dirty');
-            if (dirtyIndicator) dirtyIndicator.style.display = 'inline';
-        }
+        this.markTabDirty(tab);
         
         // Notify backend if available
         if (OnFileChange) {
@@ -679,6 +1091,12 @@ class AkashicEditor {
         const colNum = lines[lines.length - 1].length + 1;
         
         this.elements.statusPosition.textContent = `Ln ${lineNum}, Col ${colNum}`;
+        
+        if (tab.selectionStart !== pos || tab.selectionEnd !== tab.textarea.selectionEnd) {
+            tab.selectionStart = pos;
+            tab.selectionEnd = tab.textarea.selectionEnd;
+            this.reportEditorEvent(tab, 'editor.cursor', lineNum, colNum);
+        }
     }
     
     closeTab(tabId) {
@@ -713,6 +1131,28 @@ class AkashicEditor {
         tab.element.remove();
         this.tabs.splice(tabIndex, 1);
         
+        if (tab.largeFile && tab.largeFile.searchId) {
+            CancelLargeFileSearch(tab.largeFile.searchId);
+        }
+        if (tab.fileInfo.IsDirty) {
+            this.markTabClean(tab); // closed without saving; nothing to recover
+        }
+        if (tab.fileInfo.Encrypted) {
+            // Drop the note's text; the backend wipes its key on CloseFile
+            if (tab.textarea) tab.textarea.value = '';
+            tab.content = '';
+        }
+        for (const waitId of tab.waitIds || []) {
+            this.releaseWait(waitId, tab.id);
+        }
+        this.scheduleSessionUpdate();
+        
+        // Let the backend drop the file's remembered line endings
+        const path = tab.fileInfo.Path;
+        if (path && !this.tabs.some(t => t.fileInfo.Path === path)) {
+            CloseFile(path);
+        }
+        
         if (this.activeTabId === tabId) {
             if (this.tabs.length > 0) {
                 const newIndex = Math.min(tabIndex, this.tabs.length - 1);
@@ -754,18 +1194,11 @@ class AkashicEditor {
             const fileInfo = result.fileInfo;
             const content = result.content || '';
             
-            if (fileInfo) {
+            if (fileInfo && fileInfo.archive) {
+                this.showArchiveDialog(fileInfo.path);
+            } else if (fileInfo) {
                 console.log('File opened:', fileInfo.name);
-                // Convert backend field names (lowercase) to frontend field names (PascalCase)
-                const normalizedFileInfo = {
-                    Path: fileInfo.path || '',
-                    Name: fileInfo.name || 'Untitled',
-                    Encoding: fileInfo.encoding || 'UTF-8',
-                    LineEnding: fileInfo.lineEnding || 'CRLF',
-                    IsDirty: fileInfo.isDirty || false,
-                    IsNewFile: fileInfo.isNewFile || false
-                };
-                this.createNewTab(normalizedFileInfo, content);
+                this.createNewTab(this.normalizeFileInfo(fileInfo), content);
             }
         } catch (err) {
             console.error('Failed to open file:', err);
@@ -773,10 +1206,59 @@ class AkashicEditor {
         }
     }
     
-    async saveTab(tab = null) {
+    // Convert backend field names (lowercase) to frontend field names (PascalCase)
+    normalizeFileInfo(fileInfo) {
+        return {
+            Path: fileInfo.path || '',
+            Name: fileInfo.name || 'Untitled',
+            Encoding: fileInfo.encoding || 'UTF-8',
+            EncodingConfidence: fileInfo.encodingConfidence ?? 1,
+            LineEnding: fileInfo.lineEnding || 'CRLF',
+            LineEndingCounts: fileInfo.lineEndingCounts,
+            TrailingNewline: fileInfo.trailingNewline,
+            Language: fileInfo.language || 'plaintext',
+            IsDirty: fileInfo.isDirty || false,
+            IsNewFile: fileInfo.isNewFile || false,
+            LargeFile: fileInfo.largeFile || false,
+            Binary: fileInfo.binary || false,
+            BinaryKind: fileInfo.binaryKind || '',
+            Compression: fileInfo.compression || '',
+            Encrypted: fileInfo.encrypted || false,
+            ReadOnly: fileInfo.readOnly || false,
+            Size: fileInfo.size || 0
+        };
+    }
+    
+    // Open a file by path, or switch to its tab if it is already open.
+    // Archives list their files instead of opening in a tab.
+    async openFilePath(path) {
+        const existing = this.tabs.find(t => t.fileInfo.Path === path);
+        if (existing) {
+            this.switchToTab(existing.id);
+            return existing;
+        }
+        try {
+            const result = await OpenFileByPath(path);
+            if (result.fileInfo.archive) {
+                this.showArchiveDialog(path);
+                return null;
+            }
+            return this.createNewTab(this.normalizeFileInfo(result.fileInfo), result.content || '');
+        } catch (err) {
+            this.showNotification('Failed to open file: ' + (err.message || err), 'error');
+            return null;
+        }
+    }
+    
+    async saveTab(tab = null, overwrite = false) {
         if (!tab) tab = this.tabs.find(t => t.id === this.activeTabId);
         if (!tab || !tab.textarea) return;
         
+        if (tab.fileInfo.ReadOnly) {
+            this.showNotification(`${tab.fileInfo.Name} is open read-only`, 'warning');
+            return;
+        }
+        
         const content = tab.textarea.value;
         console.log('Saving file:', tab.fileInfo.Name);
         
@@ -789,9 +1271,11 @@ class AkashicEditor {
             let fileInfo;
             
             if (tab.fileInfo.IsNewFile || !tab.fileInfo.Path) {
-                fileInfo = await SaveFileAs(tab.fileInfo.Name, content, tab.fileInfo.LineEnding);
+                fileInfo = await SaveFileAs(tab.fileInfo.Name, content, tab.fileInfo.LineEnding, tab.fileInfo.Encoding);
+            } else if (overwrite) {
+                fileInfo = await OverwriteFile(tab.fileInfo.Path, content, tab.fileInfo.LineEnding, tab.fileInfo.Encoding);
             } else {
-                fileInfo = await SaveFile(tab.fileInfo.Path, content, tab.fileInfo.LineEnding);
+                fileInfo = await SaveFile(tab.fileInfo.Path, content, tab.fileInfo.LineEnding, tab.fileInfo.Encoding);
             }
             
             if (fileInfo) {
@@ -800,16 +1284,19 @@ class AkashicEditor {
                     Path: fileInfo.path || fileInfo.Path || '',
                     Name: fileInfo.name || fileInfo.Name || 'Untitled',
                     Encoding: fileInfo.encoding || fileInfo.Encoding || 'UTF-8',
+                    EncodingConfidence: 1,
                     LineEnding: fileInfo.lineEnding || fileInfo.LineEnding || 'CRLF',
+                    LineEndingCounts: fileInfo.lineEndingCounts,
+                    TrailingNewline: fileInfo.trailingNewline,
+                    Language: fileInfo.language || tab.fileInfo.Language,
                     IsDirty: false,
                     IsNewFile: fileInfo.isNewFile || fileInfo.IsNewFile || false
                 };
                 tab.fileInfo = normalizedFileInfo;
                 
                 const nameEl = tab.element.querySelector('.tab-name');
-                const dirtyEl = tab.element.querySelector('.tab-dirty');
                 if (nameEl) nameEl.textContent = normalizedFileInfo.Name;
-                if (dirtyEl) dirtyEl.style.display = 'none';
+                this.markTabClean(tab);
                 
                 this.updateStatusBar();
                 this.showNotification('File saved: ' + normalizedFileInfo.Name, 'success');
@@ -817,7 +1304,29 @@ class AkashicEditor {
             }
         } catch (err) {
             console.error('Failed to save:', err);
-            this.showNotification('Failed to save file: ' + (err.message || err), 'error');
+            const message = String(err.message || err);
+            if (message.includes('read-only')) {
+                this.showStyledConfirmDialog(
+                    'File Is Read-Only',
+                    `${tab.fileInfo.Name} can't be overwritten. Save a copy somewhere else?`,
+                    'Save As',
+                    'Cancel',
+                    () => this.saveAs()
+                );
+                return;
+            }
+            if (message.includes('changed on disk')) {
+                this.showStyledConfirmDialog(
+                    'File Changed on Disk',
+                    `${tab.fileInfo.Name} was changed by another program since it was opened. Overwrite those changes, or merge them into your edits first?`,
+                    'Overwrite',
+                    'Merge',
+                    () => this.saveTab(tab, true),
+                    () => this.mergeExternalChanges(tab)
+                );
+                return;
+            }
+            this.showNotification('Failed to save file: ' + message, 'error');
         }
     }
     
@@ -833,23 +1342,26 @@ class AkashicEditor {
         }
         
         try {
-            const fileInfo = await SaveFileAs(tab.fileInfo.Name, content, tab.fileInfo.LineEnding);
+            const fileInfo = await SaveFileAs(tab.fileInfo.Name, content, tab.fileInfo.LineEnding, tab.fileInfo.Encoding);
             if (fileInfo) {
                 // Normalize fileInfo from backend (lowercase) to frontend (PascalCase)
                 const normalizedFileInfo = {
                     Path: fileInfo.path || fileInfo.Path || '',
                     Name: fileInfo.name || fileInfo.Name || 'Untitled',
                     Encoding: fileInfo.encoding || fileInfo.Encoding || 'UTF-8',
+                    EncodingConfidence: 1,
                     LineEnding: fileInfo.lineEnding || fileInfo.LineEnding || 'CRLF',
+                    LineEndingCounts: fileInfo.lineEndingCounts,
+                    TrailingNewline: fileInfo.trailingNewline,
+                    Language: fileInfo.language || tab.fileInfo.Language,
                     IsDirty: false,
                     IsNewFile: fileInfo.isNewFile || fileInfo.IsNewFile || false
                 };
                 tab.fileInfo = normalizedFileInfo;
                 
                 const nameEl = tab.element.querySelector('.tab-name');
-                const dirtyEl = tab.element.querySelector('.tab-dirty');
                 if (nameEl) nameEl.textContent = normalizedFileInfo.Name;
-                if (dirtyEl) dirtyEl.style.display = 'none';
+                this.markTabClean(tab);
                 
                 this.updateStatusBar();
                 this.showNotification('File saved: ' + normalizedFileInfo.Name, 'success');
@@ -941,6 +1453,23 @@ class AkashicEditor {
             case 'open': this.openFile(); break;
             case 'save': this.saveTab(); break;
             case 'save-as': this.saveAs(); break;
+            case 'export-pdf': this.exportAsPDF(); break;
+            case 'export-docx': this.exportAsDOCX(); break;
+            case 'recent-files': this.showRecentFilesDialog(); break;
+            case 'sessions': this.showSessionsDialog(); break;
+            case 'file-history': this.showFileHistoryDialog(); break;
+            
+            case 'git-changes': this.showGitDiff(); break;
+            case 'git-blame': this.showGitBlame(); break;
+            case 'git-discard-hunk': this.discardGitHunkAtCursor(); break;
+            case 'git-stage': this.stageActiveFile(true); break;
+            case 'git-unstage': this.stageActiveFile(false); break;
+            case 'git-commit': this.showGitCommitDialog(); break;
+            case 'open-folder': this.openFolder(); break;
+            case 'close-folder': this.closeFolder(); break;
+            case 'go-to-file': this.showGoToFileDialog(); break;
+            case 'find-in-folder': this.showProjectSearchDialog(); break;
+            case 'explorer': this.toggleExplorer(); break;
             case 'exit': this.exitApp(); break;
             
             case 'undo': document.execCommand('undo'); break;
@@ -959,12 +1488,20 @@ class AkashicEditor {
             case 'fullscreen': this.toggleFullscreen(); break;
             
             case 'ai-sidebar': this.toggleAISidebar(); break;
+            case 'ai-rewrite':
+            case 'ai-summarize':
+            case 'ai-grammar':
+            case 'ai-expand':
+            case 'ai-continue':
+                this.runQuickAction(action);
+                break;
             
             case 'about': this.showAboutDialog(); break;
             case 'shortcuts': this.showKeyboardShortcutsDialog(); break;
             case 'word-wrap': this.toggleWordWrap(); break;
             case 'line-numbers': this.toggleLineNumbers(); break;
             case 'minimap': this.toggleMinimap(); break;
+            case 'markdown-preview': this.toggleMarkdownPreview(); break;
         }
     }
     
@@ -973,6 +1510,12 @@ class AkashicEditor {
             case 'cut': document.execCommand('cut'); break;
             case 'copy': document.execCommand('copy'); break;
             case 'paste': document.execCommand('paste'); break;
+            case 'ai-rewrite':
+            case 'ai-summarize':
+            case 'ai-grammar':
+            case 'ai-explain':
+                this.runQuickAction(action);
+                break;
         }
     }
     
@@ -995,48 +1538,2306 @@ class AkashicEditor {
         this.applyZoom();
     }
     
-    applyZoom() {
-        const tab = this.tabs.find(t => t.id === this.activeTabId);
-        if (tab && tab.textarea) {
-            tab.textarea.style.fontSize = `${this.zoomLevel}%`;
+    applyZoom() {
+        const tab = this.tabs.find(t => t.id === this.activeTabId);
+        if (tab && tab.textarea) {
+            tab.textarea.style.fontSize = `${this.zoomLevel}%`;
+        }
+        this.elements.statusZoom.textContent = `${this.zoomLevel}%`;
+    }
+    
+    toggleFullscreen() {
+        if (!document.fullscreenElement) {
+            document.documentElement.requestFullscreen();
+        } else {
+            document.exitFullscreen();
+        }
+    }
+    
+    toggleAISidebar() {
+        const isHidden = this.elements.aiSidebar.classList.contains('hidden');
+        this.elements.aiSidebar.classList.toggle('hidden');
+        
+        // Initialize AI when opening sidebar
+        if (isHidden) {
+            if (!this.ollamaStatus.checked) {
+                this.ollamaStatus.checked = true;
+                this.checkOllamaInstallation();
+                this.loadInstalledModels();
+            } else {
+                // Re-check server status to update UI
+                this.checkServerStatus();
+            }
+        }
+    }
+    
+    toggleLineEnding() {
+        const tab = this.tabs.find(t => t.id === this.activeTabId);
+        if (!tab || tab.fileInfo.ReadOnly) return;
+        
+        // Cycle through the styles; a mixed file can go back to its original endings
+        const counts = tab.fileInfo.LineEndingCounts;
+        const isMixed = counts && [counts.crlf, counts.lf, counts.cr].filter(n => n > 0).length > 1;
+        const styles = isMixed ? ['Mixed', 'CRLF', 'LF', 'CR'] : ['CRLF', 'LF', 'CR'];
+        const next = (styles.indexOf(tab.fileInfo.LineEnding) + 1) % styles.length;
+        tab.fileInfo.LineEnding = styles[next];
+        this.markTabDirty(tab);
+        this.updateStatusBar();
+    }
+    
+    async showEncodingMenu() {
+        const tab = this.getActiveTab();
+        if (!tab || tab.fileInfo.LargeFile || tab.fileInfo.Binary) return;
+        if (tab.fileInfo.Encrypted) {
+            this.showNotification('Encrypted notes are always saved as UTF-8', 'info');
+            return;
+        }
+        
+        let menu = document.getElementById('encoding-menu');
+        if (!menu) {
+            menu = document.createElement('div');
+            menu.id = 'encoding-menu';
+            menu.className = 'hidden';
+            document.body.appendChild(menu);
+            document.addEventListener('click', () => menu.classList.add('hidden'));
+        }
+        
+        const encodings = await GetSupportedEncodings();
+        const section = (title, action) => `
+            <div class="encoding-menu-title">${title}</div>
+            ${encodings.map(enc => `
+                <div class="context-item" data-action="${action}" data-encoding="${enc}">
+                    ${enc === tab.fileInfo.Encoding ? '✓ ' : ''}${enc}
+                </div>`).join('')}`;
+        
+        // Reopening needs a file on disk; converting works for any tab
+        menu.innerHTML = (tab.fileInfo.Path ? section('Reopen with Encoding', 'reopen') : '') +
+            section('Convert to Encoding', 'convert');
+        menu.querySelectorAll('.context-item').forEach(item => {
+            item.addEventListener('click', (e) => {
+                e.stopPropagation();
+                menu.classList.add('hidden');
+                if (item.dataset.action === 'reopen') {
+                    this.reopenWithEncoding(tab, item.dataset.encoding);
+                } else {
+                    this.convertToEncoding(tab, item.dataset.encoding);
+                }
+            });
+        });
+        
+        const rect = this.elements.statusEncoding.getBoundingClientRect();
+        menu.style.right = `${window.innerWidth - rect.right}px`;
+        menu.style.bottom = `${window.innerHeight - rect.top + 4}px`;
+        menu.classList.remove('hidden');
+    }
+    
+    async reloadTab(tab, encoding) {
+        try {
+            const result = await ReopenWithEncoding(tab.fileInfo.Path, encoding);
+            const fileInfo = result.fileInfo;
+            tab.textarea.value = result.content || '';
+            tab.content = tab.textarea.value;
+            tab.fileInfo.Encoding = fileInfo.encoding;
+            tab.fileInfo.EncodingConfidence = fileInfo.encodingConfidence;
+            tab.fileInfo.LineEnding = fileInfo.lineEnding;
+            tab.fileInfo.LineEndingCounts = fileInfo.lineEndingCounts;
+            tab.fileInfo.TrailingNewline = fileInfo.trailingNewline;
+            tab.fileInfo.IsDirty = false;
+            const dirtyEl = tab.element.querySelector('.tab-dirty');
+            if (dirtyEl) dirtyEl.style.display = 'none';
+            this.updateStatusBar();
+            return true;
+        } catch (err) {
+            console.error('Failed to reopen file:', err);
+            this.showNotification('Failed to reopen file: ' + (err.message || err), 'error');
+            return false;
+        }
+    }
+    
+    markTabDirty(tab) {
+        if (!tab.fileInfo.IsDirty) {
+            tab.fileInfo.IsDirty = true;
+            const dirtyEl = tab.element.querySelector('.tab-dirty');
+            if (dirtyEl) dirtyEl.style.display = 'inline';
+        }
+        tab.revision++;
+        this.scheduleRecoveryUpdate(tab);
+    }
+    
+    markTabClean(tab) {
+        tab.fileInfo.IsDirty = false;
+        const dirtyEl = tab.element.querySelector('.tab-dirty');
+        if (dirtyEl) dirtyEl.style.display = 'none';
+        clearTimeout(tab.recoveryTimer);
+        DiscardRecoveryBuffer(tab.bufferId).catch(err => {
+            console.error('Failed to discard recovery snapshot:', err);
+        });
+    }
+    
+    // ============================================
+    // Sessions
+    // ============================================
+    
+    // Tell the backend where the cursor or scroll position of a tab is
+    reportEditorEvent(tab, eventType, cursorLine = 0, cursorCol = 0) {
+        if (this.restoringSession) return;
+        clearTimeout(tab.editorEventTimers?.[eventType]);
+        tab.editorEventTimers = tab.editorEventTimers || {};
+        tab.editorEventTimers[eventType] = setTimeout(() => {
+            OnEditorEvent(eventType, {
+                filePath: tab.fileInfo.Path,
+                bufferId: tab.bufferId,
+                cursorLine,
+                cursorCol,
+                selectionStart: tab.selectionStart,
+                selectionEnd: tab.selectionEnd,
+                scrollTop: tab.scrollTop,
+                scrollLeft: tab.scrollLeft
+            });
+        }, SESSION_UPDATE_DELAY);
+    }
+    
+    // Record the open tabs, in order, once changes settle
+    scheduleSessionUpdate() {
+        if (this.restoringSession) return;
+        clearTimeout(this.sessionTimer);
+        this.sessionTimer = setTimeout(() => this.updateSessionTabs(), SESSION_UPDATE_DELAY);
+    }
+    
+    updateSessionTabs() {
+        clearTimeout(this.sessionTimer);
+        const active = this.getActiveTab();
+        const tabs = this.tabs.map(tab => ({
+            bufferId: tab.bufferId,
+            path: tab.fileInfo.IsNewFile ? '' : tab.fileInfo.Path,
+            name: tab.fileInfo.Name,
+            encoding: tab.fileInfo.Encoding,
+            lineEnding: tab.fileInfo.LineEnding,
+            selectionStart: tab.selectionStart,
+            selectionEnd: tab.selectionEnd,
+            scrollTop: tab.scrollTop,
+            scrollLeft: tab.scrollLeft
+        }));
+        return UpdateSessionTabs(tabs, active ? active.bufferId : '').catch(err => {
+            console.error('Failed to update session:', err);
+        });
+    }
+    
+    // Open a session's tabs, bringing back unsaved changes kept for them
+    async restoreSession(session) {
+        const saved = (session && session.tabs) || [];
+        const folder = session && session.folder;
+        if (folder && (!this.workspace || this.workspace.root !== folder)) {
+            await this.openFolderPath(folder);
+        } else if (!folder && this.workspace) {
+            this.closeFolder();
+        }
+        this.restoringSession = true;
+        
+        let kept = [];
+        try {
+            kept = await GetRecoveryBuffers(saved.map(t => t.bufferId)) || [];
+        } catch (err) {
+            console.error('Failed to load unsaved changes:', err);
+        }
+        const keptById = new Map(kept.map(b => [b.info.bufferId, b]));
+        
+        const missing = [];
+        for (const savedTab of saved) {
+            const buffer = keptById.get(savedTab.bufferId);
+            let tab = null;
+            
+            if (savedTab.path && !(buffer && buffer.diskMissing)) {
+                try {
+                    const result = await OpenFileByPath(savedTab.path);
+                    tab = this.createNewTab(this.normalizeFileInfo(result.fileInfo), result.content || '');
+                } catch (err) {
+                    if (!buffer) {
+                        missing.push(savedTab.name);
+                        continue;
+                    }
+                }
+            }
+            if (!tab) {
+                tab = this.createNewTab({
+                    Path: savedTab.path,
+                    Name: savedTab.name,
+                    Encoding: savedTab.encoding || 'UTF-8',
+                    LineEnding: savedTab.lineEnding || 'CRLF',
+                    IsDirty: false,
+                    IsNewFile: !savedTab.path || (buffer && buffer.diskMissing)
+                });
+            }
+            
+            tab.bufferId = savedTab.bufferId;
+            if (buffer) {
+                tab.content = buffer.content;
+                if (tab.textarea) tab.textarea.value = buffer.content;
+                tab.fileInfo.Encoding = buffer.info.encoding || tab.fileInfo.Encoding;
+                tab.fileInfo.LineEnding = buffer.info.lineEnding || tab.fileInfo.LineEnding;
+                this.markTabDirty(tab);
+            }
+            if (!tab.largeFile && !tab.hex) {
+                tab.selectionStart = savedTab.selectionStart;
+                tab.selectionEnd = savedTab.selectionEnd;
+                tab.scrollTop = savedTab.scrollTop;
+                tab.scrollLeft = savedTab.scrollLeft;
+            }
+        }
+        
+        this.restoringSession = false;
+        
+        if (this.tabs.length === 0) {
+            this.createNewTab();
+        } else {
+            const active = this.tabs.find(t => t.bufferId === session.activeTab) || this.tabs[this.tabs.length - 1];
+            this.switchToTab(active.id);
+        }
+        this.scheduleSessionUpdate();
+        
+        if (missing.length > 0) {
+            this.showNotification(`Couldn't reopen ${missing.join(', ')}`, 'warning');
+        }
+    }
+    
+    // Put the open tabs away with their session and open another session's.
+    // Unsaved changes stay in their recovery snapshots until the session returns.
+    async switchSession(name) {
+        await this.updateSessionTabs();
+        for (const tab of this.tabs) {
+            if (tab.id === this.activeTabId && tab.textarea) {
+                tab.content = tab.textarea.value;
+            }
+            if (tab.fileInfo.IsDirty) {
+                clearTimeout(tab.recoveryTimer);
+                await UpdateRecoveryBuffer({
+                    bufferId: tab.bufferId,
+                    path: tab.fileInfo.IsNewFile ? '' : tab.fileInfo.Path,
+                    name: tab.fileInfo.Name,
+                    encoding: tab.fileInfo.Encoding,
+                    lineEnding: tab.fileInfo.LineEnding,
+                    updatedAt: 0
+                }, tab.content, tab.revision);
+            }
+        }
+        
+        let session;
+        try {
+            session = await SwitchSession(name);
+        } catch (err) {
+            this.showNotification('Failed to switch session: ' + (err.message || err), 'error');
+            return;
+        }
+        
+        for (const tab of this.tabs) {
+            tab.element.remove();
+            if (tab.largeFile && tab.largeFile.searchId) {
+                CancelLargeFileSearch(tab.largeFile.searchId);
+            }
+            if (tab.fileInfo.Path) CloseFile(tab.fileInfo.Path);
+        }
+        this.tabs = [];
+        this.activeTabId = null;
+        
+        await this.restoreSession(session);
+        if (session.chatId) {
+            await this.loadChat(session.chatId);
+        }
+        this.showNotification(`Switched to session ${session.name}`, 'success');
+    }
+    
+    async showSessionsDialog() {
+        let sessions;
+        try {
+            sessions = await GetSessions();
+        } catch (err) {
+            this.showNotification('Failed to list sessions: ' + (err.message || err), 'error');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-sessions');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-sessions';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '420px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        dialog.innerHTML = `
+            <div class="dialog-header">Sessions</div>
+            <div class="dialog-body">
+                <div class="session-list"></div>
+                <input type="text" class="session-name" placeholder="New session name">
+            </div>
+            <div class="dialog-footer">
+                <button class="session-save-as">Save Current As</button>
+                <button class="session-new">New Empty</button>
+                <button class="session-close">Close</button>
+            </div>
+        `;
+        
+        const list = dialog.querySelector('.session-list');
+        for (const session of sessions) {
+            const item = document.createElement('div');
+            item.className = 'session-item' + (session.active ? ' active' : '');
+            const updated = session.updatedAt ? new Date(session.updatedAt * 1000).toLocaleString() : '';
+            item.innerHTML = `
+                <span class="session-item-name">${this.escapeHtml(session.name)}</span>
+                <span class="session-item-detail">${session.tabCount} tabs${updated ? ' · ' + updated : ''}</span>
+                ${session.active ? '<span class="session-item-detail">current</span>' : `
+                    <button class="session-open">Open</button>
+                    <button class="session-delete">Delete</button>
+                `}
+            `;
+            if (!session.active) {
+                item.querySelector('.session-open').addEventListener('click', () => {
+                    this.hideDialogs();
+                    this.switchSession(session.name);
+                });
+                item.querySelector('.session-delete').addEventListener('click', async () => {
+                    try {
+                        await DeleteSession(session.name);
+                        item.remove();
+                    } catch (err) {
+                        this.showNotification('Failed to delete session: ' + (err.message || err), 'error');
+                    }
+                });
+            }
+            list.appendChild(item);
+        }
+        
+        const nameInput = dialog.querySelector('.session-name');
+        dialog.querySelector('.session-save-as').addEventListener('click', async () => {
+            try {
+                await this.updateSessionTabs();
+                await SaveSessionAs(nameInput.value);
+                this.hideDialogs();
+                this.showNotification(`Saved session ${nameInput.value.trim()}`, 'success');
+            } catch (err) {
+                this.showNotification('Failed to save session: ' + (err.message || err), 'error');
+            }
+        });
+        dialog.querySelector('.session-new').addEventListener('click', () => {
+            const name = nameInput.value.trim();
+            if (!name) {
+                this.showNotification('Enter a name for the new session', 'warning');
+                return;
+            }
+            if (sessions.some(s => s.name === name)) {
+                this.showNotification(`A session named ${name} already exists`, 'warning');
+                return;
+            }
+            this.hideDialogs();
+            this.switchSession(name);
+        });
+        dialog.querySelector('.session-close').addEventListener('click', () => this.hideDialogs());
+        
+        dialog.classList.remove('hidden');
+        nameInput.focus();
+    }
+    
+    // ============================================
+    // Workspace
+    // ============================================
+    
+    setupExplorerListeners() {
+        const tree = this.elements.fileTree;
+        if (!tree) return;
+        
+        document.getElementById('explorer-new-file')?.addEventListener('click', () => {
+            if (this.workspace) this.createTreeEntry(this.workspace.root, false);
+        });
+        document.getElementById('explorer-new-folder')?.addEventListener('click', () => {
+            if (this.workspace) this.createTreeEntry(this.workspace.root, true);
+        });
+        document.getElementById('explorer-refresh')?.addEventListener('click', () => this.refreshWorkspace());
+        document.getElementById('explorer-close')?.addEventListener('click', () => this.closeFolder());
+        
+        // Empty space in the tree stands for the workspace folder
+        tree.addEventListener('contextmenu', (e) => {
+            if (!this.workspace) return;
+            e.preventDefault();
+            this.showTreeMenu(e, { path: this.workspace.root, name: this.workspace.name, isDir: true, isRoot: true });
+        });
+        tree.addEventListener('dragover', (e) => e.preventDefault());
+        tree.addEventListener('drop', (e) => {
+            e.preventDefault();
+            const source = e.dataTransfer.getData('text/akashic-path');
+            if (source && this.workspace) this.moveTreeEntry(source, this.workspace.root);
+        });
+        document.addEventListener('click', () => document.getElementById('tree-menu')?.classList.add('hidden'));
+    }
+    
+    async openFolder() {
+        try {
+            const info = await OpenFolder();
+            if (info) this.showWorkspace(info);
+        } catch (err) {
+            this.showNotification('Failed to open folder: ' + (err.message || err), 'error');
+        }
+    }
+    
+    async openFolderPath(path) {
+        try {
+            this.showWorkspace(await OpenFolderPath(path));
+        } catch (err) {
+            this.showNotification('Failed to open folder: ' + (err.message || err), 'error');
+        }
+    }
+    
+    closeFolder() {
+        CloseFolder();
+        this.workspace = null;
+        this.elements.explorer?.classList.add('hidden');
+        if (this.elements.fileTree) this.elements.fileTree.innerHTML = '';
+    }
+    
+    async refreshWorkspace() {
+        if (!this.workspace) return;
+        try {
+            await RefreshWorkspace();
+        } catch (err) {
+            this.showNotification('Failed to refresh folder: ' + (err.message || err), 'error');
+        }
+        this.loadTreeDir(this.workspace.root, this.elements.fileTree, 0);
+    }
+    
+    toggleExplorer() {
+        if (!this.workspace) {
+            this.openFolder();
+            return;
+        }
+        this.elements.explorer.classList.toggle('hidden');
+    }
+    
+    showWorkspace(info) {
+        this.workspace = info;
+        this.expandedDirs = new Set();
+        this.elements.explorer.classList.remove('hidden');
+        this.elements.explorerTitle.textContent = info.name;
+        this.elements.explorerTitle.title = info.root;
+        this.loadTreeDir(info.root, this.elements.fileTree, 0);
+        this.refreshGit();
+    }
+    
+    onWorkspaceIndexed(info) {
+        if (!this.workspace || this.workspace.root !== info.root) return;
+        this.workspace = info;
+        this.elements.explorerTitle.title = `${info.root}\n${info.files} files` +
+            (info.truncated ? ' (index limit reached)' : '');
+        if (this.refreshGoToFile) this.refreshGoToFile();
+    }
+    
+    // List a folder's entries into a tree container
+    async loadTreeDir(dir, container, depth) {
+        let entries;
+        try {
+            entries = await ListWorkspaceDir(dir);
+        } catch (err) {
+            container.innerHTML = `<div class="tree-error">${this.escapeHtml(err.message || String(err))}</div>`;
+            return;
+        }
+        container.dataset.dir = dir;
+        container.dataset.depth = depth;
+        container.innerHTML = '';
+        for (const entry of entries || []) {
+            container.appendChild(this.renderTreeEntry(entry, depth));
+        }
+    }
+    
+    // Reload a folder in the tree if it is showing
+    refreshTreeDir(dir) {
+        if (!this.workspace || !this.elements.fileTree) return;
+        const containers = [this.elements.fileTree, ...this.elements.fileTree.querySelectorAll('.tree-children')];
+        const container = containers.find(c => c.dataset.dir === dir);
+        if (container) this.loadTreeDir(dir, container, Number(container.dataset.depth));
+    }
+    
+    renderTreeEntry(entry, depth) {
+        const node = document.createElement('div');
+        node.className = 'tree-node';
+        const row = document.createElement('div');
+        row.className = 'tree-row ' + (entry.isDir ? 'tree-dir' : 'tree-file');
+        row.style.paddingLeft = `${8 + depth * 14}px`;
+        row.title = entry.relPath;
+        row.draggable = true;
+        row.innerHTML = `
+            <span class="tree-arrow">${entry.isDir ? '▸' : ''}</span>
+            <span class="tree-name">${this.escapeHtml(entry.name)}</span>
+        `;
+        node.appendChild(row);
+        
+        if (entry.isDir) {
+            const children = document.createElement('div');
+            children.className = 'tree-children';
+            node.appendChild(children);
+            const arrow = row.querySelector('.tree-arrow');
+            const expand = () => {
+                this.expandedDirs.add(entry.path);
+                arrow.textContent = '▾';
+                this.loadTreeDir(entry.path, children, depth + 1);
+            };
+            row.addEventListener('click', () => {
+                if (this.expandedDirs.has(entry.path)) {
+                    this.expandedDirs.delete(entry.path);
+                    arrow.textContent = '▸';
+                    children.innerHTML = '';
+                    delete children.dataset.dir;
+                } else {
+                    expand();
+                }
+            });
+            if (this.expandedDirs.has(entry.path)) expand();
+            
+            row.addEventListener('dragover', (e) => {
+                e.preventDefault();
+                e.stopPropagation();
+                row.classList.add('drop-target');
+            });
+            row.addEventListener('dragleave', () => row.classList.remove('drop-target'));
+            row.addEventListener('drop', (e) => {
+                e.preventDefault();
+                e.stopPropagation();
+                row.classList.remove('drop-target');
+                const source = e.dataTransfer.getData('text/akashic-path');
+                if (source) this.moveTreeEntry(source, entry.path);
+            });
+        } else {
+            row.addEventListener('click', () => this.openFilePath(entry.path));
+        }
+        
+        row.addEventListener('dragstart', (e) => e.dataTransfer.setData('text/akashic-path', entry.path));
+        row.addEventListener('contextmenu', (e) => {
+            e.preventDefault();
+            e.stopPropagation();
+            this.showTreeMenu(e, entry);
+        });
+        return node;
+    }
+    
+    showTreeMenu(e, entry) {
+        let menu = document.getElementById('tree-menu');
+        if (!menu) {
+            menu = document.createElement('div');
+            menu.id = 'tree-menu';
+            menu.className = 'hidden';
+            document.body.appendChild(menu);
+        }
+        const dir = entry.isDir ? entry.path : parentDir(entry.path);
+        const items = [
+            ['New File...', () => this.createTreeEntry(dir, false)],
+            ['New Folder...', () => this.createTreeEntry(dir, true)]
+        ];
+        if (!entry.isRoot) {
+            items.push(['Rename...', () => this.renameTreeEntry(entry)]);
+            items.push(['Delete', () => this.deleteTreeEntry(entry)]);
+        }
+        
+        menu.innerHTML = '';
+        for (const [label, action] of items) {
+            const option = document.createElement('div');
+            option.className = 'context-item';
+            option.textContent = label;
+            option.addEventListener('click', () => {
+                menu.classList.add('hidden');
+                action();
+            });
+            menu.appendChild(option);
+        }
+        menu.style.left = `${e.clientX}px`;
+        menu.style.top = `${e.clientY}px`;
+        menu.classList.remove('hidden');
+    }
+    
+    // Ask for a file or folder name; resolves to null if cancelled
+    promptForName(title, initial = '') {
+        return new Promise((resolve) => {
+            this.elements.dialogOverlay.classList.remove('hidden');
+            let dialog = document.getElementById('dialog-name');
+            if (!dialog) {
+                dialog = document.createElement('div');
+                dialog.id = 'dialog-name';
+                dialog.className = 'dialog hidden';
+                dialog.style.width = '360px';
+                this.elements.dialogOverlay.appendChild(dialog);
+            }
+            dialog.innerHTML = `
+                <div class="dialog-header">${this.escapeHtml(title)}</div>
+                <div class="dialog-body">
+                    <input type="text" class="name-input">
+                </div>
+                <div class="dialog-footer">
+                    <button class="name-ok">OK</button>
+                    <button class="name-cancel">Cancel</button>
+                </div>
+            `;
+            const input = dialog.querySelector('.name-input');
+            input.value = initial;
+            const finish = (value) => {
+                this.hideDialogs();
+                resolve(value);
+            };
+            dialog.querySelector('.name-ok').addEventListener('click', () => finish(input.value.trim() || null));
+            dialog.querySelector('.name-cancel').addEventListener('click', () => finish(null));
+            input.addEventListener('keydown', (e) => {
+                if (e.key === 'Enter') finish(input.value.trim() || null);
+                if (e.key === 'Escape') finish(null);
+            });
+            dialog.classList.remove('hidden');
+            input.focus();
+            // Select the name without its extension
+            const dot = initial.lastIndexOf('.');
+            input.setSelectionRange(0, dot > 0 ? dot : initial.length);
+        });
+    }
+    
+    async createTreeEntry(dir, isDir) {
+        const name = await this.promptForName(isDir ? 'New Folder' : 'New File');
+        if (!name) return;
+        try {
+            const path = isDir ? await CreateWorkspaceFolder(dir, name) : await CreateWorkspaceFile(dir, name);
+            if (dir !== this.workspace.root) this.expandedDirs.add(dir);
+            if (!isDir) this.openFilePath(path);
+        } catch (err) {
+            this.showNotification(`Failed to create ${name}: ` + (err.message || err), 'error');
+        }
+    }
+    
+    async renameTreeEntry(entry) {
+        const name = await this.promptForName(`Rename ${entry.name}`, entry.name);
+        if (!name || name === entry.name) return;
+        try {
+            await RenameWorkspaceEntry(entry.path, name);
+        } catch (err) {
+            this.showNotification(`Failed to rename ${entry.name}: ` + (err.message || err), 'error');
+        }
+    }
+    
+    async moveTreeEntry(source, destDir) {
+        if (source === destDir || parentDir(source) === destDir) return;
+        try {
+            await MoveWorkspaceEntry(source, destDir);
+        } catch (err) {
+            this.showNotification('Failed to move: ' + (err.message || err), 'error');
+        }
+    }
+    
+    deleteTreeEntry(entry) {
+        const what = entry.isDir ? `${entry.name} and everything in it` : entry.name;
+        this.showStyledConfirmDialog(
+            'Delete',
+            `Delete ${this.escapeHtml(what)}? This can't be undone.`,
+            'Delete',
+            'Cancel',
+            async () => {
+                try {
+                    await DeleteWorkspaceEntry(entry.path);
+                } catch (err) {
+                    this.showNotification(`Failed to delete ${entry.name}: ` + (err.message || err), 'error');
+                }
+            }
+        );
+    }
+    
+    // Follow a renamed or moved file or folder in the tree and in open tabs
+    onFileRenamed(data) {
+        const moved = (path) => isPathUnder(path, data.oldPath) ? data.path + path.slice(data.oldPath.length) : path;
+        
+        if (this.expandedDirs) {
+            this.expandedDirs = new Set([...this.expandedDirs].map(moved));
+        }
+        this.refreshTreeDir(parentDir(data.oldPath));
+        if (parentDir(data.path) !== parentDir(data.oldPath)) {
+            this.refreshTreeDir(parentDir(data.path));
+        }
+        
+        for (const tab of this.tabs) {
+            if (!tab.fileInfo.Path || !isPathUnder(tab.fileInfo.Path, data.oldPath)) continue;
+            tab.fileInfo.Path = moved(tab.fileInfo.Path);
+            tab.fileInfo.Name = tab.fileInfo.Path.slice(parentDir(tab.fileInfo.Path).length + 1);
+            const nameEl = tab.element.querySelector('.tab-name');
+            if (nameEl) nameEl.textContent = tab.fileInfo.Name;
+        }
+        this.updateStatusBar();
+    }
+    
+    // Fuzzy-find a file in the workspace by name (Ctrl+P)
+    showGoToFileDialog() {
+        if (!this.workspace) {
+            this.showNotification('Open a folder first (File > Open Folder)', 'warning');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-go-to-file');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-go-to-file';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '560px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        dialog.innerHTML = `
+            <div class="dialog-body">
+                <input type="text" class="go-to-file-input" placeholder="Go to file...">
+                <div class="go-to-file-status"></div>
+                <div class="go-to-file-results"></div>
+            </div>
+        `;
+        
+        const input = dialog.querySelector('.go-to-file-input');
+        const status = dialog.querySelector('.go-to-file-status');
+        const results = dialog.querySelector('.go-to-file-results');
+        let matches = [];
+        let selected = 0;
+        let query = 0;
+        
+        const close = () => {
+            this.refreshGoToFile = null;
+            this.hideDialogs();
+        };
+        const open = (match) => {
+            close();
+            this.openFilePath(match.path);
+        };
+        const render = () => {
+            results.innerHTML = '';
+            matches.forEach((match, i) => {
+                const item = document.createElement('div');
+                item.className = 'go-to-file-item' + (i === selected ? ' selected' : '');
+                const positions = new Set(match.positions || []);
+                item.innerHTML = Array.from(match.relPath).map((ch, j) =>
+                    positions.has(j) ? `<mark>${this.escapeHtml(ch)}</mark>` : this.escapeHtml(ch)
+                ).join('');
+                item.addEventListener('click', () => open(match));
+                results.appendChild(item);
+            });
+            results.querySelector('.selected')?.scrollIntoView({ block: 'nearest' });
+        };
+        const search = async () => {
+            const current = ++query;
+            let found;
+            try {
+                found = await FindFiles(input.value, GO_TO_FILE_MAX_RESULTS);
+            } catch (err) {
+                status.textContent = err.message || String(err);
+                return;
+            }
+            if (current !== query) return;
+            matches = found || [];
+            selected = 0;
+            status.textContent = !this.workspace.indexed ? 'Indexing files...'
+                : matches.length === 0 ? 'No matching files' : '';
+            render();
+        };
+        this.refreshGoToFile = search;
+        
+        input.addEventListener('input', search);
+        input.addEventListener('keydown', (e) => {
+            if (e.key === 'ArrowDown' || e.key === 'ArrowUp') {
+                e.preventDefault();
+                if (matches.length === 0) return;
+                selected = (selected + (e.key === 'ArrowDown' ? 1 : -1) + matches.length) % matches.length;
+                render();
+            } else if (e.key === 'Enter') {
+                e.preventDefault();
+                if (matches[selected]) open(matches[selected]);
+            } else if (e.key === 'Escape') {
+                close();
+            }
+        });
+        
+        dialog.classList.remove('hidden');
+        input.focus();
+        search();
+    }
+    
+    // ============================================
+    // Command Line Launches
+    // ============================================
+    
+    // Open the files, folder or diff each pending launch asked for
+    async openLaunchRequests() {
+        if (!this.launchReady) return; // taken once the editor has started
+        let requests;
+        try {
+            requests = await TakeLaunchRequests();
+        } catch (err) {
+            console.error('Failed to get launch requests:', err);
+            return;
+        }
+        
+        for (const request of requests) {
+            if (request.folder) await this.openFolderPath(request.folder);
+            
+            const opened = [];
+            for (const target of request.files || []) {
+                const tab = await this.openLaunchTarget(target);
+                if (tab) opened.push(tab);
+            }
+            if (request.waitId) {
+                // The launch ends once all its tabs are closed, or now if none opened
+                this.waits.set(request.waitId, new Set(opened.map(tab => tab.id)));
+                for (const tab of opened) {
+                    tab.waitIds = (tab.waitIds || []).concat(request.waitId);
+                }
+                if (opened.length === 0) this.releaseWait(request.waitId, null);
+            }
+            
+            if (request.diff && request.diff.length === 2) {
+                await this.showFileDiff(request.diff[0], request.diff[1]);
+            }
+        }
+    }
+    
+    // Open a file named on the command line and go to its line and column
+    async openLaunchTarget(target) {
+        let tab;
+        if (target.new) {
+            // Saving creates the file
+            tab = this.tabs.find(t => t.fileInfo.Path === target.path) || this.createNewTab({
+                Path: target.path,
+                Name: target.path.slice(parentDir(target.path).length + 1),
+                Encoding: 'UTF-8',
+                LineEnding: 'CRLF',
+                IsDirty: false,
+                IsNewFile: false
+            });
+        } else {
+            tab = await this.openFilePath(target.path);
+        }
+        if (tab && target.line > 0) {
+            this.goToPosition(tab, target.line - 1, Math.max(target.column - 1, 0));
+        }
+        return tab;
+    }
+    
+    // Forget a closed tab of a --wait launch, ending the launch once all its tabs are closed
+    releaseWait(waitId, tabId) {
+        const tabs = this.waits.get(waitId);
+        if (tabs) tabs.delete(tabId);
+        if (!tabs || tabs.size === 0) {
+            this.waits.delete(waitId);
+            FinishWait(waitId);
+        }
+    }
+    
+    // Compare two files, as asked for with --diff
+    async showFileDiff(pathA, pathB) {
+        let diff;
+        try {
+            diff = await DiffFiles(pathA, pathB);
+        } catch (err) {
+            this.showNotification('Failed to compare files: ' + (err.message || err), 'error');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-file-diff');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-file-diff';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '760px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        const name = path => path.slice(parentDir(path).length + 1);
+        dialog.innerHTML = `
+            <div class="dialog-header">${this.escapeHtml(name(pathA))} ↔ ${this.escapeHtml(name(pathB))}</div>
+            <div class="dialog-body">
+                <pre class="file-diff"></pre>
+            </div>
+            <div class="dialog-footer">
+                <button class="file-diff-open">Open Both</button>
+                <button class="file-diff-close">Close</button>
+            </div>
+        `;
+        dialog.querySelector('.file-diff').textContent = diff || 'The files are identical';
+        dialog.querySelector('.file-diff-open').addEventListener('click', async () => {
+            this.hideDialogs();
+            await this.openFilePath(pathA);
+            await this.openFilePath(pathB);
+        });
+        dialog.querySelector('.file-diff-close').addEventListener('click', () => this.hideDialogs());
+        dialog.classList.remove('hidden');
+    }
+    
+    // ============================================
+    // Git
+    // ============================================
+    
+    // The file or folder whose repository the Git menu and the status bar
+    // show: the active tab's file, or else the open folder
+    gitPath() {
+        const tab = this.getActiveTab();
+        const path = tab && tab.fileInfo.Path;
+        if (path && !path.includes(ARCHIVE_SEPARATOR)) return path;
+        return this.workspace ? this.workspace.root : '';
+    }
+    
+    // Update the branch indicator and the active tab's change markers
+    async refreshGit() {
+        const path = this.gitPath();
+        let status = null;
+        if (path) {
+            try {
+                status = await GitStatus(path);
+            } catch (err) {
+                // Not in a repository, or git isn't installed
+            }
+        }
+        if (path !== this.gitPath()) return; // another tab was opened meanwhile
+        this.showGitStatus(status);
+        const tab = this.getActiveTab();
+        if (tab) this.loadGitChanges(tab);
+    }
+    
+    onGitStatus(status) {
+        const path = this.gitPath();
+        if (path && isPathUnder(path, status.root)) this.showGitStatus(status);
+        for (const tab of this.tabs) {
+            if (!tab.fileInfo.Path || !isPathUnder(tab.fileInfo.Path, status.root)) continue;
+            if (tab.id === this.activeTabId) {
+                this.loadGitChanges(tab);
+            } else {
+                tab.git = null; // loaded again when the tab is shown
+            }
+        }
+    }
+    
+    showGitStatus(status) {
+        this.gitStatus = status;
+        const el = this.elements.statusGit;
+        if (!el) return;
+        el.classList.toggle('hidden', !status);
+        if (!status) return;
+        
+        let text = `⎇ ${status.branch}${status.dirty ? '*' : ''}`;
+        if (status.ahead) text += ` ↑${status.ahead}`;
+        if (status.behind) text += ` ↓${status.behind}`;
+        el.textContent = text;
+        const changed = (status.files || []).length;
+        el.title = `${status.root}\n` +
+            (changed ? `${changed} changed file${changed === 1 ? '' : 's'}. Click to commit.` : 'No changes');
+    }
+    
+    // Load which lines of a tab's saved file differ from HEAD, for the
+    // markers beside the line numbers
+    async loadGitChanges(tab) {
+        const path = tab.fileInfo.Path;
+        if (!path || path.includes(ARCHIVE_SEPARATOR) || tab.largeFile || tab.fileInfo.Binary || tab.fileInfo.Encrypted) {
+            tab.git = null;
+            return;
+        }
+        let changes = null;
+        try {
+            changes = await GitFileChanges(path);
+        } catch (err) {
+            // Not in a repository
+        }
+        
+        tab.git = null;
+        if (changes) {
+            const lines = new Map();
+            for (const hunk of changes.hunks || []) {
+                if (hunk.kind === 'deleted') {
+                    lines.set(Math.max(hunk.newStart, 1), 'deleted');
+                    continue;
+                }
+                for (let line = hunk.newStart; line < hunk.newStart + hunk.newLines; line++) {
+                    lines.set(line, hunk.kind);
+                }
+            }
+            tab.git = { changes, lines };
+        }
+        if (tab.id === this.activeTabId && this.showLineNumbers) this.updateLineNumbers(tab);
+    }
+    
+    // The active tab, if its file is saved where git can see it
+    gitFileTab() {
+        const tab = this.getActiveTab();
+        if (!tab || !tab.fileInfo.Path || tab.fileInfo.Path.includes(ARCHIVE_SEPARATOR)) {
+            this.showNotification('Save the file in a git repository first', 'warning');
+            return null;
+        }
+        return tab;
+    }
+    
+    // Show the active file's changes since the last commit
+    async showGitDiff() {
+        const tab = this.gitFileTab();
+        if (!tab) return;
+        let diff;
+        try {
+            diff = await GitDiff(tab.fileInfo.Path);
+        } catch (err) {
+            this.showNotification('Failed to show changes: ' + (err.message || err), 'error');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-git-diff');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-git-diff';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '760px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        dialog.innerHTML = `
+            <div class="dialog-header">${this.escapeHtml(tab.fileInfo.Name)} ↔ HEAD</div>
+            <div class="dialog-body">
+                ${tab.fileInfo.IsDirty ? '<p class="git-note">Unsaved edits are not included.</p>' : ''}
+                <pre class="file-diff"></pre>
+            </div>
+            <div class="dialog-footer">
+                <button class="git-diff-close">Close</button>
+            </div>
+        `;
+        dialog.querySelector('.file-diff').textContent = diff || 'No changes since the last commit';
+        dialog.querySelector('.git-diff-close').addEventListener('click', () => this.hideDialogs());
+        dialog.classList.remove('hidden');
+    }
+    
+    // Show who last changed each line of the active file, and in which commit
+    async showGitBlame() {
+        const tab = this.gitFileTab();
+        if (!tab) return;
+        let blame;
+        try {
+            blame = await GitBlame(tab.fileInfo.Path) || [];
+        } catch (err) {
+            this.showNotification('Failed to blame file: ' + (err.message || err), 'error');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-git-blame');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-git-blame';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '860px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        dialog.innerHTML = `
+            <div class="dialog-header">Blame: ${this.escapeHtml(tab.fileInfo.Name)}</div>
+            <div class="dialog-body">
+                ${tab.fileInfo.IsDirty ? '<p class="git-note">Showing the file as last saved.</p>' : ''}
+                <div class="git-blame"></div>
+            </div>
+            <div class="dialog-footer">
+                <button class="git-blame-close">Close</button>
+            </div>
+        `;
+        
+        // The saved text lines up with the blame only when there are no edits
+        const text = tab.fileInfo.IsDirty || !tab.textarea ? null : tab.textarea.value.split('\n');
+        const list = dialog.querySelector('.git-blame');
+        const currentLine = this.getCurrentLineNumber(tab);
+        let previous = null;
+        let current = null;
+        for (const entry of blame) {
+            const row = document.createElement('div');
+            row.className = 'git-blame-row';
+            if (entry.commit !== previous) {
+                row.classList.add('git-blame-first');
+                row.title = entry.uncommitted ? 'Not committed yet' : entry.summary;
+            }
+            const meta = entry.commit === previous ? ''
+                : entry.uncommitted ? 'Not committed yet'
+                : `${entry.commit} · ${entry.author} · ${new Date(entry.time * 1000).toLocaleDateString()}`;
+            row.innerHTML = `
+                <span class="git-blame-meta">${this.escapeHtml(meta)}</span>
+                <span class="git-blame-line">${entry.line}</span>
+                <span class="git-blame-text">${text ? this.escapeHtml(text[entry.line - 1] ?? '') : ''}</span>
+            `;
+            row.addEventListener('click', () => {
+                this.hideDialogs();
+                this.goToPosition(tab, entry.line - 1);
+            });
+            if (entry.line === currentLine) {
+                row.classList.add('current');
+                current = row;
+            }
+            list.appendChild(row);
+            previous = entry.commit;
+        }
+        dialog.querySelector('.git-blame-close').addEventListener('click', () => this.hideDialogs());
+        dialog.classList.remove('hidden');
+        if (current) current.scrollIntoView({ block: 'center' });
+    }
+    
+    // Revert the change under the cursor to how it is in HEAD
+    async discardGitHunkAtCursor() {
+        const tab = this.gitFileTab();
+        if (!tab || !tab.textarea) return;
+        if (tab.fileInfo.IsDirty) {
+            this.showNotification('Save or undo your edits before discarding a change', 'warning');
+            return;
+        }
+        if (!tab.git) await this.loadGitChanges(tab);
+        
+        const line = this.getCurrentLineNumber(tab);
+        const hunk = tab.git && tab.git.changes.hunks.find(h => h.kind === 'deleted'
+            ? Math.max(h.newStart, 1) === line
+            : line >= h.newStart && line < h.newStart + h.newLines);
+        if (!hunk) {
+            this.showNotification('There is no change at the cursor', 'info');
+            return;
+        }
+        
+        const lines = (n) => `${n} line${n === 1 ? '' : 's'}`;
+        const what = hunk.kind === 'added' ? `Remove the ${lines(hunk.newLines)} added`
+            : hunk.kind === 'deleted' ? `Restore the ${lines(hunk.oldLines)} deleted`
+            : `Revert the ${lines(hunk.newLines)} changed`;
+        this.showStyledConfirmDialog(
+            'Discard Change',
+            `${what} at line ${Math.max(hunk.newStart, 1)} of ${tab.fileInfo.Name}, as in the last commit?`,
+            'Discard',
+            'Cancel',
+            async () => {
+                try {
+                    await GitDiscardHunk(tab.fileInfo.Path, hunk);
+                } catch (err) {
+                    this.showNotification('Failed to discard change: ' + (err.message || err), 'error');
+                    return;
+                }
+                await this.reloadTab(tab, tab.fileInfo.Encoding);
+                this.goToPosition(tab, Math.max(hunk.newStart, 1) - 1);
+            }
+        );
+    }
+    
+    async stageActiveFile(stage) {
+        const tab = this.gitFileTab();
+        if (!tab) return;
+        try {
+            await (stage ? GitStage : GitUnstage)(tab.fileInfo.Path);
+        } catch (err) {
+            this.showNotification(`Failed to ${stage ? 'stage' : 'unstage'} file: ` + (err.message || err), 'error');
+            return;
+        }
+        this.showNotification(stage && tab.fileInfo.IsDirty
+            ? `Staged ${tab.fileInfo.Name} as last saved; unsaved edits are not included`
+            : `${stage ? 'Staged' : 'Unstaged'} ${tab.fileInfo.Name}`, 'success');
+    }
+    
+    // List the repository's changed files to stage or unstage, and commit
+    // what is staged
+    async showGitCommitDialog() {
+        const path = this.gitPath();
+        if (!path) {
+            this.showNotification('Open a file or folder in a git repository first', 'warning');
+            return;
+        }
+        let status;
+        try {
+            status = await GitStatus(path);
+        } catch (err) {
+            this.showNotification('Failed to read git status: ' + (err.message || err), 'error');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-git-commit');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-git-commit';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '600px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        dialog.innerHTML = `
+            <div class="dialog-header"></div>
+            <div class="dialog-body">
+                <div class="git-files"></div>
+                <textarea class="git-message" rows="4" placeholder="Commit message (Ctrl+Enter to commit)"></textarea>
+            </div>
+            <div class="dialog-footer">
+                <button class="git-commit-cancel">Cancel</button>
+                <button class="git-commit-ok">Commit</button>
+            </div>
+        `;
+        
+        const list = dialog.querySelector('.git-files');
+        const message = dialog.querySelector('.git-message');
+        const render = () => {
+            this.showGitStatus(status);
+            dialog.querySelector('.dialog-header').textContent = `Commit to ${status.branch}`;
+            list.innerHTML = '';
+            for (const entry of status.files || []) {
+                const state = entry.untracked ? '?' : entry.conflicted ? 'U' : (entry.staged || '·') + (entry.unstaged || '·');
+                const item = document.createElement('div');
+                item.className = 'git-file';
+                item.innerHTML = `
+                    <span class="git-file-state">${this.escapeHtml(state)}</span>
+                    <span class="git-file-name">${this.escapeHtml(entry.path.slice(status.root.length + 1))}</span>
+                    ${entry.staged ? '<button class="git-file-unstage">Unstage</button>' : ''}
+                    ${entry.unstaged || entry.untracked || entry.conflicted ? '<button class="git-file-stage">Stage</button>' : ''}
+                `;
+                item.querySelector('.git-file-name').addEventListener('click', () => {
+                    if (entry.unstaged === 'D' || entry.staged === 'D') return;
+                    this.hideDialogs();
+                    this.openFilePath(entry.path);
+                });
+                for (const [selector, action] of [['.git-file-stage', GitStage], ['.git-file-unstage', GitUnstage]]) {
+                    const button = item.querySelector(selector);
+                    if (!button) continue;
+                    button.addEventListener('click', async () => {
+                        try {
+                            await action(entry.path);
+                            status = await GitStatus(path);
+                        } catch (err) {
+                            this.showNotification(err.message || String(err), 'error');
+                        }
+                        render();
+                    });
+                }
+                list.appendChild(item);
+            }
+            if (!list.firstChild) {
+                list.innerHTML = '<div class="git-empty">No changes</div>';
+            }
+        };
+        
+        const commit = async () => {
+            if (!(status.files || []).some(entry => entry.staged)) {
+                this.showNotification('Stage some changes to commit first', 'warning');
+                return;
+            }
+            if (!message.value.trim()) {
+                this.showNotification('Enter a commit message', 'warning');
+                message.focus();
+                return;
+            }
+            try {
+                const hash = await GitCommit(path, message.value);
+                this.hideDialogs();
+                this.showNotification(`Committed ${hash} to ${status.branch}`, 'success');
+            } catch (err) {
+                this.showNotification('Failed to commit: ' + (err.message || err), 'error');
+            }
+        };
+        message.addEventListener('keydown', (e) => {
+            if (e.key === 'Enter' && e.ctrlKey) commit();
+        });
+        dialog.querySelector('.git-commit-ok').addEventListener('click', commit);
+        dialog.querySelector('.git-commit-cancel').addEventListener('click', () => this.hideDialogs());
+        render();
+        
+        dialog.classList.remove('hidden');
+        message.focus();
+    }
+    
+    // ============================================
+    // Find in Folder
+    // ============================================
+    
+    // Search and replace across the files of the open folder (Ctrl+Shift+F)
+    showProjectSearchDialog() {
+        if (!this.workspace) {
+            this.showNotification('Open a folder first (File > Open Folder)', 'warning');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-project-search');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-project-search';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '720px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        const last = this.projectSearchOptions || { query: '', replacement: '', include: '', exclude: '' };
+        const selected = this.getActiveTab()?.textarea;
+        const selection = selected ? selected.value.substring(selected.selectionStart, selected.selectionEnd) : '';
+        const query = selection && !selection.includes('\n') ? selection : last.query;
+        dialog.innerHTML = `
+            <div class="dialog-header">Find in Folder</div>
+            <div class="dialog-body">
+                <div class="project-search-row">
+                    <input type="text" class="project-search-query" placeholder="Search">
+                    <label title="Match case"><input type="checkbox" class="project-search-case"> Aa</label>
+                    <label title="Whole word"><input type="checkbox" class="project-search-word"> Word</label>
+                    <label title="Regular expression"><input type="checkbox" class="project-search-regex"> .*</label>
+                </div>
+                <div class="project-search-row">
+                    <input type="text" class="project-search-replacement" placeholder="Replace with">
+                    <button class="project-search-preview">Preview Replace</button>
+                </div>
+                <div class="project-search-row">
+                    <input type="text" class="project-search-include" placeholder="Files to include, e.g. *.go, src/">
+                    <input type="text" class="project-search-exclude" placeholder="Files to exclude">
+                </div>
+                <div class="project-search-status"></div>
+                <div class="project-search-results"></div>
+            </div>
+            <div class="dialog-footer">
+                <button class="project-search-undo">Undo Last Replace</button>
+                <button class="project-search-apply hidden">Replace in Selected Files</button>
+                <button class="project-search-close">Close</button>
+            </div>
+        `;
+        
+        const queryInput = dialog.querySelector('.project-search-query');
+        const replacementInput = dialog.querySelector('.project-search-replacement');
+        const includeInput = dialog.querySelector('.project-search-include');
+        const excludeInput = dialog.querySelector('.project-search-exclude');
+        const caseBox = dialog.querySelector('.project-search-case');
+        const wordBox = dialog.querySelector('.project-search-word');
+        const regexBox = dialog.querySelector('.project-search-regex');
+        const status = dialog.querySelector('.project-search-status');
+        const results = dialog.querySelector('.project-search-results');
+        const applyBtn = dialog.querySelector('.project-search-apply');
+        const undoBtn = dialog.querySelector('.project-search-undo');
+        
+        queryInput.value = query;
+        replacementInput.value = last.replacement;
+        includeInput.value = last.include;
+        excludeInput.value = last.exclude;
+        caseBox.checked = !!last.caseSensitive;
+        wordBox.checked = !!last.wholeWord;
+        regexBox.checked = !!last.regex;
+        
+        const globs = value => value.split(',').map(g => g.trim()).filter(Boolean);
+        const readOptions = () => {
+            this.projectSearchOptions = {
+                query: queryInput.value,
+                replacement: replacementInput.value,
+                include: includeInput.value,
+                exclude: excludeInput.value,
+                caseSensitive: caseBox.checked,
+                wholeWord: wordBox.checked,
+                regex: regexBox.checked
+            };
+            return {
+                query: queryInput.value,
+                regex: regexBox.checked,
+                caseSensitive: caseBox.checked,
+                wholeWord: wordBox.checked,
+                include: globs(includeInput.value),
+                exclude: globs(excludeInput.value)
+            };
+        };
+        const refreshUndo = async () => {
+            undoBtn.disabled = !(await CanUndoReplace().catch(() => false));
+        };
+        const discardPlan = () => {
+            if (this.replacePlanId) DiscardReplace(this.replacePlanId);
+            this.replacePlanId = null;
+            applyBtn.classList.add('hidden');
+        };
+        const close = () => {
+            this.cancelProjectSearch();
+            discardPlan();
+            this.hideDialogs();
+        };
+        
+        const search = async () => {
+            this.cancelProjectSearch();
+            discardPlan();
+            results.innerHTML = '';
+            const options = readOptions();
+            if (!options.query) {
+                status.textContent = '';
+                return;
+            }
+            this.projectSearch = {
+                requestId: `project-search-${Date.now()}`,
+                list: results,
+                status,
+                files: 0,
+                matches: 0
+            };
+            status.textContent = 'Searching…';
+            try {
+                await SearchWorkspace(this.projectSearch.requestId, options);
+            } catch (err) {
+                this.projectSearch = null;
+                status.textContent = err.message || String(err);
+            }
+        };
+        
+        const preview = async () => {
+            this.cancelProjectSearch();
+            discardPlan();
+            results.innerHTML = '';
+            const options = readOptions();
+            if (!options.query) return;
+            status.textContent = 'Preparing replace…';
+            let plan;
+            try {
+                plan = await PrepareReplace(options, replacementInput.value);
+            } catch (err) {
+                status.textContent = err.message || String(err);
+                return;
+            }
+            if (!plan.files.length) {
+                status.textContent = 'Nothing to replace';
+                DiscardReplace(plan.planId);
+                return;
+            }
+            this.replacePlanId = plan.planId;
+            status.textContent = `${plan.replacements.toLocaleString()} replacements in ${plan.files.length.toLocaleString()} files`;
+            for (const file of plan.files) {
+                const item = document.createElement('div');
+                item.className = 'project-search-file';
+                item.innerHTML = `
+                    <label class="project-search-file-name">
+                        <input type="checkbox" checked data-path="${this.escapeHtml(file.path)}">
+                        ${this.escapeHtml(file.relPath)}
+                        <span class="project-search-count">${file.replacements.toLocaleString()}</span>
+                    </label>
+                    <pre class="project-search-diff"></pre>
+                `;
+                item.querySelector('.project-search-diff').textContent = file.diff;
+                results.appendChild(item);
+            }
+            applyBtn.classList.remove('hidden');
+        };
+        
+        applyBtn.addEventListener('click', async () => {
+            const paths = Array.from(results.querySelectorAll('input[type=checkbox]:checked')).map(box => box.dataset.path);
+            if (!this.replacePlanId || paths.length === 0) return;
+            try {
+                const result = await ApplyReplace(this.replacePlanId, paths);
+                this.replacePlanId = null;
+                applyBtn.classList.add('hidden');
+                results.innerHTML = '';
+                status.textContent = `Replaced ${result.replacements.toLocaleString()} matches in ${result.files.length.toLocaleString()} files`;
+            } catch (err) {
+                this.replacePlanId = null;
+                applyBtn.classList.add('hidden');
+                this.showNotification('Replace failed: ' + (err.message || err), 'error');
+            }
+            refreshUndo();
+        });
+        undoBtn.addEventListener('click', async () => {
+            try {
+                const result = await UndoReplace();
+                status.textContent = `Undid ${result.replacements.toLocaleString()} replacements in ${result.files.length.toLocaleString()} files`;
+            } catch (err) {
+                this.showNotification('Undo failed: ' + (err.message || err), 'error');
+            }
+            refreshUndo();
+        });
+        dialog.querySelector('.project-search-preview').addEventListener('click', preview);
+        dialog.querySelector('.project-search-close').addEventListener('click', close);
+        for (const input of [queryInput, includeInput, excludeInput]) {
+            input.addEventListener('keydown', (e) => {
+                if (e.key === 'Enter') {
+                    e.preventDefault();
+                    search();
+                } else if (e.key === 'Escape') {
+                    close();
+                }
+            });
+        }
+        replacementInput.addEventListener('keydown', (e) => {
+            if (e.key === 'Enter') {
+                e.preventDefault();
+                preview();
+            } else if (e.key === 'Escape') {
+                close();
+            }
+        });
+        for (const box of [caseBox, wordBox, regexBox]) {
+            box.addEventListener('change', search);
+        }
+        
+        dialog.classList.remove('hidden');
+        queryInput.focus();
+        queryInput.select();
+        refreshUndo();
+        if (query) search();
+    }
+    
+    cancelProjectSearch() {
+        if (this.projectSearch) {
+            CancelWorkspaceSearch(this.projectSearch.requestId);
+            this.projectSearch = null;
+        }
+    }
+    
+    onProjectSearchResults(results) {
+        const state = this.projectSearch;
+        if (!state || state.requestId !== results.requestId) return;
+        
+        for (const file of results.files || []) {
+            state.files++;
+            const group = document.createElement('div');
+            group.className = 'project-search-file';
+            group.innerHTML = `<div class="project-search-file-name">${this.escapeHtml(file.relPath)}` +
+                `<span class="project-search-count">${file.matches.length.toLocaleString()}</span></div>`;
+            for (const match of file.matches) {
+                state.matches++;
+                if (state.matches > PROJECT_SEARCH_MAX_LISTED_MATCHES) continue;
+                
+                const item = document.createElement('div');
+                item.className = 'project-search-match';
+                const before = match.text.substring(0, match.column);
+                const hit = match.text.substring(match.column, match.column + match.length);
+                const after = match.text.substring(match.column + match.length);
+                item.innerHTML = `<span class="project-search-line">${(match.line + 1).toLocaleString()}</span>` +
+                    `${this.escapeHtml(before)}<mark>${this.escapeHtml(hit)}</mark>${this.escapeHtml(after)}`;
+                item.addEventListener('click', () => this.openProjectMatch(file.path, match));
+                group.appendChild(item);
+            }
+            state.list.appendChild(group);
+        }
+        
+        let summary = `${results.matches.toLocaleString()} matches in ${state.files.toLocaleString()} files`;
+        if (!results.done) summary += ` (searched ${results.filesSearched.toLocaleString()} files)`;
+        if (results.truncated) summary += ', stopped at the match limit';
+        if (state.matches > PROJECT_SEARCH_MAX_LISTED_MATCHES) {
+            summary += `, first ${PROJECT_SEARCH_MAX_LISTED_MATCHES.toLocaleString()} listed`;
+        }
+        state.status.textContent = summary;
+        
+        if (results.done) {
+            this.projectSearch = null;
+            if (results.error) {
+                state.status.textContent = 'Search failed: ' + results.error;
+            }
+        }
+    }
+    
+    // Open a file from the project search results and select the match
+    async openProjectMatch(path, match) {
+        this.cancelProjectSearch();
+        this.hideDialogs();
+        const tab = await this.openFilePath(path);
+        if (tab) this.goToPosition(tab, match.line, match.lineColumn, match.length);
+    }
+    
+    // Select length characters at a 0-based line and column, or just place
+    // the cursor there if length is 0
+    goToPosition(tab, line, column = 0, length = 0) {
+        if (tab.largeFile) {
+            this.goToLargeFileLine(tab, line, column, length);
+            return;
+        }
+        if (!tab.textarea) return;
+        
+        const lines = tab.textarea.value.split('\n');
+        line = Math.min(line, lines.length - 1);
+        column = Math.min(column, lines[line].length);
+        let offset = 0;
+        for (let i = 0; i < line; i++) offset += lines[i].length + 1;
+        tab.textarea.focus();
+        tab.textarea.setSelectionRange(offset + column, offset + column + length);
+        tab.textarea.scrollTop = Math.max(0, line * 21 - tab.textarea.clientHeight / 2);
+        if (this.showLineNumbers) this.updateLineNumbers(tab);
+    }
+    
+    // ============================================
+    // Archives and Recent Files
+    // ============================================
+    
+    // List the files inside a zip or tar archive; each opens read-only in a tab
+    async showArchiveDialog(path) {
+        let listing;
+        try {
+            listing = await ListArchive(path);
+        } catch (err) {
+            this.showNotification('Failed to read archive: ' + (err.message || err), 'error');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-archive');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-archive';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '600px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        
+        const name = path.slice(parentDir(path).length + 1);
+        const entries = listing.entries || [];
+        dialog.innerHTML = `
+            <div class="dialog-header">${this.escapeHtml(name)} (${this.escapeHtml(listing.kind)})</div>
+            <div class="dialog-body">
+                <input type="text" class="archive-filter" placeholder="Filter ${entries.length.toLocaleString()} files">
+                <div class="archive-list"></div>
+            </div>
+            <div class="dialog-footer">
+                <button class="archive-close">Close</button>
+            </div>
+        `;
+        
+        const list = dialog.querySelector('.archive-list');
+        const filter = dialog.querySelector('.archive-filter');
+        const render = () => {
+            const query = filter.value.toLowerCase();
+            list.innerHTML = '';
+            for (const entry of entries) {
+                if (query && !entry.name.toLowerCase().includes(query)) continue;
+                const item = document.createElement('div');
+                item.className = 'history-item archive-item';
+                item.innerHTML = `
+                    <span class="history-item-name">${this.escapeHtml(entry.name)}</span>
+                    <span class="history-item-detail">${this.formatSize(entry.size)}</span>
+                `;
+                item.addEventListener('click', () => {
+                    this.hideDialogs();
+                    this.openFilePath(path + ARCHIVE_SEPARATOR + entry.name);
+                });
+                list.appendChild(item);
+            }
+            if (!list.firstChild) {
+                list.innerHTML = `<div class="archive-empty">${entries.length ? 'No matching files' : 'The archive is empty'}</div>`;
+            }
+        };
+        filter.addEventListener('input', render);
+        dialog.querySelector('.archive-close').addEventListener('click', () => this.hideDialogs());
+        render();
+        
+        dialog.classList.remove('hidden');
+        filter.focus();
+    }
+    
+    // Files opened lately, including ones inside archives
+    async showRecentFilesDialog() {
+        let recent;
+        try {
+            recent = await GetRecentFiles() || [];
+        } catch (err) {
+            this.showNotification('Failed to load recent files: ' + (err.message || err), 'error');
+            return;
+        }
+        if (recent.length === 0) {
+            this.showNotification('No recent files yet', 'info');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-recent-files');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-recent-files';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '600px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        dialog.innerHTML = `
+            <div class="dialog-header">Recent Files</div>
+            <div class="dialog-body">
+                <div class="archive-list"></div>
+            </div>
+            <div class="dialog-footer">
+                <button class="recent-clear">Clear</button>
+                <button class="recent-close">Close</button>
+            </div>
+        `;
+        
+        const list = dialog.querySelector('.archive-list');
+        for (const path of recent) {
+            // A file inside an archive shows as "bundle.zip › config/app.yaml"
+            const split = path.indexOf(ARCHIVE_SEPARATOR);
+            const file = split >= 0 ? path.slice(0, split) : path;
+            const folder = parentDir(file);
+            let name = file.slice(folder.length + 1);
+            if (split >= 0) name += ' › ' + path.slice(split + ARCHIVE_SEPARATOR.length);
+            
+            const item = document.createElement('div');
+            item.className = 'history-item archive-item';
+            item.title = path;
+            item.innerHTML = `
+                <span class="history-item-name">${this.escapeHtml(name)}</span>
+                <span class="history-item-detail">${this.escapeHtml(folder)}</span>
+            `;
+            item.addEventListener('click', () => {
+                this.hideDialogs();
+                this.openFilePath(path);
+            });
+            list.appendChild(item);
+        }
+        
+        dialog.querySelector('.recent-clear').addEventListener('click', async () => {
+            await ClearRecentFiles();
+            this.hideDialogs();
+        });
+        dialog.querySelector('.recent-close').addEventListener('click', () => this.hideDialogs());
+        
+        dialog.classList.remove('hidden');
+    }
+    
+    // ============================================
+    // File History
+    // ============================================
+    
+    // List the saved versions of the active file, to compare or restore
+    async showFileHistoryDialog() {
+        const tab = this.getActiveTab();
+        if (!tab || tab.fileInfo.IsNewFile || !tab.fileInfo.Path) {
+            this.showNotification('Save the file first to keep its history', 'warning');
+            return;
+        }
+        const path = tab.fileInfo.Path;
+        
+        let revisions;
+        try {
+            revisions = await GetFileHistory(path) || [];
+        } catch (err) {
+            this.showNotification('Failed to load file history: ' + (err.message || err), 'error');
+            return;
+        }
+        if (revisions.length === 0) {
+            this.showNotification(`No saved versions of ${tab.fileInfo.Name} yet`, 'info');
+            return;
+        }
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-file-history');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-file-history';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '640px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        
+        const savedAt = rev => new Date(rev.savedAt * 1000).toLocaleString();
+        const options = revisions.map(rev => `<option value="${rev.id}">${savedAt(rev)}</option>`).join('');
+        dialog.innerHTML = `
+            <div class="dialog-header">History of ${this.escapeHtml(tab.fileInfo.Name)}</div>
+            <div class="dialog-body">
+                <div class="history-list"></div>
+                <div class="history-compare">
+                    <select class="history-from">${options}</select>
+                    <span>to</span>
+                    <select class="history-to"><option value="">On disk</option>${options}</select>
+                    <button class="history-compare-btn">Compare</button>
+                </div>
+                <pre class="history-diff hidden"></pre>
+            </div>
+            <div class="dialog-footer">
+                <button class="history-close">Close</button>
+            </div>
+        `;
+        
+        const diffView = dialog.querySelector('.history-diff');
+        const fromSelect = dialog.querySelector('.history-from');
+        const toSelect = dialog.querySelector('.history-to');
+        const compare = async (fromId, toId) => {
+            fromSelect.value = fromId;
+            toSelect.value = toId;
+            try {
+                const diff = await DiffFileRevisions(path, fromId, toId);
+                diffView.textContent = diff || 'No differences';
+            } catch (err) {
+                diffView.textContent = 'Failed to compare: ' + (err.message || err);
+            }
+            diffView.classList.remove('hidden');
+        };
+        
+        const list = dialog.querySelector('.history-list');
+        revisions.forEach((rev, i) => {
+            const item = document.createElement('div');
+            item.className = 'history-item';
+            item.innerHTML = `
+                <span class="history-item-name">${savedAt(rev)}</span>
+                <span class="history-item-detail">${this.formatSize(rev.size)} · ${this.escapeHtml(rev.encoding)}</span>
+                <button class="history-changes">Changes</button>
+                <button class="history-restore">Open</button>
+            `;
+            // Changes made by this save, against the one before it
+            const previous = revisions[i + 1];
+            const changes = item.querySelector('.history-changes');
+            if (previous) {
+                changes.addEventListener('click', () => compare(previous.id, rev.id));
+            } else {
+                changes.disabled = true;
+            }
+            item.querySelector('.history-restore').addEventListener('click', async () => {
+                try {
+                    const result = await RestoreFileRevision(path, rev.id);
+                    const fileInfo = result.fileInfo;
+                    this.hideDialogs();
+                    const restored = this.createNewTab({
+                        Path: '',
+                        Name: fileInfo.name,
+                        Encoding: fileInfo.encoding || 'UTF-8',
+                        LineEnding: fileInfo.lineEnding || 'CRLF',
+                        LineEndingCounts: fileInfo.lineEndingCounts,
+                        TrailingNewline: fileInfo.trailingNewline,
+                        Language: fileInfo.language,
+                        IsDirty: false,
+                        IsNewFile: true
+                    }, result.content);
+                    this.markTabDirty(restored);
+                } catch (err) {
+                    this.showNotification('Failed to open version: ' + (err.message || err), 'error');
+                }
+            });
+            list.appendChild(item);
+        });
+        
+        dialog.querySelector('.history-compare-btn').addEventListener('click', () => {
+            compare(fromSelect.value, toSelect.value);
+        });
+        dialog.querySelector('.history-close').addEventListener('click', () => this.hideDialogs());
+        
+        dialog.classList.remove('hidden');
+    }
+    
+    // ============================================
+    // Crash Recovery
+    // ============================================
+    
+    scheduleRecoveryUpdate(tab) {
+        clearTimeout(tab.recoveryTimer);
+        tab.recoveryTimer = setTimeout(() => {
+            if (!tab.fileInfo.IsDirty || !this.tabs.includes(tab)) return;
+            const content = tab.textarea && this.activeTabId === tab.id ? tab.textarea.value : tab.content;
+            UpdateRecoveryBuffer({
+                bufferId: tab.bufferId,
+                path: tab.fileInfo.IsNewFile ? '' : tab.fileInfo.Path,
+                name: tab.fileInfo.Name,
+                encoding: tab.fileInfo.Encoding,
+                lineEnding: tab.fileInfo.LineEnding,
+                updatedAt: 0
+            }, content, tab.revision).catch(err => {
+                console.error('Failed to update recovery snapshot:', err);
+            });
+        }, RECOVERY_UPDATE_DELAY);
+    }
+    
+    onAutoSave(data) {
+        const tab = this.tabs.find(t => t.bufferId === data.bufferId);
+        if (!tab) return;
+        
+        if (data.error) {
+            this.showNotification(`Auto save of ${tab.fileInfo.Name} failed: ${data.error}`, 'warning');
+            return;
+        }
+        if (tab.revision !== data.revision) return; // edited again since
+        
+        tab.fileInfo.LineEnding = data.fileInfo.lineEnding;
+        tab.fileInfo.LineEndingCounts = data.fileInfo.lineEndingCounts;
+        tab.fileInfo.TrailingNewline = data.fileInfo.trailingNewline;
+        tab.fileInfo.IsDirty = false;
+        const dirtyEl = tab.element.querySelector('.tab-dirty');
+        if (dirtyEl) dirtyEl.style.display = 'none';
+        this.updateStatusBar();
+    }
+    
+    async offerRecovery() {
+        let buffers;
+        try {
+            buffers = await GetRecoverableBuffers();
+        } catch (err) {
+            console.error('Failed to list recoverable buffers:', err);
+            return;
+        }
+        const open = new Set(this.tabs.map(t => t.bufferId));
+        buffers = (buffers || []).filter(b => !open.has(b.info.bufferId));
+        if (buffers.length === 0) return;
+        
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-recovery');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-recovery';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '600px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        dialog.innerHTML = `
+            <div class="dialog-header">Recover Unsaved Changes</div>
+            <div class="dialog-body">
+                <p class="recovery-intro">Akashic closed with unsaved changes. Restore them?</p>
+                <div class="recovery-list"></div>
+            </div>
+            <div class="dialog-footer">
+                <button class="recovery-restore-all">Restore All</button>
+                <button class="recovery-discard-all">Discard All</button>
+                <button class="recovery-close">Later</button>
+            </div>
+        `;
+        
+        const list = dialog.querySelector('.recovery-list');
+        const remaining = new Set(buffers.map(b => b.info.bufferId));
+        const finish = (buffer, item) => {
+            remaining.delete(buffer.info.bufferId);
+            item.remove();
+            if (remaining.size === 0) this.hideDialogs();
+        };
+        const restore = (buffer, item) => {
+            this.restoreRecoveredBuffer(buffer);
+            finish(buffer, item);
+        };
+        const discard = (buffer, item) => {
+            DiscardRecoveryBuffer(buffer.info.bufferId).catch(err => {
+                console.error('Failed to discard recovery snapshot:', err);
+            });
+            finish(buffer, item);
+        };
+        
+        const items = buffers.map(buffer => {
+            const info = buffer.info;
+            const item = document.createElement('div');
+            item.className = 'recovery-item';
+            let detail = info.path ? this.escapeHtml(info.path) : 'Untitled';
+            if (buffer.diskMissing) detail += ' (deleted from disk)';
+            const saved = new Date(info.updatedAt * 1000).toLocaleString();
+            item.innerHTML = `
+                <div class="recovery-item-header">
+                    <span class="recovery-item-name">${this.escapeHtml(info.name)}</span>
+                    <span class="recovery-item-detail">${detail} · ${saved}</span>
+                    <button class="recovery-restore">Restore</button>
+                    <button class="recovery-discard">Discard</button>
+                </div>
+                ${buffer.diff ? `<pre class="recovery-diff">${this.escapeHtml(buffer.diff)}</pre>` : ''}
+            `;
+            item.querySelector('.recovery-restore').addEventListener('click', () => restore(buffer, item));
+            item.querySelector('.recovery-discard').addEventListener('click', () => discard(buffer, item));
+            list.appendChild(item);
+            return { buffer, item };
+        });
+        
+        dialog.querySelector('.recovery-restore-all').addEventListener('click', () => {
+            items.filter(({ buffer }) => remaining.has(buffer.info.bufferId)).forEach(({ buffer, item }) => restore(buffer, item));
+        });
+        dialog.querySelector('.recovery-discard-all').addEventListener('click', () => {
+            items.filter(({ buffer }) => remaining.has(buffer.info.bufferId)).forEach(({ buffer, item }) => discard(buffer, item));
+        });
+        dialog.querySelector('.recovery-close').addEventListener('click', () => this.hideDialogs());
+        
+        dialog.classList.remove('hidden');
+    }
+    
+    // Open a recovered buffer in a new tab. The tab keeps the buffer's ID so
+    // its snapshot is updated in place rather than dropped and rewritten.
+    restoreRecoveredBuffer(buffer) {
+        const info = buffer.info;
+        const tab = this.createNewTab({
+            Path: info.path,
+            Name: info.name,
+            Encoding: info.encoding || 'UTF-8',
+            LineEnding: info.lineEnding || 'CRLF',
+            IsDirty: false,
+            IsNewFile: !info.path || buffer.diskMissing
+        }, buffer.content);
+        tab.bufferId = info.bufferId;
+        this.markTabDirty(tab);
+        
+        // Replace the initial empty tab if it was never touched
+        const first = this.tabs[0];
+        if (first !== tab && first.fileInfo.IsNewFile && !first.fileInfo.IsDirty && !first.content) {
+            this.forceCloseTab(first.id);
+        }
+    }
+    
+    // Reload a clean tab when its file changes on disk; offer to merge into a dirty one
+    async onExternalChange(path) {
+        const tab = this.tabs.find(t => t.fileInfo.Path === path);
+        if (!tab) return;
+        
+        if (!tab.fileInfo.IsDirty) {
+            if (await this.reloadTab(tab, tab.fileInfo.Encoding)) {
+                this.showNotification(`${tab.fileInfo.Name} was reloaded after changing on disk`, 'info');
+            }
+            return;
+        }
+        
+        this.showStyledConfirmDialog(
+            'File Changed on Disk',
+            `${tab.fileInfo.Name} was changed by another program and has unsaved edits here. Merge the changes into your edits?`,
+            'Merge',
+            'Keep Mine',
+            () => this.mergeExternalChanges(tab)
+        );
+    }
+    
+    async mergeExternalChanges(tab) {
+        try {
+            const result = await MergeExternalChanges(tab.fileInfo.Path, tab.textarea.value);
+            tab.textarea.value = result.content;
+            tab.content = tab.textarea.value;
+            this.markTabDirty(tab);
+            this.updateStatusBar();
+            if (result.clean) {
+                this.showNotification(`Merged changes from disk into ${tab.fileInfo.Name}`, 'success');
+            } else {
+                this.showNotification(`${result.conflicts} conflicting regions in ${tab.fileInfo.Name} are marked with <<<<<<< and >>>>>>>`, 'warning');
+            }
+        } catch (err) {
+            console.error('Failed to merge changes:', err);
+            this.showNotification('Failed to merge changes: ' + (err.message || err), 'error');
+        }
+    }
+    
+    // ============================================
+    // Large Files
+    // ============================================
+    
+    createLargeFileBar(tab) {
+        const bar = document.createElement('div');
+        bar.className = 'large-file-bar';
+        bar.innerHTML = `
+            <div class="large-file-nav">
+                <button class="large-file-prev" title="Previous page">◀</button>
+                <button class="large-file-next" title="Next page">▶</button>
+                <span class="large-file-status"></span>
+                <input class="large-file-goto" type="number" min="1" placeholder="Go to line">
+                <input class="large-file-query" type="text" placeholder="Search whole file">
+                <button class="large-file-search">Search</button>
+                <button class="large-file-edit" title="Load the whole file into the editor">Edit</button>
+            </div>
+            <div class="large-file-results hidden"></div>
+        `;
+        
+        bar.querySelector('.large-file-prev').addEventListener('click', () => {
+            this.loadLargeFilePage(tab, tab.largeFile.pageStart - LARGE_FILE_PAGE_LINES);
+        });
+        bar.querySelector('.large-file-next').addEventListener('click', () => {
+            this.loadLargeFilePage(tab, tab.largeFile.pageStart + LARGE_FILE_PAGE_LINES);
+        });
+        bar.querySelector('.large-file-goto').addEventListener('keydown', (e) => {
+            if (e.key !== 'Enter') return;
+            const line = parseInt(e.target.value, 10);
+            if (line > 0) this.goToLargeFileLine(tab, line - 1);
+        });
+        const query = bar.querySelector('.large-file-query');
+        query.addEventListener('keydown', (e) => {
+            if (e.key === 'Enter') this.searchLargeFile(tab, query.value);
+        });
+        bar.querySelector('.large-file-search').addEventListener('click', () => {
+            this.searchLargeFile(tab, query.value);
+        });
+        bar.querySelector('.large-file-edit').addEventListener('click', () => this.editLargeFile(tab));
+        
+        tab.largeFileBar = bar;
+        this.loadLargeFilePage(tab, tab.largeFile.pageStart);
+        return bar;
+    }
+    
+    async loadLargeFilePage(tab, start) {
+        const state = tab.largeFile;
+        start = Math.max(0, Math.min(start, Math.max(0, state.totalLines - 1)));
+        try {
+            const range = await ReadLines(tab.fileInfo.Path, start, LARGE_FILE_PAGE_LINES);
+            state.pageStart = range.start;
+            state.pageLength = range.lines.length;
+            state.totalLines = range.totalLines;
+            state.indexed = range.indexed;
+            tab.content = range.lines.join('\n');
+            if (tab.textarea) {
+                tab.textarea.value = tab.content;
+                tab.textarea.scrollTop = 0;
+                if (this.showLineNumbers) this.updateLineNumbers(tab);
+            }
+            if (range.truncated) {
+                this.showNotification('Very long lines on this page are cut short', 'info');
+            }
+            this.updateLargeFileBar(tab);
+        } catch (err) {
+            console.error('Failed to read lines:', err);
+            this.showNotification('Failed to read lines: ' + (err.message || err), 'error');
+        }
+    }
+    
+    // Show the page holding a 0-based line and select that line
+    async goToLargeFileLine(tab, line, column = 0, length = 0) {
+        const start = Math.floor(line / LARGE_FILE_PAGE_LINES) * LARGE_FILE_PAGE_LINES;
+        if (start !== tab.largeFile.pageStart || !tab.largeFile.pageLength) {
+            await this.loadLargeFilePage(tab, start);
+        }
+        if (!tab.textarea) return;
+        
+        const lines = tab.textarea.value.split('\n');
+        const index = line - tab.largeFile.pageStart;
+        if (index < 0 || index >= lines.length) return;
+        let offset = 0;
+        for (let i = 0; i < index; i++) offset += lines[i].length + 1;
+        tab.textarea.focus();
+        tab.textarea.setSelectionRange(offset + column, offset + column + (length || lines[index].length - column));
+        tab.textarea.scrollTop = Math.max(0, index * 21 - tab.textarea.clientHeight / 2);
+        if (this.showLineNumbers) this.updateLineNumbers(tab);
+    }
+    
+    updateLargeFileBar(tab) {
+        const bar = tab.largeFileBar;
+        if (!bar || !bar.isConnected) return;
+        
+        const state = tab.largeFile;
+        const first = state.pageLength ? state.pageStart + 1 : 0;
+        const last = state.pageStart + (state.pageLength || 0);
+        let status = `Lines ${first.toLocaleString()}–${last.toLocaleString()} of ${state.totalLines.toLocaleString()}`;
+        if (!state.indexed) {
+            status += ` (indexing ${Math.round(state.progress * 100)}%)`;
         }
-        this.elements.statusZoom.textContent = `${this.zoomLevel}%`;
+        bar.querySelector('.large-file-status').textContent = status + ' · read-only';
+        bar.querySelector('.large-file-prev').disabled = state.pageStart === 0;
+        bar.querySelector('.large-file-next').disabled = last >= state.totalLines;
     }
     
-    toggleFullscreen() {
-        if (!document.fullscreenElement) {
-            document.documentElement.requestFullscreen();
-        } else {
-            document.exitFullscreen();
+    onLargeFileProgress(progress) {
+        for (const tab of this.tabs) {
+            if (!tab.largeFile || tab.fileInfo.Path !== progress.path) continue;
+            
+            const state = tab.largeFile;
+            state.totalLines = progress.lines;
+            state.indexed = progress.done;
+            state.progress = progress.size ? progress.bytesIndexed / progress.size : 1;
+            if (progress.error) {
+                this.showNotification(`Failed to index ${tab.fileInfo.Name}: ${progress.error}`, 'error');
+            }
+            
+            // Fill the page in as indexing reaches it
+            if (tab.id === this.activeTabId && (state.pageLength || 0) < LARGE_FILE_PAGE_LINES &&
+                state.totalLines > state.pageStart + (state.pageLength || 0)) {
+                this.loadLargeFilePage(tab, state.pageStart);
+            } else {
+                this.updateLargeFileBar(tab);
+            }
         }
     }
     
-    toggleAISidebar() {
-        const isHidden = this.elements.aiSidebar.classList.contains('hidden');
-        this.elements.aiSidebar.classList.toggle('hidden');
+    async searchLargeFile(tab, query) {
+        if (!query) return;
+        const state = tab.largeFile;
+        if (state.searchId) {
+            CancelLargeFileSearch(state.searchId);
+        }
         
-        // Initialize AI when opening sidebar
-        if (isHidden) {
-            if (!this.ollamaStatus.checked) {
-                this.ollamaStatus.checked = true;
-                this.checkOllamaInstallation();
-                this.loadInstalledModels();
-            } else {
-                // Re-check server status to update UI
-                this.checkServerStatus();
+        state.searchId = `search-${tab.id}-${Date.now()}`;
+        state.matchCount = 0;
+        const results = tab.largeFileBar.querySelector('.large-file-results');
+        results.innerHTML = '<div class="large-file-results-summary">Searching…</div>';
+        results.classList.remove('hidden');
+        
+        try {
+            await SearchLargeFile(state.searchId, tab.fileInfo.Path, query, false, false);
+        } catch (err) {
+            state.searchId = null;
+            results.classList.add('hidden');
+            this.showNotification('Search failed: ' + (err.message || err), 'error');
+        }
+    }
+    
+    onLargeFileSearchResults(results) {
+        const tab = this.tabs.find(t => t.largeFile && t.largeFile.searchId === results.requestId);
+        if (!tab || !tab.largeFileBar) return;
+        
+        const state = tab.largeFile;
+        const list = tab.largeFileBar.querySelector('.large-file-results');
+        for (const match of results.matches || []) {
+            state.matchCount++;
+            if (state.matchCount > LARGE_FILE_MAX_LISTED_MATCHES) continue;
+            
+            const item = document.createElement('div');
+            item.className = 'large-file-match';
+            const before = match.text.substring(0, match.column);
+            const hit = match.text.substring(match.column, match.column + match.length);
+            const after = match.text.substring(match.column + match.length);
+            item.innerHTML = `<span class="large-file-match-line">${(match.line + 1).toLocaleString()}</span>` +
+                `${this.escapeHtml(before)}<mark>${this.escapeHtml(hit)}</mark>${this.escapeHtml(after)}`;
+            item.addEventListener('click', () => {
+                this.goToLargeFileLine(tab, match.line, match.lineColumn, match.length);
+            });
+            list.appendChild(item);
+        }
+        
+        const percent = results.size ? Math.round(results.bytesSearched / results.size * 100) : 100;
+        let summary = `${state.matchCount.toLocaleString()} matches`;
+        if (!results.done) summary += ` (searched ${percent}%)`;
+        if (results.truncated) summary += ', stopped at the match limit';
+        if (state.matchCount > LARGE_FILE_MAX_LISTED_MATCHES) {
+            summary += `, first ${LARGE_FILE_MAX_LISTED_MATCHES.toLocaleString()} listed`;
+        }
+        list.querySelector('.large-file-results-summary').textContent = summary;
+        
+        if (results.done) {
+            state.searchId = null;
+            if (results.error) {
+                this.showNotification('Search failed: ' + results.error, 'error');
             }
         }
     }
     
-    toggleLineEnding() {
-        const tab = this.tabs.find(t => t.id === this.activeTabId);
-        if (!tab) return;
+    // Load a large file whole so it can be edited
+    editLargeFile(tab) {
+        const sizeMB = Math.round((tab.fileInfo.Size || 0) / (1024 * 1024));
+        this.showStyledConfirmDialog(
+            'Edit Large File',
+            `${tab.fileInfo.Name} is ${sizeMB} MB. Loading it whole may make the editor slow. Continue?`,
+            'Load',
+            'Cancel',
+            async () => {
+                try {
+                    const result = await OpenFileForEditing(tab.fileInfo.Path);
+                    const fileInfo = result.fileInfo;
+                    if (tab.largeFile.searchId) {
+                        CancelLargeFileSearch(tab.largeFile.searchId);
+                    }
+                    delete tab.largeFile;
+                    delete tab.largeFileBar;
+                    tab.content = result.content || '';
+                    Object.assign(tab.fileInfo, {
+                        Encoding: fileInfo.encoding,
+                        EncodingConfidence: fileInfo.encodingConfidence,
+                        LineEnding: fileInfo.lineEnding,
+                        LineEndingCounts: fileInfo.lineEndingCounts,
+                        TrailingNewline: fileInfo.trailingNewline,
+                        Language: fileInfo.language,
+                        LargeFile: false,
+                        ReadOnly: false
+                    });
+                    if (tab.id === this.activeTabId) {
+                        tab.textarea = null; // keep the page from being saved over the content
+                        this.switchToTab(tab.id);
+                    }
+                } catch (err) {
+                    console.error('Failed to load file:', err);
+                    this.showNotification('Failed to load file: ' + (err.message || err), 'error');
+                }
+            }
+        );
+    }
+    
+    async reopenWithEncoding(tab, encoding) {
+        const reopen = () => this.reloadTab(tab, encoding);
         
-        tab.fileInfo.LineEnding = tab.fileInfo.LineEnding === 'CRLF' ? 'LF' : 'CRLF';
-        tab.fileInfo.IsDirty = true;
-        const dirtyEl = tab.element.querySelector('.tab-dirty');
-        if (dirtyEl) dirtyEl.style.display = 'inline';
-        this.updateStatusBar();
+        if (tab.fileInfo.IsDirty) {
+            this.showStyledConfirmDialog(
+                'Reopen with Encoding',
+                `Reopening ${tab.fileInfo.Name} as ${encoding} discards your unsaved changes.`,
+                'Reopen',
+                'Cancel',
+                reopen
+            );
+        } else {
+            await reopen();
+        }
+    }
+    
+    async convertToEncoding(tab, encoding) {
+        try {
+            const check = await ConvertToEncoding(tab.textarea.value, encoding);
+            if (!check.representable) {
+                this.showNotification(
+                    `${check.unsupported} characters cannot be saved as ${encoding} (${check.unsupportedSet.join(' ')})`,
+                    'warning'
+                );
+                return;
+            }
+            
+            tab.fileInfo.Encoding = encoding;
+            tab.fileInfo.EncodingConfidence = 1;
+            this.markTabDirty(tab);
+            this.updateStatusBar();
+            this.showNotification(`Will save as ${encoding}`, 'success');
+        } catch (err) {
+            console.error('Failed to convert encoding:', err);
+            this.showNotification('Failed to convert encoding: ' + (err.message || err), 'error');
+        }
     }
     
     toggleWordWrap() {
@@ -1097,6 +3898,18 @@ class AkashicEditor {
             this.lineNumbersEl = null;
         }
         
+        // Handle Markdown preview, beside the text
+        if (this.showMarkdownPreview && this.canPreviewMarkdown(tab)) {
+            if (!this.markdownPreviewEl || this.markdownPreviewEl.parentNode !== wrapper) {
+                this.markdownPreviewEl = this.createMarkdownPreview();
+                wrapper.insertBefore(this.markdownPreviewEl, tab.textarea.nextSibling);
+            }
+            this.renderMarkdownPreview(tab);
+        } else if (this.markdownPreviewEl && this.markdownPreviewEl.parentNode === wrapper) {
+            this.markdownPreviewEl.remove();
+            this.markdownPreviewEl = null;
+        }
+        
         // Handle minimap
         if (this.showMinimap) {
             if (!this.minimapEl || this.minimapEl.parentNode !== wrapper) {
@@ -1120,10 +3933,12 @@ class AkashicEditor {
         tab._scrollHandler = () => {
             if (this.showLineNumbers) this.updateLineNumbers(tab);
             if (this.showMinimap) this.updateMinimap(tab);
+            if (this.markdownPreviewEl) this.syncMarkdownPreview(tab);
         };
         tab._inputHandler = () => {
             if (this.showLineNumbers) this.updateLineNumbers(tab);
             if (this.showMinimap) this.updateMinimap(tab);
+            if (this.markdownPreviewEl) this.scheduleMarkdownPreview(tab);
         };
         
         tab.textarea.addEventListener('scroll', tab._scrollHandler);
@@ -1141,10 +3956,12 @@ class AkashicEditor {
         
         let html = '';
         const currentLine = this.getCurrentLineNumber(tab);
+        const firstLine = tab.largeFile ? tab.largeFile.pageStart : 0;
         
         for (let i = startLine; i < Math.min(startLine + visibleLines + 1, lines.length); i++) {
             const isActive = (i + 1) === currentLine;
-            html += `<div class="line-number${isActive ? ' active' : ''}">${i + 1}</div>`;
+            const change = tab.git && tab.git.lines.get(i + 1);
+            html += `<div class="line-number${isActive ? ' active' : ''}${change ? ' git-' + change : ''}">${firstLine + i + 1}</div>`;
         }
         
         this.lineNumbersEl.innerHTML = html;
@@ -1184,6 +4001,366 @@ class AkashicEditor {
         viewport.style.height = `${Math.max(viewportHeight, 10)}%`;
     }
     
+    // ============================================
+    // Markdown Preview
+    // ============================================
+    
+    // Markdown files, and plain text notes that are often Markdown too
+    canPreviewMarkdown(tab) {
+        return !tab.largeFile && !tab.hex && ['markdown', 'plaintext'].includes(this.languageOf(tab).id);
+    }
+    
+    toggleMarkdownPreview() {
+        this.showMarkdownPreview = !this.showMarkdownPreview;
+        const tab = this.getActiveTab();
+        if (tab) {
+            this.updateEditorView(tab);
+        }
+        if (this.showMarkdownPreview && tab && !this.canPreviewMarkdown(tab)) {
+            this.showNotification('Markdown preview enabled for Markdown and text files');
+            return;
+        }
+        this.showNotification(`Markdown preview ${this.showMarkdownPreview ? 'enabled' : 'disabled'}`);
+    }
+    
+    createMarkdownPreview() {
+        const preview = document.createElement('div');
+        preview.className = 'markdown-preview markdown-body';
+        preview.title = 'Double-click to go to the source';
+        
+        // Double-click a block to go to the line it was written on
+        preview.addEventListener('dblclick', (e) => {
+            const block = e.target.closest('[data-line]');
+            const tab = this.getActiveTab();
+            if (block && tab && tab.textarea) {
+                this.goToPosition(tab, parseInt(block.dataset.line, 10) - 1);
+            }
+        });
+        return preview;
+    }
+    
+    async renderMarkdownPreview(tab) {
+        const preview = this.markdownPreviewEl;
+        if (!preview || !tab.textarea) return;
+        
+        try {
+            const html = await RenderMarkdown(tab.textarea.value);
+            // The preview may have closed or moved to another tab meanwhile
+            if (preview !== this.markdownPreviewEl || tab.id !== this.activeTabId) return;
+            preview.innerHTML = html;
+            this.syncMarkdownPreview(tab);
+        } catch (err) {
+            console.error('Failed to render Markdown:', err);
+        }
+    }
+    
+    // Re-render once typing pauses
+    scheduleMarkdownPreview(tab) {
+        clearTimeout(this.markdownPreviewTimer);
+        this.markdownPreviewTimer = setTimeout(() => this.renderMarkdownPreview(tab), 200);
+    }
+    
+    // Scroll the preview to where the editor's top line is, between the
+    // rendered blocks that start before and after it
+    syncMarkdownPreview(tab) {
+        const preview = this.markdownPreviewEl;
+        const textarea = tab.textarea;
+        if (!preview || !textarea) return;
+        
+        // Showing the end of the text shows the end of the preview
+        if (textarea.scrollTop > 0 && textarea.scrollTop + textarea.clientHeight >= textarea.scrollHeight - 1) {
+            preview.scrollTop = preview.scrollHeight;
+            return;
+        }
+        
+        const lineHeight = 21; // 14px * 1.5 line-height
+        const topLine = textarea.scrollTop / lineHeight + 1;
+        let before = null;
+        let after = null;
+        for (const block of preview.querySelectorAll('[data-line]')) {
+            const line = parseInt(block.dataset.line, 10);
+            if (line <= topLine) {
+                before = { line, top: block.offsetTop };
+            } else if (line > topLine) {
+                after = { line, top: block.offsetTop };
+                break;
+            }
+        }
+        
+        let target = 0;
+        if (before && after) {
+            target = before.top + (after.top - before.top) * (topLine - before.line) / (after.line - before.line);
+        } else if (before) {
+            target = before.top;
+        }
+        preview.scrollTop = target;
+    }
+    
+    // Show rendered Markdown in an AI reply, keeping the plain text if it can't be rendered
+    renderAIMarkdown(contentDiv, text) {
+        contentDiv.textContent = text;
+        contentDiv.classList.add('markdown-body');
+        RenderMarkdown(text)
+            .then(html => { contentDiv.innerHTML = html; })
+            .catch(err => console.error('Failed to render Markdown:', err));
+    }
+    
+    // Links in rendered Markdown open in the browser, in the editor when
+    // they point at a file beside the note, or scroll to a heading
+    onMarkdownLinkClick(e) {
+        const link = e.target.closest('.markdown-body a');
+        if (!link) return;
+        e.preventDefault();
+        
+        const href = link.getAttribute('href') || '';
+        if (href.startsWith('#')) {
+            let id = href.slice(1);
+            try {
+                id = decodeURIComponent(id);
+            } catch (err) {
+                // Keep the id as written
+            }
+            const heading = link.closest('.markdown-body').querySelector(`[id="md-${CSS.escape(id.toLowerCase())}"]`);
+            if (heading) heading.scrollIntoView({ block: 'start' });
+            return;
+        }
+        if (/^(https?|mailto):/i.test(href)) {
+            BrowserOpenURL(href);
+            return;
+        }
+        
+        const tab = this.getActiveTab();
+        if (tab && tab.fileInfo.Path && link.closest('.markdown-preview')) {
+            const path = decodeURI(href.split('#')[0]);
+            const sep = tab.fileInfo.Path.includes('\\') ? '\\' : '/';
+            this.openFilePath(/^([a-zA-Z]:)?[\\/]/.test(path) ? path : parentDir(tab.fileInfo.Path) + sep + path);
+        }
+    }
+    
+    // ============================================
+    // Encrypted Notes
+    // ============================================
+    
+    // Ask for the passphrase of an encrypted note being opened, or choose one
+    // for a note saved for the first time. The passphrase only goes back to
+    // the backend; nothing here keeps it.
+    showPassphraseDialog(request) {
+        this.elements.dialogOverlay.classList.remove('hidden');
+        let dialog = document.getElementById('dialog-passphrase');
+        if (!dialog) {
+            dialog = document.createElement('div');
+            dialog.id = 'dialog-passphrase';
+            dialog.className = 'dialog hidden';
+            dialog.style.width = '420px';
+            this.elements.dialogOverlay.appendChild(dialog);
+        }
+        
+        dialog.innerHTML = `
+            <div class="dialog-header">${request.new ? 'Choose a passphrase' : 'Enter passphrase'}</div>
+            <div class="dialog-body">
+                <p class="passphrase-file">${this.escapeHtml(request.name)}</p>
+                <input type="password" class="passphrase-input" placeholder="Passphrase" autocomplete="off">
+                ${request.new ? '<input type="password" class="passphrase-confirm" placeholder="Repeat passphrase" autocomplete="off">' : ''}
+                <p class="passphrase-error">${this.escapeHtml(request.error || '')}</p>
+                ${request.new ? '<p class="passphrase-hint">The note can\'t be opened without this passphrase. It is not stored anywhere.</p>' : ''}
+            </div>
+            <div class="dialog-footer">
+                <button class="passphrase-cancel">Cancel</button>
+                <button class="passphrase-ok">${request.new ? 'Encrypt' : 'Open'}</button>
+            </div>
+        `;
+        
+        const input = dialog.querySelector('.passphrase-input');
+        const confirm = dialog.querySelector('.passphrase-confirm');
+        const error = dialog.querySelector('.passphrase-error');
+        let answered = false;
+        const answer = (passphrase, cancelled) => {
+            if (answered) return;
+            answered = true;
+            input.value = '';
+            if (confirm) confirm.value = '';
+            this.hideDialogs();
+            AnswerPassphrase(request.id, passphrase, cancelled);
+        };
+        const submit = () => {
+            if (!input.value) {
+                error.textContent = 'Enter a passphrase';
+                return;
+            }
+            if (confirm && confirm.value !== input.value) {
+                error.textContent = 'The passphrases don\'t match';
+                confirm.focus();
+                return;
+            }
+            answer(input.value, false);
+        };
+        
+        for (const field of [input, confirm].filter(Boolean)) {
+            field.addEventListener('keydown', (e) => {
+                if (e.key === 'Enter') submit();
+                else if (e.key === 'Escape') answer('', true);
+            });
+        }
+        dialog.querySelector('.passphrase-ok').addEventListener('click', submit);
+        dialog.querySelector('.passphrase-cancel').addEventListener('click', () => answer('', true));
+        
+        dialog.classList.remove('hidden');
+        input.focus();
+    }
+    
+    // ============================================
+    // Binary Files
+    // ============================================
+    
+    createHexBar(tab) {
+        const bar = document.createElement('div');
+        bar.className = 'large-file-bar';
+        bar.innerHTML = `
+            <div class="large-file-nav">
+                <button class="hex-prev" title="Previous page">◀</button>
+                <button class="hex-next" title="Next page">▶</button>
+                <span class="hex-status"></span>
+                <input class="hex-goto" type="text" placeholder="Go to offset (0x for hex)">
+                <input class="hex-query" type="text" placeholder="Find bytes">
+                <label class="hex-mode" title="Search for hex bytes such as 'de ad be ef'">
+                    <input type="checkbox" class="hex-query-hex"> Hex
+                </label>
+                <button class="hex-find">Find Next</button>
+            </div>
+        `;
+        
+        bar.querySelector('.hex-prev').addEventListener('click', () => {
+            this.loadHexPage(tab, tab.hex.offset - HEX_PAGE_BYTES);
+        });
+        bar.querySelector('.hex-next').addEventListener('click', () => {
+            this.loadHexPage(tab, tab.hex.offset + HEX_PAGE_BYTES);
+        });
+        bar.querySelector('.hex-goto').addEventListener('keydown', (e) => {
+            if (e.key !== 'Enter') return;
+            const offset = this.parseOffset(e.target.value);
+            if (offset === null) {
+                this.showNotification('Enter an offset such as 4096 or 0x1000', 'error');
+                return;
+            }
+            this.goToHexOffset(tab, offset);
+        });
+        const query = bar.querySelector('.hex-query');
+        const isHex = bar.querySelector('.hex-query-hex');
+        query.addEventListener('input', () => { tab.hex.lastMatch = -1; });
+        isHex.addEventListener('change', () => { tab.hex.lastMatch = -1; });
+        query.addEventListener('keydown', (e) => {
+            if (e.key === 'Enter') this.findHexBytes(tab, query.value, isHex.checked);
+        });
+        bar.querySelector('.hex-find').addEventListener('click', () => {
+            this.findHexBytes(tab, query.value, isHex.checked);
+        });
+        
+        tab.hexBar = bar;
+        this.loadHexPage(tab, tab.hex.offset);
+        return bar;
+    }
+    
+    // Read an offset typed as decimal, or as hex with a 0x prefix or a-f digits
+    parseOffset(text) {
+        const value = text.trim().toLowerCase();
+        if (/^(0x)?[0-9a-f]+$/.test(value) && (value.startsWith('0x') || /[a-f]/.test(value))) {
+            return parseInt(value.replace(/^0x/, ''), 16);
+        }
+        return /^\d+$/.test(value) ? parseInt(value, 10) : null;
+    }
+    
+    async loadHexPage(tab, offset) {
+        const state = tab.hex;
+        offset = Math.max(0, Math.min(offset, Math.max(0, state.size - 1)));
+        offset = Math.floor(offset / HEX_PAGE_BYTES) * HEX_PAGE_BYTES;
+        try {
+            const page = await ReadHex(tab.fileInfo.Path, offset, HEX_PAGE_BYTES);
+            state.offset = page.offset;
+            state.size = page.size;
+            state.pageLength = (page.rows || []).reduce((n, row) => n + row.text.length, 0);
+            tab.content = (page.rows || []).map(row =>
+                `${row.offset.toString(16).padStart(8, '0')}  ${row.hex.padEnd(HEX_ROW_BYTES * 3)}  |${row.text}|`
+            ).join('\n');
+            if (tab.textarea) {
+                tab.textarea.value = tab.content;
+                tab.textarea.scrollTop = 0;
+                if (this.showLineNumbers) this.updateLineNumbers(tab);
+            }
+            this.updateHexBar(tab);
+        } catch (err) {
+            console.error('Failed to read bytes:', err);
+            this.showNotification('Failed to read bytes: ' + (err.message || err), 'error');
+        }
+    }
+    
+    // Show the page holding a byte and select length bytes from it, up to
+    // the end of its row
+    async goToHexOffset(tab, offset, length = 1) {
+        const state = tab.hex;
+        if (offset >= state.size) {
+            this.showNotification(`The file is only ${state.size.toLocaleString()} bytes`, 'error');
+            return;
+        }
+        if (offset < state.offset || offset >= state.offset + state.pageLength) {
+            await this.loadHexPage(tab, offset);
+        }
+        if (!tab.textarea) return;
+        
+        const lines = tab.textarea.value.split('\n');
+        const row = Math.floor((offset - state.offset) / HEX_ROW_BYTES);
+        if (row < 0 || row >= lines.length) return;
+        const column = (i) => 10 + i * 3 + (i >= HEX_ROW_BYTES / 2 ? 1 : 0);
+        const first = offset % HEX_ROW_BYTES;
+        const last = Math.min(first + Math.max(length, 1), HEX_ROW_BYTES) - 1;
+        let start = 0;
+        for (let i = 0; i < row; i++) start += lines[i].length + 1;
+        tab.textarea.focus();
+        tab.textarea.setSelectionRange(start + column(first), start + column(last) + 2);
+        tab.textarea.scrollTop = Math.max(0, row * 21 - tab.textarea.clientHeight / 2);
+        if (this.showLineNumbers) this.updateLineNumbers(tab);
+    }
+    
+    // Find the next match after the last one, wrapping to the start of the file
+    async findHexBytes(tab, query, isHex) {
+        if (!query.trim()) return;
+        const state = tab.hex;
+        const from = state.lastMatch >= 0 ? state.lastMatch + 1 : state.offset;
+        const length = isHex
+            ? query.replace(/^0x/i, '').replace(/[\s,]/g, '').length / 2
+            : new TextEncoder().encode(query).length;
+        try {
+            let match = await FindBytes(tab.fileInfo.Path, query, isHex, from);
+            if (match < 0 && from > 0) {
+                match = await FindBytes(tab.fileInfo.Path, query, isHex, 0);
+                if (match >= 0) this.showNotification('Search wrapped to the start of the file', 'info');
+            }
+            if (match < 0) {
+                state.lastMatch = -1;
+                this.showNotification('No match found', 'info');
+                return;
+            }
+            state.lastMatch = match;
+            await this.goToHexOffset(tab, match, length);
+        } catch (err) {
+            this.showNotification('Search failed: ' + (err.message || err), 'error');
+        }
+    }
+    
+    updateHexBar(tab) {
+        const bar = tab.hexBar;
+        if (!bar || !bar.isConnected) return;
+        
+        const state = tab.hex;
+        const hex = (n) => '0x' + n.toString(16).toUpperCase();
+        const last = state.offset + state.pageLength;
+        const status = state.size
+            ? `Bytes ${hex(state.offset)}–${hex(Math.max(last - 1, 0))} of ${state.size.toLocaleString()}`
+            : 'Empty file';
+        bar.querySelector('.hex-status').textContent = status + ' · read-only';
+        bar.querySelector('.hex-prev').disabled = state.offset === 0;
+        bar.querySelector('.hex-next').disabled = last >= state.size;
+    }
+    
     // ============================================
     // Dialogs
     // ============================================
@@ -1748,6 +4925,9 @@ class AkashicEditor {
             this.elements.newTabBtn.addEventListener('click', () => this.newFile());
         }
         
+        // Links in the Markdown preview and AI replies
+        document.addEventListener('click', (e) => this.onMarkdownLinkClick(e));
+        
         // Export PDF button
         const exportPdfBtn = document.getElementById('export-pdf-btn');
         if (exportPdfBtn) {
@@ -1766,10 +4946,21 @@ class AkashicEditor {
             this.elements.statusLineEnding.addEventListener('click', () => this.toggleLineEnding());
         }
         
+        if (this.elements.statusEncoding) {
+            this.elements.statusEncoding.addEventListener('click', (e) => {
+                e.stopPropagation();
+                this.showEncodingMenu();
+            });
+        }
+        
         if (this.elements.statusZoom) {
             this.elements.statusZoom.addEventListener('click', () => this.resetZoom());
         }
         
+        if (this.elements.statusGit) {
+            this.elements.statusGit.addEventListener('click', () => this.showGitCommitDialog());
+        }
+        
         // Find/Replace dialog buttons
         const findClose = document.getElementById('find-close');
         if (findClose) {
@@ -1819,12 +5010,17 @@ class AkashicEditor {
 
             'replace': { key: 'h', ctrl: true, shift: false, action: () => this.showFindReplaceDialog(true) },
             'go-to': { key: 'g', ctrl: true, shift: false, action: () => this.showGoToDialog() },
+            'go-to-file': { key: 'p', ctrl: true, shift: false, action: () => this.showGoToFileDialog() },
+            'find-in-folder': { key: 'f', ctrl: true, shift: true, action: () => this.showProjectSearchDialog() },
+            'open-folder': { key: 'o', ctrl: true, shift: true, action: () => this.openFolder() },
+            'explorer': { key: 'b', ctrl: true, shift: false, action: () => this.toggleExplorer() },
             'zoom-in': { key: '+', ctrl: true, shift: true, action: () => this.zoomIn() },
             'zoom-out': { key: '-', ctrl: true, shift: false, action: () => this.zoomOut() },
             'reset-zoom': { key: '0', ctrl: true, shift: false, action: () => this.resetZoom() },
             'word-wrap': { key: 'z', ctrl: true, shift: false, action: () => this.toggleWordWrap() },
             'line-numbers': { key: 'l', ctrl: true, shift: true, action: () => this.toggleLineNumbers() },
             'minimap': { key: 'm', ctrl: true, shift: true, action: () => this.toggleMinimap() },
+            'markdown-preview': { key: 'v', ctrl: true, shift: true, action: () => this.toggleMarkdownPreview() },
             'fullscreen': { key: 'F11', ctrl: false, shift: false, action: () => this.toggleFullscreen() },
             'ai-sidebar': { key: 'a', ctrl: true, shift: true, action: () => this.toggleAISidebar() }
         };
@@ -1877,7 +5073,7 @@ class AkashicEditor {
                 if (keyMatch && ctrlMatch && shiftMatch) {
                     // Don't trigger shortcuts in inputs except for file operations
                     if (e.target.tagName === 'INPUT' || e.target.tagName === 'TEXTAREA') {
-                        if (name !== 'save' && name !== 'save-as' && name !== 'open' && name !== 'new' && name !== 'find' && name !== 'replace') {
+                        if (name !== 'save' && name !== 'save-as' && name !== 'open' && name !== 'new' && name !== 'find' && name !== 'replace' && name !== 'go-to-file' && name !== 'find-in-folder' && name !== 'markdown-preview') {
                             continue;
                         }
                     }
@@ -1902,16 +5098,34 @@ class AkashicEditor {
             this.elements.statusFile.textContent = tab.fileInfo.Name;
         }
         if (this.elements.statusEncoding) {
-            this.elements.statusEncoding.textContent = tab.fileInfo.Encoding;
+            const confidence = tab.fileInfo.EncodingConfidence ?? 1;
+            this.elements.statusEncoding.textContent = tab.fileInfo.Binary
+                ? (tab.fileInfo.BinaryKind || 'Binary')
+                : tab.fileInfo.Encoding + (tab.fileInfo.Compression ? ` · ${tab.fileInfo.Compression}` : '') +
+                  (tab.fileInfo.Encrypted ? ' · encrypted' : '');
+            this.elements.statusEncoding.title = tab.fileInfo.Binary
+                ? 'Binary file, shown read-only as hex'
+                : confidence < 1
+                ? `Detected with ${Math.round(confidence * 100)}% confidence. Click to change.`
+                : 'Click to reopen or convert encoding';
+        }
+        if (this.elements.statusLanguage) {
+            this.elements.statusLanguage.textContent = this.languageOf(tab).name;
         }
         if (this.elements.statusLineEnding) {
+            const counts = tab.fileInfo.LineEndingCounts;
             this.elements.statusLineEnding.textContent = tab.fileInfo.LineEnding;
+            this.elements.statusLineEnding.title = counts
+                ? `CRLF: ${counts.crlf}, LF: ${counts.lf}, CR: ${counts.cr}` +
+                  (tab.fileInfo.TrailingNewline ? '' : ' (no newline at end of file)')
+                : '';
         }
         if (this.elements.statusZoom) {
             this.elements.statusZoom.textContent = `${this.zoomLevel}%`;
         }
         
         this.updateCursorPosition(tab);
+        this.scheduleSessionUpdate();
     }
     
     getActiveTab() {
@@ -2017,6 +5231,7 @@ class AkashicEditor {
             'word-wrap': 'Toggle Word Wrap',
             'line-numbers': 'Toggle Line Numbers',
             'minimap': 'Toggle Minimap',
+            'markdown-preview': 'Toggle Markdown Preview',
             'fullscreen': 'Fullscreen',
             'ai-sidebar': 'Toggle AI Sidebar'
         };
@@ -2106,7 +5321,7 @@ class AkashicEditor {
     }
 
     // ============================================
-    // PDF Export
+    // PDF and Word Export
     // ============================================
     
     async exportAsPDF() {
@@ -2132,6 +5347,24 @@ class AkashicEditor {
             this.showNotification('Failed to export PDF: ' + (err.message || err), 'error');
         }
     }
+    
+    async exportAsDOCX() {
+        const tab = this.getActiveTab();
+        if (!tab || !tab.textarea) {
+            this.showNotification('No file to export', 'warning');
+            return;
+        }
+        
+        const content = tab.textarea.value;
+        const defaultName = tab.fileInfo.Name.replace(/\.[^/.]+$/, '') || 'Untitled';
+        
+        try {
+            await ExportAsDOCX(content, defaultName);
+        } catch (err) {
+            console.error('Failed to export DOCX:', err);
+            this.showNotification('Failed to export Word document: ' + (err.message || err), 'error');
+        }
+    }
 
 
     // ============================================
@@ -2249,6 +5482,7 @@ class AkashicEditor {
     }
     
     async generateWithAI() {
+        if (this.currentGeneration) return;
         if (!this.selectedModel) {
             this.showNotification('Please select a model first', 'warning');
             return;
@@ -2284,6 +5518,9 @@ class AkashicEditor {
         aiMsgDiv.innerHTML = '<div class="ai-message-content" style="color: var(--text-secondary); font-style: italic;">Generating...</div>';
         messagesDiv.appendChild(aiMsgDiv);
         
+        // Capture the editor selection before the prompt is sent
+        const selection = this.getEditorSelection();
+        
         // Clear input
         promptInput.value = '';
         
@@ -2292,30 +5529,32 @@ class AkashicEditor {
         
         try {
             // Save user message to database
-            await AddMessage(this.currentChatId, 'user', prompt);
+            const userMessage = await AddMessage(this.currentChatId, 'user', prompt);
+            if (selection && userMessage && !selection.encrypted) {
+                AttachSelection(userMessage.id, selection.path, selection.startLine, selection.endLine, selection.text)
+                    .catch(err => console.error('Failed to attach selection:', err));
+            }
             
             // Get context from previous messages
             const context = await GetChatContext(this.currentChatId, 10);
             
-            // Generate with context
+            // Generate with context, showing the reply as it arrives. The
+            // backend saves it to the chat and titles new chats.
             const fullPrompt = context + '\n\nUser: ' + prompt + '\n\nAssistant:';
-            const response = await GenerateWithOllama(this.selectedModel, fullPrompt);
+            let contentEl = null;
+            const response = await this.streamReply(this.currentChatId, this.selectedModel, fullPrompt, (text) => {
+                if (!contentEl) {
+                    aiMsgDiv.innerHTML = '<div class="ai-message-content"></div>';
+                    contentEl = aiMsgDiv.querySelector('.ai-message-content');
+                }
+                contentEl.textContent = text;
+                messagesDiv.scrollTop = messagesDiv.scrollHeight;
+            });
             
             // Update AI message
-            aiMsgDiv.innerHTML = `<div class="ai-message-content" style="white-space: pre-wrap;">${this.escapeHtml(response)}</div>`;
+            aiMsgDiv.innerHTML = '<div class="ai-message-content"></div>';
+            this.renderAIMarkdown(aiMsgDiv.querySelector('.ai-message-content'), response);
             this.lastAIResponse = response;
-            
-            // Save AI response to database
-            await AddMessage(this.currentChatId, 'assistant', response);
-            
-            // Update chat title if it's the first message
-            const chat = this.chats.find(c => c.id === this.currentChatId);
-            if (chat && chat.title === 'New Chat') {
-                await RenameChatFromFirstMessage(this.currentChatId);
-                // Reload chat list to show updated title
-                await this.loadChatHistory();
-                this.updateChatInfo();
-            }
         } catch (err) {
             console.error('Generation failed:', err);
             const safeErrorMessage = this.escapeHtml(err && err.message ? String(err.message) : String(err));
@@ -2326,12 +5565,99 @@ class AkashicEditor {
         messagesDiv.scrollTop = messagesDiv.scrollHeight;
     }
     
+    // Stream a reply to chatId, calling onChunk with the text so far. Resolves
+    // with the whole reply once it ends or is stopped.
+    streamReply(chatId, model, prompt, onChunk) {
+        const requestId = `chat-${Date.now()}`;
+        let text = '';
+        return new Promise((resolve, reject) => {
+            this.currentGeneration = {
+                requestId,
+                onChunk: (chunk) => {
+                    text += chunk;
+                    onChunk(text);
+                },
+                resolve: () => resolve(text),
+                reject,
+            };
+            this.setGenerating(true);
+            GenerateWithOllamaStream(requestId, chatId, model, prompt, '').catch(reject);
+        }).finally(() => {
+            this.currentGeneration = null;
+            this.setGenerating(false);
+        });
+    }
+    
+    // Swap the send button for the stop button while a reply streams
+    setGenerating(generating) {
+        document.getElementById('ai-send').classList.toggle('hidden', generating);
+        document.getElementById('ai-stop').classList.toggle('hidden', !generating);
+    }
+    
+    // Fill in {{language}} and {{selection}} in a prompt from the active tab
+    fillPromptTemplate(template) {
+        const tab = this.getActiveTab();
+        const language = tab ? this.languageOf(tab).name : 'Plain Text';
+        const selection = this.getEditorSelection();
+        let text = selection ? selection.text : '';
+        if (!text && tab && tab.textarea) text = tab.textarea.value;
+        return template
+            .replaceAll('{{language}}', language === 'Plain Text' ? 'text' : language)
+            .replaceAll('{{selection}}', text);
+    }
+    
+    // Send an AI menu action's prompt for the selection, or the whole file
+    // if nothing is selected
+    async runQuickAction(action) {
+        const template = QUICK_ACTION_PROMPTS[action];
+        const tab = this.getActiveTab();
+        if (!template || !tab || !tab.textarea) return;
+        if (!tab.textarea.value.trim()) {
+            this.showNotification('There is no text to send', 'warning');
+            return;
+        }
+        if (tab.fileInfo.Encrypted) {
+            try {
+                await CheckAIAccess(tab.fileInfo.Path);
+            } catch (err) {
+                this.showNotification(err.message || String(err), 'error');
+                return;
+            }
+        }
+        
+        if (this.elements.aiSidebar.classList.contains('hidden')) this.toggleAISidebar();
+        document.getElementById('ai-prompt').value = this.fillPromptTemplate(template);
+        this.updateSendButtonState();
+        this.generateWithAI();
+    }
+    
+    // Returns the active editor's selection with its file and line range,
+    // or null if nothing is selected in a saved file
+    getEditorSelection() {
+        const tab = this.getActiveTab();
+        if (!tab || !tab.textarea || !tab.fileInfo.Path) return null;
+        
+        const { selectionStart, selectionEnd, value } = tab.textarea;
+        if (selectionStart === selectionEnd) return null;
+        
+        const text = value.substring(selectionStart, selectionEnd);
+        const startLine = value.substring(0, selectionStart).split('\n').length;
+        const endLine = startLine + text.replace(/\n$/, '').split('\n').length - 1;
+        return { path: tab.fileInfo.Path, startLine, endLine, text, encrypted: tab.fileInfo.Encrypted };
+    }
+    
     escapeHtml(text) {
         const div = document.createElement('div');
         div.textContent = text;
         return div.innerHTML;
     }
     
+    formatSize(bytes) {
+        return bytes < 1024 ? `${bytes} B`
+            : bytes < 1024 * 1024 ? `${(bytes / 1024).toFixed(1)} KB`
+            : `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+    }
+    
     insertAIResponse() {
         if (!this.lastAIResponse) {
             this.showNotification('No AI response to insert', 'warning');
@@ -2390,6 +5716,13 @@ class AkashicEditor {
             generateBtn.addEventListener('click', () => this.generateWithAI());
         }
         
+        const stopBtn = document.getElementById('ai-stop');
+        if (stopBtn) {
+            stopBtn.addEventListener('click', () => {
+                if (this.currentGeneration) StopGeneration(this.currentGeneration.requestId);
+            });
+        }
+        
         const insertBtn = document.getElementById('ai-insert');
         if (insertBtn) {
             insertBtn.addEventListener('click', () => this.insertAIResponse());
@@ -2404,7 +5737,7 @@ class AkashicEditor {
         document.querySelectorAll('.ai-suggestion').forEach(btn => {
             btn.addEventListener('click', () => {
                 const prompt = btn.dataset.prompt;
-                document.getElementById('ai-prompt').value = prompt;
+                document.getElementById('ai-prompt').value = this.fillPromptTemplate(prompt);
                 this.updateSendButtonState();
                 this.generateWithAI();
             });
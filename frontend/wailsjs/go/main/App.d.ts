@@ -4,42 +4,166 @@ import {main} from '../models';
 
 export function AddMessage(arg1:number,arg2:string,arg3:string):Promise<main.Message>;
 
+export function AnswerPassphrase(arg1:string,arg2:string,arg3:boolean):Promise<void>;
+
+export function ApplyChatRetention():Promise<main.RetentionResult>;
+
+export function ApplyReplace(arg1:string,arg2:Array<string>):Promise<main.ReplaceResult>;
+
+export function AttachFile(arg1:number,arg2:string):Promise<main.Attachment>;
+
+export function AttachSelection(arg1:number,arg2:string,arg3:number,arg4:number,arg5:string):Promise<main.Attachment>;
+
+export function CanUndoReplace():Promise<boolean>;
+
+export function CancelLargeFileSearch(arg1:string):Promise<void>;
+
+export function CancelWorkspaceSearch(arg1:string):Promise<void>;
+
+export function ChangeChatPassphrase(arg1:string,arg2:string):Promise<void>;
+
+export function CheckAIAccess(arg1:string):Promise<void>;
+
 export function CheckOllamaInstalled():Promise<main.OllamaStatus>;
 
 export function CheckOllamaServerRunning():Promise<boolean>;
 
 export function ClearRecentFiles():Promise<void>;
 
+export function CloseFile(arg1:string):Promise<void>;
+
+export function CloseFolder():Promise<void>;
+
+export function ConvertToEncoding(arg1:string,arg2:string):Promise<main.EncodingCheck>;
+
 export function CreateChat(arg1:string,arg2:string):Promise<main.Chat>;
 
+export function CreateChatBackup():Promise<main.BackupInfo>;
+
+export function CreateWorkspaceFile(arg1:string,arg2:string):Promise<string>;
+
+export function CreateWorkspaceFolder(arg1:string,arg2:string):Promise<string>;
+
 export function DeleteAllChats():Promise<void>;
 
 export function DeleteChat(arg1:number):Promise<void>;
 
+export function DeleteSession(arg1:string):Promise<void>;
+
+export function DeleteWorkspaceEntry(arg1:string):Promise<void>;
+
+export function DetectLanguage(arg1:string,arg2:string):Promise<string>;
+
+export function DiffFileRevisions(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function DiffFiles(arg1:string,arg2:string):Promise<string>;
+
+export function DisableChatEncryption(arg1:string):Promise<void>;
+
+export function DiscardRecoveryBuffer(arg1:string):Promise<void>;
+
+export function DiscardReplace(arg1:string):Promise<void>;
+
+export function EnableChatEncryption(arg1:string):Promise<void>;
+
+export function ExportAsDOCX(arg1:string,arg2:string):Promise<void>;
+
 export function ExportAsPDF(arg1:string,arg2:string):Promise<void>;
 
 export function ExportChat(arg1:number):Promise<string>;
 
+export function FindBytes(arg1:string,arg2:string,arg3:boolean,arg4:number):Promise<number>;
+
+export function FindFiles(arg1:string,arg2:number):Promise<Array<main.FileMatch>>;
+
+export function FinishWait(arg1:string):Promise<void>;
+
 export function GenerateWithOllama(arg1:string,arg2:string):Promise<string>;
 
-export function GenerateWithOllamaStream(arg1:string,arg2:string,arg3:string,arg4:string):Promise<void>;
+export function GenerateWithOllamaStream(arg1:string,arg2:number,arg3:string,arg4:string,arg5:string):Promise<void>;
+
+export function GetArchivedChats():Promise<Array<main.Chat>>;
+
+export function GetChatAttachments(arg1:number):Promise<Array<main.Attachment>>;
 
 export function GetChatContext(arg1:number,arg2:number):Promise<string>;
 
+export function GetChatCount():Promise<number>;
+
+export function GetChatEncryptionStatus():Promise<main.ChatEncryptionStatus>;
+
+export function GetChatIntegrityReport():Promise<main.IntegrityReport>;
+
 export function GetChatMessages(arg1:number):Promise<Array<main.Message>>;
 
+export function GetChatMessagesPage(arg1:number,arg2:number,arg3:number):Promise<main.MessagePage>;
+
 export function GetChats():Promise<Array<main.Chat>>;
 
+export function GetChatsPage(arg1:main.ChatCursor,arg2:number):Promise<main.ChatPage>;
+
+export function GetCurrentSession():Promise<main.Session>;
+
+export function GetFileHistory(arg1:string):Promise<Array<main.FileRevision>>;
+
 export function GetInstalledModels():Promise<Array<main.OllamaModel>>;
 
+export function GetLanguages():Promise<Array<main.Language>>;
+
+export function GetMessageAttachments(arg1:number):Promise<Array<main.Attachment>>;
+
+export function GetMessageCount(arg1:number):Promise<number>;
+
 export function GetRecentFiles():Promise<Array<string>>;
 
+export function GetRecoverableBuffers():Promise<Array<main.RecoverableBuffer>>;
+
+export function GetRecoveryBuffers(arg1:Array<string>):Promise<Array<main.RecoverableBuffer>>;
+
+export function GetSessions():Promise<Array<main.SessionSummary>>;
+
 export function GetSettings():Promise<main.Settings>;
 
+export function GetSupportedEncodings():Promise<Array<string>>;
+
+export function GetWorkspace():Promise<main.WorkspaceInfo>;
+
+export function GitBlame(arg1:string):Promise<Array<main.GitBlameLine>>;
+
+export function GitCommit(arg1:string,arg2:string):Promise<string>;
+
+export function GitDiff(arg1:string):Promise<string>;
+
+export function GitDiscardHunk(arg1:string,arg2:main.GitHunk):Promise<void>;
+
+export function GitFileChanges(arg1:string):Promise<main.GitFileChanges>;
+
+export function GitStage(arg1:string):Promise<void>;
+
+export function GitStatus(arg1:string):Promise<main.GitRepoStatus>;
+
+export function GitUnstage(arg1:string):Promise<void>;
+
 export function Greet(arg1:string):Promise<string>;
 
+export function ListArchive(arg1:string):Promise<main.ArchiveListing>;
+
+export function ListChatBackups():Promise<Array<main.BackupInfo>>;
+
+export function ListWorkspaceDir(arg1:string):Promise<Array<main.WorkspaceEntry>>;
+
+export function LocateAttachment(arg1:number):Promise<main.AttachmentLocation>;
+
+export function LockChats():Promise<void>;
+
+export function MergeExternalChanges(arg1:string,arg2:string):Promise<main.MergeResult>;
+
+export function MoveWorkspaceEntry(arg1:string,arg2:string):Promise<string>;
+
 export function NewFile():Promise<main.FileInfo>;
 
+export function NormalizeLineEndings(arg1:string,arg2:string):Promise<string>;
+
 export function OnEditorEvent(arg1:string,arg2:main.EditorEventData):Promise<void>;
 
 export function OnFileChange(arg1:string,arg2:boolean):Promise<void>;
@@ -48,22 +172,72 @@ export function OpenFile():Promise<main.FileOpenResult>;
 
 export function OpenFileByPath(arg1:string):Promise<main.FileOpenResult>;
 
+export function OpenFileForEditing(arg1:string):Promise<main.FileOpenResult>;
+
+export function OpenFolder():Promise<main.WorkspaceInfo>;
+
+export function OpenFolderPath(arg1:string):Promise<main.WorkspaceInfo>;
+
+export function OverwriteFile(arg1:string,arg2:string,arg3:string,arg4:string):Promise<main.FileInfo>;
+
+export function PrepareReplace(arg1:main.ProjectSearchOptions,arg2:string):Promise<main.ReplacePlan>;
+
+export function PreviewChatRetention():Promise<main.RetentionResult>;
+
 export function PullModel(arg1:string):Promise<void>;
 
+export function ReadHex(arg1:string,arg2:number,arg3:number):Promise<main.HexPage>;
+
+export function ReadLines(arg1:string,arg2:number,arg3:number):Promise<main.LineRange>;
+
+export function RefreshWorkspace():Promise<void>;
+
 export function RenameChatFromFirstMessage(arg1:number):Promise<void>;
 
-export function SaveFile(arg1:string,arg2:string,arg3:string):Promise<main.FileInfo>;
+export function RenameWorkspaceEntry(arg1:string,arg2:string):Promise<string>;
+
+export function RenderMarkdown(arg1:string):Promise<string>;
+
+export function ReopenWithEncoding(arg1:string,arg2:string):Promise<main.FileOpenResult>;
+
+export function RestoreChatBackup(arg1:string):Promise<void>;
+
+export function RestoreFileRevision(arg1:string,arg2:string):Promise<main.FileOpenResult>;
+
+export function SaveFile(arg1:string,arg2:string,arg3:string,arg4:string):Promise<main.FileInfo>;
+
+export function SaveFileAs(arg1:string,arg2:string,arg3:string,arg4:string):Promise<main.FileInfo>;
 
-export function SaveFileAs(arg1:string,arg2:string,arg3:string):Promise<main.FileInfo>;
+export function SaveSessionAs(arg1:string):Promise<void>;
 
 export function SearchChats(arg1:string):Promise<Array<main.Chat>>;
 
+export function SearchLargeFile(arg1:string,arg2:string,arg3:string,arg4:boolean,arg5:boolean):Promise<void>;
+
+export function SearchWorkspace(arg1:string,arg2:main.ProjectSearchOptions):Promise<void>;
+
+export function SetSessionChat(arg1:number):Promise<void>;
+
 export function StartOllamaServer():Promise<void>;
 
 export function StopGeneration(arg1:string):Promise<void>;
 
 export function StopOllamaServer():Promise<void>;
 
+export function SwitchSession(arg1:string):Promise<main.Session>;
+
+export function TakeLaunchRequests():Promise<Array<main.LaunchRequest>>;
+
+export function UnarchiveChat(arg1:number):Promise<void>;
+
+export function UndoReplace():Promise<main.ReplaceResult>;
+
+export function UnlockChats(arg1:string):Promise<void>;
+
 export function UpdateChatTitle(arg1:number,arg2:string):Promise<void>;
 
+export function UpdateRecoveryBuffer(arg1:main.RecoveryInfo,arg2:string,arg3:number):Promise<void>;
+
+export function UpdateSessionTabs(arg1:Array<main.SessionTab>,arg2:string):Promise<void>;
+
 export function UpdateSettings(arg1:main.Settings):Promise<void>;
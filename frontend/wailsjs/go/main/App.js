@@ -6,6 +6,46 @@ export function AddMessage(arg1, arg2, arg3) {
   return window['go']['main']['App']['AddMessage'](arg1, arg2, arg3);
 }
 
+export function AnswerPassphrase(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AnswerPassphrase'](arg1, arg2, arg3);
+}
+
+export function ApplyChatRetention() {
+  return window['go']['main']['App']['ApplyChatRetention']();
+}
+
+export function ApplyReplace(arg1, arg2) {
+  return window['go']['main']['App']['ApplyReplace'](arg1, arg2);
+}
+
+export function AttachFile(arg1, arg2) {
+  return window['go']['main']['App']['AttachFile'](arg1, arg2);
+}
+
+export function AttachSelection(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['AttachSelection'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function CanUndoReplace() {
+  return window['go']['main']['App']['CanUndoReplace']();
+}
+
+export function CancelLargeFileSearch(arg1) {
+  return window['go']['main']['App']['CancelLargeFileSearch'](arg1);
+}
+
+export function CancelWorkspaceSearch(arg1) {
+  return window['go']['main']['App']['CancelWorkspaceSearch'](arg1);
+}
+
+export function ChangeChatPassphrase(arg1, arg2) {
+  return window['go']['main']['App']['ChangeChatPassphrase'](arg1, arg2);
+}
+
+export function CheckAIAccess(arg1) {
+  return window['go']['main']['App']['CheckAIAccess'](arg1);
+}
+
 export function CheckOllamaInstalled() {
   return window['go']['main']['App']['CheckOllamaInstalled']();
 }
@@ -18,10 +58,34 @@ export function ClearRecentFiles() {
   return window['go']['main']['App']['ClearRecentFiles']();
 }
 
+export function CloseFile(arg1) {
+  return window['go']['main']['App']['CloseFile'](arg1);
+}
+
+export function CloseFolder() {
+  return window['go']['main']['App']['CloseFolder']();
+}
+
+export function ConvertToEncoding(arg1, arg2) {
+  return window['go']['main']['App']['ConvertToEncoding'](arg1, arg2);
+}
+
 export function CreateChat(arg1, arg2) {
   return window['go']['main']['App']['CreateChat'](arg1, arg2);
 }
 
+export function CreateChatBackup() {
+  return window['go']['main']['App']['CreateChatBackup']();
+}
+
+export function CreateWorkspaceFile(arg1, arg2) {
+  return window['go']['main']['App']['CreateWorkspaceFile'](arg1, arg2);
+}
+
+export function CreateWorkspaceFolder(arg1, arg2) {
+  return window['go']['main']['App']['CreateWorkspaceFolder'](arg1, arg2);
+}
+
 export function DeleteAllChats() {
   return window['go']['main']['App']['DeleteAllChats']();
 }
@@ -30,6 +94,46 @@ export function DeleteChat(arg1) {
   return window['go']['main']['App']['DeleteChat'](arg1);
 }
 
+export function DeleteSession(arg1) {
+  return window['go']['main']['App']['DeleteSession'](arg1);
+}
+
+export function DeleteWorkspaceEntry(arg1) {
+  return window['go']['main']['App']['DeleteWorkspaceEntry'](arg1);
+}
+
+export function DetectLanguage(arg1, arg2) {
+  return window['go']['main']['App']['DetectLanguage'](arg1, arg2);
+}
+
+export function DiffFileRevisions(arg1, arg2, arg3) {
+  return window['go']['main']['App']['DiffFileRevisions'](arg1, arg2, arg3);
+}
+
+export function DiffFiles(arg1, arg2) {
+  return window['go']['main']['App']['DiffFiles'](arg1, arg2);
+}
+
+export function DisableChatEncryption(arg1) {
+  return window['go']['main']['App']['DisableChatEncryption'](arg1);
+}
+
+export function DiscardRecoveryBuffer(arg1) {
+  return window['go']['main']['App']['DiscardRecoveryBuffer'](arg1);
+}
+
+export function DiscardReplace(arg1) {
+  return window['go']['main']['App']['DiscardReplace'](arg1);
+}
+
+export function EnableChatEncryption(arg1) {
+  return window['go']['main']['App']['EnableChatEncryption'](arg1);
+}
+
+export function ExportAsDOCX(arg1, arg2) {
+  return window['go']['main']['App']['ExportAsDOCX'](arg1, arg2);
+}
+
 export function ExportAsPDF(arg1, arg2) {
   return window['go']['main']['App']['ExportAsPDF'](arg1, arg2);
 }
@@ -38,46 +142,190 @@ export function ExportChat(arg1) {
   return window['go']['main']['App']['ExportChat'](arg1);
 }
 
+export function FindBytes(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['FindBytes'](arg1, arg2, arg3, arg4);
+}
+
+export function FindFiles(arg1, arg2) {
+  return window['go']['main']['App']['FindFiles'](arg1, arg2);
+}
+
+export function FinishWait(arg1) {
+  return window['go']['main']['App']['FinishWait'](arg1);
+}
+
 export function GenerateWithOllama(arg1, arg2) {
   return window['go']['main']['App']['GenerateWithOllama'](arg1, arg2);
 }
 
-export function GenerateWithOllamaStream(arg1, arg2, arg3, arg4) {
-  return window['go']['main']['App']['GenerateWithOllamaStream'](arg1, arg2, arg3, arg4);
+export function GenerateWithOllamaStream(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['GenerateWithOllamaStream'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function GetArchivedChats() {
+  return window['go']['main']['App']['GetArchivedChats']();
+}
+
+export function GetChatAttachments(arg1) {
+  return window['go']['main']['App']['GetChatAttachments'](arg1);
 }
 
 export function GetChatContext(arg1, arg2) {
   return window['go']['main']['App']['GetChatContext'](arg1, arg2);
 }
 
+export function GetChatCount() {
+  return window['go']['main']['App']['GetChatCount']();
+}
+
+export function GetChatEncryptionStatus() {
+  return window['go']['main']['App']['GetChatEncryptionStatus']();
+}
+
+export function GetChatIntegrityReport() {
+  return window['go']['main']['App']['GetChatIntegrityReport']();
+}
+
 export function GetChatMessages(arg1) {
   return window['go']['main']['App']['GetChatMessages'](arg1);
 }
 
+export function GetChatMessagesPage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetChatMessagesPage'](arg1, arg2, arg3);
+}
+
 export function GetChats() {
   return window['go']['main']['App']['GetChats']();
 }
 
+export function GetChatsPage(arg1, arg2) {
+  return window['go']['main']['App']['GetChatsPage'](arg1, arg2);
+}
+
+export function GetCurrentSession() {
+  return window['go']['main']['App']['GetCurrentSession']();
+}
+
+export function GetFileHistory(arg1) {
+  return window['go']['main']['App']['GetFileHistory'](arg1);
+}
+
 export function GetInstalledModels() {
   return window['go']['main']['App']['GetInstalledModels']();
 }
 
+export function GetLanguages() {
+  return window['go']['main']['App']['GetLanguages']();
+}
+
+export function GetMessageAttachments(arg1) {
+  return window['go']['main']['App']['GetMessageAttachments'](arg1);
+}
+
+export function GetMessageCount(arg1) {
+  return window['go']['main']['App']['GetMessageCount'](arg1);
+}
+
 export function GetRecentFiles() {
   return window['go']['main']['App']['GetRecentFiles']();
 }
 
+export function GetRecoverableBuffers() {
+  return window['go']['main']['App']['GetRecoverableBuffers']();
+}
+
+export function GetRecoveryBuffers(arg1) {
+  return window['go']['main']['App']['GetRecoveryBuffers'](arg1);
+}
+
+export function GetSessions() {
+  return window['go']['main']['App']['GetSessions']();
+}
+
 export function GetSettings() {
   return window['go']['main']['App']['GetSettings']();
 }
 
+export function GetSupportedEncodings() {
+  return window['go']['main']['App']['GetSupportedEncodings']();
+}
+
+export function GetWorkspace() {
+  return window['go']['main']['App']['GetWorkspace']();
+}
+
+export function GitBlame(arg1) {
+  return window['go']['main']['App']['GitBlame'](arg1);
+}
+
+export function GitCommit(arg1, arg2) {
+  return window['go']['main']['App']['GitCommit'](arg1, arg2);
+}
+
+export function GitDiff(arg1) {
+  return window['go']['main']['App']['GitDiff'](arg1);
+}
+
+export function GitDiscardHunk(arg1, arg2) {
+  return window['go']['main']['App']['GitDiscardHunk'](arg1, arg2);
+}
+
+export function GitFileChanges(arg1) {
+  return window['go']['main']['App']['GitFileChanges'](arg1);
+}
+
+export function GitStage(arg1) {
+  return window['go']['main']['App']['GitStage'](arg1);
+}
+
+export function GitStatus(arg1) {
+  return window['go']['main']['App']['GitStatus'](arg1);
+}
+
+export function GitUnstage(arg1) {
+  return window['go']['main']['App']['GitUnstage'](arg1);
+}
+
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ListArchive(arg1) {
+  return window['go']['main']['App']['ListArchive'](arg1);
+}
+
+export function ListChatBackups() {
+  return window['go']['main']['App']['ListChatBackups']();
+}
+
+export function ListWorkspaceDir(arg1) {
+  return window['go']['main']['App']['ListWorkspaceDir'](arg1);
+}
+
+export function LocateAttachment(arg1) {
+  return window['go']['main']['App']['LocateAttachment'](arg1);
+}
+
+export function LockChats() {
+  return window['go']['main']['App']['LockChats']();
+}
+
+export function MergeExternalChanges(arg1, arg2) {
+  return window['go']['main']['App']['MergeExternalChanges'](arg1, arg2);
+}
+
+export function MoveWorkspaceEntry(arg1, arg2) {
+  return window['go']['main']['App']['MoveWorkspaceEntry'](arg1, arg2);
+}
+
 export function NewFile() {
   return window['go']['main']['App']['NewFile']();
 }
 
+export function NormalizeLineEndings(arg1, arg2) {
+  return window['go']['main']['App']['NormalizeLineEndings'](arg1, arg2);
+}
+
 export function OnEditorEvent(arg1, arg2) {
   return window['go']['main']['App']['OnEditorEvent'](arg1, arg2);
 }
@@ -94,26 +342,98 @@ export function OpenFileByPath(arg1) {
   return window['go']['main']['App']['OpenFileByPath'](arg1);
 }
 
+export function OpenFileForEditing(arg1) {
+  return window['go']['main']['App']['OpenFileForEditing'](arg1);
+}
+
+export function OpenFolder() {
+  return window['go']['main']['App']['OpenFolder']();
+}
+
+export function OpenFolderPath(arg1) {
+  return window['go']['main']['App']['OpenFolderPath'](arg1);
+}
+
+export function OverwriteFile(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['OverwriteFile'](arg1, arg2, arg3, arg4);
+}
+
+export function PrepareReplace(arg1, arg2) {
+  return window['go']['main']['App']['PrepareReplace'](arg1, arg2);
+}
+
+export function PreviewChatRetention() {
+  return window['go']['main']['App']['PreviewChatRetention']();
+}
+
 export function PullModel(arg1) {
   return window['go']['main']['App']['PullModel'](arg1);
 }
 
+export function ReadHex(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ReadHex'](arg1, arg2, arg3);
+}
+
+export function ReadLines(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ReadLines'](arg1, arg2, arg3);
+}
+
+export function RefreshWorkspace() {
+  return window['go']['main']['App']['RefreshWorkspace']();
+}
+
 export function RenameChatFromFirstMessage(arg1) {
   return window['go']['main']['App']['RenameChatFromFirstMessage'](arg1);
 }
 
-export function SaveFile(arg1, arg2, arg3) {
-  return window['go']['main']['App']['SaveFile'](arg1, arg2, arg3);
+export function RenameWorkspaceEntry(arg1, arg2) {
+  return window['go']['main']['App']['RenameWorkspaceEntry'](arg1, arg2);
 }
 
-export function SaveFileAs(arg1, arg2, arg3) {
-  return window['go']['main']['App']['SaveFileAs'](arg1, arg2, arg3);
+export function RenderMarkdown(arg1) {
+  return window['go']['main']['App']['RenderMarkdown'](arg1);
+}
+
+export function ReopenWithEncoding(arg1, arg2) {
+  return window['go']['main']['App']['ReopenWithEncoding'](arg1, arg2);
+}
+
+export function RestoreChatBackup(arg1) {
+  return window['go']['main']['App']['RestoreChatBackup'](arg1);
+}
+
+export function RestoreFileRevision(arg1, arg2) {
+  return window['go']['main']['App']['RestoreFileRevision'](arg1, arg2);
+}
+
+export function SaveFile(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SaveFile'](arg1, arg2, arg3, arg4);
+}
+
+export function SaveFileAs(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SaveFileAs'](arg1, arg2, arg3, arg4);
+}
+
+export function SaveSessionAs(arg1) {
+  return window['go']['main']['App']['SaveSessionAs'](arg1);
 }
 
 export function SearchChats(arg1) {
   return window['go']['main']['App']['SearchChats'](arg1);
 }
 
+export function SearchLargeFile(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['SearchLargeFile'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function SearchWorkspace(arg1, arg2) {
+  return window['go']['main']['App']['SearchWorkspace'](arg1, arg2);
+}
+
+export function SetSessionChat(arg1) {
+  return window['go']['main']['App']['SetSessionChat'](arg1);
+}
+
 export function StartOllamaServer() {
   return window['go']['main']['App']['StartOllamaServer']();
 }
@@ -126,10 +446,38 @@ export function StopOllamaServer() {
   return window['go']['main']['App']['StopOllamaServer']();
 }
 
+export function SwitchSession(arg1) {
+  return window['go']['main']['App']['SwitchSession'](arg1);
+}
+
+export function TakeLaunchRequests() {
+  return window['go']['main']['App']['TakeLaunchRequests']();
+}
+
+export function UnarchiveChat(arg1) {
+  return window['go']['main']['App']['UnarchiveChat'](arg1);
+}
+
+export function UndoReplace() {
+  return window['go']['main']['App']['UndoReplace']();
+}
+
+export function UnlockChats(arg1) {
+  return window['go']['main']['App']['UnlockChats'](arg1);
+}
+
 export function UpdateChatTitle(arg1, arg2) {
   return window['go']['main']['App']['UpdateChatTitle'](arg1, arg2);
 }
 
+export function UpdateRecoveryBuffer(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UpdateRecoveryBuffer'](arg1, arg2, arg3);
+}
+
+export function UpdateSessionTabs(arg1, arg2) {
+  return window['go']['main']['App']['UpdateSessionTabs'](arg1, arg2);
+}
+
 export function UpdateSettings(arg1) {
   return window['go']['main']['App']['UpdateSettings'](arg1);
 }
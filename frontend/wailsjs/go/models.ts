@@ -7,6 +7,7 @@ export namespace main {
 	    temperature: number;
 	    maxTokens: number;
 	    availableModels: string[];
+	    titleModel: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new AISettings(source);
@@ -20,6 +21,125 @@ export namespace main {
 	        this.temperature = source["temperature"];
 	        this.maxTokens = source["maxTokens"];
 	        this.availableModels = source["availableModels"];
+	        this.titleModel = source["titleModel"];
+	    }
+	}
+	export class ArchiveEntry {
+	    name: string;
+	    size: number;
+	    modTime: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ArchiveEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.size = source["size"];
+	        this.modTime = source["modTime"];
+	    }
+	}
+	export class ArchiveListing {
+	    path: string;
+	    kind: string;
+	    entries: ArchiveEntry[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ArchiveListing(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.kind = source["kind"];
+	        this.entries = this.convertValues(source["entries"], ArchiveEntry);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Attachment {
+	    id: number;
+	    messageId: number;
+	    filePath: string;
+	    startLine: number;
+	    endLine: number;
+	    contentHash: string;
+	    excerpt: string;
+	    createdAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Attachment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.messageId = source["messageId"];
+	        this.filePath = source["filePath"];
+	        this.startLine = source["startLine"];
+	        this.endLine = source["endLine"];
+	        this.contentHash = source["contentHash"];
+	        this.excerpt = source["excerpt"];
+	        this.createdAt = source["createdAt"];
+	    }
+	}
+	export class AttachmentLocation {
+	    filePath: string;
+	    found: boolean;
+	    exact: boolean;
+	    moved: boolean;
+	    startLine: number;
+	    endLine: number;
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AttachmentLocation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.filePath = source["filePath"];
+	        this.found = source["found"];
+	        this.exact = source["exact"];
+	        this.moved = source["moved"];
+	        this.startLine = source["startLine"];
+	        this.endLine = source["endLine"];
+	        this.score = source["score"];
+	    }
+	}
+	export class BackupInfo {
+	    name: string;
+	    reason: string;
+	    createdAt: string;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BackupInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.reason = source["reason"];
+	        this.createdAt = source["createdAt"];
+	        this.size = source["size"];
 	    }
 	}
 	export class Chat {
@@ -42,11 +162,80 @@ export namespace main {
 	        this.updatedAt = source["updatedAt"];
 	    }
 	}
+	export class ChatCursor {
+	    updatedAt: string;
+	    id: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChatCursor(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.updatedAt = source["updatedAt"];
+	        this.id = source["id"];
+	    }
+	}
+	export class ChatEncryptionStatus {
+	    enabled: boolean;
+	    locked: boolean;
+	    autoLockMinutes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChatEncryptionStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.locked = source["locked"];
+	        this.autoLockMinutes = source["autoLockMinutes"];
+	    }
+	}
+	export class ChatPage {
+	    chats: Chat[];
+	    nextCursor: ChatCursor;
+	    hasMore: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChatPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.chats = this.convertValues(source["chats"], Chat);
+	        this.nextCursor = this.convertValues(source["nextCursor"], ChatCursor);
+	        this.hasMore = source["hasMore"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class EditorEventData {
 	    filePath: string;
+	    bufferId?: string;
 	    selection?: string;
 	    cursorLine: number;
 	    cursorCol: number;
+	    selectionStart: number;
+	    selectionEnd: number;
+	    scrollTop: number;
+	    scrollLeft: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new EditorEventData(source);
@@ -55,9 +244,14 @@ export namespace main {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.filePath = source["filePath"];
+	        this.bufferId = source["bufferId"];
 	        this.selection = source["selection"];
 	        this.cursorLine = source["cursorLine"];
 	        this.cursorCol = source["cursorCol"];
+	        this.selectionStart = source["selectionStart"];
+	        this.selectionEnd = source["selectionEnd"];
+	        this.scrollTop = source["scrollTop"];
+	        this.scrollLeft = source["scrollLeft"];
 	    }
 	}
 	export class EditorSettings {
@@ -73,6 +267,12 @@ export namespace main {
 	    autoSaveDelay: number;
 	    showWhitespace: boolean;
 	    highlightActiveLine: boolean;
+	    backupOnSave: string;
+	    largeFileThresholdMB: number;
+	    fileHistory: boolean;
+	    fileHistoryMaxRevisions: number;
+	    fileHistoryMaxAgeDays: number;
+	    fileHistoryMaxSizeMB: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new EditorSettings(source);
@@ -92,16 +292,68 @@ export namespace main {
 	        this.autoSaveDelay = source["autoSaveDelay"];
 	        this.showWhitespace = source["showWhitespace"];
 	        this.highlightActiveLine = source["highlightActiveLine"];
+	        this.backupOnSave = source["backupOnSave"];
+	        this.largeFileThresholdMB = source["largeFileThresholdMB"];
+	        this.fileHistory = source["fileHistory"];
+	        this.fileHistoryMaxRevisions = source["fileHistoryMaxRevisions"];
+	        this.fileHistoryMaxAgeDays = source["fileHistoryMaxAgeDays"];
+	        this.fileHistoryMaxSizeMB = source["fileHistoryMaxSizeMB"];
+	    }
+	}
+	export class EncodingCheck {
+	    encoding: string;
+	    representable: boolean;
+	    unsupported: number;
+	    unsupportedSet: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new EncodingCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.encoding = source["encoding"];
+	        this.representable = source["representable"];
+	        this.unsupported = source["unsupported"];
+	        this.unsupportedSet = source["unsupportedSet"];
+	    }
+	}
+	export class LineEndingCounts {
+	    crlf: number;
+	    lf: number;
+	    cr: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new LineEndingCounts(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.crlf = source["crlf"];
+	        this.lf = source["lf"];
+	        this.cr = source["cr"];
 	    }
 	}
 	export class FileInfo {
 	    path: string;
 	    name: string;
 	    encoding: string;
+	    encodingConfidence: number;
 	    lineEnding: string;
+	    lineEndingCounts: LineEndingCounts;
+	    trailingNewline: boolean;
+	    language: string;
 	    isDirty: boolean;
 	    isNewFile: boolean;
 	    lastSaved: number;
+	    largeFile: boolean;
+	    readOnly: boolean;
+	    binary: boolean;
+	    binaryKind: string;
+	    archive: string;
+	    compression: string;
+	    encrypted: boolean;
+	    size: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new FileInfo(source);
@@ -112,10 +364,58 @@ export namespace main {
 	        this.path = source["path"];
 	        this.name = source["name"];
 	        this.encoding = source["encoding"];
+	        this.encodingConfidence = source["encodingConfidence"];
 	        this.lineEnding = source["lineEnding"];
+	        this.lineEndingCounts = this.convertValues(source["lineEndingCounts"], LineEndingCounts);
+	        this.trailingNewline = source["trailingNewline"];
+	        this.language = source["language"];
 	        this.isDirty = source["isDirty"];
 	        this.isNewFile = source["isNewFile"];
 	        this.lastSaved = source["lastSaved"];
+	        this.largeFile = source["largeFile"];
+	        this.readOnly = source["readOnly"];
+	        this.binary = source["binary"];
+	        this.binaryKind = source["binaryKind"];
+	        this.archive = source["archive"];
+	        this.compression = source["compression"];
+	        this.encrypted = source["encrypted"];
+	        this.size = source["size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FileMatch {
+	    path: string;
+	    relPath: string;
+	    score: number;
+	    positions: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new FileMatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.relPath = source["relPath"];
+	        this.score = source["score"];
+	        this.positions = source["positions"];
 	    }
 	}
 	export class FileOpenResult {
@@ -150,94 +450,110 @@ export namespace main {
 		    return a;
 		}
 	}
-	export class Message {
-	    id: number;
-	    chatId: number;
-	    role: string;
-	    content: string;
-	    createdAt: string;
+	export class FileRevision {
+	    id: string;
+	    savedAt: number;
+	    size: number;
+	    storedSize: number;
+	    hash: string;
+	    encoding: string;
+	    lineEnding: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new Message(source);
+	        return new FileRevision(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.id = source["id"];
-	        this.chatId = source["chatId"];
-	        this.role = source["role"];
-	        this.content = source["content"];
-	        this.createdAt = source["createdAt"];
+	        this.savedAt = source["savedAt"];
+	        this.size = source["size"];
+	        this.storedSize = source["storedSize"];
+	        this.hash = source["hash"];
+	        this.encoding = source["encoding"];
+	        this.lineEnding = source["lineEnding"];
 	    }
 	}
-	export class OllamaModel {
-	    name: string;
-	    size: string;
-	    modified: string;
-	    parameters: string;
+	export class FileTarget {
+	    path: string;
+	    line: number;
+	    column: number;
+	    new: boolean;
 	
 	    static createFrom(source: any = {}) {
-	        return new OllamaModel(source);
+	        return new FileTarget(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.name = source["name"];
-	        this.size = source["size"];
-	        this.modified = source["modified"];
-	        this.parameters = source["parameters"];
+	        this.path = source["path"];
+	        this.line = source["line"];
+	        this.column = source["column"];
+	        this.new = source["new"];
 	    }
 	}
-	export class OllamaStatus {
-	    installed: boolean;
-	    version: string;
-	    message: string;
+	export class GitBlameLine {
+	    line: number;
+	    commit: string;
+	    author: string;
+	    time: number;
+	    summary: string;
+	    uncommitted: boolean;
 	
 	    static createFrom(source: any = {}) {
-	        return new OllamaStatus(source);
+	        return new GitBlameLine(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.installed = source["installed"];
-	        this.version = source["version"];
-	        this.message = source["message"];
+	        this.line = source["line"];
+	        this.commit = source["commit"];
+	        this.author = source["author"];
+	        this.time = source["time"];
+	        this.summary = source["summary"];
+	        this.uncommitted = source["uncommitted"];
 	    }
 	}
-	export class UISettings {
-	    theme: string;
-	    darkMode: boolean;
-	    showStatusBar: boolean;
-	    compactMode: boolean;
-	    sidebarPosition: string;
+	export class GitHunk {
+	    oldStart: number;
+	    oldLines: number;
+	    newStart: number;
+	    newLines: number;
+	    kind: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new UISettings(source);
+	        return new GitHunk(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.theme = source["theme"];
-	        this.darkMode = source["darkMode"];
-	        this.showStatusBar = source["showStatusBar"];
-	        this.compactMode = source["compactMode"];
-	        this.sidebarPosition = source["sidebarPosition"];
+	        this.oldStart = source["oldStart"];
+	        this.oldLines = source["oldLines"];
+	        this.newStart = source["newStart"];
+	        this.newLines = source["newLines"];
+	        this.kind = source["kind"];
 	    }
 	}
-	export class Settings {
-	    editor: EditorSettings;
-	    ui: UISettings;
-	    ai: AISettings;
+	export class GitFileChanges {
+	    root: string;
+	    tracked: boolean;
+	    hunks: GitHunk[];
+	    staged: boolean;
+	    unstaged: boolean;
+	    untracked: boolean;
 	
 	    static createFrom(source: any = {}) {
-	        return new Settings(source);
+	        return new GitFileChanges(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.editor = this.convertValues(source["editor"], EditorSettings);
-	        this.ui = this.convertValues(source["ui"], UISettings);
-	        this.ai = this.convertValues(source["ai"], AISettings);
+	        this.root = source["root"];
+	        this.tracked = source["tracked"];
+	        this.hunks = this.convertValues(source["hunks"], GitHunk);
+	        this.staged = source["staged"];
+	        this.unstaged = source["unstaged"];
+	        this.untracked = source["untracked"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -258,6 +574,762 @@ export namespace main {
 		    return a;
 		}
 	}
+	
+	export class GitStatusEntry {
+	    path: string;
+	    origPath?: string;
+	    staged: string;
+	    unstaged: string;
+	    untracked: boolean;
+	    conflicted: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new GitStatusEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.origPath = source["origPath"];
+	        this.staged = source["staged"];
+	        this.unstaged = source["unstaged"];
+	        this.untracked = source["untracked"];
+	        this.conflicted = source["conflicted"];
+	    }
+	}
+	export class GitRepoStatus {
+	    root: string;
+	    branch: string;
+	    detached: boolean;
+	    upstream?: string;
+	    ahead: number;
+	    behind: number;
+	    dirty: boolean;
+	    files: GitStatusEntry[];
+	
+	    static createFrom(source: any = {}) {
+	        return new GitRepoStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.root = source["root"];
+	        this.branch = source["branch"];
+	        this.detached = source["detached"];
+	        this.upstream = source["upstream"];
+	        this.ahead = source["ahead"];
+	        this.behind = source["behind"];
+	        this.dirty = source["dirty"];
+	        this.files = this.convertValues(source["files"], GitStatusEntry);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class HexRow {
+	    offset: number;
+	    hex: string;
+	    text: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HexRow(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.offset = source["offset"];
+	        this.hex = source["hex"];
+	        this.text = source["text"];
+	    }
+	}
+	export class HexPage {
+	    offset: number;
+	    size: number;
+	    rows: HexRow[];
+	
+	    static createFrom(source: any = {}) {
+	        return new HexPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.offset = source["offset"];
+	        this.size = source["size"];
+	        this.rows = this.convertValues(source["rows"], HexRow);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class HistorySettings {
+	    backupIntervalHours: number;
+	    maxBackups: number;
+	    retentionDays: number;
+	    retentionMaxChats: number;
+	    retentionAction: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HistorySettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.backupIntervalHours = source["backupIntervalHours"];
+	        this.maxBackups = source["maxBackups"];
+	        this.retentionDays = source["retentionDays"];
+	        this.retentionMaxChats = source["retentionMaxChats"];
+	        this.retentionAction = source["retentionAction"];
+	    }
+	}
+	export class IntegrityReport {
+	    ok: boolean;
+	    problems: string[];
+	    orphansRemoved: number;
+	    vacuumed: boolean;
+	    checkedAt: string;
+	    latestBackup?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ok = source["ok"];
+	        this.problems = source["problems"];
+	        this.orphansRemoved = source["orphansRemoved"];
+	        this.vacuumed = source["vacuumed"];
+	        this.checkedAt = source["checkedAt"];
+	        this.latestBackup = source["latestBackup"];
+	    }
+	}
+	export class Language {
+	    id: string;
+	    name: string;
+	    extensions: string[];
+	    filenames: string[];
+	    interpreters: string[];
+	    aliases: string[];
+	    lineComment: string;
+	    blockCommentStart: string;
+	    blockCommentEnd: string;
+	    indentSize: number;
+	    indentWithTabs: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Language(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.extensions = source["extensions"];
+	        this.filenames = source["filenames"];
+	        this.interpreters = source["interpreters"];
+	        this.aliases = source["aliases"];
+	        this.lineComment = source["lineComment"];
+	        this.blockCommentStart = source["blockCommentStart"];
+	        this.blockCommentEnd = source["blockCommentEnd"];
+	        this.indentSize = source["indentSize"];
+	        this.indentWithTabs = source["indentWithTabs"];
+	    }
+	}
+	export class LaunchRequest {
+	    files: FileTarget[];
+	    folder?: string;
+	    diff?: string[];
+	    waitId?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LaunchRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = this.convertValues(source["files"], FileTarget);
+	        this.folder = source["folder"];
+	        this.diff = source["diff"];
+	        this.waitId = source["waitId"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class LineRange {
+	    start: number;
+	    lines: string[];
+	    totalLines: number;
+	    indexed: boolean;
+	    truncated: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new LineRange(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.start = source["start"];
+	        this.lines = source["lines"];
+	        this.totalLines = source["totalLines"];
+	        this.indexed = source["indexed"];
+	        this.truncated = source["truncated"];
+	    }
+	}
+	export class MergeResult {
+	    content: string;
+	    conflicts: number;
+	    clean: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new MergeResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.content = source["content"];
+	        this.conflicts = source["conflicts"];
+	        this.clean = source["clean"];
+	    }
+	}
+	export class Message {
+	    id: number;
+	    chatId: number;
+	    role: string;
+	    content: string;
+	    status: string;
+	    error?: string;
+	    createdAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Message(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.chatId = source["chatId"];
+	        this.role = source["role"];
+	        this.content = source["content"];
+	        this.status = source["status"];
+	        this.error = source["error"];
+	        this.createdAt = source["createdAt"];
+	    }
+	}
+	export class MessagePage {
+	    messages: Message[];
+	    beforeId: number;
+	    hasMore: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessagePage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.messages = this.convertValues(source["messages"], Message);
+	        this.beforeId = source["beforeId"];
+	        this.hasMore = source["hasMore"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class OllamaModel {
+	    name: string;
+	    size: string;
+	    modified: string;
+	    parameters: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new OllamaModel(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.size = source["size"];
+	        this.modified = source["modified"];
+	        this.parameters = source["parameters"];
+	    }
+	}
+	export class OllamaStatus {
+	    installed: boolean;
+	    version: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new OllamaStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.installed = source["installed"];
+	        this.version = source["version"];
+	        this.message = source["message"];
+	    }
+	}
+	export class ProjectSearchOptions {
+	    query: string;
+	    regex: boolean;
+	    caseSensitive: boolean;
+	    wholeWord: boolean;
+	    include: string[];
+	    exclude: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ProjectSearchOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.query = source["query"];
+	        this.regex = source["regex"];
+	        this.caseSensitive = source["caseSensitive"];
+	        this.wholeWord = source["wholeWord"];
+	        this.include = source["include"];
+	        this.exclude = source["exclude"];
+	    }
+	}
+	export class RecoveryInfo {
+	    bufferId: string;
+	    path: string;
+	    name: string;
+	    encoding: string;
+	    lineEnding: string;
+	    updatedAt: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RecoveryInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.bufferId = source["bufferId"];
+	        this.path = source["path"];
+	        this.name = source["name"];
+	        this.encoding = source["encoding"];
+	        this.lineEnding = source["lineEnding"];
+	        this.updatedAt = source["updatedAt"];
+	    }
+	}
+	export class RecoverableBuffer {
+	    info: RecoveryInfo;
+	    content: string;
+	    diff: string;
+	    diskMissing: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RecoverableBuffer(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.info = this.convertValues(source["info"], RecoveryInfo);
+	        this.content = source["content"];
+	        this.diff = source["diff"];
+	        this.diskMissing = source["diskMissing"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ReplacePreview {
+	    path: string;
+	    relPath: string;
+	    replacements: number;
+	    diff: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReplacePreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.relPath = source["relPath"];
+	        this.replacements = source["replacements"];
+	        this.diff = source["diff"];
+	    }
+	}
+	export class ReplacePlan {
+	    planId: string;
+	    files: ReplacePreview[];
+	    replacements: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReplacePlan(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.planId = source["planId"];
+	        this.files = this.convertValues(source["files"], ReplacePreview);
+	        this.replacements = source["replacements"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ReplaceResult {
+	    files: string[];
+	    replacements: number;
+	    canUndo: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReplaceResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = source["files"];
+	        this.replacements = source["replacements"];
+	        this.canUndo = source["canUndo"];
+	    }
+	}
+	export class RetentionResult {
+	    action: string;
+	    dryRun: boolean;
+	    chats: Chat[];
+	    backup?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RetentionResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.action = source["action"];
+	        this.dryRun = source["dryRun"];
+	        this.chats = this.convertValues(source["chats"], Chat);
+	        this.backup = source["backup"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SecuritySettings {
+	    autoLockMinutes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SecuritySettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.autoLockMinutes = source["autoLockMinutes"];
+	    }
+	}
+	export class SessionTab {
+	    bufferId: string;
+	    path: string;
+	    name: string;
+	    encoding: string;
+	    lineEnding: string;
+	    selectionStart: number;
+	    selectionEnd: number;
+	    scrollTop: number;
+	    scrollLeft: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SessionTab(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.bufferId = source["bufferId"];
+	        this.path = source["path"];
+	        this.name = source["name"];
+	        this.encoding = source["encoding"];
+	        this.lineEnding = source["lineEnding"];
+	        this.selectionStart = source["selectionStart"];
+	        this.selectionEnd = source["selectionEnd"];
+	        this.scrollTop = source["scrollTop"];
+	        this.scrollLeft = source["scrollLeft"];
+	    }
+	}
+	export class Session {
+	    name: string;
+	    tabs: SessionTab[];
+	    activeTab: string;
+	    chatId: number;
+	    folder: string;
+	    updatedAt: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Session(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.tabs = this.convertValues(source["tabs"], SessionTab);
+	        this.activeTab = source["activeTab"];
+	        this.chatId = source["chatId"];
+	        this.folder = source["folder"];
+	        this.updatedAt = source["updatedAt"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SessionSummary {
+	    name: string;
+	    tabCount: number;
+	    updatedAt: number;
+	    active: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SessionSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.tabCount = source["tabCount"];
+	        this.updatedAt = source["updatedAt"];
+	        this.active = source["active"];
+	    }
+	}
+	
+	export class WorkspaceSettings {
+	    excludes: string[];
+	    maxIndexedFiles: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspaceSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.excludes = source["excludes"];
+	        this.maxIndexedFiles = source["maxIndexedFiles"];
+	    }
+	}
+	export class UISettings {
+	    theme: string;
+	    darkMode: boolean;
+	    showStatusBar: boolean;
+	    compactMode: boolean;
+	    sidebarPosition: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new UISettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.theme = source["theme"];
+	        this.darkMode = source["darkMode"];
+	        this.showStatusBar = source["showStatusBar"];
+	        this.compactMode = source["compactMode"];
+	        this.sidebarPosition = source["sidebarPosition"];
+	    }
+	}
+	export class Settings {
+	    editor: EditorSettings;
+	    ui: UISettings;
+	    ai: AISettings;
+	    security: SecuritySettings;
+	    history: HistorySettings;
+	    workspace: WorkspaceSettings;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.editor = this.convertValues(source["editor"], EditorSettings);
+	        this.ui = this.convertValues(source["ui"], UISettings);
+	        this.ai = this.convertValues(source["ai"], AISettings);
+	        this.security = this.convertValues(source["security"], SecuritySettings);
+	        this.history = this.convertValues(source["history"], HistorySettings);
+	        this.workspace = this.convertValues(source["workspace"], WorkspaceSettings);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class WorkspaceEntry {
+	    name: string;
+	    path: string;
+	    relPath: string;
+	    isDir: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspaceEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.path = source["path"];
+	        this.relPath = source["relPath"];
+	        this.isDir = source["isDir"];
+	    }
+	}
+	export class WorkspaceInfo {
+	    root: string;
+	    name: string;
+	    files: number;
+	    indexed: boolean;
+	    truncated: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspaceInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.root = source["root"];
+	        this.name = source["name"];
+	        this.files = source["files"];
+	        this.indexed = source["indexed"];
+	        this.truncated = source["truncated"];
+	    }
+	}
 
 }
 
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"unicode"
+)
+
+// Fuzzy match scoring. A match earns points for every query character,
+// more where it starts a word or continues the previous match, and loses
+// a little for every gap.
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusBoundary    = 8 // first character, or after a separator
+	fuzzyBonusCamel       = 7 // an upper-case letter after a lower-case one
+	fuzzyBonusBasename    = 4 // in the file name rather than its directories
+	fuzzyBonusConsecutive = 6
+	fuzzyPenaltyGap       = 3
+)
+
+// fuzzyNone marks positions a query prefix can't end at
+const fuzzyNone = -1 << 30
+
+// fuzzyMatch finds the best placement of query, already lower-cased, as a
+// subsequence of path. It returns the score and the rune index of each
+// matched character, or ok false if path doesn't contain the query.
+func fuzzyMatch(query []rune, path string) (score int, positions []int, ok bool) {
+	text := []rune(path)
+	if len(query) == 0 {
+		return 0, nil, true
+	}
+	if len(query) > len(text) {
+		return 0, nil, false
+	}
+
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// Cheap rejection before scoring
+	next := 0
+	for _, r := range lower {
+		if next < len(query) && r == query[next] {
+			next++
+		}
+	}
+	if next < len(query) {
+		return 0, nil, false
+	}
+
+	basename := 0
+	for i, r := range text {
+		if r == '/' || r == '\\' {
+			basename = i + 1
+		}
+	}
+	bonus := make([]int, len(text))
+	for i, r := range text {
+		switch {
+		case i == 0 || isFuzzySeparator(text[i-1]):
+			bonus[i] = fuzzyBonusBoundary
+		case unicode.IsUpper(r) && unicode.IsLower(text[i-1]):
+			bonus[i] = fuzzyBonusCamel
+		}
+		if i >= basename {
+			bonus[i] += fuzzyBonusBasename
+		}
+	}
+
+	// best[i][j] is the best score for query[:i+1] with query[i] at text[j],
+	// and from[i][j] where query[i-1] went to get it
+	best := make([][]int, len(query))
+	from := make([][]int, len(query))
+	for i := range query {
+		best[i] = make([]int, len(text))
+		from[i] = make([]int, len(text))
+		for j := range text {
+			best[i][j] = fuzzyNone
+		}
+	}
+
+	for j := range text {
+		if lower[j] == query[0] {
+			best[0][j] = fuzzyScoreMatch + bonus[j]
+		}
+	}
+	for i := 1; i < len(query); i++ {
+		gapBest, gapFrom := fuzzyNone, -1 // best[i-1][k] for k <= j-2
+		for j := i; j < len(text); j++ {
+			if k := j - 2; k >= 0 && best[i-1][k] > gapBest {
+				gapBest, gapFrom = best[i-1][k], k
+			}
+			if lower[j] != query[i] {
+				continue
+			}
+			if prev := best[i-1][j-1]; prev != fuzzyNone {
+				best[i][j] = prev + fuzzyScoreMatch + bonus[j] + fuzzyBonusConsecutive
+				from[i][j] = j - 1
+			}
+			if gapBest != fuzzyNone {
+				if s := gapBest + fuzzyScoreMatch + bonus[j] - fuzzyPenaltyGap; s > best[i][j] {
+					best[i][j] = s
+					from[i][j] = gapFrom
+				}
+			}
+		}
+	}
+
+	last := len(query) - 1
+	end := -1
+	for j := range text {
+		if best[last][j] != fuzzyNone && (end < 0 || best[last][j] > best[last][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	positions = make([]int, len(query))
+	for i, j := last, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	// Shorter paths win ties
+	return best[last][end] - len(text)/8, positions, true
+}
+
+// isFuzzySeparator reports whether r separates words in a path
+func isFuzzySeparator(r rune) bool {
+	switch r {
+	case '/', '\\', '_', '-', '.', ' ':
+		return true
+	}
+	return false
+}
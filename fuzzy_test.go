@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		query         string
+		path          string
+		wantOK        bool
+		wantPositions []int
+	}{
+		{"", "main.go", true, nil},
+		{"main", "main.go", true, []int{0, 1, 2, 3}},
+		{"mgo", "main.go", true, []int{0, 5, 6}},
+		{"xyz", "main.go", false, nil},
+		{"main.go.bak", "main.go", false, nil}, // longer than the path
+		{"og", "main.go", false, nil},          // out of order
+		{"fm", "src/FileManager.go", true, []int{4, 8}},
+		{"app", "src/App.go", true, []int{4, 5, 6}}, // case-insensitive
+		{"ab", "a/b/ab.txt", true, []int{4, 5}},     // the file name beats directories
+	}
+
+	for _, tt := range tests {
+		_, positions, ok := fuzzyMatch([]rune(tt.query), tt.path)
+		if ok != tt.wantOK {
+			t.Errorf("fuzzyMatch(%q, %q) ok = %v, want %v", tt.query, tt.path, ok, tt.wantOK)
+			continue
+		}
+		if ok && !reflect.DeepEqual(positions, tt.wantPositions) {
+			t.Errorf("fuzzyMatch(%q, %q) positions = %v, want %v", tt.query, tt.path, positions, tt.wantPositions)
+		}
+	}
+}
+
+func TestFuzzyMatchRanking(t *testing.T) {
+	// Each query should rank the first path above the second
+	tests := []struct {
+		query  string
+		better string
+		worse  string
+	}{
+		{"main", "main.go", "mxaxixn.go"},            // consecutive over scattered
+		{"fw", "file_watcher.go", "firmware.go"},     // word starts over the middle of a word
+		{"fm", "FileManager.go", "format.go"},        // camel case humps
+		{"util", "src/util.go", "util/src/other.go"}, // the file name over a directory
+		{"app", "app.go", "pkg/deep/nested/app.go"},  // shorter paths win ties
+	}
+
+	for _, tt := range tests {
+		better, _, okBetter := fuzzyMatch([]rune(tt.query), tt.better)
+		worse, _, okWorse := fuzzyMatch([]rune(tt.query), tt.worse)
+		if !okBetter || !okWorse {
+			t.Errorf("%q: expected both %q and %q to match", tt.query, tt.better, tt.worse)
+			continue
+		}
+		if better <= worse {
+			t.Errorf("%q: %q scored %d, not above %q at %d", tt.query, tt.better, better, tt.worse, worse)
+		}
+	}
+}
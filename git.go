@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitTimeout bounds each git command
+const gitTimeout = 30 * time.Second
+
+// ErrNotGitRepo is returned for files outside any git repository
+var ErrNotGitRepo = errors.New("not in a git repository")
+
+// gitHunkHeader matches the "@@ -a,b +c,d @@" line starting a diff hunk
+var gitHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// GitHunk is a run of lines changed in the working tree since HEAD
+type GitHunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"` // after a deletion, the line the removed lines followed
+	NewLines int    `json:"newLines"`
+	Kind     string `json:"kind"` // "added", "modified" or "deleted"
+
+	patch []byte // the hunk as git printed it, for DiscardHunk
+}
+
+// GitFileChanges is how a file differs from HEAD, for gutter markers
+type GitFileChanges struct {
+	Root      string    `json:"root"` // of the repository
+	Tracked   bool      `json:"tracked"`
+	Hunks     []GitHunk `json:"hunks"`
+	Staged    bool      `json:"staged"`    // some changes are in the index
+	Unstaged  bool      `json:"unstaged"`  // some changes are only in the working tree
+	Untracked bool      `json:"untracked"` // a new file git doesn't know about yet
+
+	header []byte // the diff's file header, for DiscardHunk
+}
+
+// GitBlameLine says which commit last changed a line
+type GitBlameLine struct {
+	Line        int    `json:"line"`
+	Commit      string `json:"commit"` // abbreviated
+	Author      string `json:"author"`
+	Time        int64  `json:"time"`
+	Summary     string `json:"summary"`
+	Uncommitted bool   `json:"uncommitted"`
+}
+
+// GitStatusEntry is a changed file in a repository
+type GitStatusEntry struct {
+	Path       string `json:"path"`
+	OrigPath   string `json:"origPath,omitempty"` // before a rename or copy
+	Staged     string `json:"staged"`             // git's one-letter status in the index, or ""
+	Unstaged   string `json:"unstaged"`           // and in the working tree
+	Untracked  bool   `json:"untracked"`
+	Conflicted bool   `json:"conflicted"`
+}
+
+// GitRepoStatus is the branch and changed files of a repository
+type GitRepoStatus struct {
+	Root     string           `json:"root"`
+	Branch   string           `json:"branch"` // the abbreviated commit when detached
+	Detached bool             `json:"detached"`
+	Upstream string           `json:"upstream,omitempty"`
+	Ahead    int              `json:"ahead"`
+	Behind   int              `json:"behind"`
+	Dirty    bool             `json:"dirty"`
+	Files    []GitStatusEntry `json:"files"`
+}
+
+// GitClient runs the local git binary for the open files and workspace
+type GitClient struct {
+	app *App
+	mu  sync.Mutex // one command that changes a repository at a time
+}
+
+// NewGitClient creates a client for the git binary on the PATH
+func NewGitClient(app *App) *GitClient {
+	return &GitClient{app: app}
+}
+
+// run runs git in dir and returns its output. Pathspecs are taken
+// literally, so file names with * or ? aren't treated as patterns, and
+// a repository's fsmonitor hook is never started.
+func (g *GitClient) run(dir string, stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--literal-pathspecs", "-c", "core.quotepath=off", "-c", "core.fsmonitor=false"}, args...)...)
+	cmd.Dir = dir
+	cmd.SysProcAttr = hideConsoleWindows()
+	// Never wait on a credential prompt, don't take the index lock just
+	// to refresh it while reading status, and keep messages in English
+	// so locate can recognise them
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_OPTIONAL_LOCKS=0", "LC_ALL=C")
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("git is not installed or not on the PATH")
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// locate returns the directory git commands for a path run in, the name
+// of the file in it ("." for a folder) and the repository root
+func (g *GitClient) locate(path string) (dir string, name string, root string, err error) {
+	if _, _, ok := splitArchivePath(path); ok {
+		return "", "", "", ErrNotGitRepo
+	}
+	dir, name = filepath.Dir(path), filepath.Base(path)
+	if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+		dir, name = path, "."
+	}
+
+	out, err := g.run(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		if strings.Contains(err.Error(), "not a git repository") {
+			return "", "", "", ErrNotGitRepo
+		}
+		return "", "", "", err
+	}
+	root = filepath.Clean(filepath.FromSlash(strings.TrimSpace(string(out))))
+	return dir, name, root, nil
+}
+
+// head returns HEAD, or the empty tree in a repository with no commits yet
+// so new files diff as wholly added
+func (g *GitClient) head(dir string) (string, error) {
+	if _, err := g.run(dir, nil, "rev-parse", "--verify", "--quiet", "HEAD^{commit}"); err == nil {
+		return "HEAD", nil
+	}
+	out, err := g.run(dir, []byte{}, "hash-object", "-t", "tree", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// FileChanges compares a file on disk with HEAD
+func (g *GitClient) FileChanges(path string) (*GitFileChanges, error) {
+	dir, name, root, err := g.locate(path)
+	if err != nil {
+		return nil, err
+	}
+	changes := &GitFileChanges{Root: root, Hunks: []GitHunk{}}
+
+	status, err := g.run(dir, nil, "status", "--porcelain=v2", "-z", "--untracked-files=all", "--", name)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range strings.Split(string(status), "\x00") {
+		switch {
+		case strings.HasPrefix(record, "? "):
+			changes.Untracked = true
+		case strings.HasPrefix(record, "1 "), strings.HasPrefix(record, "2 "), strings.HasPrefix(record, "u "):
+			changes.Staged = changes.Staged || record[2] != '.'
+			changes.Unstaged = changes.Unstaged || record[3] != '.'
+		}
+	}
+	if changes.Untracked {
+		return changes, nil
+	}
+	changes.Tracked = true
+
+	head, err := g.head(dir)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := g.run(dir, nil, "diff", "--no-color", "--no-ext-diff", "--no-textconv", "-U0", head, "--", name)
+	if err != nil {
+		return nil, err
+	}
+	changes.header, changes.Hunks = parseGitHunks(diff)
+	return changes, nil
+}
+
+// parseGitHunks splits a zero-context diff of one file into its file
+// header and hunks. A binary file has no hunks.
+func parseGitHunks(diff []byte) ([]byte, []GitHunk) {
+	var header []byte
+	hunks := []GitHunk{}
+	for len(diff) > 0 {
+		end := bytes.IndexByte(diff, '\n') + 1
+		if end == 0 {
+			end = len(diff)
+		}
+		line := diff[:end]
+		diff = diff[end:]
+
+		match := gitHunkHeader.FindSubmatch(line)
+		switch {
+		case match != nil:
+			hunk := GitHunk{
+				OldStart: atoiOr(match[1], 0),
+				OldLines: atoiOr(match[2], 1),
+				NewStart: atoiOr(match[3], 0),
+				NewLines: atoiOr(match[4], 1),
+			}
+			switch {
+			case hunk.OldLines == 0:
+				hunk.Kind = "added"
+			case hunk.NewLines == 0:
+				hunk.Kind = "deleted"
+			default:
+				hunk.Kind = "modified"
+			}
+			hunk.patch = append([]byte(nil), line...)
+			hunks = append(hunks, hunk)
+		case len(hunks) > 0:
+			hunks[len(hunks)-1].patch = append(hunks[len(hunks)-1].patch, line...)
+		default:
+			header = append(header, line...)
+		}
+	}
+	return header, hunks
+}
+
+// atoiOr parses a number from a diff header, or returns def if it's missing
+func atoiOr(digits []byte, def int) int {
+	if len(digits) == 0 {
+		return def
+	}
+	n, err := strconv.Atoi(string(digits))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// Diff returns the full diff of a file on disk against HEAD
+func (g *GitClient) Diff(path string) (string, error) {
+	dir, name, _, err := g.locate(path)
+	if err != nil {
+		return "", err
+	}
+	head, err := g.head(dir)
+	if err != nil {
+		return "", err
+	}
+	diff, err := g.run(dir, nil, "diff", "--no-color", "--no-ext-diff", "--no-textconv", head, "--", name)
+	if err != nil {
+		return "", err
+	}
+	return string(diff), nil
+}
+
+// Blame returns the commit that last changed each line of a file on disk.
+// Lines not committed yet are marked Uncommitted.
+func (g *GitClient) Blame(path string) ([]GitBlameLine, error) {
+	dir, name, _, err := g.locate(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := g.run(dir, nil, "blame", "--porcelain", "--no-textconv", "--", name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Porcelain output describes each commit once, before its first line
+	type commitInfo struct {
+		author  string
+		time    int64
+		summary string
+	}
+	commits := make(map[string]*commitInfo)
+	var lines []GitBlameLine
+	var current *GitBlameLine
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "\t") {
+			if current != nil {
+				info := commits[current.Commit]
+				current.Author, current.Time, current.Summary = info.author, info.time, info.summary
+				lines = append(lines, *current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			fields := strings.Fields(text)
+			if len(fields) < 3 {
+				continue
+			}
+			line, _ := strconv.Atoi(fields[2])
+			current = &GitBlameLine{Line: line, Commit: fields[0]}
+			if commits[fields[0]] == nil {
+				commits[fields[0]] = &commitInfo{}
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(text, " ")
+		info := commits[current.Commit]
+		switch key {
+		case "author":
+			info.author = value
+		case "author-time":
+			info.time, _ = strconv.ParseInt(value, 10, 64)
+		case "summary":
+			info.summary = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blame: %w", err)
+	}
+
+	for i := range lines {
+		if strings.Trim(lines[i].Commit, "0") == "" {
+			lines[i].Uncommitted = true
+			lines[i].Summary = "" // git describes the working tree file here
+		}
+		if len(lines[i].Commit) > 8 {
+			lines[i].Commit = lines[i].Commit[:8]
+		}
+	}
+	return lines, nil
+}
+
+// Status returns the branch and changed files of the repository a file
+// or folder is in
+func (g *GitClient) Status(path string) (*GitRepoStatus, error) {
+	_, _, root, err := g.locate(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := g.run(root, nil, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return parseGitStatus(root, out), nil
+}
+
+// parseGitStatus reads the output of "git status --porcelain=v2 --branch -z"
+// run at the root of a repository
+func parseGitStatus(root string, out []byte) *GitRepoStatus {
+	status := &GitRepoStatus{Root: root, Files: []GitStatusEntry{}}
+	oid := ""
+	records := strings.Split(string(out), "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(record) < 2 {
+			continue
+		}
+		switch record[0] {
+		case '#':
+			key, value, _ := strings.Cut(strings.TrimPrefix(record, "# "), " ")
+			switch key {
+			case "branch.oid":
+				oid = value
+			case "branch.head":
+				status.Detached = value == "(detached)"
+				if !status.Detached {
+					status.Branch = value
+				}
+			case "branch.upstream":
+				status.Upstream = value
+			case "branch.ab":
+				fmt.Sscanf(value, "+%d -%d", &status.Ahead, &status.Behind)
+			}
+		case '1', '2', 'u':
+			// Ordinary, renamed or copied, and unmerged entries have 8, 9
+			// and 10 fields before the path
+			fields := map[byte]int{'1': 8, '2': 9, 'u': 10}[record[0]]
+			parts := strings.SplitN(record, " ", fields+1)
+			if len(parts) <= fields {
+				continue
+			}
+			entry := GitStatusEntry{
+				Path:       repoPath(root, parts[fields]),
+				Staged:     gitStatusLetter(parts[1][0]),
+				Unstaged:   gitStatusLetter(parts[1][1]),
+				Conflicted: record[0] == 'u',
+			}
+			if record[0] == '2' && i+1 < len(records) {
+				i++
+				entry.OrigPath = repoPath(root, records[i])
+			}
+			status.Files = append(status.Files, entry)
+		case '?':
+			status.Files = append(status.Files, GitStatusEntry{Path: repoPath(root, record[2:]), Untracked: true})
+		}
+	}
+	if status.Detached {
+		status.Branch = oid[:min(len(oid), 8)]
+	}
+	status.Dirty = len(status.Files) > 0
+	return status
+}
+
+// repoPath turns a path git printed, relative to the root, into a full one
+func repoPath(root string, rel string) string {
+	return filepath.Join(root, filepath.FromSlash(rel))
+}
+
+// gitStatusLetter returns a status letter, or "" for git's "unchanged" dot
+func gitStatusLetter(letter byte) string {
+	if letter == '.' {
+		return ""
+	}
+	return string(letter)
+}
+
+// Stage adds a file's changes to the index
+func (g *GitClient) Stage(path string) error {
+	dir, name, _, err := g.locate(path)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, err = g.run(dir, nil, "add", "--", name)
+	return err
+}
+
+// Unstage takes a file's changes out of the index, leaving the file alone
+func (g *GitClient) Unstage(path string) error {
+	dir, name, _, err := g.locate(path)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if head, err := g.head(dir); err == nil && head != "HEAD" {
+		// With no commits yet there is nothing to reset to
+		_, err = g.run(dir, nil, "rm", "--cached", "--quiet", "-r", "--", name)
+		return err
+	}
+	_, err = g.run(dir, nil, "reset", "--quiet", "--", name)
+	return err
+}
+
+// Commit commits what is staged in the repository a file or folder is in,
+// and returns the new commit's abbreviated hash
+func (g *GitClient) Commit(path string, message string) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("the commit message is empty")
+	}
+	_, _, root, err := g.locate(path)
+	if err != nil {
+		return "", err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, err := g.run(root, []byte(message), "commit", "--quiet", "--file", "-"); err != nil {
+		return "", err
+	}
+	out, err := g.run(root, nil, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DiscardHunk reverts one hunk of a file on disk to how it is in HEAD.
+// The hunk is found again by its line numbers, so it fails if the file
+// changed since FileChanges was called.
+func (g *GitClient) DiscardHunk(path string, hunk GitHunk) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	changes, err := g.FileChanges(path)
+	if err != nil {
+		return err
+	}
+	for _, h := range changes.Hunks {
+		if h.OldStart != hunk.OldStart || h.OldLines != hunk.OldLines || h.NewStart != hunk.NewStart || h.NewLines != hunk.NewLines {
+			continue
+		}
+		patch := append(append([]byte(nil), changes.header...), h.patch...)
+		// The patch names files from the root of the repository
+		_, err := g.run(changes.Root, patch, "apply", "--reverse", "--unidiff-zero", "--whitespace=nowarn", "-")
+		return err
+	}
+	return fmt.Errorf("the change is no longer in %s; refresh and try again", filepath.Base(path))
+}
+
+// refresh tells the frontend the status of the repository a saved file
+// is in, so gutter markers and the branch indicator follow saves
+func (g *GitClient) refresh(path string) {
+	status, err := g.Status(path)
+	if err != nil {
+		if !errors.Is(err, ErrNotGitRepo) {
+			fmt.Printf("Failed to read git status: %v\n", err)
+		}
+		return
+	}
+	g.app.EventBus.Emit(EventGitStatus, status)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGitHunks(t *testing.T) {
+	header := "diff --git a/f.txt b/f.txt\nindex 1111111..2222222 100644\n--- a/f.txt\n+++ b/f.txt\n"
+
+	tests := []struct {
+		name       string
+		diff       string
+		wantHeader string
+		wantHunks  []GitHunk
+	}{
+		{
+			name: "no changes",
+			diff: "",
+		},
+		{
+			name: "modified, added and deleted",
+			diff: header +
+				"@@ -2 +2 @@\n-old\n+new\n" +
+				"@@ -5,0 +6,2 @@\n+a\n+b\n" +
+				"@@ -9,3 +10,0 @@ func x\n-x\n-y\n-z\n",
+			wantHeader: header,
+			wantHunks: []GitHunk{
+				{OldStart: 2, OldLines: 1, NewStart: 2, NewLines: 1, Kind: "modified"},
+				{OldStart: 5, OldLines: 0, NewStart: 6, NewLines: 2, Kind: "added"},
+				{OldStart: 9, OldLines: 3, NewStart: 10, NewLines: 0, Kind: "deleted"},
+			},
+		},
+		{
+			name:       "new file",
+			diff:       header + "@@ -0,0 +1,3 @@\n+a\n+b\n+c",
+			wantHeader: header,
+			wantHunks:  []GitHunk{{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 3, Kind: "added"}},
+		},
+		{
+			name:       "binary",
+			diff:       "diff --git a/x.png b/x.png\nBinary files a/x.png and b/x.png differ\n",
+			wantHeader: "diff --git a/x.png b/x.png\nBinary files a/x.png and b/x.png differ\n",
+		},
+	}
+
+	for _, tt := range tests {
+		gotHeader, gotHunks := parseGitHunks([]byte(tt.diff))
+		if string(gotHeader) != tt.wantHeader {
+			t.Errorf("%s: header = %q, want %q", tt.name, gotHeader, tt.wantHeader)
+		}
+		patches := make([]string, len(gotHunks))
+		for i := range gotHunks {
+			patches[i] = string(gotHunks[i].patch)
+			gotHunks[i].patch = nil
+		}
+		if tt.wantHunks == nil {
+			tt.wantHunks = []GitHunk{}
+		}
+		if !reflect.DeepEqual(gotHunks, tt.wantHunks) {
+			t.Errorf("%s: hunks = %+v, want %+v", tt.name, gotHunks, tt.wantHunks)
+		}
+		// The header and hunks together are the whole diff again
+		if joined := string(gotHeader) + strings.Join(patches, ""); joined != tt.diff {
+			t.Errorf("%s: header and patches = %q, want %q", tt.name, joined, tt.diff)
+		}
+	}
+}
+
+func TestParseGitStatus(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	records := func(lines ...string) []byte {
+		return []byte(strings.Join(lines, "\x00") + "\x00")
+	}
+
+	tests := []struct {
+		name string
+		out  []byte
+		want *GitRepoStatus
+	}{
+		{
+			name: "branch with changes",
+			out: records(
+				"# branch.oid 0123456789abcdef0123456789abcdef01234567",
+				"# branch.head main",
+				"# branch.upstream origin/main",
+				"# branch.ab +2 -1",
+				"1 .M N... 100644 100644 100644 1111111 1111111 src/a b.txt",
+				"2 R. N... 100644 100644 100644 2222222 2222222 R100 new name.txt",
+				"old name.txt",
+				"u UU N... 100644 100644 100644 100644 3333333 4444444 5555555 conflict.txt",
+				"? notes/untracked.txt",
+			),
+			want: &GitRepoStatus{
+				Root:     root,
+				Branch:   "main",
+				Upstream: "origin/main",
+				Ahead:    2,
+				Behind:   1,
+				Dirty:    true,
+				Files: []GitStatusEntry{
+					{Path: filepath.Join(root, "src", "a b.txt"), Unstaged: "M"},
+					{Path: filepath.Join(root, "new name.txt"), OrigPath: filepath.Join(root, "old name.txt"), Staged: "R"},
+					{Path: filepath.Join(root, "conflict.txt"), Staged: "U", Unstaged: "U", Conflicted: true},
+					{Path: filepath.Join(root, "notes", "untracked.txt"), Untracked: true},
+				},
+			},
+		},
+		{
+			name: "detached and clean",
+			out: records(
+				"# branch.oid 0123456789abcdef0123456789abcdef01234567",
+				"# branch.head (detached)",
+			),
+			want: &GitRepoStatus{Root: root, Branch: "01234567", Detached: true, Files: []GitStatusEntry{}},
+		},
+		{
+			name: "no commits yet",
+			out: records(
+				"# branch.oid (initial)",
+				"# branch.head main",
+				"1 A. N... 000000 100644 100644 0000000 1111111 first.txt",
+			),
+			want: &GitRepoStatus{
+				Root:   root,
+				Branch: "main",
+				Dirty:  true,
+				Files:  []GitStatusEntry{{Path: filepath.Join(root, "first.txt"), Staged: "A"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := parseGitStatus(root, tt.out); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseGitStatus = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
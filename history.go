@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default retention for local file history
+const (
+	defaultFileHistoryMaxRevisions = 100
+	defaultFileHistoryMaxAgeDays   = 90
+	defaultFileHistoryMaxSizeMB    = 256
+)
+
+// FileRevision is a saved version of a file kept in the local history
+type FileRevision struct {
+	ID         string `json:"id"`
+	SavedAt    int64  `json:"savedAt"`
+	Size       int64  `json:"size"`       // bytes written to the file
+	StoredSize int64  `json:"storedSize"` // bytes used by the compressed copy
+	Hash       string `json:"hash"`       // SHA-256 of the bytes written
+	Encoding   string `json:"encoding"`
+	LineEnding string `json:"lineEnding"`
+}
+
+// historyIndex is the stored list of revisions of every file
+type historyIndex struct {
+	Files map[string][]FileRevision `json:"files"` // by path, oldest first
+}
+
+// FileHistory keeps a compressed copy of every saved file version in
+// ~/.akashic/history. Copies are stored once per content hash under
+// objects/, and index.json maps each path to its revisions.
+type FileHistory struct {
+	app   *App
+	dir   string
+	mu    sync.Mutex
+	index *historyIndex // loaded on first use
+}
+
+// NewFileHistory creates a file history stored in ~/.akashic/history
+func NewFileHistory(app *App) *FileHistory {
+	return &FileHistory{
+		app: app,
+		dir: filepath.Join(getSettingsDir(), "history"),
+	}
+}
+
+// objectPath returns where the copy with the given hash is stored
+func (h *FileHistory) objectPath(hash string) string {
+	return filepath.Join(h.dir, "objects", hash[:2], hash+".gz")
+}
+
+// load reads the index if it hasn't been yet. The caller holds h.mu.
+func (h *FileHistory) load() error {
+	if h.index != nil {
+		return nil
+	}
+
+	index := &historyIndex{Files: make(map[string][]FileRevision)}
+	data, err := os.ReadFile(filepath.Join(h.dir, "index.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read history index: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, index); err != nil {
+			return fmt.Errorf("failed to parse history index: %w", err)
+		}
+		if index.Files == nil {
+			index.Files = make(map[string][]FileRevision)
+		}
+	}
+	h.index = index
+	return nil
+}
+
+// save writes the index. The caller holds h.mu.
+func (h *FileHistory) save() error {
+	data, err := json.Marshal(h.index)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(h.dir, "index.json"), data, BackupOnSaveNone)
+}
+
+// Record stores a version of a file just written with the given bytes.
+// Saving the same content twice in a row keeps a single revision.
+func (h *FileHistory) Record(filePath string, data []byte, encodingName string, lineEnding string, savedAt time.Time) error {
+	settings := h.app.SettingsManager.Get().Editor
+	if !settings.FileHistory {
+		return nil
+	}
+	filePath = filepath.Clean(filePath)
+	hash := hashBytes(data)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.load(); err != nil {
+		return err
+	}
+	revisions := h.index.Files[filePath]
+	if n := len(revisions); n > 0 && revisions[n-1].Hash == hash {
+		return nil
+	}
+
+	stored, err := h.storeObject(hash, data)
+	if err != nil {
+		return fmt.Errorf("failed to store revision: %w", err)
+	}
+
+	id := savedAt.UnixNano()
+	if n := len(revisions); n > 0 {
+		if last, _ := strconv.ParseInt(revisions[n-1].ID, 10, 64); id <= last {
+			id = last + 1 // keep IDs unique and in save order
+		}
+	}
+	revision := FileRevision{
+		ID:         strconv.FormatInt(id, 10),
+		SavedAt:    savedAt.Unix(),
+		Size:       int64(len(data)),
+		StoredSize: stored,
+		Hash:       hash,
+		Encoding:   encodingName,
+		LineEnding: lineEnding,
+	}
+	h.index.Files[filePath] = append(revisions, revision)
+
+	h.prune(settings, revision.ID)
+	return h.save()
+}
+
+// storeObject writes a compressed copy of data unless one is already
+// stored, and returns its size. The caller holds h.mu.
+func (h *FileHistory) storeObject(hash string, data []byte) (int64, error) {
+	path := h.objectPath(hash)
+	if stat, err := os.Stat(path); err == nil {
+		return stat.Size(), nil
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(path, compressed.Bytes(), BackupOnSaveNone); err != nil {
+		return 0, err
+	}
+	return int64(compressed.Len()), nil
+}
+
+// prune applies the retention settings and deletes copies no revision
+// uses any more. The newest revision of each file is kept by the age rule,
+// and the revision just recorded is always kept. The caller holds h.mu.
+func (h *FileHistory) prune(settings EditorSettings, keepID string) {
+	before := h.objectSizes()
+
+	cutoff := time.Now().AddDate(0, 0, -settings.FileHistoryMaxAgeDays).Unix()
+	for path, revisions := range h.index.Files {
+		if settings.FileHistoryMaxRevisions > 0 && len(revisions) > settings.FileHistoryMaxRevisions {
+			revisions = revisions[len(revisions)-settings.FileHistoryMaxRevisions:]
+		}
+		if settings.FileHistoryMaxAgeDays > 0 {
+			kept := revisions[:0:0]
+			for i, revision := range revisions {
+				if revision.SavedAt >= cutoff || i == len(revisions)-1 {
+					kept = append(kept, revision)
+				}
+			}
+			revisions = kept
+		}
+		h.index.Files[path] = revisions
+	}
+
+	if settings.FileHistoryMaxSizeMB > 0 {
+		h.pruneToSize(int64(settings.FileHistoryMaxSizeMB)*1024*1024, keepID)
+	}
+
+	after := h.objectSizes()
+	for hash := range before {
+		if _, ok := after[hash]; !ok {
+			if err := os.Remove(h.objectPath(hash)); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("Failed to delete history copy %s: %v\n", hash, err)
+			}
+		}
+	}
+}
+
+// pruneToSize drops the oldest revisions across all files until the
+// stored copies fit in limit bytes. The caller holds h.mu.
+func (h *FileHistory) pruneToSize(limit int64, keepID string) {
+	sizes := h.objectSizes()
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	if total <= limit {
+		return
+	}
+
+	type ref struct {
+		path     string
+		revision FileRevision
+	}
+	var all []ref
+	uses := make(map[string]int)
+	for path, revisions := range h.index.Files {
+		for _, revision := range revisions {
+			all = append(all, ref{path, revision})
+			uses[revision.Hash]++
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].revision.SavedAt < all[j].revision.SavedAt })
+
+	dropped := make(map[string]map[string]bool) // revision IDs by path
+	for _, r := range all {
+		if total <= limit {
+			break
+		}
+		if r.revision.ID == keepID {
+			continue
+		}
+		if dropped[r.path] == nil {
+			dropped[r.path] = make(map[string]bool)
+		}
+		dropped[r.path][r.revision.ID] = true
+		if uses[r.revision.Hash]--; uses[r.revision.Hash] == 0 {
+			total -= sizes[r.revision.Hash]
+		}
+	}
+
+	for path, ids := range dropped {
+		var kept []FileRevision
+		for _, revision := range h.index.Files[path] {
+			if !ids[revision.ID] {
+				kept = append(kept, revision)
+			}
+		}
+		h.index.Files[path] = kept
+	}
+}
+
+// objectSizes returns the size of each stored copy in use, and drops
+// files left with no revisions. The caller holds h.mu.
+func (h *FileHistory) objectSizes() map[string]int64 {
+	sizes := make(map[string]int64)
+	for path, revisions := range h.index.Files {
+		if len(revisions) == 0 {
+			delete(h.index.Files, path)
+			continue
+		}
+		for _, revision := range revisions {
+			sizes[revision.Hash] = revision.StoredSize
+		}
+	}
+	return sizes
+}
+
+// Revisions lists a file's revisions, newest first
+func (h *FileHistory) Revisions(filePath string) ([]FileRevision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	stored := h.index.Files[filepath.Clean(filePath)]
+	revisions := make([]FileRevision, len(stored))
+	for i, revision := range stored {
+		revisions[len(stored)-1-i] = revision
+	}
+	return revisions, nil
+}
+
+// Read returns the bytes of a revision as they were written to the file
+func (h *FileHistory) Read(filePath string, revisionID string) (*FileRevision, []byte, error) {
+	h.mu.Lock()
+	if err := h.load(); err != nil {
+		h.mu.Unlock()
+		return nil, nil, err
+	}
+	var revision *FileRevision
+	for _, r := range h.index.Files[filepath.Clean(filePath)] {
+		if r.ID == revisionID {
+			revision = &r
+			break
+		}
+	}
+	h.mu.Unlock()
+
+	if revision == nil {
+		return nil, nil, fmt.Errorf("no revision %s of %s", revisionID, filepath.Base(filePath))
+	}
+
+	file, err := os.Open(h.objectPath(revision.Hash))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open revision: %w", err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+	if hashBytes(data) != revision.Hash {
+		return nil, nil, fmt.Errorf("revision %s of %s is damaged", revisionID, filepath.Base(filePath))
+	}
+	return revision, data, nil
+}
+
+// revisionLabel names a revision in diffs and restored tabs
+func revisionLabel(filePath string, revision *FileRevision) string {
+	return fmt.Sprintf("%s (%s)", filepath.Base(filePath), time.Unix(revision.SavedAt, 0).Format("2006-01-02 15:04:05"))
+}
+
+// readText returns a revision decoded with the encoding it was saved in
+func (h *FileHistory) readText(filePath string, revisionID string) (*FileRevision, string, *lineLayout, error) {
+	revision, data, err := h.Read(filePath, revisionID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	content, _, layout, err := h.app.FileManager.readWithDetection(data, revision.Encoding)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to decode revision: %w", err)
+	}
+	return revision, content, layout, nil
+}
+
+// Diff returns a unified diff from one revision of a file to another. An
+// empty toID compares with the file as it is on disk now.
+func (h *FileHistory) Diff(filePath string, fromID string, toID string) (string, error) {
+	from, fromContent, _, err := h.readText(filePath, fromID)
+	if err != nil {
+		return "", err
+	}
+
+	if toID != "" {
+		to, toContent, _, err := h.readText(filePath, toID)
+		if err != nil {
+			return "", err
+		}
+		return unifiedDiff(fromContent, toContent, revisionLabel(filePath, from), revisionLabel(filePath, to)), nil
+	}
+
+	data, err := h.app.FileManager.readPlain(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	diskContent, _, _, err := h.app.FileManager.readWithDetection(data, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return unifiedDiff(fromContent, diskContent, revisionLabel(filePath, from), filepath.Base(filePath)+" (on disk)"), nil
+}
+
+// Restore returns a revision's content for opening in a new untitled tab,
+// leaving the file itself alone until the tab is saved over it
+func (h *FileHistory) Restore(filePath string, revisionID string) (*FileOpenResult, error) {
+	revision, content, layout, err := h.readText(filePath, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	return &FileOpenResult{
+		FileInfo: &FileInfo{
+			Name:               revisionLabel(filePath, revision),
+			Encoding:           revision.Encoding,
+			EncodingConfidence: 1,
+			LineEnding:         layoutStyle(layout),
+			LineEndingCounts:   layout.counts,
+			TrailingNewline:    strings.HasSuffix(content, "\n"),
+			Language:           detectLanguage(filePath, content),
+			IsDirty:            true,
+			IsNewFile:          true,
+			Size:               revision.Size,
+		},
+		Content: content,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern from a .gitignore file or the workspace excludes
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool // "!pattern" re-includes what an earlier rule ignored
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// ignoreRules are the patterns of one .gitignore file, which apply to
+// paths under the directory it is in
+type ignoreRules struct {
+	base  string // slash-separated directory relative to the workspace root, "" for the root
+	rules []ignoreRule
+}
+
+// parseIgnoreRules reads patterns in .gitignore syntax. Patterns that
+// don't compile are skipped.
+func parseIgnoreRules(base string, lines []string) *ignoreRules {
+	rules := &ignoreRules{base: base}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A slash anywhere but the end anchors the pattern to the base directory
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		expr := globToRegexp(line)
+		if anchored {
+			expr = "^" + expr + "$"
+		} else {
+			expr = "^(?:.*/)?" + expr + "$"
+		}
+
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			continue
+		}
+		rule.pattern = pattern
+		rules.rules = append(rules.rules, rule)
+	}
+	return rules
+}
+
+// globToRegexp converts a gitignore glob to a regular expression. "*"
+// and "?" stop at slashes, while "**" crosses them.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			if i+2 < len(glob) && glob[i+2] == '/' {
+				b.WriteString("(?:.*/)?") // "**/" matches zero or more directories
+				i += 2
+			} else {
+				b.WriteString(".*")
+				i++
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end <= 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			b.WriteString(regexp.QuoteMeta(glob[i+1 : i+2]))
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match reports whether any rule matches a slash-separated path relative
+// to the workspace root, and if so whether the last matching rule ignores it
+func (r *ignoreRules) match(relPath string, isDir bool) (matched bool, ignored bool) {
+	if r == nil {
+		return false, false
+	}
+	if r.base != "" {
+		var ok bool
+		if relPath, ok = strings.CutPrefix(relPath, r.base+"/"); !ok {
+			return false, false
+		}
+	}
+
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		rule := r.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(relPath) {
+			return true, !rule.negate
+		}
+	}
+	return false, false
+}
+
+// matchesPath reports whether the rules ignore a file or any folder above
+// it, for filters such as search include and exclude globs
+func (r *ignoreRules) matchesPath(relPath string) bool {
+	for i := 0; i < len(relPath); i++ {
+		if relPath[i] == '/' {
+			if matched, ignored := r.match(relPath[:i], true); matched && ignored {
+				return true
+			}
+		}
+	}
+	matched, ignored := r.match(relPath, false)
+	return matched && ignored
+}
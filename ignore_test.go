@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"*.go", `[^/]*\.go`},
+		{"a?c", `a[^/]c`},
+		{"**/tmp", `(?:.*/)?tmp`},
+		{"logs/**", `logs/.*`},
+		{"a/**/b", `a/(?:.*/)?b`},
+		{"[abc].txt", `[abc]\.txt`},
+		{"[!abc]", `[^abc]`},
+		{"[", `\[`},
+		{`\*.md`, `\*\.md`},
+	}
+
+	for _, tt := range tests {
+		if got := globToRegexp(tt.glob); got != tt.want {
+			t.Errorf("globToRegexp(%q) = %q, want %q", tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	rules := parseIgnoreRules("", []string{
+		"# build output",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"docs/*.md",
+		"out/",
+		"**/cache",
+		"a/**/z",
+		`\#notes`,
+		"",
+	})
+
+	tests := []struct {
+		path        string
+		isDir       bool
+		wantMatched bool
+		wantIgnored bool
+	}{
+		{"error.log", false, true, true},
+		{"src/error.log", false, true, true},
+		{"error.logs", false, false, false},
+		{"keep.log", false, true, false},     // negated by a later rule
+		{"src/keep.log", false, true, false}, // negation isn't anchored either
+		{"build", true, true, true},
+		{"src/build", true, false, false}, // a leading slash anchors to the base
+		{"docs/readme.md", false, true, true},
+		{"src/docs/readme.md", false, false, false}, // a middle slash anchors too
+		{"docs/api/readme.md", false, false, false}, // "*" stops at slashes
+		{"out", true, true, true},
+		{"out", false, false, false}, // a trailing slash only matches directories
+		{"cache", true, true, true},
+		{"a/b/c/cache", true, true, true},
+		{"a/z", false, true, true},
+		{"a/b/c/z", false, true, true},
+		{"#notes", false, true, true},
+		{"# build output", false, false, false},
+	}
+
+	for _, tt := range tests {
+		matched, ignored := rules.match(tt.path, tt.isDir)
+		if matched != tt.wantMatched || ignored != tt.wantIgnored {
+			t.Errorf("match(%q, dir=%v) = (%v, %v), want (%v, %v)",
+				tt.path, tt.isDir, matched, ignored, tt.wantMatched, tt.wantIgnored)
+		}
+	}
+}
+
+func TestIgnoreRulesBase(t *testing.T) {
+	rules := parseIgnoreRules("sub", []string{"*.tmp", "/top"})
+
+	tests := []struct {
+		path        string
+		wantIgnored bool
+	}{
+		{"sub/a.tmp", true},
+		{"sub/deep/a.tmp", true},
+		{"a.tmp", false}, // outside the .gitignore's directory
+		{"subway/a.tmp", false},
+		{"sub/top", true},
+		{"sub/deep/top", false},
+	}
+
+	for _, tt := range tests {
+		if _, ignored := rules.match(tt.path, false); ignored != tt.wantIgnored {
+			t.Errorf("match(%q) ignored = %v, want %v", tt.path, ignored, tt.wantIgnored)
+		}
+	}
+
+	if !rules.matchesPath("sub/a.tmp/inner.txt") {
+		t.Errorf("matchesPath didn't ignore a file under an ignored folder")
+	}
+}
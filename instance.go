@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// instanceSocketName is the socket a running instance listens on for
+// launches to forward their files to it. Windows 10 and later support
+// Unix sockets too, so every platform uses one.
+const instanceSocketName = "instance.sock"
+
+// instanceTimeout bounds each step of talking to another instance, other
+// than waiting for files to close
+const instanceTimeout = 5 * time.Second
+
+// Replies a running instance sends a forwarded launch
+const (
+	instanceReplyOpened = "opened"
+	instanceReplyClosed = "closed" // the files of a --wait launch were closed
+)
+
+// instanceMessage is a launch forwarded to the running instance
+type instanceMessage struct {
+	Request LaunchRequest `json:"request"`
+	Wait    bool          `json:"wait"`
+}
+
+// instanceSocketPath returns the path of the single-instance socket
+func instanceSocketPath() string {
+	return filepath.Join(getSettingsDir(), instanceSocketName)
+}
+
+// forwardLaunch hands a launch to an already running instance. It
+// returns false if none is running. With wait set it returns only once
+// that instance reports the files closed or exits.
+func forwardLaunch(request LaunchRequest, wait bool) bool {
+	conn, err := net.DialTimeout("unix", instanceSocketPath(), instanceTimeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(instanceTimeout))
+	if err := json.NewEncoder(conn).Encode(instanceMessage{Request: request, Wait: wait}); err != nil {
+		return false
+	}
+	reader := bufio.NewReader(conn)
+	if reply, err := reader.ReadString('\n'); err != nil || reply != instanceReplyOpened+"\n" {
+		return false
+	}
+
+	if wait {
+		// Closing the connection also ends the wait, as when the instance exits
+		conn.SetDeadline(time.Time{})
+		reader.ReadString('\n')
+	}
+	return true
+}
+
+// listenForInstances claims the single-instance socket and serves
+// launches forwarded by later instances. It does nothing if another
+// instance already holds the socket, as happens with --new-window.
+func (a *App) listenForInstances() error {
+	path := instanceSocketPath()
+	if conn, err := net.DialTimeout("unix", path, instanceTimeout); err == nil {
+		conn.Close()
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create settings directory: %w", err)
+	}
+	// Nothing answered, so any socket there was left by a crash
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen for other instances: %w", err)
+	}
+	os.Chmod(path, 0600)
+
+	a.launchMu.Lock()
+	a.instanceListener = listener
+	a.launchMu.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // closed on shutdown
+			}
+			go a.serveInstance(conn)
+		}
+	}()
+	return nil
+}
+
+// serveInstance opens what another instance forwarded and, for --wait,
+// tells it when the files have been closed
+func (a *App) serveInstance(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(instanceTimeout))
+	var message instanceMessage
+	if err := json.NewDecoder(conn).Decode(&message); err != nil {
+		if err != io.EOF { // an instance checking this one is running
+			fmt.Printf("Failed to read forwarded launch: %v\n", err)
+		}
+		return
+	}
+
+	_, closed := a.queueLaunch(message.Request, message.Wait)
+	if a.ctx != nil {
+		wailsRuntime.WindowUnminimise(a.ctx)
+		wailsRuntime.WindowShow(a.ctx)
+	}
+	if _, err := conn.Write([]byte(instanceReplyOpened + "\n")); err != nil || closed == nil {
+		return
+	}
+
+	conn.SetDeadline(time.Time{})
+	<-closed
+	conn.Write([]byte(instanceReplyClosed + "\n"))
+}
+
+// queueLaunch keeps a launch for the frontend to take with
+// TakeLaunchRequests and tells it one is waiting. With wait set, the
+// returned channel is closed once FinishWait is called for it.
+func (a *App) queueLaunch(request LaunchRequest, wait bool) (waitID string, closed <-chan struct{}) {
+	a.launchMu.Lock()
+	if wait {
+		a.nextWaitID++
+		request.WaitID = strconv.Itoa(a.nextWaitID)
+		done := make(chan struct{})
+		a.waits[request.WaitID] = done
+		closed = done
+	}
+	a.launches = append(a.launches, request)
+	a.launchMu.Unlock()
+
+	a.EventBus.Emit(EventAppLaunch, nil)
+	return request.WaitID, closed
+}
+
+// openAtLaunch queues what this instance's own command line asked for
+func (a *App) openAtLaunch(launch *LaunchArgs) {
+	request := launch.Request
+	if len(request.Files) == 0 && request.Folder == "" && len(request.Diff) == 0 {
+		return
+	}
+	waitID, _ := a.queueLaunch(request, launch.Wait)
+	if waitID != "" {
+		a.launchMu.Lock()
+		a.quitAfterWait = waitID
+		a.launchMu.Unlock()
+	}
+}
+
+// finishWait ends a --wait launch
+func (a *App) finishWait(waitID string) {
+	a.launchMu.Lock()
+	closed, ok := a.waits[waitID]
+	delete(a.waits, waitID)
+	quit := waitID != "" && waitID == a.quitAfterWait
+	a.launchMu.Unlock()
+
+	if ok {
+		close(closed)
+	}
+	// Launched with --wait and nothing to forward to, this window is the
+	// editor the caller is waiting on
+	if quit && a.ctx != nil {
+		wailsRuntime.Quit(a.ctx)
+	}
+}
+
+// stopInstances releases the single-instance socket and ends every wait
+func (a *App) stopInstances() {
+	a.launchMu.Lock()
+	listener := a.instanceListener
+	a.instanceListener = nil
+	waits := a.waits
+	a.waits = make(map[string]chan struct{})
+	a.launchMu.Unlock()
+
+	if listener != nil {
+		listener.Close()
+	}
+	for _, closed := range waits {
+		close(closed)
+	}
+}
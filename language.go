@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// PlainTextLanguage is the language of files nothing else matches
+const PlainTextLanguage = "plaintext"
+
+// languageSniffBytes is how much of a file modelines and content
+// heuristics look at
+const languageSniffBytes = 4096
+
+// Language describes a language the editor recognizes
+type Language struct {
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	Extensions        []string `json:"extensions"`   // lower case, with the dot
+	Filenames         []string `json:"filenames"`    // whole names such as Makefile
+	Interpreters      []string `json:"interpreters"` // programs named by a #! line
+	Aliases           []string `json:"aliases"`      // other names modelines use
+	LineComment       string   `json:"lineComment"`
+	BlockCommentStart string   `json:"blockCommentStart"`
+	BlockCommentEnd   string   `json:"blockCommentEnd"`
+	IndentSize        int      `json:"indentSize"` // 0 follows the editor settings
+	IndentWithTabs    bool     `json:"indentWithTabs"`
+}
+
+// languages is the registry, in the order file dialogs list them
+var languages = []Language{
+	{ID: PlainTextLanguage, Name: "Plain Text", Extensions: []string{".txt", ".text", ".log"}, Aliases: []string{"text", "fundamental"}},
+	{ID: "markdown", Name: "Markdown", Extensions: []string{".md", ".markdown", ".mdown", ".mkd"}, Aliases: []string{"md", "gfm"},
+		BlockCommentStart: "<!--", BlockCommentEnd: "-->", IndentSize: 2},
+	{ID: "go", Name: "Go", Extensions: []string{".go"}, Filenames: []string{"go.mod", "go.sum", "go.work"}, Aliases: []string{"golang"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4, IndentWithTabs: true},
+	{ID: "python", Name: "Python", Extensions: []string{".py", ".pyw", ".pyi"}, Interpreters: []string{"python", "python2", "python3"}, Aliases: []string{"py"},
+		LineComment: "#", IndentSize: 4},
+	{ID: "javascript", Name: "JavaScript", Extensions: []string{".js", ".mjs", ".cjs", ".jsx"}, Interpreters: []string{"node", "nodejs"}, Aliases: []string{"js"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 2},
+	{ID: "typescript", Name: "TypeScript", Extensions: []string{".ts", ".mts", ".cts", ".tsx"}, Interpreters: []string{"deno", "ts-node"}, Aliases: []string{"ts"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 2},
+	{ID: "json", Name: "JSON", Extensions: []string{".json", ".jsonc", ".json5"}, Filenames: []string{".babelrc", ".eslintrc"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 2},
+	{ID: "html", Name: "HTML", Extensions: []string{".html", ".htm", ".xhtml"},
+		BlockCommentStart: "<!--", BlockCommentEnd: "-->", IndentSize: 2},
+	{ID: "css", Name: "CSS", Extensions: []string{".css", ".scss", ".less"},
+		BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 2},
+	{ID: "xml", Name: "XML", Extensions: []string{".xml", ".xsd", ".xsl", ".xslt", ".svg", ".plist", ".csproj"},
+		BlockCommentStart: "<!--", BlockCommentEnd: "-->", IndentSize: 2},
+	{ID: "yaml", Name: "YAML", Extensions: []string{".yaml", ".yml"}, Aliases: []string{"yml"},
+		LineComment: "#", IndentSize: 2},
+	{ID: "toml", Name: "TOML", Extensions: []string{".toml"}, Filenames: []string{"Cargo.lock", "Pipfile"},
+		LineComment: "#", IndentSize: 2},
+	{ID: "ini", Name: "INI", Extensions: []string{".ini", ".cfg", ".conf", ".properties"}, Filenames: []string{".editorconfig", ".gitconfig"}, Aliases: []string{"dosini", "conf"},
+		LineComment: ";"},
+	{ID: "shell", Name: "Shell Script", Extensions: []string{".sh", ".bash", ".zsh", ".ksh"}, Filenames: []string{".bashrc", ".bash_profile", ".profile", ".zshrc"},
+		Interpreters: []string{"sh", "bash", "zsh", "ksh", "dash", "ash"}, Aliases: []string{"sh", "bash", "zsh"},
+		LineComment: "#", IndentSize: 2},
+	{ID: "powershell", Name: "PowerShell", Extensions: []string{".ps1", ".psm1", ".psd1"}, Interpreters: []string{"pwsh", "powershell"}, Aliases: []string{"ps1"},
+		LineComment: "#", BlockCommentStart: "<#", BlockCommentEnd: "#>", IndentSize: 4},
+	{ID: "batch", Name: "Batch", Extensions: []string{".bat", ".cmd"}, Aliases: []string{"bat", "dosbatch"},
+		LineComment: "REM"},
+	{ID: "c", Name: "C", Extensions: []string{".c", ".h"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "cpp", Name: "C++", Extensions: []string{".cpp", ".cc", ".cxx", ".hpp", ".hh", ".hxx"}, Aliases: []string{"c++"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "csharp", Name: "C#", Extensions: []string{".cs", ".csx"}, Aliases: []string{"cs", "c#"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "java", Name: "Java", Extensions: []string{".java"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "kotlin", Name: "Kotlin", Extensions: []string{".kt", ".kts"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "rust", Name: "Rust", Extensions: []string{".rs"}, Aliases: []string{"rs"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "swift", Name: "Swift", Extensions: []string{".swift"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "ruby", Name: "Ruby", Extensions: []string{".rb", ".rake", ".gemspec"}, Filenames: []string{"Gemfile", "Rakefile"}, Interpreters: []string{"ruby"}, Aliases: []string{"rb"},
+		LineComment: "#", BlockCommentStart: "=begin", BlockCommentEnd: "=end", IndentSize: 2},
+	{ID: "perl", Name: "Perl", Extensions: []string{".pl", ".pm"}, Interpreters: []string{"perl"},
+		LineComment: "#", IndentSize: 4},
+	{ID: "php", Name: "PHP", Extensions: []string{".php", ".phtml"}, Interpreters: []string{"php"},
+		LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 4},
+	{ID: "lua", Name: "Lua", Extensions: []string{".lua"}, Interpreters: []string{"lua"},
+		LineComment: "--", BlockCommentStart: "--[[", BlockCommentEnd: "]]", IndentSize: 2},
+	{ID: "sql", Name: "SQL", Extensions: []string{".sql"},
+		LineComment: "--", BlockCommentStart: "/*", BlockCommentEnd: "*/", IndentSize: 2},
+	{ID: "r", Name: "R", Extensions: []string{".r"}, Interpreters: []string{"Rscript"},
+		LineComment: "#", IndentSize: 2},
+	{ID: "makefile", Name: "Makefile", Extensions: []string{".mk", ".mak"}, Filenames: []string{"Makefile", "makefile", "GNUmakefile"}, Interpreters: []string{"make"}, Aliases: []string{"make"},
+		LineComment: "#", IndentSize: 4, IndentWithTabs: true},
+	{ID: "dockerfile", Name: "Dockerfile", Extensions: []string{".dockerfile"}, Filenames: []string{"Dockerfile", "Containerfile"}, Aliases: []string{"docker"},
+		LineComment: "#", IndentSize: 4},
+	{ID: "diff", Name: "Diff", Extensions: []string{".diff", ".patch"}, Aliases: []string{"patch"}},
+	{ID: "csv", Name: "CSV", Extensions: []string{".csv", ".tsv"}},
+}
+
+// languageByID returns a language from the registry, or nil
+func languageByID(id string) *Language {
+	for i := range languages {
+		if languages[i].ID == id {
+			return &languages[i]
+		}
+	}
+	return nil
+}
+
+// languageByName finds a language by its ID, name or an alias, ignoring case
+func languageByName(name string) *Language {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+	for i := range languages {
+		lang := &languages[i]
+		if lang.ID == name || strings.ToLower(lang.Name) == name {
+			return lang
+		}
+		for _, alias := range lang.Aliases {
+			if alias == name {
+				return lang
+			}
+		}
+	}
+	return nil
+}
+
+// detectLanguage works out the language of a file from, in order, a
+// modeline, its name, its extension, a #! line and finally its content.
+// content may be just the start of the file.
+func detectLanguage(path string, content string) string {
+	if len(content) > languageSniffBytes {
+		content = content[:languageSniffBytes]
+	}
+
+	if lang := languageFromModeline(content); lang != nil {
+		return lang.ID
+	}
+
+	name := filepath.Base(path)
+	if path != "" {
+		for i := range languages {
+			for _, filename := range languages[i].Filenames {
+				if name == filename {
+					return languages[i].ID
+				}
+			}
+		}
+		// Dockerfile.dev and similar
+		if stem, _, ok := strings.Cut(name, "."); ok && (stem == "Dockerfile" || stem == "Containerfile") {
+			return "dockerfile"
+		}
+		if ext := strings.ToLower(filepath.Ext(name)); ext != "" {
+			for i := range languages {
+				for _, known := range languages[i].Extensions {
+					if ext == known {
+						return languages[i].ID
+					}
+				}
+			}
+		}
+	}
+
+	if lang := languageFromShebang(content); lang != nil {
+		return lang.ID
+	}
+	return languageFromContent(content)
+}
+
+// Modelines: "vim: set ft=python:", "vi: filetype=sh" and "-*- mode: ruby -*-"
+var (
+	vimModeline   = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex):.*?\b(?:ft|filetype|syntax)=([\w+#-]+)`)
+	emacsModeline = regexp.MustCompile(`-\*-\s*(?:.*?;\s*)?(?:mode:\s*)?([\w+#-]+)\s*(?:;.*?)?-\*-`)
+)
+
+// languageFromModeline reads a vim or emacs modeline from the first or
+// last five lines, where editors look for them
+func languageFromModeline(content string) *Language {
+	lines := strings.Split(content, "\n")
+	candidates := lines
+	if len(lines) > 10 {
+		candidates = append(append([]string{}, lines[:5]...), lines[len(lines)-5:]...)
+	}
+	for _, line := range candidates {
+		if m := vimModeline.FindStringSubmatch(line); m != nil {
+			if lang := languageByName(m[1]); lang != nil {
+				return lang
+			}
+		}
+		if m := emacsModeline.FindStringSubmatch(line); m != nil {
+			if lang := languageByName(m[1]); lang != nil {
+				return lang
+			}
+		}
+	}
+	return nil
+}
+
+// languageFromShebang reads the interpreter from a #! line, looking past
+// "env" and its options
+func languageFromShebang(content string) *Language {
+	line, _, _ := strings.Cut(content, "\n")
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return nil
+	}
+	program := filepath.Base(fields[0])
+	if program == "env" {
+		program = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				program = filepath.Base(field)
+				break
+			}
+		}
+	}
+	// python3.12 and the like
+	program = strings.TrimRight(program, "0123456789.")
+
+	for i := range languages {
+		for _, interpreter := range languages[i].Interpreters {
+			if strings.TrimRight(interpreter, "0123456789.") == program {
+				return &languages[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Content heuristics, tried in order
+var languageHints = []struct {
+	id      string
+	pattern *regexp.Regexp
+}{
+	{"php", regexp.MustCompile(`^\s*<\?php`)},
+	{"xml", regexp.MustCompile(`^\s*<\?xml`)},
+	{"html", regexp.MustCompile(`(?i)^\s*(?:<!doctype html|<html)`)},
+	{"go", regexp.MustCompile(`(?m)^package \w+\s*$[\s\S]*^(?:func|import|type|var|const) `)},
+	{"diff", regexp.MustCompile(`(?m)^(?:diff --git |--- \S.*\n\+\+\+ \S)`)},
+	{"c", regexp.MustCompile(`(?m)^#include\s*[<"]`)},
+	{"python", regexp.MustCompile(`(?m)^(?:def \w+\(.*\):|class \w+(?:\(.*\))?:|from [\w.]+ import |import \w+$)`)},
+	{"shell", regexp.MustCompile(`(?m)^(?:set -e|export \w+=|if \[ )`)},
+	{"markdown", regexp.MustCompile("(?m)^(?:#{1,6} \\S|```|\\[.+\\]\\(.+\\))")},
+}
+
+// languageFromContent guesses a language from what the text looks like
+func languageFromContent(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return PlainTextLanguage
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && len(content) < languageSniffBytes && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	for _, hint := range languageHints {
+		if hint.pattern.MatchString(content) {
+			return hint.id
+		}
+	}
+	return PlainTextLanguage
+}
+
+// fileDialogFilters builds the filters for the open and save dialogs
+// from the registry. The filter for first, if any, comes first so save
+// dialogs default to the file's own type.
+func fileDialogFilters(first string) []runtime.FileFilter {
+	allFiles := runtime.FileFilter{DisplayName: "All Files (*.*)", Pattern: "*.*"}
+	notes := runtime.FileFilter{DisplayName: "Encrypted Note (*" + EncryptedNoteExtension + ")", Pattern: "*" + EncryptedNoteExtension}
+
+	var filters []runtime.FileFilter
+	var firstFilter *runtime.FileFilter
+	for i := range languages {
+		lang := &languages[i]
+		if len(lang.Extensions) == 0 {
+			continue
+		}
+		patterns := make([]string, len(lang.Extensions))
+		for j, ext := range lang.Extensions {
+			patterns[j] = "*" + ext
+		}
+		filter := runtime.FileFilter{
+			DisplayName: lang.Name + " (" + strings.Join(patterns, ", ") + ")",
+			Pattern:     strings.Join(patterns, ";"),
+		}
+		if lang.ID == first {
+			firstFilter = &filter
+			continue
+		}
+		filters = append(filters, filter)
+	}
+	// Plain text stays at the top; the rest are alphabetical
+	sort.SliceStable(filters, func(i, j int) bool {
+		if strings.HasPrefix(filters[j].DisplayName, "Plain Text") {
+			return false
+		}
+		if strings.HasPrefix(filters[i].DisplayName, "Plain Text") {
+			return true
+		}
+		return filters[i].DisplayName < filters[j].DisplayName
+	})
+
+	if firstFilter != nil {
+		return append([]runtime.FileFilter{*firstFilter, allFiles, notes}, filters...)
+	}
+	return append([]runtime.FileFilter{allFiles, notes}, filters...)
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultLargeFileThresholdMB is the file size above which files open in
+// large-file mode unless EditorSettings.LargeFileThresholdMB says otherwise
+const defaultLargeFileThresholdMB = 50
+
+const (
+	largeFileIndexStride   = 256       // lines between index checkpoints
+	largeFileBufferSize    = 1 << 20   // read buffer for indexing and search
+	maxLargeFileLineBytes  = 64 * 1024 // longer lines are cut when served
+	maxSearchLineBytes     = 4 << 20   // longer lines are only searched this far
+	maxLargeFileReadLines  = 10000     // most lines one ReadLines call returns
+	maxLargeFileMatches    = 100000    // search stops after this many matches
+	largeFileSearchBatch   = 500       // matches per search event
+	largeFileEventInterval = 250 * time.Millisecond
+	largeFileMatchContext  = 80 // characters of the line kept around a match
+)
+
+// LargeFileProgress is published while a large file's line index is built
+type LargeFileProgress struct {
+	Path         string `json:"path"`
+	BytesIndexed int64  `json:"bytesIndexed"`
+	Size         int64  `json:"size"`
+	Lines        int    `json:"lines"` // lines indexed so far
+	Done         bool   `json:"done"`
+	Error        string `json:"error,omitempty"`
+}
+
+// LineRange is a run of lines read from a large file
+type LineRange struct {
+	Start      int      `json:"start"` // 0-based index of the first line
+	Lines      []string `json:"lines"`
+	TotalLines int      `json:"totalLines"` // lines indexed so far
+	Indexed    bool     `json:"indexed"`    // whether TotalLines is final
+	Truncated  bool     `json:"truncated"`  // a line was longer than the display limit
+}
+
+// LineMatch is one search hit in a large file
+type LineMatch struct {
+	Line       int    `json:"line"`       // 0-based
+	Column     int    `json:"column"`     // in characters, within Text
+	LineColumn int    `json:"lineColumn"` // in characters, within the whole line
+	Length     int    `json:"length"`     // in characters
+	Text       string `json:"text"`       // the line, cut down around the match if long
+}
+
+// LargeFileSearchResults is published as a large-file search progresses
+type LargeFileSearchResults struct {
+	RequestID     string      `json:"requestId"`
+	Path          string      `json:"path"`
+	Matches       []LineMatch `json:"matches"` // new since the previous event
+	BytesSearched int64       `json:"bytesSearched"`
+	Size          int64       `json:"size"`
+	Done          bool        `json:"done"`
+	Truncated     bool        `json:"truncated"` // stopped at maxLargeFileMatches
+	Error         string      `json:"error,omitempty"`
+}
+
+// largeFile is an open large file and its line index. Only every
+// largeFileIndexStride-th line's offset is kept, so the index stays small
+// for files with hundreds of millions of lines.
+type largeFile struct {
+	path     string
+	size     int64
+	encoding string
+	start    int64 // offset of the first line, after any byte order mark
+
+	mu          sync.RWMutex
+	checkpoints []int64 // offset of line i*largeFileIndexStride
+	lines       int     // complete lines indexed so far
+	done        bool
+	err         error
+	cancel      context.CancelFunc
+}
+
+// isLargeFile reports whether a file is big enough for large-file mode
+func (fm *FileManager) isLargeFile(filePath string) bool {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return false // reading it reports the error
+	}
+	return stat.Size() > fm.largeFileThreshold()
+}
+
+// largeFileThreshold returns the configured large-file threshold in bytes
+func (fm *FileManager) largeFileThreshold() int64 {
+	threshold := defaultLargeFileThresholdMB
+	if fm.app != nil && fm.app.SettingsManager != nil {
+		if mb := fm.app.SettingsManager.Get().Editor.LargeFileThresholdMB; mb > 0 {
+			threshold = mb
+		}
+	}
+	return int64(threshold) << 20
+}
+
+// OpenLargeFile opens a file in read-only large-file mode. Lines are
+// indexed in the background, publishing EventFileIndexProgress, and read
+// on demand with ReadLines. Files in UTF-16 or UTF-32 can't be split into
+// lines byte by byte and are refused.
+func (fm *FileManager) OpenLargeFile(filePath string) (*FileInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	// Detect the encoding from whole lines at the start of the file
+	sample := make([]byte, detectionSampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	sample = sample[:n]
+	if i := bytes.LastIndexByte(sample, '\n'); i > 0 {
+		sample = sample[:i+1]
+	}
+	detection := detectEncoding(sample)
+	enc, err := lookupEncoding(detection.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(enc.name, "UTF-16") || strings.HasPrefix(enc.name, "UTF-32") {
+		return nil, fmt.Errorf("large-file mode doesn't support %s", enc.name)
+	}
+
+	_, sampleLayout := splitLineEndings(string(sample))
+
+	lf := &largeFile{
+		path:        filePath,
+		size:        stat.Size(),
+		encoding:    detection.Encoding,
+		start:       int64(len(enc.bom)),
+		checkpoints: []int64{int64(len(enc.bom))},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	lf.cancel = cancel
+
+	fm.largeMu.Lock()
+	if old, ok := fm.largeFiles[filePath]; ok {
+		old.cancel()
+	}
+	fm.largeFiles[filePath] = lf
+	fm.largeMu.Unlock()
+
+	go lf.index(ctx, fm.emit)
+
+	fm.addToRecentFiles(filePath)
+
+	return &FileInfo{
+		Path:               filePath,
+		Name:               filepath.Base(filePath),
+		Encoding:           detection.Encoding,
+		EncodingConfidence: detection.Confidence,
+		LineEnding:         layoutStyle(sampleLayout),
+		Language:           detectLanguage(filePath, string(sample)),
+		LargeFile:          true,
+		ReadOnly:           true,
+		Size:               stat.Size(),
+		LastSaved:          stat.ModTime().Unix(),
+	}, nil
+}
+
+// getLargeFile returns an open large file
+func (fm *FileManager) getLargeFile(filePath string) (*largeFile, error) {
+	fm.largeMu.Lock()
+	defer fm.largeMu.Unlock()
+
+	lf, ok := fm.largeFiles[filePath]
+	if !ok {
+		return nil, fmt.Errorf("%s is not open in large-file mode", filepath.Base(filePath))
+	}
+	return lf, nil
+}
+
+// closeLargeFile stops indexing a large file and drops its index
+func (fm *FileManager) closeLargeFile(filePath string) {
+	fm.largeMu.Lock()
+	defer fm.largeMu.Unlock()
+
+	if lf, ok := fm.largeFiles[filePath]; ok {
+		lf.cancel()
+		delete(fm.largeFiles, filePath)
+	}
+}
+
+// index scans the file for line breaks, recording a checkpoint every
+// largeFileIndexStride lines and reporting progress as it goes
+func (lf *largeFile) index(ctx context.Context, emit func(string, interface{})) {
+	progress := func(offset int64, done bool, err error) {
+		lf.mu.RLock()
+		p := LargeFileProgress{Path: lf.path, BytesIndexed: offset, Size: lf.size, Lines: lf.lines, Done: done}
+		lf.mu.RUnlock()
+		if err != nil {
+			p.Error = err.Error()
+		}
+		emit(EventFileIndexProgress, p)
+	}
+
+	fail := func(offset int64, err error) {
+		lf.mu.Lock()
+		lf.err = err
+		lf.mu.Unlock()
+		progress(offset, true, err)
+	}
+
+	file, err := os.Open(lf.path)
+	if err != nil {
+		fail(0, fmt.Errorf("failed to open file: %w", err))
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(lf.start, io.SeekStart); err != nil {
+		fail(0, fmt.Errorf("failed to read file: %w", err))
+		return
+	}
+
+	buf := make([]byte, largeFileBufferSize)
+	offset := lf.start
+	lines := 0
+	lastEvent := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		n, err := file.Read(buf)
+		chunk := buf[:n]
+		var found []int64
+		for pos := 0; ; {
+			i := bytes.IndexByte(chunk[pos:], '\n')
+			if i < 0 {
+				break
+			}
+			pos += i + 1
+			lines++
+			if lines%largeFileIndexStride == 0 {
+				found = append(found, offset+int64(pos))
+			}
+		}
+		offset += int64(n)
+
+		lf.mu.Lock()
+		lf.lines = lines
+		lf.checkpoints = append(lf.checkpoints, found...)
+		lf.mu.Unlock()
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(offset, fmt.Errorf("failed to read file: %w", err))
+			return
+		}
+
+		if time.Since(lastEvent) >= largeFileEventInterval {
+			progress(offset, false, nil)
+			lastEvent = time.Now()
+		}
+	}
+
+	// The text after the last line break is a line too, even if empty
+	lf.mu.Lock()
+	lf.lines++
+	lf.done = true
+	lf.mu.Unlock()
+	progress(offset, true, nil)
+}
+
+// readLimitedLine reads one line, without its line break, keeping at most
+// limit bytes of it. The rest of a longer line is skipped.
+func readLimitedLine(r *bufio.Reader, limit int) ([]byte, bool, error) {
+	var line []byte
+	truncated := false
+	for {
+		part, err := r.ReadSlice('\n')
+		if err == nil {
+			part = part[:len(part)-1] // the line break
+		}
+		if room := limit - len(line); len(part) > room {
+			part = part[:max(room, 0)]
+			truncated = true
+		}
+		line = append(line, part...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+
+		if !truncated {
+			line = bytes.TrimSuffix(line, []byte("\r"))
+		} else {
+			// Don't end on half a character
+			for len(line) > 0 && !utf8.RuneStart(line[len(line)-1]) {
+				line = line[:len(line)-1]
+			}
+		}
+		return line, truncated, err
+	}
+}
+
+// ReadLines returns up to count lines of a large file starting at line
+// start (0-based). Lines past the indexed part of the file aren't served
+// until indexing reaches them.
+func (fm *FileManager) ReadLines(filePath string, start int, count int) (*LineRange, error) {
+	lf, err := fm.getLargeFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || count < 0 {
+		return nil, fmt.Errorf("invalid line range %d+%d", start, count)
+	}
+
+	lf.mu.RLock()
+	total, done, indexErr := lf.lines, lf.done, lf.err
+	checkpoint := lf.checkpoints[min(start/largeFileIndexStride, len(lf.checkpoints)-1)]
+	lf.mu.RUnlock()
+	if indexErr != nil {
+		return nil, indexErr
+	}
+
+	result := &LineRange{Start: start, Lines: []string{}, TotalLines: total, Indexed: done}
+	count = min(count, maxLargeFileReadLines, total-start)
+	if count <= 0 {
+		return result, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(checkpoint, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	reader := bufio.NewReaderSize(file, largeFileBufferSize)
+
+	// Skip from the checkpoint to the first wanted line
+	for skip := start % largeFileIndexStride; skip > 0; skip-- {
+		if _, _, err := readLimitedLine(reader, 0); err != nil {
+			if err == io.EOF {
+				return result, nil
+			}
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	for len(result.Lines) < count {
+		line, truncated, err := readLimitedLine(reader, maxLargeFileLineBytes)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		text, _, decodeErr := decodeText(line, lf.encoding)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		result.Lines = append(result.Lines, text)
+		result.Truncated = result.Truncated || truncated
+		if err == io.EOF {
+			break
+		}
+	}
+	return result, nil
+}
+
+// compileSearch builds the pattern for a search. Plain queries match literally.
+func compileSearch(query string, caseSensitive bool, useRegex bool) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty search")
+	}
+	if !useRegex {
+		query = regexp.QuoteMeta(query)
+	}
+	if !caseSensitive {
+		query = "(?i)" + query
+	}
+	re, err := regexp.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern: %w", err)
+	}
+	return re, nil
+}
+
+// matchExcerpt cuts a long line down to the text around a match, returning
+// the excerpt and the match's character offset within it
+func matchExcerpt(line string, matchStart int, matchEnd int) (string, int) {
+	before := line[:matchStart]
+	column := utf8.RuneCountInString(before)
+	if utf8.RuneCountInString(line) <= 2*largeFileMatchContext+utf8.RuneCountInString(line[matchStart:matchEnd]) {
+		return line, column
+	}
+
+	// Keep largeFileMatchContext characters either side of the match
+	from := matchStart
+	for kept := 0; from > 0 && kept < largeFileMatchContext; kept++ {
+		_, size := utf8.DecodeLastRuneInString(line[:from])
+		from -= size
+	}
+	to := matchEnd
+	for kept := 0; to < len(line) && kept < largeFileMatchContext; kept++ {
+		_, size := utf8.DecodeRuneInString(line[to:])
+		to += size
+	}
+	return line[from:to], utf8.RuneCountInString(line[from:matchStart])
+}
+
+// SearchLargeFile searches a large file line by line in the background,
+// publishing EventFileSearchResults in batches until the end of the file
+// or CancelLargeFileSearch. It doesn't wait for indexing.
+func (fm *FileManager) SearchLargeFile(requestID string, filePath string, query string, caseSensitive bool, useRegex bool) error {
+	lf, err := fm.getLargeFile(filePath)
+	if err != nil {
+		return err
+	}
+	re, err := compileSearch(query, caseSensitive, useRegex)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fm.largeMu.Lock()
+	if old, ok := fm.searches[requestID]; ok {
+		old()
+	}
+	fm.searches[requestID] = cancel
+	fm.largeMu.Unlock()
+
+	go func() {
+		defer func() {
+			fm.largeMu.Lock()
+			delete(fm.searches, requestID)
+			fm.largeMu.Unlock()
+			cancel()
+		}()
+
+		results := LargeFileSearchResults{RequestID: requestID, Path: filePath, Size: lf.size}
+		send := func() {
+			fm.emit(EventFileSearchResults, results)
+			results.Matches = nil
+		}
+		fail := func(err error) {
+			results.Done = true
+			results.Error = err.Error()
+			send()
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			fail(fmt.Errorf("failed to open file: %w", err))
+			return
+		}
+		defer file.Close()
+
+		if _, err := file.Seek(lf.start, io.SeekStart); err != nil {
+			fail(fmt.Errorf("failed to read file: %w", err))
+			return
+		}
+		counter := &countingReader{r: file, n: lf.start}
+		reader := bufio.NewReaderSize(counter, largeFileBufferSize)
+
+		found := 0
+		lastEvent := time.Now()
+		for line := 0; ; line++ {
+			if ctx.Err() != nil {
+				return
+			}
+
+			raw, _, err := readLimitedLine(reader, maxSearchLineBytes)
+			if err != nil && err != io.EOF {
+				fail(fmt.Errorf("failed to read file: %w", err))
+				return
+			}
+			text, _, decodeErr := decodeText(raw, lf.encoding)
+			if decodeErr != nil {
+				fail(decodeErr)
+				return
+			}
+
+			for _, loc := range re.FindAllStringIndex(text, -1) {
+				excerpt, column := matchExcerpt(text, loc[0], loc[1])
+				results.Matches = append(results.Matches, LineMatch{
+					Line:       line,
+					Column:     column,
+					Length:     utf8.RuneCountInString(text[loc[0]:loc[1]]),
+					Text:       excerpt,
+					LineColumn: utf8.RuneCountInString(text[:loc[0]]),
+				})
+				found++
+				if found >= maxLargeFileMatches {
+					results.Truncated = true
+					break
+				}
+			}
+
+			results.BytesSearched = counter.n - int64(reader.Buffered())
+			if err == io.EOF || results.Truncated {
+				break
+			}
+			if len(results.Matches) >= largeFileSearchBatch || time.Since(lastEvent) >= largeFileEventInterval {
+				send()
+				lastEvent = time.Now()
+			}
+		}
+
+		results.Done = true
+		send()
+	}()
+
+	return nil
+}
+
+// CancelLargeFileSearch stops a running large-file search
+func (fm *FileManager) CancelLargeFileSearch(requestID string) {
+	fm.largeMu.Lock()
+	defer fm.largeMu.Unlock()
+
+	if cancel, ok := fm.searches[requestID]; ok {
+		cancel()
+		delete(fm.searches, requestID)
+	}
+}
+
+// countingReader counts the bytes read through it, starting from n
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Line ending styles reported in FileInfo.LineEnding
+const (
+	LineEndingCRLF  = "CRLF"
+	LineEndingLF    = "LF"
+	LineEndingCR    = "CR"
+	LineEndingMixed = "Mixed" // more than one style; saving keeps each line's own ending
+)
+
+// defaultLineEnding is used for new files and files without any line break
+const defaultLineEnding = LineEndingCRLF
+
+// LineEndingCounts counts each kind of line ending in a file
+type LineEndingCounts struct {
+	CRLF int `json:"crlf"`
+	LF   int `json:"lf"`
+	CR   int `json:"cr"`
+}
+
+// style returns the single style used, Mixed, or "" if there are no line breaks
+func (c LineEndingCounts) style() string {
+	kinds := 0
+	style := ""
+	for _, kind := range []struct {
+		name  string
+		count int
+	}{{LineEndingCRLF, c.CRLF}, {LineEndingLF, c.LF}, {LineEndingCR, c.CR}} {
+		if kind.count > 0 {
+			kinds++
+			style = kind.name
+		}
+	}
+	if kinds > 1 {
+		return LineEndingMixed
+	}
+	return style
+}
+
+// dominant returns the most common style, preferring CRLF on ties
+func (c LineEndingCounts) dominant() string {
+	switch {
+	case c.CRLF == 0 && c.LF == 0 && c.CR == 0:
+		return defaultLineEnding
+	case c.CRLF >= c.LF && c.CRLF >= c.CR:
+		return LineEndingCRLF
+	case c.LF >= c.CR:
+		return LineEndingLF
+	default:
+		return LineEndingCR
+	}
+}
+
+// lineLayout remembers the original ending of every line of an open file
+// so a mixed file can be saved without rewriting the lines that weren't edited
+type lineLayout struct {
+	hashes  []uint64 // one per line, including the text after the last break
+	endings []string // the break after each line; one fewer than hashes
+	counts  LineEndingCounts
+}
+
+// lineSeparators maps a style to the characters written for it
+var lineSeparators = map[string]string{
+	LineEndingCRLF: "\r\n",
+	LineEndingLF:   "\n",
+	LineEndingCR:   "\r",
+}
+
+// hashLine identifies a line's content for matching after edits
+func hashLine(line string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(line))
+	return h.Sum64()
+}
+
+// splitLineEndings converts every line break in text to "\n" and records
+// the original ending of each line
+func splitLineEndings(text string) (string, *lineLayout) {
+	layout := &lineLayout{}
+	var content strings.Builder
+	content.Grow(len(text))
+
+	start := 0
+	for i := 0; i < len(text); i++ {
+		var ending string
+		switch text[i] {
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				ending = "\r\n"
+				layout.counts.CRLF++
+			} else {
+				ending = "\r"
+				layout.counts.CR++
+			}
+		case '\n':
+			ending = "\n"
+			layout.counts.LF++
+		default:
+			continue
+		}
+
+		line := text[start:i]
+		layout.hashes = append(layout.hashes, hashLine(line))
+		layout.endings = append(layout.endings, ending)
+		content.WriteString(line)
+		content.WriteByte('\n')
+
+		i += len(ending) - 1
+		start = i + 1
+	}
+
+	last := text[start:]
+	layout.hashes = append(layout.hashes, hashLine(last))
+	content.WriteString(last)
+
+	return content.String(), layout
+}
+
+// applyLineLayout writes content, whose lines are separated by "\n", with
+// the original endings of the layout. A line that is unchanged, or edited
+// in place, keeps the break that followed it, and the break before such a
+// line stays too. Breaks between inserted lines take the most common one.
+func applyLineLayout(content string, layout *lineLayout) string {
+	lines := strings.Split(content, "\n")
+	fallback := lineSeparators[layout.counts.dominant()]
+	origin := matchLayoutLines(lines, layout.hashes)
+
+	var out strings.Builder
+	out.Grow(len(content) + len(lines))
+	for i, line := range lines {
+		out.WriteString(line)
+		if i == len(lines)-1 {
+			break
+		}
+
+		ending := fallback
+		switch {
+		case origin[i] != -1 && origin[i] < len(layout.endings):
+			// The break after a line that was already there
+			ending = layout.endings[origin[i]]
+		case origin[i+1] > 0:
+			// The break before one
+			ending = layout.endings[origin[i+1]-1]
+		}
+		out.WriteString(ending)
+	}
+	return out.String()
+}
+
+// matchLayoutLines returns, for each line, the index of the original line
+// it stands for, or -1 for an inserted line. Unchanged lines are matched by
+// content; between two of them, a run of edited lines as long as the run
+// it replaced is matched line for line.
+func matchLayoutLines(lines []string, hashes []uint64) []int {
+	current := make([]string, len(lines))
+	for i, line := range lines {
+		current[i] = strconv.FormatUint(hashLine(line), 16)
+	}
+	original := make([]string, len(hashes))
+	for i, hash := range hashes {
+		original[i] = strconv.FormatUint(hash, 16)
+	}
+	origin := diffMatches(current, original)
+
+	prevLine, prevOrigin := -1, -1
+	for i := 0; i <= len(origin); i++ {
+		if i < len(origin) && origin[i] == -1 {
+			continue
+		}
+		nextOrigin := len(hashes)
+		if i < len(origin) {
+			nextOrigin = origin[i]
+		}
+		if i-prevLine == nextOrigin-prevOrigin {
+			for j := prevLine + 1; j < i; j++ {
+				origin[j] = prevOrigin + j - prevLine
+			}
+		}
+		if i < len(origin) {
+			prevLine, prevOrigin = i, origin[i]
+		}
+	}
+	return origin
+}
+
+// normalizeLineEndings converts every line break in text to one style
+func normalizeLineEndings(text string, style string) (string, error) {
+	separator, ok := lineSeparators[style]
+	if !ok {
+		return "", fmt.Errorf("unsupported line ending: %s", style)
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	if separator == "\n" {
+		return text, nil
+	}
+	return strings.ReplaceAll(text, "\n", separator), nil
+}
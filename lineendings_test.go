@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+func TestSplitLineEndings(t *testing.T) {
+	tests := []struct {
+		text        string
+		wantContent string
+		wantCounts  LineEndingCounts
+		wantStyle   string
+	}{
+		{"", "", LineEndingCounts{}, ""},
+		{"no breaks", "no breaks", LineEndingCounts{}, ""},
+		{"a\r\nb\r\n", "a\nb\n", LineEndingCounts{CRLF: 2}, LineEndingCRLF},
+		{"a\nb", "a\nb", LineEndingCounts{LF: 1}, LineEndingLF},
+		{"a\rb\r", "a\nb\n", LineEndingCounts{CR: 2}, LineEndingCR},
+		{"a\r\nb\nc\rd", "a\nb\nc\nd", LineEndingCounts{CRLF: 1, LF: 1, CR: 1}, LineEndingMixed},
+		{"\r\r\n\n", "\n\n\n", LineEndingCounts{CRLF: 1, LF: 1, CR: 1}, LineEndingMixed},
+	}
+
+	for _, tt := range tests {
+		content, layout := splitLineEndings(tt.text)
+		if content != tt.wantContent {
+			t.Errorf("splitLineEndings(%q) content = %q, want %q", tt.text, content, tt.wantContent)
+		}
+		if layout.counts != tt.wantCounts {
+			t.Errorf("splitLineEndings(%q) counts = %+v, want %+v", tt.text, layout.counts, tt.wantCounts)
+		}
+		if style := layout.counts.style(); style != tt.wantStyle {
+			t.Errorf("splitLineEndings(%q) style = %q, want %q", tt.text, style, tt.wantStyle)
+		}
+		if len(layout.hashes) != len(layout.endings)+1 {
+			t.Errorf("splitLineEndings(%q) has %d lines and %d endings", tt.text, len(layout.hashes), len(layout.endings))
+		}
+
+		// Writing the content back unchanged restores every ending
+		if got := applyLineLayout(content, layout); got != tt.text {
+			t.Errorf("applyLineLayout(splitLineEndings(%q)) = %q", tt.text, got)
+		}
+	}
+}
+
+func TestApplyLineLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		edited   string // with "\n" breaks, as the editor has it
+		want     string
+	}{
+		{
+			name:     "edited line in a mixed file",
+			original: "a\r\nb\nc\r\nd",
+			edited:   "a\nB\nc\nd",
+			want:     "a\r\nB\nc\r\nd",
+		},
+		{
+			name:     "inserted line takes the ending of the line it displaced",
+			original: "a\r\nb\nc",
+			edited:   "a\nnew\nb\nc",
+			want:     "a\r\nnew\r\nb\nc",
+		},
+		{
+			name:     "appended line uses the dominant ending",
+			original: "x\ny\r\nz\n",
+			edited:   "x\ny\nz\nadded\n",
+			want:     "x\ny\r\nz\nadded\n",
+		},
+		{
+			name:     "deleted line",
+			original: "a\rb\r\nc\n",
+			edited:   "a\nc\n",
+			want:     "a\rc\n",
+		},
+		{
+			name:     "unchanged line between two edits",
+			original: "x foo\r\ny\nfoo\r",
+			edited:   "x bar\ny\nbar\n",
+			want:     "x bar\r\ny\nbar\r",
+		},
+		{
+			name:     "every line edited in place",
+			original: "a\rb\nc\r\n",
+			edited:   "A\nB\nC\n",
+			want:     "A\rB\nC\r\n",
+		},
+		{
+			name:     "line inserted between edits",
+			original: "a\r\nb\nc\rd",
+			edited:   "A\nb\nnew\nc\nd",
+			want:     "A\r\nb\nnew\nc\rd",
+		},
+		{
+			name:     "everything replaced",
+			original: "a\nb\nc\r\n",
+			edited:   "x\ny",
+			want:     "x\ny",
+		},
+		{
+			name:     "breaks added to a file without any",
+			original: "single",
+			edited:   "single\nline",
+			want:     "single\r\nline",
+		},
+	}
+
+	for _, tt := range tests {
+		_, layout := splitLineEndings(tt.original)
+		if got := applyLineLayout(tt.edited, layout); got != tt.want {
+			t.Errorf("%s: applyLineLayout = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
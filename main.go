@@ -2,6 +2,8 @@ package main
 
 import (
 	"embed"
+	"fmt"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,11 +14,31 @@ import (
 var assets embed.FS
 
 func main() {
+	cwd, _ := os.Getwd()
+	launch, err := parseArgs(os.Args[1:], cwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "akashic: %v\n\n%s", err, usage)
+		os.Exit(2)
+	}
+	if launch.Help {
+		fmt.Print(usage)
+		return
+	}
+
+	// Hand the files to the window that is already open, if there is one
+	if !launch.NewWindow && forwardLaunch(launch.Request, launch.Wait) {
+		return
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
+	if err := app.listenForInstances(); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+	app.openAtLaunch(launch)
 
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:     "Akashic",
 		Width:     800,
 		Height:    800,
@@ -0,0 +1,146 @@
+// Package markdown parses CommonMark, with GitHub's tables, task lists,
+// strikethrough and bare links, into a tree that renders to sanitized
+// HTML and that exporters can lay out in their own formats
+package markdown
+
+import "strings"
+
+// NodeKind is what a node of the tree is
+type NodeKind int
+
+// Block kinds
+const (
+	Document NodeKind = iota
+	Paragraph
+	Heading
+	ThematicBreak
+	BlockQuote
+	List
+	ListItem
+	CodeBlock
+	HTMLBlock
+	Table
+	TableRow
+	TableCell
+)
+
+// Inline kinds
+const (
+	Text NodeKind = iota + 100
+	SoftBreak
+	HardBreak
+	Code
+	Emphasis
+	Strong
+	Strikethrough
+	Link
+	Image
+	RawHTML
+)
+
+// Alignment is how a table column is aligned
+type Alignment int
+
+// Column alignments from a table's delimiter row
+const (
+	AlignNone Alignment = iota
+	AlignLeft
+	AlignCenter
+	AlignRight
+)
+
+// Node is a block or inline element. Which fields are set depends on its kind.
+type Node struct {
+	Kind     NodeKind
+	Children []*Node
+
+	// Line and EndLine are the 1-based source lines a block spans; they
+	// are 0 for inline nodes
+	Line    int
+	EndLine int
+
+	Literal string // the text of Text, Code, CodeBlock, HTMLBlock and RawHTML
+
+	Level int    // of a Heading, 1 to 6
+	Info  string // a fenced CodeBlock's info string, its first word being the language
+
+	Ordered   bool // a List numbered rather than bulleted
+	Start     int  // the first number of an ordered List
+	Tight     bool // a List whose items aren't separated by blank lines
+	Delimiter byte // '.' or ')' after the numbers of an ordered List, or the bullet character
+
+	Task    bool // a ListItem starting with [ ] or [x]
+	Checked bool
+
+	Destination string // of a Link or Image
+	Title       string
+
+	Align  Alignment   // of a TableCell
+	Header bool        // a TableRow or TableCell of the header row
+	Aligns []Alignment // of each column of a Table
+
+	parent *Node
+	open   bool
+	state  *blockState // while the block is being parsed
+}
+
+// Walk visits a node and its descendants depth first, skipping the
+// children of any node for which visit returns false
+func Walk(node *Node, visit func(*Node) bool) {
+	if !visit(node) {
+		return
+	}
+	for _, child := range node.Children {
+		Walk(child, visit)
+	}
+}
+
+// PlainText returns the text of a node without any markup. Line breaks
+// within a paragraph are kept as newlines and images give their alt text.
+func (n *Node) PlainText() string {
+	var b strings.Builder
+	n.writePlainText(&b)
+	return b.String()
+}
+
+func (n *Node) writePlainText(b *strings.Builder) {
+	switch n.Kind {
+	case Text, Code, RawHTML, CodeBlock, HTMLBlock:
+		b.WriteString(n.Literal)
+		return
+	case SoftBreak, HardBreak:
+		b.WriteByte('\n')
+		return
+	}
+	for i, child := range n.Children {
+		// Separate blocks, and the cells of a row
+		if i > 0 && (child.Kind < Text || n.Kind == TableRow) {
+			if n.Kind == TableRow {
+				b.WriteString(" | ")
+			} else {
+				b.WriteByte('\n')
+			}
+		}
+		child.writePlainText(b)
+	}
+}
+
+// Language returns the first word of a code block's info string
+func (n *Node) Language() string {
+	if fields := strings.Fields(n.Info); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func (n *Node) lastChild() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[len(n.Children)-1]
+}
+
+func (n *Node) appendChild(child *Node) {
+	child.parent = n
+	n.Children = append(n.Children, child)
+}
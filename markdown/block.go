@@ -0,0 +1,893 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// codeIndent is how far a line must be indented to be code
+const codeIndent = 4
+
+var (
+	reATXHeading     = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	reATXClosingOnly = regexp.MustCompile(`^[ \t]*#+[ \t]*$`)
+	reATXClosing     = regexp.MustCompile(`[ \t]+#+[ \t]*$`)
+	reSetextHeading  = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	reThematicBreak  = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:_[ \t]*){3,}|(?:-[ \t]*){3,})$`)
+	reOrderedMarker  = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	reTaskMarker     = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+|$)`)
+	reDelimiterCell  = regexp.MustCompile(`^:?-+:?$`)
+)
+
+// htmlBlockStarts and htmlBlockEnds are the seven kinds of HTML block. The
+// last two end at a blank line, and the last can't interrupt a paragraph.
+var (
+	htmlBlockStarts = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^<(?:script|pre|textarea|style)(?:\s|>|$)`),
+		regexp.MustCompile(`^<!--`),
+		regexp.MustCompile(`^<[?]`),
+		regexp.MustCompile(`^<![A-Za-z]`),
+		regexp.MustCompile(`^<!\[CDATA\[`),
+		regexp.MustCompile(`(?i)^</?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[1-6]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:\s|/?>|$)`),
+		regexp.MustCompile(`^(?:` + openTag + `|` + closeTag + `)[ \t]*$`),
+	}
+	htmlBlockEnds = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)</(?:script|pre|textarea|style)>`),
+		regexp.MustCompile(`-->`),
+		regexp.MustCompile(`\?>`),
+		regexp.MustCompile(`>`),
+		regexp.MustCompile(`\]\]>`),
+		nil,
+		nil,
+	}
+)
+
+// blockState is what the parser keeps about a block while it is open
+type blockState struct {
+	lines       []string // of a paragraph, heading, code block, HTML block or table
+	lineNumbers []int    // of each row of a table
+
+	fenceChar   byte // of a fenced code block
+	fenceLength int
+	fenceOffset int
+
+	contentIndent int // of a list item's content
+	htmlEnd       *regexp.Regexp
+
+	lastLineBlank bool
+}
+
+// linkReference is a link reference definition such as [label]: /url "title"
+type linkReference struct {
+	destination string
+	title       string
+}
+
+// listMarker is a bullet or number starting a list item
+type listMarker struct {
+	ordered   bool
+	start     int
+	delimiter byte
+	offset    int // indent of the marker
+	padding   int // from the marker to the content
+}
+
+// blockParser builds the block structure one line at a time, as the
+// CommonMark spec describes
+type blockParser struct {
+	doc  *Node
+	tip  *Node // the deepest open block
+	refs map[string]linkReference
+
+	lineNo       int
+	line         string
+	offset       int
+	nextNonspace int
+	indent       int
+	indented     bool
+	blank        bool
+
+	oldTip      *Node
+	lastMatched *Node
+	allClosed   bool
+}
+
+// Parse parses Markdown into a document tree
+func Parse(source string) *Node {
+	p := &blockParser{refs: make(map[string]linkReference)}
+	p.doc = &Node{Kind: Document, Line: 1, open: true, state: &blockState{}}
+	p.tip = p.doc
+
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+	lines := strings.Split(source, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		p.incorporateLine(line)
+	}
+	for p.tip != nil {
+		p.finalize(p.tip, p.lineNo)
+	}
+
+	p.parseInlines(p.doc)
+	return p.doc
+}
+
+// expandLeadingTabs turns tabs in a line's indentation and block quote
+// markers into spaces, to the next multiple of four columns
+func expandLeadingTabs(line string) string {
+	end := 0
+	for end < len(line) && (line[end] == ' ' || line[end] == '\t' || line[end] == '>') {
+		end++
+	}
+	if !strings.Contains(line[:end], "\t") {
+		return line
+	}
+	var b strings.Builder
+	column := 0
+	for i := 0; i < end; i++ {
+		if line[i] == '\t' {
+			spaces := 4 - column%4
+			b.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		}
+		b.WriteByte(line[i])
+		column++
+	}
+	b.WriteString(line[end:])
+	return b.String()
+}
+
+func (p *blockParser) findNextNonspace() {
+	i := p.offset
+	for i < len(p.line) && p.line[i] == ' ' {
+		i++
+	}
+	p.nextNonspace = i
+	p.indent = i - p.offset
+	p.indented = p.indent >= codeIndent
+	p.blank = strings.TrimSpace(p.line[i:]) == ""
+}
+
+func (p *blockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+}
+
+// peek returns the first character after the indentation, or 0
+func (p *blockParser) peek() byte {
+	if p.nextNonspace < len(p.line) {
+		return p.line[p.nextNonspace]
+	}
+	return 0
+}
+
+// incorporateLine adds a line to the open blocks, closing those it doesn't
+// continue and opening any it starts
+func (p *blockParser) incorporateLine(line string) {
+	p.lineNo++
+	p.line = expandLeadingTabs(line)
+	p.offset = 0
+	p.oldTip = p.tip
+
+	// Find the deepest open block the line continues
+	container := p.doc
+matching:
+	for {
+		last := container.lastChild()
+		if last == nil || !last.open {
+			break
+		}
+		p.findNextNonspace()
+		switch p.continueBlock(last) {
+		case continued:
+			container = last
+		case notContinued:
+			break matching
+		case consumed:
+			return
+		}
+	}
+	p.allClosed = container == p.oldTip
+	p.lastMatched = container
+
+	// Open any new blocks the line starts
+	matchedLeaf := container.Kind != Paragraph && container.Kind != Table && acceptsLines(container.Kind)
+	for !matchedLeaf {
+		p.findNextNonspace()
+		if !p.indented && !strings.ContainsRune("#`~*+_=<>0123456789-|:", rune(p.peek())) {
+			p.advanceNextNonspace()
+			break
+		}
+		started := notStarted
+		for _, start := range blockStarts {
+			if started = start(p, container); started != notStarted {
+				break
+			}
+		}
+		if started == notStarted {
+			p.advanceNextNonspace()
+			break
+		}
+		container = p.tip
+		if started == startedLeaf {
+			break
+		}
+	}
+
+	// What's left is text: a lazy continuation of a paragraph, a line of
+	// a block that takes lines, or the start of a new paragraph
+	if !p.allClosed && !p.blank && p.tip.Kind == Paragraph {
+		p.addLine()
+		return
+	}
+	p.closeUnmatchedBlocks()
+	if p.blank && container.lastChild() != nil {
+		container.lastChild().state.lastLineBlank = true
+	}
+	lastLineBlank := p.blank && !(container.Kind == BlockQuote ||
+		(container.Kind == CodeBlock && container.state.fenceLength > 0) ||
+		(container.Kind == ListItem && len(container.Children) == 0 && container.Line == p.lineNo))
+	for c := container; c != nil; c = c.parent {
+		c.state.lastLineBlank = lastLineBlank
+	}
+
+	switch {
+	case acceptsLines(container.Kind):
+		p.addLine()
+		if container.Kind == HTMLBlock && container.state.htmlEnd != nil && container.state.htmlEnd.MatchString(p.line[p.offset:]) {
+			p.finalize(container, p.lineNo)
+		}
+	case container.Kind == Table:
+		if p.offset < len(p.line) && !p.blank {
+			p.addLine()
+		}
+	case p.offset < len(p.line) && !p.blank:
+		p.addChild(Paragraph)
+		p.advanceNextNonspace()
+		p.addLine()
+	}
+}
+
+// How an open block took a line
+const (
+	continued = iota
+	notContinued
+	consumed // the line closed the block and there's nothing left of it
+)
+
+// continueBlock consumes the part of the line that continues an open
+// block, such as a block quote's ">"
+func (p *blockParser) continueBlock(n *Node) int {
+	switch n.Kind {
+	case BlockQuote:
+		if p.indented || p.peek() != '>' {
+			return notContinued
+		}
+		p.advanceNextNonspace()
+		p.offset++
+		if p.offset < len(p.line) && p.line[p.offset] == ' ' {
+			p.offset++
+		}
+	case ListItem:
+		switch {
+		case p.blank:
+			if len(n.Children) == 0 {
+				return notContinued // a list item can begin with at most one blank line
+			}
+			p.advanceNextNonspace()
+		case p.indent >= n.state.contentIndent:
+			p.offset += n.state.contentIndent
+		default:
+			return notContinued
+		}
+	case Heading, ThematicBreak:
+		return notContinued
+	case CodeBlock:
+		if n.state.fenceLength > 0 {
+			rest := p.line[p.nextNonspace:]
+			run := countRun(rest, n.state.fenceChar)
+			if p.indent <= 3 && run >= n.state.fenceLength && strings.Trim(rest[run:], " \t") == "" {
+				p.finalize(n, p.lineNo)
+				return consumed
+			}
+			for i := n.state.fenceOffset; i > 0 && p.offset < len(p.line) && p.line[p.offset] == ' '; i-- {
+				p.offset++
+			}
+		} else {
+			switch {
+			case p.indent >= codeIndent:
+				p.offset += codeIndent
+			case p.blank:
+				p.advanceNextNonspace()
+			default:
+				return notContinued
+			}
+		}
+	case HTMLBlock:
+		if p.blank && n.state.htmlEnd == nil {
+			return notContinued
+		}
+	case Paragraph, Table:
+		if p.blank {
+			return notContinued
+		}
+	}
+	return continued
+}
+
+// acceptsLines reports whether a block's content is its lines
+func acceptsLines(kind NodeKind) bool {
+	return kind == Paragraph || kind == CodeBlock || kind == HTMLBlock
+}
+
+// canContain reports whether a block can hold a child block
+func canContain(parent NodeKind, child NodeKind) bool {
+	switch parent {
+	case Document, BlockQuote, ListItem:
+		return child != ListItem
+	case List:
+		return child == ListItem
+	}
+	return false
+}
+
+func (p *blockParser) addLine() {
+	p.tip.state.lines = append(p.tip.state.lines, p.line[p.offset:])
+}
+
+// addChild opens a block under the tip, closing blocks that can't hold it
+func (p *blockParser) addChild(kind NodeKind) *Node {
+	for !canContain(p.tip.Kind, kind) {
+		p.finalize(p.tip, p.lineNo-1)
+	}
+	n := &Node{Kind: kind, Line: p.lineNo, open: true, state: &blockState{}}
+	p.tip.appendChild(n)
+	p.tip = n
+	return n
+}
+
+// closeUnmatchedBlocks closes the blocks the current line didn't continue
+func (p *blockParser) closeUnmatchedBlocks() {
+	if p.allClosed {
+		return
+	}
+	for p.oldTip != p.lastMatched {
+		parent := p.oldTip.parent
+		p.finalize(p.oldTip, p.lineNo-1)
+		p.oldTip = parent
+	}
+	p.allClosed = true
+}
+
+// replaceNode puts a new block in place of an open one
+func (p *blockParser) replaceNode(old *Node, n *Node) {
+	parent := old.parent
+	for i, child := range parent.Children {
+		if child == old {
+			parent.Children[i] = n
+		}
+	}
+	n.parent = parent
+	p.tip = n
+}
+
+// finalize closes a block and works out its content from its lines
+func (p *blockParser) finalize(n *Node, endLine int) {
+	parent := n.parent
+	n.open = false
+	n.EndLine = max(endLine, n.Line)
+
+	switch n.Kind {
+	case Paragraph:
+		if content := p.extractReferences(n); strings.TrimSpace(content) == "" {
+			removeChild(parent, n)
+		}
+	case CodeBlock:
+		lines := n.state.lines
+		if n.state.fenceLength > 0 {
+			if len(lines) > 0 {
+				n.Info = unescapeString(strings.TrimSpace(lines[0]))
+				lines = lines[1:]
+			}
+		} else {
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+		}
+		if len(lines) > 0 {
+			n.Literal = strings.Join(lines, "\n") + "\n"
+		}
+	case HTMLBlock:
+		n.Literal = strings.TrimRight(strings.Join(n.state.lines, "\n"), "\n")
+	case List:
+		n.Tight = listIsTight(n)
+	case Table:
+		p.buildTable(n)
+	}
+	p.tip = parent
+}
+
+func removeChild(parent *Node, n *Node) {
+	for i, child := range parent.Children {
+		if child == n {
+			parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+			return
+		}
+	}
+}
+
+// countRun counts the repeats of c at the start of s
+func countRun(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// endsWithBlankLine reports whether a block, or the last item of a list
+// in it, ended with a blank line
+func endsWithBlankLine(n *Node) bool {
+	for n != nil {
+		if n.state.lastLineBlank {
+			return true
+		}
+		if n.Kind != List && n.Kind != ListItem {
+			return false
+		}
+		n = n.lastChild()
+	}
+	return false
+}
+
+// listIsTight reports whether no items, nor blocks within them, are
+// separated by blank lines
+func listIsTight(list *Node) bool {
+	for i, item := range list.Children {
+		lastItem := i == len(list.Children)-1
+		if endsWithBlankLine(item) && !lastItem {
+			return false
+		}
+		for j, child := range item.Children {
+			if endsWithBlankLine(child) && (!lastItem || j < len(item.Children)-1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// blockStarts try in turn to open a block at the current position
+var blockStarts = []func(p *blockParser, container *Node) int{
+	startBlockQuote,
+	startATXHeading,
+	startFencedCode,
+	startHTMLBlock,
+	startTable,
+	startSetextHeading,
+	startThematicBreak,
+	startListItem,
+	startIndentedCode,
+}
+
+// What a block start found
+const (
+	notStarted = iota
+	startedContainer
+	startedLeaf
+)
+
+func startBlockQuote(p *blockParser, container *Node) int {
+	if p.indented || p.peek() != '>' {
+		return notStarted
+	}
+	p.advanceNextNonspace()
+	p.offset++
+	if p.offset < len(p.line) && p.line[p.offset] == ' ' {
+		p.offset++
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(BlockQuote)
+	return startedContainer
+}
+
+func startATXHeading(p *blockParser, container *Node) int {
+	if p.indented {
+		return notStarted
+	}
+	rest := p.line[p.nextNonspace:]
+	marker := reATXHeading.FindString(rest)
+	if marker == "" {
+		return notStarted
+	}
+	p.advanceNextNonspace()
+	p.closeUnmatchedBlocks()
+	heading := p.addChild(Heading)
+	heading.Level = len(strings.TrimRight(marker, " \t"))
+	content := rest[len(marker):]
+	if reATXClosingOnly.MatchString(content) {
+		content = ""
+	} else {
+		content = reATXClosing.ReplaceAllString(content, "")
+	}
+	heading.state.lines = []string{strings.TrimSpace(content)}
+	p.offset = len(p.line)
+	return startedLeaf
+}
+
+func startFencedCode(p *blockParser, container *Node) int {
+	if p.indented {
+		return notStarted
+	}
+	rest := p.line[p.nextNonspace:]
+	fence := p.peek()
+	if fence != '`' && fence != '~' {
+		return notStarted
+	}
+	run := countRun(rest, fence)
+	if run < 3 || (fence == '`' && strings.Contains(rest[run:], "`")) {
+		return notStarted
+	}
+	p.closeUnmatchedBlocks()
+	code := p.addChild(CodeBlock)
+	code.state.fenceChar = fence
+	code.state.fenceLength = run
+	code.state.fenceOffset = p.indent
+	p.advanceNextNonspace()
+	p.offset += run // the info string is the first line
+	return startedLeaf
+}
+
+func startHTMLBlock(p *blockParser, container *Node) int {
+	if p.indented || p.peek() != '<' {
+		return notStarted
+	}
+	rest := p.line[p.nextNonspace:]
+	for kind, start := range htmlBlockStarts {
+		// The last kind can't interrupt a paragraph, even lazily
+		if !start.MatchString(rest) || (kind == 6 && (container.Kind == Paragraph || (!p.allClosed && p.tip.Kind == Paragraph))) {
+			continue
+		}
+		p.closeUnmatchedBlocks()
+		block := p.addChild(HTMLBlock)
+		block.state.htmlEnd = htmlBlockEnds[kind]
+		return startedLeaf
+	}
+	return notStarted
+}
+
+// startTable turns the last line of a paragraph into a table's header row
+// when the line after it is a delimiter row with as many cells
+func startTable(p *blockParser, container *Node) int {
+	if p.indented || container.Kind != Paragraph || len(container.state.lines) == 0 {
+		return notStarted
+	}
+	delimiterRow := p.line[p.nextNonspace:]
+	if !strings.Contains(delimiterRow, "|") {
+		return notStarted
+	}
+	aligns, ok := parseDelimiterRow(delimiterRow)
+	if !ok {
+		return notStarted
+	}
+	lines := container.state.lines
+	header := lines[len(lines)-1]
+	if len(splitTableRow(header)) != len(aligns) {
+		return notStarted
+	}
+
+	p.closeUnmatchedBlocks()
+	table := &Node{
+		Kind:   Table,
+		Line:   p.lineNo - 1,
+		Aligns: aligns,
+		open:   true,
+		state:  &blockState{lines: []string{header}, lineNumbers: []int{p.lineNo - 1}},
+	}
+	if len(lines) == 1 {
+		p.replaceNode(container, table)
+	} else {
+		container.state.lines = lines[:len(lines)-1]
+		p.finalize(container, p.lineNo-2)
+		p.tip.appendChild(table)
+		p.tip = table
+	}
+	p.offset = len(p.line)
+	return startedLeaf
+}
+
+// parseDelimiterRow reads the alignments from a table's delimiter row
+func parseDelimiterRow(row string) ([]Alignment, bool) {
+	cells := splitTableRow(row)
+	if len(cells) == 0 {
+		return nil, false
+	}
+	aligns := make([]Alignment, len(cells))
+	for i, cell := range cells {
+		if !reDelimiterCell.MatchString(cell) {
+			return nil, false
+		}
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[i] = AlignCenter
+		case right:
+			aligns[i] = AlignRight
+		case left:
+			aligns[i] = AlignLeft
+		}
+	}
+	return aligns, true
+}
+
+// splitTableRow splits a table row into its trimmed cells at pipes that
+// aren't escaped. An escaped pipe becomes part of the cell.
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// buildTable turns a table's lines into rows of cells, as many in each
+// row as the header has
+func (p *blockParser) buildTable(table *Node) {
+	for i, line := range table.state.lines {
+		lineNo := table.Line
+		if i > 0 {
+			lineNo = table.state.lineNumbers[0] + i + 1 // after the delimiter row
+		}
+		row := &Node{Kind: TableRow, Line: lineNo, EndLine: lineNo, Header: i == 0, state: &blockState{}}
+		cells := splitTableRow(line)
+		for column, align := range table.Aligns {
+			cell := &Node{Kind: TableCell, Line: lineNo, EndLine: lineNo, Align: align, Header: i == 0, state: &blockState{}}
+			if column < len(cells) {
+				cell.state.lines = []string{cells[column]}
+			}
+			row.appendChild(cell)
+		}
+		table.appendChild(row)
+	}
+}
+
+func startSetextHeading(p *blockParser, container *Node) int {
+	if p.indented || container.Kind != Paragraph {
+		return notStarted
+	}
+	underline := reSetextHeading.FindString(p.line[p.nextNonspace:])
+	if underline == "" {
+		return notStarted
+	}
+	p.closeUnmatchedBlocks()
+	// Link reference definitions before the text aren't part of the heading
+	content := p.extractReferences(container)
+	if strings.TrimSpace(content) == "" {
+		return notStarted
+	}
+	heading := &Node{Kind: Heading, Line: container.Line, open: true, state: &blockState{lines: strings.Split(content, "\n")}}
+	heading.Level = 2
+	if underline[0] == '=' {
+		heading.Level = 1
+	}
+	p.replaceNode(container, heading)
+	p.offset = len(p.line)
+	return startedLeaf
+}
+
+func startThematicBreak(p *blockParser, container *Node) int {
+	if p.indented || !reThematicBreak.MatchString(p.line[p.nextNonspace:]) {
+		return notStarted
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(ThematicBreak)
+	p.offset = len(p.line)
+	return startedLeaf
+}
+
+func startListItem(p *blockParser, container *Node) int {
+	if p.indented && container.Kind != List {
+		return notStarted
+	}
+	marker, ok := p.parseListMarker(container)
+	if !ok {
+		return notStarted
+	}
+	p.closeUnmatchedBlocks()
+	if p.tip.Kind != List || !listMatches(p.tip, marker) {
+		list := p.addChild(List)
+		list.Ordered = marker.ordered
+		list.Start = marker.start
+		list.Delimiter = marker.delimiter
+		list.Tight = true
+	}
+	item := p.addChild(ListItem)
+	item.state.contentIndent = marker.offset + marker.padding
+	return startedContainer
+}
+
+// listMatches reports whether a list item continues a list
+func listMatches(list *Node, marker listMarker) bool {
+	return list.Ordered == marker.ordered && list.Delimiter == marker.delimiter
+}
+
+// parseListMarker reads a bullet or number followed by a space, and
+// moves past it and the spaces up to the item's content
+func (p *blockParser) parseListMarker(container *Node) (listMarker, bool) {
+	if p.indent >= codeIndent {
+		return listMarker{}, false
+	}
+	rest := p.line[p.nextNonspace:]
+	marker := listMarker{offset: p.indent}
+	length := 0
+	switch {
+	case rest != "" && strings.IndexByte("*+-", rest[0]) >= 0:
+		marker.delimiter = rest[0]
+		length = 1
+	default:
+		match := reOrderedMarker.FindStringSubmatch(rest)
+		// Only a list starting at 1 can interrupt a paragraph
+		if match == nil || (container.Kind == Paragraph && match[1] != "1") {
+			return listMarker{}, false
+		}
+		marker.ordered = true
+		marker.start, _ = strconv.Atoi(match[1])
+		marker.delimiter = match[2][0]
+		length = len(match[0])
+	}
+	if length < len(rest) && rest[length] != ' ' && rest[length] != '\t' {
+		return listMarker{}, false
+	}
+	if container.Kind == Paragraph && strings.TrimSpace(rest[length:]) == "" {
+		return listMarker{}, false // an empty item can't interrupt a paragraph
+	}
+
+	p.advanceNextNonspace()
+	p.offset += length
+	spaces := 0
+	for p.offset+spaces < len(p.line) && spaces < 5 && (p.line[p.offset+spaces] == ' ' || p.line[p.offset+spaces] == '\t') {
+		spaces++
+	}
+	blankItem := strings.TrimSpace(p.line[p.offset:]) == ""
+	if spaces >= 5 || spaces < 1 || blankItem {
+		// Content indented further is code, so it starts one space in
+		marker.padding = length + 1
+		if p.offset < len(p.line) && (p.line[p.offset] == ' ' || p.line[p.offset] == '\t') {
+			p.offset++
+		}
+	} else {
+		marker.padding = length + spaces
+		p.offset += spaces
+	}
+	return marker, true
+}
+
+func startIndentedCode(p *blockParser, container *Node) int {
+	if !p.indented || p.tip.Kind == Paragraph || p.blank {
+		return notStarted
+	}
+	p.offset += codeIndent
+	p.closeUnmatchedBlocks()
+	p.addChild(CodeBlock)
+	return startedLeaf
+}
+
+// extractReferences takes link reference definitions off the start of a
+// paragraph and returns what's left of it
+func (p *blockParser) extractReferences(paragraph *Node) string {
+	content := strings.Join(paragraph.state.lines, "\n")
+	for strings.HasPrefix(content, "[") {
+		consumed := p.parseReference(content)
+		if consumed == 0 {
+			break
+		}
+		content = content[consumed:]
+	}
+	if content == "" {
+		paragraph.state.lines = nil
+	} else {
+		paragraph.state.lines = strings.Split(content, "\n")
+	}
+	return content
+}
+
+// parseReference reads a link reference definition from the start of s
+// and returns how much of s it took, or 0 if there isn't one
+func (p *blockParser) parseReference(s string) int {
+	labelLength := scanLinkLabel(s, 0)
+	if labelLength <= 2 || labelLength >= len(s) || s[labelLength] != ':' {
+		return 0
+	}
+	label := s[1 : labelLength-1]
+	if strings.TrimSpace(label) == "" {
+		return 0
+	}
+	pos := skipSpaceNewline(s, labelLength+1)
+	destination, pos, ok := scanLinkDestination(s, pos)
+	if !ok {
+		return 0
+	}
+
+	// A title must be separated from the destination, and nothing but
+	// spaces may follow either on their line
+	beforeTitle := pos
+	title := ""
+	if afterSpace := skipSpaceNewline(s, pos); afterSpace > pos {
+		if t, end, ok := scanLinkTitle(s, afterSpace); ok && atLineEnd(s, end) {
+			title, pos = t, end
+		}
+	}
+	if pos == beforeTitle && !atLineEnd(s, pos) {
+		return 0
+	}
+	pos = skipToNextLine(s, pos)
+
+	key := normalizeLabel(label)
+	if _, exists := p.refs[key]; !exists {
+		p.refs[key] = linkReference{destination: destination, title: title}
+	}
+	return pos
+}
+
+// atLineEnd reports whether only spaces come before the end of the line
+func atLineEnd(s string, pos int) bool {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+		pos++
+	}
+	return pos >= len(s) || s[pos] == '\n'
+}
+
+// skipToNextLine returns where the line after pos starts
+func skipToNextLine(s string, pos int) int {
+	if i := strings.IndexByte(s[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(s)
+}
+
+// parseInlines parses the text of every paragraph, heading and table cell
+func (p *blockParser) parseInlines(n *Node) {
+	switch n.Kind {
+	case Paragraph, Heading, TableCell:
+		content := strings.TrimRight(strings.Join(n.state.lines, "\n"), " \t")
+		n.Children = parseInline(content, p.refs)
+	case ListItem:
+		// A task list item's checkbox comes first in its first paragraph
+		if len(n.Children) > 0 && n.Children[0].Kind == Paragraph {
+			first := n.Children[0]
+			if lines := first.state.lines; len(lines) > 0 {
+				if match := reTaskMarker.FindStringSubmatch(lines[0]); match != nil {
+					n.Task = true
+					n.Checked = match[1] != " "
+					lines[0] = lines[0][len(match[0]):]
+				}
+			}
+		}
+	}
+	for _, child := range n.Children {
+		if child.Kind < Text {
+			p.parseInlines(child)
+		}
+	}
+	n.state = nil
+}
@@ -0,0 +1,266 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	reBreakTag   = regexp.MustCompile(`(?i)^<br\s*/?>$`)
+	reDataImage  = regexp.MustCompile(`(?i)^data:image/(?:png|gif|jpeg|webp);base64,[A-Za-z0-9+/=\s]*$`)
+	reLanguageID = regexp.MustCompile(`[^A-Za-z0-9_+#.-]`)
+)
+
+// RenderHTML renders a document as HTML that is safe to put in a page. Raw
+// HTML in the source is shown as text rather than run, and links and
+// images may only point at web, mail and relative addresses. Blocks carry
+// a data-line attribute with their first source line so a preview can be
+// scrolled along with the editor, and headings an id starting with "md-".
+func RenderHTML(doc *Node) string {
+	r := &htmlRenderer{slugs: make(map[string]int)}
+	r.block(doc, false)
+	return r.b.String()
+}
+
+type htmlRenderer struct {
+	b     strings.Builder
+	slugs map[string]int // how many headings have each id
+}
+
+func (r *htmlRenderer) open(tag string, n *Node, attrs string) {
+	r.b.WriteString("<" + tag + attrs + ` data-line="` + strconv.Itoa(n.Line) + `">`)
+}
+
+// block renders a block; tight is set for the children of a tight list's
+// items, whose paragraphs have no <p>
+func (r *htmlRenderer) block(n *Node, tight bool) {
+	switch n.Kind {
+	case Document, BlockQuote, ListItem:
+		r.container(n, tight)
+	case Paragraph:
+		if tight {
+			r.inlines(n.Children)
+			return
+		}
+		r.open("p", n, "")
+		r.inlines(n.Children)
+		r.b.WriteString("</p>\n")
+	case Heading:
+		tag := "h" + strconv.Itoa(n.Level)
+		r.open(tag, n, ` id="`+r.slug(n.PlainText())+`"`)
+		r.inlines(n.Children)
+		r.b.WriteString("</" + tag + ">\n")
+	case ThematicBreak:
+		r.open("hr", n, "")
+		r.b.WriteString("\n")
+	case List:
+		tag, attrs := "ul", ""
+		if n.Ordered {
+			tag = "ol"
+			if n.Start != 1 {
+				attrs = ` start="` + strconv.Itoa(n.Start) + `"`
+			}
+		}
+		for _, item := range n.Children {
+			if item.Task {
+				attrs += ` class="contains-task-list"`
+				break
+			}
+		}
+		r.open(tag, n, attrs)
+		r.b.WriteString("\n")
+		for _, item := range n.Children {
+			r.block(item, n.Tight)
+		}
+		r.b.WriteString("</" + tag + ">\n")
+	case CodeBlock:
+		attrs := ""
+		if language := reLanguageID.ReplaceAllString(n.Language(), ""); language != "" {
+			attrs = ` class="language-` + language + `"`
+		}
+		r.open("pre", n, "")
+		r.b.WriteString("<code" + attrs + ">" + html.EscapeString(n.Literal) + "</code></pre>\n")
+	case HTMLBlock:
+		// Comments are how Markdown hides text, so they stay hidden
+		if strings.HasPrefix(n.Literal, "<!--") && strings.HasSuffix(strings.TrimSpace(n.Literal), "-->") {
+			return
+		}
+		r.open("pre", n, ` class="md-html"`)
+		r.b.WriteString(html.EscapeString(n.Literal) + "</pre>\n")
+	case Table:
+		r.table(n)
+	}
+}
+
+func (r *htmlRenderer) container(n *Node, tight bool) {
+	switch n.Kind {
+	case BlockQuote:
+		r.open("blockquote", n, "")
+		r.b.WriteString("\n")
+	case ListItem:
+		attrs := ""
+		if n.Task {
+			attrs = ` class="task-list-item"`
+		}
+		r.open("li", n, attrs)
+		if n.Task {
+			checked := ""
+			if n.Checked {
+				checked = " checked"
+			}
+			r.b.WriteString(`<input type="checkbox" disabled` + checked + `> `)
+		}
+	}
+	for i, child := range n.Children {
+		if n.Kind == ListItem && i > 0 && tight {
+			r.b.WriteString("\n")
+		}
+		r.block(child, tight && n.Kind == ListItem)
+	}
+	switch n.Kind {
+	case BlockQuote:
+		r.b.WriteString("</blockquote>\n")
+	case ListItem:
+		r.b.WriteString("</li>\n")
+	}
+}
+
+func (r *htmlRenderer) table(n *Node) {
+	r.open("table", n, "")
+	r.b.WriteString("\n")
+	for i, row := range n.Children {
+		switch {
+		case i == 0:
+			r.b.WriteString("<thead>\n")
+		case i == 1:
+			r.b.WriteString("<tbody>\n")
+		}
+		r.open("tr", row, "")
+		for _, cell := range row.Children {
+			tag, attrs := "td", ""
+			if cell.Header {
+				tag = "th"
+			}
+			switch cell.Align {
+			case AlignLeft:
+				attrs = ` style="text-align: left"`
+			case AlignCenter:
+				attrs = ` style="text-align: center"`
+			case AlignRight:
+				attrs = ` style="text-align: right"`
+			}
+			r.b.WriteString("<" + tag + attrs + ">")
+			r.inlines(cell.Children)
+			r.b.WriteString("</" + tag + ">")
+		}
+		r.b.WriteString("</tr>\n")
+		if i == 0 {
+			r.b.WriteString("</thead>\n")
+		}
+	}
+	if len(n.Children) > 1 {
+		r.b.WriteString("</tbody>\n")
+	}
+	r.b.WriteString("</table>\n")
+}
+
+func (r *htmlRenderer) inlines(nodes []*Node) {
+	for _, n := range nodes {
+		r.inline(n)
+	}
+}
+
+func (r *htmlRenderer) inline(n *Node) {
+	switch n.Kind {
+	case Text:
+		r.b.WriteString(html.EscapeString(n.Literal))
+	case SoftBreak:
+		r.b.WriteString("\n")
+	case HardBreak:
+		r.b.WriteString("<br>\n")
+	case Code:
+		r.b.WriteString("<code>" + html.EscapeString(n.Literal) + "</code>")
+	case Emphasis, Strong, Strikethrough:
+		tag := map[NodeKind]string{Emphasis: "em", Strong: "strong", Strikethrough: "del"}[n.Kind]
+		r.b.WriteString("<" + tag + ">")
+		r.inlines(n.Children)
+		r.b.WriteString("</" + tag + ">")
+	case Link:
+		href, ok := safeURL(n.Destination, false)
+		if !ok {
+			r.inlines(n.Children)
+			return
+		}
+		r.b.WriteString(`<a href="` + href + `"` + titleAttr(n.Title) + ">")
+		r.inlines(n.Children)
+		r.b.WriteString("</a>")
+	case Image:
+		alt := html.EscapeString(n.PlainText())
+		src, ok := safeURL(n.Destination, true)
+		if !ok {
+			r.b.WriteString(alt)
+			return
+		}
+		r.b.WriteString(`<img src="` + src + `" alt="` + alt + `"` + titleAttr(n.Title) + ">")
+	case RawHTML:
+		if reBreakTag.MatchString(n.Literal) {
+			r.b.WriteString("<br>")
+			return
+		}
+		r.b.WriteString(html.EscapeString(n.Literal))
+	}
+}
+
+func titleAttr(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` title="` + html.EscapeString(title) + `"`
+}
+
+// safeURL escapes a link or image address for an attribute, refusing any
+// with a scheme other than http, https or mailto, or for images a data
+// URL of a common image type
+func safeURL(destination string, image bool) (string, bool) {
+	u := strings.TrimSpace(destination)
+	if i := strings.IndexAny(u, ":/?#"); i > 0 && u[i] == ':' {
+		switch strings.ToLower(u[:i]) {
+		case "http", "https":
+		case "mailto":
+			if image {
+				return "", false
+			}
+		case "data":
+			if !image || !reDataImage.MatchString(u) {
+				return "", false
+			}
+		default:
+			return "", false
+		}
+	}
+	return html.EscapeString(u), true
+}
+
+// slug makes a unique heading id from its text the way GitHub does:
+// lower case, spaces as hyphens, and other punctuation left out
+func (r *htmlRenderer) slug(text string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '-' || c == '_':
+			b.WriteRune(c)
+		case unicode.IsSpace(c):
+			b.WriteByte('-')
+		}
+	}
+	slug := b.String()
+	count := r.slugs[slug]
+	r.slugs[slug] = count + 1
+	if count > 0 {
+		slug += "-" + strconv.Itoa(count)
+	}
+	return "md-" + html.EscapeString(slug)
+}
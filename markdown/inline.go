@@ -0,0 +1,693 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Patterns for raw HTML, shared by HTML blocks and inline HTML
+const (
+	tagName      = `[A-Za-z][A-Za-z0-9-]*`
+	attribute    = `(?:\s+[a-zA-Z_:][a-zA-Z0-9_.:-]*(?:\s*=\s*(?:[^"'=<>` + "`" + `\x00-\x20]+|'[^']*'|"[^"]*"))?)`
+	openTag      = `<` + tagName + attribute + `*\s*/?>`
+	closeTag     = `</` + tagName + `\s*>`
+	htmlComment  = `<!-->|<!--->|<!--[\s\S]*?-->`
+	procInstr    = `<[?][\s\S]*?[?]>`
+	declaration  = `<![A-Za-z][^>]*>`
+	cdataSection = `<!\[CDATA\[[\s\S]*?\]\]>`
+)
+
+var (
+	reHTMLTag       = regexp.MustCompile(`^(?:` + openTag + `|` + closeTag + `|` + htmlComment + `|` + procInstr + `|` + declaration + `|` + cdataSection + `)`)
+	reAutolinkURI   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9.+-]{1,31}:[^<>\x00-\x20]*)>`)
+	reAutolinkEmail = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	reEntity        = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	reBareLink      = regexp.MustCompile(`^(?i:https?://|www\.)[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*[^\s<]*`)
+	reEscapeOrRef   = regexp.MustCompile(`\\[!"#$%&'()*+,./:;<=>?@[\\\]^_` + "`" + `{|}~-]|&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// inlineItem is a node in the list of inlines being parsed, which
+// emphasis and links rearrange as their closers are found
+type inlineItem struct {
+	node       *Node
+	prev, next *inlineItem
+	fixed      bool // a delimiter or bracket, which text doesn't merge into
+}
+
+// delimiter is a run of *, _ or ~ that may open or close emphasis
+type delimiter struct {
+	item          *inlineItem
+	char          byte
+	count         int // still unused
+	originalCount int
+	canOpen       bool
+	canClose      bool
+	prev, next    *delimiter
+}
+
+// bracket is a [ or ![ that may open a link or image
+type bracket struct {
+	item      *inlineItem
+	image     bool
+	active    bool
+	textStart int // where the link text starts
+	prevDelim *delimiter
+	prev      *bracket
+}
+
+type inlineParser struct {
+	src  string
+	pos  int
+	refs map[string]linkReference
+
+	head, tail *inlineItem
+	delims     *delimiter // the top of the stack
+	brackets   *bracket
+}
+
+// parseInline parses the text of a block into inline nodes
+func parseInline(src string, refs map[string]linkReference) []*Node {
+	p := &inlineParser{src: src, refs: refs}
+	for p.pos < len(p.src) {
+		p.parseOne()
+	}
+	p.processEmphasis(nil)
+	return collect(p.head, nil)
+}
+
+func (p *inlineParser) append(n *Node, fixed bool) *inlineItem {
+	item := &inlineItem{node: n, prev: p.tail, fixed: fixed}
+	if p.tail != nil {
+		p.tail.next = item
+	} else {
+		p.head = item
+	}
+	p.tail = item
+	return item
+}
+
+func (p *inlineParser) appendText(s string) {
+	if p.tail != nil && p.tail.node.Kind == Text && !p.tail.fixed {
+		p.tail.node.Literal += s
+		return
+	}
+	p.append(&Node{Kind: Text, Literal: s}, false)
+}
+
+func (p *inlineParser) remove(item *inlineItem) {
+	if item.prev != nil {
+		item.prev.next = item.next
+	} else {
+		p.head = item.next
+	}
+	if item.next != nil {
+		item.next.prev = item.prev
+	} else {
+		p.tail = item.prev
+	}
+}
+
+// collect returns the nodes from one item up to another, joining
+// neighbouring text
+func collect(from *inlineItem, to *inlineItem) []*Node {
+	var nodes []*Node
+	var text []string
+	flush := func() {
+		if joined := strings.Join(text, ""); joined != "" {
+			nodes = append(nodes, &Node{Kind: Text, Literal: joined})
+		}
+		text = text[:0]
+	}
+	for item := from; item != to && item != nil; item = item.next {
+		if item.node.Kind == Text {
+			text = append(text, item.node.Literal)
+			continue
+		}
+		flush()
+		nodes = append(nodes, item.node)
+	}
+	flush()
+	return nodes
+}
+
+func (p *inlineParser) parseOne() {
+	switch c := p.src[p.pos]; c {
+	case '\n':
+		p.parseNewline()
+	case '\\':
+		p.parseBackslash()
+	case '`':
+		p.parseCodeSpan()
+	case '*', '_', '~':
+		p.parseDelimiterRun(c)
+	case '[':
+		p.pos++
+		p.pushBracket(p.append(&Node{Kind: Text, Literal: "["}, true), false)
+	case '!':
+		if p.pos+1 < len(p.src) && p.src[p.pos+1] == '[' {
+			p.pos += 2
+			p.pushBracket(p.append(&Node{Kind: Text, Literal: "!["}, true), true)
+			return
+		}
+		p.pos++
+		p.appendText("!")
+	case ']':
+		p.closeBracket()
+	case '<':
+		p.parseAngle()
+	case '&':
+		if entity := reEntity.FindString(p.src[p.pos:]); entity != "" {
+			p.pos += len(entity)
+			p.appendText(html.UnescapeString(entity))
+			return
+		}
+		p.pos++
+		p.appendText("&")
+	default:
+		if p.parseBareLink() {
+			return
+		}
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && !isSpecial(p.src[p.pos]) && !p.mayStartBareLink() {
+			p.pos++
+		}
+		p.appendText(p.src[start:p.pos])
+	}
+}
+
+func isSpecial(c byte) bool {
+	return strings.IndexByte("\n\\`*_~[]!<&", c) >= 0
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// parseNewline ends a line with a soft break, or a hard break when the
+// line ended with two or more spaces
+func (p *inlineParser) parseNewline() {
+	p.pos++
+	hard := false
+	if p.tail != nil && p.tail.node.Kind == Text && !p.tail.fixed {
+		text := p.tail.node.Literal
+		trimmed := strings.TrimRight(text, " ")
+		hard = len(text)-len(trimmed) >= 2
+		p.tail.node.Literal = trimmed
+	}
+	if hard {
+		p.append(&Node{Kind: HardBreak}, false)
+	} else {
+		p.append(&Node{Kind: SoftBreak}, false)
+	}
+	p.skipLeadingSpaces()
+}
+
+func (p *inlineParser) skipLeadingSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *inlineParser) parseBackslash() {
+	p.pos++
+	switch {
+	case p.pos < len(p.src) && p.src[p.pos] == '\n':
+		p.pos++
+		p.append(&Node{Kind: HardBreak}, false)
+		p.skipLeadingSpaces()
+	case p.pos < len(p.src) && isASCIIPunct(p.src[p.pos]):
+		p.appendText(p.src[p.pos : p.pos+1])
+		p.pos++
+	default:
+		p.appendText(`\`)
+	}
+}
+
+// parseCodeSpan reads a code span up to a run of as many backticks, or
+// takes the backticks as text if there's none
+func (p *inlineParser) parseCodeSpan() {
+	start := p.pos
+	ticks := countRun(p.src[p.pos:], '`')
+	p.pos += ticks
+	for i := p.pos; i < len(p.src); {
+		j := strings.IndexByte(p.src[i:], '`')
+		if j < 0 {
+			break
+		}
+		j += i
+		run := countRun(p.src[j:], '`')
+		if run == ticks {
+			content := strings.ReplaceAll(p.src[p.pos:j], "\n", " ")
+			if len(content) >= 2 && content[0] == ' ' && content[len(content)-1] == ' ' && strings.Trim(content, " ") != "" {
+				content = content[1 : len(content)-1]
+			}
+			p.append(&Node{Kind: Code, Literal: content}, false)
+			p.pos = j + run
+			return
+		}
+		i = j + run
+	}
+	p.appendText(p.src[start:p.pos])
+}
+
+// parseDelimiterRun reads a run of *, _ or ~ and, if it is left or right
+// flanking, pushes it for processEmphasis to pair up
+func (p *inlineParser) parseDelimiterRun(c byte) {
+	start := p.pos
+	count := countRun(p.src[p.pos:], c)
+	p.pos += count
+	item := p.append(&Node{Kind: Text, Literal: p.src[start:p.pos]}, true)
+	if c == '~' && count > 2 {
+		return
+	}
+
+	before, after := '\n', '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:start])
+	}
+	if p.pos < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[p.pos:])
+	}
+	spaceBefore, spaceAfter := unicode.IsSpace(before), unicode.IsSpace(after)
+	punctBefore, punctAfter := isPunctuation(before), isPunctuation(after)
+	leftFlanking := !spaceAfter && (!punctAfter || spaceBefore || punctBefore)
+	rightFlanking := !spaceBefore && (!punctBefore || spaceAfter || punctAfter)
+
+	canOpen, canClose := leftFlanking, rightFlanking
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || punctBefore)
+		canClose = rightFlanking && (!leftFlanking || punctAfter)
+	}
+	if !canOpen && !canClose {
+		return
+	}
+	d := &delimiter{item: item, char: c, count: count, originalCount: count, canOpen: canOpen, canClose: canClose, prev: p.delims}
+	if p.delims != nil {
+		p.delims.next = d
+	}
+	p.delims = d
+}
+
+func isPunctuation(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+func (p *inlineParser) removeDelimiter(d *delimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next != nil {
+		d.next.prev = d.prev
+	} else {
+		p.delims = d.prev
+	}
+}
+
+// processEmphasis pairs up the delimiters above stackBottom, innermost
+// first, wrapping what's between each pair in emphasis
+func (p *inlineParser) processEmphasis(stackBottom *delimiter) {
+	type bottomKey struct {
+		char    byte
+		canOpen bool
+		mod3    int
+	}
+	openersBottom := make(map[bottomKey]*delimiter)
+
+	closer := p.delims
+	for closer != nil && closer.prev != stackBottom {
+		closer = closer.prev
+	}
+	if closer == stackBottom {
+		closer = nil
+	}
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+		key := bottomKey{closer.char, closer.canOpen, closer.originalCount % 3}
+		bottom, seen := openersBottom[key]
+		if !seen {
+			bottom = stackBottom
+		}
+
+		var opener *delimiter
+		for o := closer.prev; o != nil && o != stackBottom && o != bottom; o = o.prev {
+			if o.char != closer.char || !o.canOpen {
+				continue
+			}
+			// The rule of three: a delimiter that could open and close
+			// only pairs up when the counts together aren't a multiple
+			// of three, unless both are
+			oddMatch := (closer.canOpen || o.canClose) && closer.originalCount%3 != 0 &&
+				(o.originalCount+closer.originalCount)%3 == 0
+			if oddMatch || (closer.char == '~' && o.count != closer.count) {
+				continue
+			}
+			opener = o
+			break
+		}
+
+		if opener == nil {
+			openersBottom[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				p.removeDelimiter(closer)
+			}
+			closer = next
+			continue
+		}
+
+		used := 1
+		kind := Emphasis
+		switch {
+		case closer.char == '~':
+			used = closer.count
+			kind = Strikethrough
+		case closer.count >= 2 && opener.count >= 2:
+			used = 2
+			kind = Strong
+		}
+		opener.count -= used
+		closer.count -= used
+		opener.item.node.Literal = opener.item.node.Literal[:opener.count]
+		closer.item.node.Literal = closer.item.node.Literal[:closer.count]
+
+		wrapper := &inlineItem{node: &Node{Kind: kind, Children: collect(opener.item.next, closer.item)}}
+		wrapper.prev, wrapper.next = opener.item, closer.item
+		opener.item.next, closer.item.prev = wrapper, wrapper
+
+		// Delimiters inside the emphasis can no longer pair with ones outside
+		opener.next, closer.prev = closer, opener
+
+		if opener.count == 0 {
+			p.remove(opener.item)
+			p.removeDelimiter(opener)
+		}
+		if closer.count == 0 {
+			next := closer.next
+			p.remove(closer.item)
+			p.removeDelimiter(closer)
+			closer = next
+		}
+	}
+
+	for p.delims != nil && p.delims != stackBottom {
+		p.removeDelimiter(p.delims)
+	}
+}
+
+func (p *inlineParser) pushBracket(item *inlineItem, image bool) {
+	p.brackets = &bracket{item: item, image: image, active: true, textStart: p.pos, prevDelim: p.delims, prev: p.brackets}
+}
+
+// closeBracket makes a link or image of the text since the last open
+// bracket, if a destination or a known reference follows
+func (p *inlineParser) closeBracket() {
+	p.pos++
+	opener := p.brackets
+	if opener == nil {
+		p.appendText("]")
+		return
+	}
+	if !opener.active {
+		p.brackets = opener.prev
+		p.appendText("]")
+		return
+	}
+
+	afterBracket := p.pos
+	destination, title, matched := p.parseInlineLink()
+	if !matched {
+		p.pos = afterBracket
+		label := ""
+		if n := scanLinkLabel(p.src, p.pos); n > 2 {
+			label = p.src[p.pos+1 : p.pos+n-1]
+			p.pos += n
+		} else {
+			// A collapsed [] or shortcut reference uses the link text
+			label = p.src[opener.textStart : afterBracket-1]
+			if n == 2 {
+				p.pos += n
+			}
+		}
+		if ref, ok := p.refs[normalizeLabel(label)]; ok && len(label) <= 999 {
+			destination, title, matched = ref.destination, ref.title, true
+		} else {
+			p.pos = afterBracket
+		}
+	}
+	p.brackets = opener.prev
+	if !matched {
+		p.appendText("]")
+		return
+	}
+
+	kind := Link
+	if opener.image {
+		kind = Image
+	}
+	p.processEmphasis(opener.prevDelim)
+	n := &Node{Kind: kind, Destination: destination, Title: title, Children: collect(opener.item.next, nil)}
+	p.tail = opener.item
+	opener.item.next = nil
+	p.remove(opener.item)
+	p.append(n, false)
+
+	// Links can't contain links
+	if !opener.image {
+		for b := p.brackets; b != nil; b = b.prev {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+}
+
+// parseInlineLink reads a (destination "title") after a closing bracket
+func (p *inlineParser) parseInlineLink() (destination string, title string, ok bool) {
+	if p.pos >= len(p.src) || p.src[p.pos] != '(' {
+		return "", "", false
+	}
+	pos := skipSpaceNewline(p.src, p.pos+1)
+	destination, pos, ok = scanLinkDestination(p.src, pos)
+	if !ok {
+		return "", "", false
+	}
+	if afterSpace := skipSpaceNewline(p.src, pos); afterSpace > pos {
+		if t, end, ok := scanLinkTitle(p.src, afterSpace); ok {
+			title, pos = t, end
+		}
+	}
+	pos = skipSpaceNewline(p.src, pos)
+	if pos >= len(p.src) || p.src[pos] != ')' {
+		return "", "", false
+	}
+	p.pos = pos + 1
+	return destination, title, true
+}
+
+// parseAngle reads an autolink or raw HTML starting with <
+func (p *inlineParser) parseAngle() {
+	rest := p.src[p.pos:]
+	if m := reAutolinkURI.FindStringSubmatch(rest); m != nil {
+		p.pos += len(m[0])
+		p.append(&Node{Kind: Link, Destination: m[1], Children: []*Node{{Kind: Text, Literal: m[1]}}}, false)
+		return
+	}
+	if m := reAutolinkEmail.FindStringSubmatch(rest); m != nil {
+		p.pos += len(m[0])
+		p.append(&Node{Kind: Link, Destination: "mailto:" + m[1], Children: []*Node{{Kind: Text, Literal: m[1]}}}, false)
+		return
+	}
+	if tag := reHTMLTag.FindString(rest); tag != "" {
+		p.pos += len(tag)
+		p.append(&Node{Kind: RawHTML, Literal: tag}, false)
+		return
+	}
+	p.pos++
+	p.appendText("<")
+}
+
+// mayStartBareLink reports whether a bare link could start here: at the
+// start of a word, or after an emphasis delimiter or parenthesis
+func (p *inlineParser) mayStartBareLink() bool {
+	c := p.src[p.pos]
+	if c != 'h' && c != 'H' && c != 'w' && c != 'W' {
+		return false
+	}
+	if p.pos > 0 {
+		if before := p.src[p.pos-1]; before != ' ' && before != '\t' && before != '\n' && before != '(' && before != '*' && before != '_' && before != '~' {
+			return false
+		}
+	}
+	return reBareLink.MatchString(p.src[p.pos:])
+}
+
+// parseBareLink reads a link written without brackets, such as
+// https://example.com or www.example.com, leaving off trailing punctuation
+func (p *inlineParser) parseBareLink() bool {
+	if !p.mayStartBareLink() {
+		return false
+	}
+	link := reBareLink.FindString(p.src[p.pos:])
+	for link != "" {
+		last := link[len(link)-1]
+		if strings.IndexByte("?!.,:*_~'\"", last) >= 0 ||
+			(last == ')' && strings.Count(link, ")") > strings.Count(link, "(")) {
+			link = link[:len(link)-1]
+			continue
+		}
+		if last == ';' {
+			if i := strings.LastIndexByte(link, '&'); i >= 0 && reEntity.MatchString(link[i:]) {
+				link = link[:i]
+				continue
+			}
+		}
+		break
+	}
+	destination := link
+	if strings.HasPrefix(strings.ToLower(link), "www.") {
+		destination = "http://" + link
+	}
+	// The domain needs at least one period
+	host := link
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if !strings.Contains(strings.Trim(host, "."), ".") {
+		return false
+	}
+	p.pos += len(link)
+	p.append(&Node{Kind: Link, Destination: destination, Children: []*Node{{Kind: Text, Literal: link}}}, false)
+	return true
+}
+
+// skipSpaceNewline skips spaces and tabs and at most one line ending
+func skipSpaceNewline(s string, pos int) int {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+		pos++
+	}
+	if pos < len(s) && s[pos] == '\n' {
+		pos++
+		for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+			pos++
+		}
+	}
+	return pos
+}
+
+// scanLinkLabel returns the length of a [label] at pos, or 0 if there
+// isn't one
+func scanLinkLabel(s string, pos int) int {
+	if pos >= len(s) || s[pos] != '[' {
+		return 0
+	}
+	for i := pos + 1; i < len(s) && i-pos <= 1000; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			return 0
+		case ']':
+			return i - pos + 1
+		}
+	}
+	return 0
+}
+
+// scanLinkDestination reads a <bracketed> or bare link destination
+func scanLinkDestination(s string, pos int) (string, int, bool) {
+	if pos < len(s) && s[pos] == '<' {
+		for i := pos + 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+				i++
+			case c == '\n' || c == '<':
+				return "", pos, false
+			case c == '>':
+				return unescapeString(s[pos+1 : i]), i + 1, true
+			}
+		}
+		return "", pos, false
+	}
+
+	depth := 0
+	i := pos
+scan:
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			i += 2
+			continue
+		case c == '(':
+			if depth++; depth > 32 {
+				return "", pos, false
+			}
+		case c == ')':
+			if depth == 0 {
+				break scan
+			}
+			depth--
+		case c <= ' ' || c == 0x7f:
+			break scan
+		}
+		i++
+	}
+	if depth != 0 || (i == pos && (i >= len(s) || s[i] != ')')) {
+		return "", pos, false
+	}
+	return unescapeString(s[pos:i]), i, true
+}
+
+// scanLinkTitle reads a "title", 'title' or (title)
+func scanLinkTitle(s string, pos int) (string, int, bool) {
+	if pos >= len(s) {
+		return "", pos, false
+	}
+	closer := s[pos]
+	switch closer {
+	case '"', '\'':
+	case '(':
+		closer = ')'
+	default:
+		return "", pos, false
+	}
+	for i := pos + 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			i++
+		case c == closer:
+			return unescapeString(s[pos+1 : i]), i + 1, true
+		case c == '(' && closer == ')':
+			return "", pos, false
+		}
+	}
+	return "", pos, false
+}
+
+// normalizeLabel makes link labels that match the same regardless of case
+// and whitespace equal
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.ToUpper(strings.Join(strings.Fields(label), " ")))
+}
+
+// unescapeString resolves backslash escapes and entities
+func unescapeString(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	return reEscapeOrRef.ReplaceAllStringFunc(s, func(m string) string {
+		if m[0] == '\\' {
+			return m[1:]
+		}
+		return html.UnescapeString(m)
+	})
+}
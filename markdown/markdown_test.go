@@ -0,0 +1,74 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	content := `# Notes & Ideas
+
+Some *emphasis*, **strong**, ~~struck~~ and ` + "`code`" + `,
+see [the docs](https://example.com "Docs") or www.example.com.
+
+- [x] done
+- [ ] todo
+
+| Name | Size |
+|:-----|-----:|
+| a \| b | 10 |
+
+` + "```go\nfmt.Println(\"<hi>\")\n```" + `
+
+<script>alert(1)</script>
+
+[bad](javascript:alert(1)) ![pixel](data:text/html;base64,AAAA)`
+
+	got := RenderHTML(Parse(content))
+
+	for _, want := range []string{
+		`<h1 id="md-notes--ideas" data-line="1">Notes &amp; Ideas</h1>`,
+		`<em>emphasis</em>, <strong>strong</strong>, <del>struck</del> and <code>code</code>`,
+		`<a href="https://example.com" title="Docs">the docs</a>`,
+		`<a href="http://www.example.com">www.example.com</a>.`,
+		`<li class="task-list-item" data-line="6"><input type="checkbox" disabled checked> done</li>`,
+		`<th style="text-align: left">Name</th><th style="text-align: right">Size</th>`,
+		`<tr data-line="11"><td style="text-align: left">a | b</td>`,
+		`<pre data-line="13"><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)`,
+		`<pre class="md-html" data-line="17">&lt;script&gt;`,
+		`<p data-line="19">bad pixel</p>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered HTML is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<script") || strings.Contains(got, "javascript:") {
+		t.Errorf("rendered HTML is not sanitized:\n%s", got)
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	doc := Parse("Title\n\n> quoted\ncontinued\n\n1. one\n2. two\n\n   more\n")
+
+	kinds := []NodeKind{Paragraph, BlockQuote, List}
+	if len(doc.Children) != len(kinds) {
+		t.Fatalf("got %d top-level blocks, want %d", len(doc.Children), len(kinds))
+	}
+	for i, kind := range kinds {
+		if doc.Children[i].Kind != kind {
+			t.Errorf("block %d is kind %d, want %d", i, doc.Children[i].Kind, kind)
+		}
+	}
+
+	quote := doc.Children[1]
+	if quote.Line != 3 || quote.EndLine != 4 {
+		t.Errorf("quote spans lines %d-%d, want 3-4", quote.Line, quote.EndLine)
+	}
+	list := doc.Children[2]
+	if !list.Ordered || list.Tight || len(list.Children) != 2 {
+		t.Errorf("list is ordered=%v tight=%v with %d items, want a loose ordered list of 2", list.Ordered, list.Tight, len(list.Children))
+	}
+	if text := list.Children[1].PlainText(); text != "two\nmore" {
+		t.Errorf("second item text is %q", text)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffEdits bounds the edit distance the line diff searches for. Past
+// it, the differing middle of the two texts is treated as one change.
+const maxDiffEdits = 2000
+
+// Conflict markers written into merged text
+const (
+	conflictStartMarker = "<<<<<<< Editor"
+	conflictSepMarker   = "======="
+	conflictEndMarker   = ">>>>>>> Disk"
+)
+
+// MergeResult is the outcome of a three-way merge
+type MergeResult struct {
+	Content   string `json:"content"`
+	Conflicts int    `json:"conflicts"` // regions wrapped in conflict markers
+	Clean     bool   `json:"clean"`
+}
+
+// diffMatches returns, for each line of a, the index of the matching line
+// of b in a longest common subsequence, or -1
+func diffMatches(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// Common prefix and suffix need no search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		matches[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	for _, pair := range myersMatches(midA, midB) {
+		matches[prefix+pair[0]] = prefix + pair[1]
+	}
+	return matches
+}
+
+// myersMatches finds the matching line pairs of a shortest edit script
+// between a and b using Myers' algorithm. It gives up, returning no
+// matches, if more than maxDiffEdits edits are needed.
+func myersMatches(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int // v[offset-d : offset+d+1] before each round d
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackMatches(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrackMatches walks the saved rounds of a Myers search back from the
+// end, collecting the diagonal moves as matching pairs
+func backtrackMatches(trace [][]int, n, m int) [][2]int {
+	// at reads round d's v for diagonal k; rounds store only [-d, d]
+	at := func(d, k int) int {
+		if k < -d || k > d {
+			return 0
+		}
+		return trace[d][k+d]
+	}
+
+	var pairs [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(d, k-1) < at(d, k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(d, prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			pairs = append(pairs, [2]int{x, y})
+		}
+		if d > 0 {
+			x, y = prevX, prevY
+		}
+	}
+
+	// Collected back to front
+	for i, j := 0, len(pairs)-1; i < j; i, j = i+1, j-1 {
+		pairs[i], pairs[j] = pairs[j], pairs[i]
+	}
+	return pairs
+}
+
+// merge3 merges two edited versions of base line by line. Regions changed
+// on only one side take that side; regions changed identically on both
+// sides are kept once; anything else becomes a conflict wrapped in markers.
+func merge3(base, ours, theirs string) MergeResult {
+	baseLines := strings.Split(base, "\n")
+	ourLines := strings.Split(ours, "\n")
+	theirLines := strings.Split(theirs, "\n")
+
+	toOurs := diffMatches(baseLines, ourLines)
+	toTheirs := diffMatches(baseLines, theirLines)
+
+	var out []string
+	result := MergeResult{}
+	i, j, k := 0, 0, 0
+	for {
+		// Find the next base line kept by both sides
+		next := i
+		for next < len(baseLines) && (toOurs[next] == -1 || toTheirs[next] == -1) {
+			next++
+		}
+		endOurs, endTheirs := len(ourLines), len(theirLines)
+		if next < len(baseLines) {
+			endOurs, endTheirs = toOurs[next], toTheirs[next]
+		}
+
+		// Resolve the unstable region before it
+		baseSeg := baseLines[i:next]
+		ourSeg := ourLines[j:endOurs]
+		theirSeg := theirLines[k:endTheirs]
+		switch {
+		case equalLines(ourSeg, baseSeg):
+			out = append(out, theirSeg...)
+		case equalLines(theirSeg, baseSeg), equalLines(ourSeg, theirSeg):
+			out = append(out, ourSeg...)
+		default:
+			result.Conflicts++
+			out = append(out, conflictStartMarker)
+			out = append(out, ourSeg...)
+			out = append(out, conflictSepMarker)
+			out = append(out, theirSeg...)
+			out = append(out, conflictEndMarker)
+		}
+
+		if next == len(baseLines) {
+			break
+		}
+		out = append(out, baseLines[next])
+		i, j, k = next+1, endOurs+1, endTheirs+1
+	}
+
+	result.Content = strings.Join(out, "\n")
+	result.Clean = result.Conflicts == 0
+	return result
+}
+
+// equalLines reports whether two line slices are identical
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffContextLines is how many unchanged lines surround each hunk of a unified diff
+const diffContextLines = 3
+
+// unifiedDiff describes the changes from a to b as a unified diff with the
+// given file labels. It returns "" when the texts are equal.
+func unifiedDiff(a, b string, labelA, labelB string) string {
+	if a == b {
+		return ""
+	}
+	aLines := diffLines(a)
+	bLines := diffLines(b)
+	matches := diffMatches(aLines, bLines)
+
+	// Flatten the matches into one line per edit: ' ', '-' or '+'
+	type edit struct {
+		op   byte
+		line string
+	}
+	var edits []edit
+	j := 0
+	for i, match := range matches {
+		if match == -1 {
+			edits = append(edits, edit{'-', aLines[i]})
+			continue
+		}
+		for ; j < match; j++ {
+			edits = append(edits, edit{'+', bLines[j]})
+		}
+		edits = append(edits, edit{' ', aLines[i]})
+		j++
+	}
+	for ; j < len(bLines); j++ {
+		edits = append(edits, edit{'+', bLines[j]})
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", labelA, labelB)
+
+	// Group changes closer than twice the context into hunks
+	lineA, lineB := 0, 0 // lines of a and b before edits[k]
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			lineA++
+			lineB++
+			k++
+			continue
+		}
+
+		start := max(0, k-diffContextLines)
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*diffContextLines {
+				end = min(end+diffContextLines, len(edits))
+				break
+			}
+			end = run
+		}
+
+		startA, startB := lineA-(k-start), lineB-(k-start)
+		countA, countB := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				countA++
+			}
+			if e.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			if line, ok := strings.CutSuffix(e.line, noNewlineMark); ok {
+				out.WriteString(line)
+				out.WriteString("\n\\ No newline at end of file\n")
+				continue
+			}
+			out.WriteString(e.line)
+			out.WriteByte('\n')
+		}
+
+		for _, e := range edits[k:end] {
+			if e.op != '+' {
+				lineA++
+			}
+			if e.op != '-' {
+				lineB++
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// noNewlineMark tags a last line without a line break, so it doesn't
+// match the same text followed by one
+const noNewlineMark = "\x00"
+
+// diffLines splits text into lines for unifiedDiff
+func diffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	if trimmed, ok := strings.CutSuffix(text, "\n"); ok {
+		return strings.Split(trimmed, "\n")
+	}
+	return strings.Split(text+noNewlineMark, "\n")
+}
+
+// hunkRange formats the 1-based start and length of a hunk side
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	lines := func(l ...string) string { return strings.Join(l, "\n") }
+	base := lines("one", "two", "three", "four", "five")
+
+	tests := []struct {
+		name          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts int
+	}{
+		{
+			name:   "unchanged",
+			ours:   base,
+			theirs: base,
+			want:   base,
+		},
+		{
+			name:   "only ours changed",
+			ours:   lines("one", "TWO", "three", "four", "five"),
+			theirs: base,
+			want:   lines("one", "TWO", "three", "four", "five"),
+		},
+		{
+			name:   "only theirs changed",
+			ours:   base,
+			theirs: lines("one", "two", "three", "four", "five", "six"),
+			want:   lines("one", "two", "three", "four", "five", "six"),
+		},
+		{
+			name:   "separate regions",
+			ours:   lines("ONE", "two", "three", "four", "five"),
+			theirs: lines("one", "two", "three", "five"),
+			want:   lines("ONE", "two", "three", "five"),
+		},
+		{
+			name:   "same change on both sides",
+			ours:   lines("one", "two", "3", "four", "five"),
+			theirs: lines("one", "two", "3", "four", "five"),
+			want:   lines("one", "two", "3", "four", "five"),
+		},
+		{
+			name:   "conflicting edits",
+			ours:   lines("one", "two", "mine", "four", "five"),
+			theirs: lines("one", "two", "yours", "four", "five"),
+			want: lines("one", "two",
+				conflictStartMarker, "mine", conflictSepMarker, "yours", conflictEndMarker,
+				"four", "five"),
+			wantConflicts: 1,
+		},
+		{
+			name:   "edit against delete",
+			ours:   lines("one", "two", "three", "FOUR", "five"),
+			theirs: lines("one", "two", "three", "five"),
+			want: lines("one", "two", "three",
+				conflictStartMarker, "FOUR", conflictSepMarker, conflictEndMarker,
+				"five"),
+			wantConflicts: 1,
+		},
+		{
+			name:   "insertions at both ends",
+			ours:   lines("zero", "one", "two", "three", "four", "five"),
+			theirs: lines("one", "two", "three", "four", "five", "six"),
+			want:   lines("zero", "one", "two", "three", "four", "five", "six"),
+		},
+	}
+
+	for _, tt := range tests {
+		got := merge3(base, tt.ours, tt.theirs)
+		if got.Content != tt.want {
+			t.Errorf("%s: merged\n%s\nwant\n%s", tt.name, got.Content, tt.want)
+		}
+		if got.Conflicts != tt.wantConflicts || got.Clean != (tt.wantConflicts == 0) {
+			t.Errorf("%s: conflicts = %d, clean = %v, want %d", tt.name, got.Conflicts, got.Clean, tt.wantConflicts)
+		}
+	}
+}
+
+func TestDiffMatches(t *testing.T) {
+	a := strings.Split("a b c d e f", " ")
+	b := strings.Split("a x c d y f", " ")
+	want := []int{0, -1, 2, 3, -1, 5}
+
+	got := diffMatches(a, b)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("diffMatches = %v, want %v", got, want)
+		}
+	}
+}
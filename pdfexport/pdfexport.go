@@ -7,16 +7,18 @@ import (
 	"compress/zlib"
 	"fmt"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"unicode"
+
+	"Akashic/markdown"
 )
 
 // Font constants
 const (
 	fontRegular = "/F1" // Helvetica
 	fontBold    = "/F2" // Helvetica-Bold
+	fontMono    = "/F3" // Courier
 )
 
 // textStyle represents the visual style of a text element
@@ -36,9 +38,11 @@ var (
 	styleBullet    = textStyle{fontRegular, 10.5, 8}
 	styleSubBullet = textStyle{fontRegular, 10.5, 14}
 	styleNumbered  = textStyle{fontRegular, 10.5, 8}
+	styleQuote     = textStyle{fontRegular, 10.5, 8}
+	styleCode      = textStyle{fontMono, 9.5, 4}
 
-	// Regex for numbered list items like "1.", "2.", "1)", etc.
-	numberedListRe = regexp.MustCompile(`^\d+[\.\)]\s+`)
+	styleTableHeader = textStyle{fontBold, 10.5, 0}
+	styleTableRow    = textStyle{fontRegular, 10.5, 0}
 )
 
 // Exporter handles PDF export operations
@@ -84,7 +88,12 @@ func (e *Exporter) Export(content string, filePath string) error {
 		availableWidth := contentWidth - style.indent
 
 		// Word-wrap the text
-		lines := e.wrapText(block.text, availableWidth, style.fontSize)
+		var lines []string
+		if block.preformatted {
+			lines = e.wrapCode(block.text, availableWidth, style.fontSize)
+		} else {
+			lines = e.wrapText(block.text, availableWidth, style.fontSize)
+		}
 		if len(lines) == 0 {
 			// Empty block just adds spacing
 			if page != nil {
@@ -122,120 +131,241 @@ func (e *Exporter) Export(content string, filePath string) error {
 	return doc.write(filePath)
 }
 
+// BlockKind is what a parsed piece of content is
+type BlockKind int
+
+// Kinds of content block
+const (
+	BlockBlank BlockKind = iota // an empty line between paragraphs
+	BlockTitle
+	BlockHeading1
+	BlockHeading2
+	BlockHeading3
+	BlockBullet
+	BlockNumbered
+	BlockBody
+	BlockQuote
+	BlockCode // a line of a code block, its spacing kept
+	BlockTableRow
+)
+
+// Block is a parsed piece of content without any PDF styling, for other
+// exporters to lay out in their own format
+type Block struct {
+	Kind   BlockKind
+	Text   string   // without the bullet or number
+	Level  int      // 0, or 1 for an indented sub-item
+	Number int      // the number a numbered item was written with
+	Cells  []string // of a table row
+	Header bool     // a table's header row
+}
+
 // contentBlock represents a parsed piece of content with its style
 type contentBlock struct {
-	text        string
-	style       textStyle
-	spaceBefore float64 // mm of space before this block
+	text         string
+	style        textStyle
+	spaceBefore  float64 // mm of space before this block
+	preformatted bool    // code, broken only where it overflows
+	block        Block
 }
 
-// parseContent converts raw text into styled content blocks
+// Blocks parses content into the same blocks the PDF is laid out from
+func (e *Exporter) Blocks(content string) []Block {
+	parsed := e.parseContent(content)
+	blocks := make([]Block, len(parsed))
+	for i, block := range parsed {
+		blocks[i] = block.block
+	}
+	return blocks
+}
+
+// parseContent converts Markdown into styled content blocks. Plain text
+// lays out as before: each line a block, with blank lines as spacing.
 func (e *Exporter) parseContent(content string) []contentBlock {
 	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	isBlank := func(lineNo int) bool {
+		return lineNo >= 1 && lineNo <= len(lines) && strings.TrimSpace(lines[lineNo-1]) == ""
+	}
 
+	doc := markdown.Parse(content)
 	var blocks []contentBlock
-	isFirstContent := true
-
-	for i := 0; i < len(lines); i++ {
-		line := lines[i]
-		trimmed := strings.TrimSpace(line)
-
-		// Empty line - add paragraph spacing
-		if trimmed == "" {
-			if !isFirstContent {
-				blocks = append(blocks, contentBlock{
-					text:        "",
-					style:       styleBody,
-					spaceBefore: 2.0,
-				})
-			}
-			continue
+	for i, node := range doc.Children {
+		// Blank lines between blocks - add paragraph spacing
+		if i > 0 && isBlank(node.Line-1) {
+			blocks = append(blocks, contentBlock{
+				text:        "",
+				style:       styleBody,
+				spaceBefore: 2.0,
+				block:       Block{Kind: BlockBlank},
+			})
 		}
 
-		// Auto-detect title: ONLY the very first non-empty line,
-		// and only if it's short + followed by a blank line
-		if isFirstContent {
-			isFirstContent = false
-			if len(trimmed) < 60 && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == "" {
+		// Auto-detect title: ONLY a first paragraph of one short line
+		// followed by a blank line
+		if i == 0 && node.Kind == markdown.Paragraph && node.EndLine == node.Line && isBlank(node.Line+1) {
+			if text := node.PlainText(); len(text) < 60 {
 				blocks = append(blocks, contentBlock{
-					text:        trimmed,
+					text:        text,
 					style:       styleTitle,
 					spaceBefore: 0,
+					block:       Block{Kind: BlockTitle, Text: text},
 				})
 				continue
 			}
 		}
-		isFirstContent = false
 
-		// Detect explicit markdown headings only
-		if strings.HasPrefix(trimmed, "### ") {
+		blocks = e.appendNode(blocks, node, 0, false)
+	}
+
+	return blocks
+}
+
+// appendNode adds the blocks for a Markdown block; depth is how deeply
+// it is nested in lists
+func (e *Exporter) appendNode(blocks []contentBlock, node *markdown.Node, depth int, quoted bool) []contentBlock {
+	switch node.Kind {
+	case markdown.Heading:
+		text := strings.ReplaceAll(node.PlainText(), "\n", " ")
+		switch node.Level {
+		case 1:
 			blocks = append(blocks, contentBlock{
-				text:        strings.TrimPrefix(trimmed, "### "),
-				style:       styleH3,
-				spaceBefore: 4.0,
+				text:        text,
+				style:       styleH1,
+				spaceBefore: 6.0,
+				block:       Block{Kind: BlockHeading1, Text: text},
 			})
-			continue
-		}
-		if strings.HasPrefix(trimmed, "## ") {
+		case 2:
 			blocks = append(blocks, contentBlock{
-				text:        strings.TrimPrefix(trimmed, "## "),
+				text:        text,
 				style:       styleH2,
 				spaceBefore: 5.0,
+				block:       Block{Kind: BlockHeading2, Text: text},
 			})
-			continue
-		}
-		if strings.HasPrefix(trimmed, "# ") {
+		default:
 			blocks = append(blocks, contentBlock{
-				text:        strings.TrimPrefix(trimmed, "# "),
-				style:       styleH1,
-				spaceBefore: 6.0,
+				text:        text,
+				style:       styleH3,
+				spaceBefore: 4.0,
+				block:       Block{Kind: BlockHeading3, Text: text},
 			})
-			continue
 		}
 
-		// Detect leading whitespace for sub-items
-		leadingSpaces := len(line) - len(strings.TrimLeft(line, " \t"))
-		isSubItem := leadingSpaces >= 2
-
-		// Detect bullet points (- or *)
-		if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
-			bulletText := trimmed[2:]
-			st := styleBullet
-			if isSubItem {
-				st = styleSubBullet
+	case markdown.Paragraph:
+		for _, line := range strings.Split(node.PlainText(), "\n") {
+			line = strings.TrimSpace(line)
+			if quoted {
+				blocks = append(blocks, contentBlock{
+					text:        line,
+					style:       styleQuote,
+					spaceBefore: 1.5,
+					block:       Block{Kind: BlockQuote, Text: line},
+				})
+				continue
 			}
 			blocks = append(blocks, contentBlock{
-				text:        "-  " + bulletText,
-				style:       st,
+				text:        line,
+				style:       styleBody,
 				spaceBefore: 1.5,
+				block:       Block{Kind: BlockBody, Text: line},
 			})
-			continue
 		}
 
-		// Detect numbered lists
-		if loc := numberedListRe.FindStringIndex(trimmed); loc != nil {
-			number := trimmed[:loc[1]]
-			rest := trimmed[loc[1]:]
-			st := styleNumbered
-			if isSubItem {
-				st.indent = 14
+	case markdown.BlockQuote:
+		for _, child := range node.Children {
+			blocks = e.appendNode(blocks, child, depth, true)
+		}
+
+	case markdown.List:
+		for i, item := range node.Children {
+			blocks = e.appendListItem(blocks, node, i, item, depth, quoted)
+		}
+
+	case markdown.CodeBlock, markdown.HTMLBlock:
+		for _, line := range strings.Split(strings.TrimSuffix(node.Literal, "\n"), "\n") {
+			blocks = append(blocks, contentBlock{
+				text:         line,
+				style:        styleCode,
+				spaceBefore:  0.5,
+				preformatted: true,
+				block:        Block{Kind: BlockCode, Text: line},
+			})
+		}
+
+	case markdown.Table:
+		for _, row := range node.Children {
+			cells := make([]string, len(row.Children))
+			for i, cell := range row.Children {
+				cells[i] = cell.PlainText()
+			}
+			st := styleTableRow
+			if row.Header {
+				st = styleTableHeader
 			}
 			blocks = append(blocks, contentBlock{
-				text:        number + rest,
+				text:        strings.Join(cells, "   |   "),
 				style:       st,
 				spaceBefore: 1.5,
+				block:       Block{Kind: BlockTableRow, Cells: cells, Header: row.Header},
 			})
-			continue
 		}
 
-		// Regular body text
+	case markdown.ThematicBreak:
 		blocks = append(blocks, contentBlock{
-			text:        trimmed,
+			text:        "",
 			style:       styleBody,
+			spaceBefore: 4.0,
+			block:       Block{Kind: BlockBlank},
+		})
+	}
+	return blocks
+}
+
+// appendListItem adds a bullet or numbered line for the item's first
+// paragraph, then the rest of the item one level further in
+func (e *Exporter) appendListItem(blocks []contentBlock, list *markdown.Node, index int, item *markdown.Node, depth int, quoted bool) []contentBlock {
+	level := min(depth, 1)
+	text := ""
+	rest := item.Children
+	if len(rest) > 0 && rest[0].Kind == markdown.Paragraph {
+		text = strings.ReplaceAll(rest[0].PlainText(), "\n", " ")
+		rest = rest[1:]
+	}
+	if item.Task {
+		if item.Checked {
+			text = "[x] " + text
+		} else {
+			text = "[ ] " + text
+		}
+	}
+
+	if list.Ordered {
+		st := styleNumbered
+		if level > 0 {
+			st.indent = 14
+		}
+		n := list.Start + index
+		blocks = append(blocks, contentBlock{
+			text:        strconv.Itoa(n) + string(list.Delimiter) + " " + text,
+			style:       st,
+			spaceBefore: 1.5,
+			block:       Block{Kind: BlockNumbered, Text: text, Level: level, Number: n},
+		})
+	} else {
+		st := styleBullet
+		if level > 0 {
+			st = styleSubBullet
+		}
+		blocks = append(blocks, contentBlock{
+			text:        "-  " + text,
+			style:       st,
 			spaceBefore: 1.5,
+			block:       Block{Kind: BlockBullet, Text: text, Level: level},
 		})
 	}
 
+	for _, child := range rest {
+		blocks = e.appendNode(blocks, child, depth+1, quoted)
+	}
 	return blocks
 }
 
@@ -294,6 +424,24 @@ func (e *Exporter) wrapText(text string, maxWidthMM float64, fontSize float64) [
 	return lines
 }
 
+// wrapCode breaks a line of code wherever it is too long, keeping its spacing
+func (e *Exporter) wrapCode(text string, maxWidthMM float64, fontSize float64) []string {
+	// Courier glyphs are all 600/1000 em wide
+	charWidth := fontSize * 0.6 * 0.3528
+	maxChars := int(maxWidthMM / charWidth)
+	if maxChars < 20 {
+		maxChars = 20
+	}
+
+	runes := []rune(strings.ReplaceAll(text, "\t", "    "))
+	var lines []string
+	for len(runes) > maxChars {
+		lines = append(lines, string(runes[:maxChars]))
+		runes = runes[maxChars:]
+	}
+	return append(lines, string(runes))
+}
+
 // splitWords splits text into words
 func (e *Exporter) splitWords(text string) []string {
 	var words []string
@@ -407,7 +555,7 @@ func (d *pdfDocument) write(filePath string) error {
 		content := page.buildContentStream()
 		compressed := compressStream(content)
 
-		// Page object with both regular and bold fonts
+		// Page object with the regular, bold and monospace fonts
 		writeObject(fmt.Sprintf("<<\n/Type /Page\n/Parent 2 0 R\n"+
 			"/MediaBox [0 0 595.28 841.89]\n"+
 			"/Contents %d 0 R\n"+
@@ -415,6 +563,7 @@ func (d *pdfDocument) write(filePath string) error {
 			"/Font <<\n"+
 			"/F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\n"+
 			"/F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold >>\n"+
+			"/F3 << /Type /Font /Subtype /Type1 /BaseFont /Courier >>\n"+
 			">>\n>>\n>>\n",
 			contentObjNum))
 
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Project search limits
+const (
+	maxProjectMatches     = 20000 // search stops after this many matches
+	projectSearchBatch    = 200   // matches per search event
+	projectEventInterval  = 150 * time.Millisecond
+	maxReplaceUndo        = 20 // replaces that can be undone
+	projectReplacePlanTTL = 30 * time.Minute
+)
+
+// ProjectSearchOptions describes a search across the workspace
+type ProjectSearchOptions struct {
+	Query         string   `json:"query"`
+	Regex         bool     `json:"regex"`
+	CaseSensitive bool     `json:"caseSensitive"`
+	WholeWord     bool     `json:"wholeWord"`
+	Include       []string `json:"include"` // .gitignore-style globs; empty searches every file
+	Exclude       []string `json:"exclude"`
+}
+
+// ProjectFileMatches are the matches in one file
+type ProjectFileMatches struct {
+	Path    string      `json:"path"`
+	RelPath string      `json:"relPath"`
+	Matches []LineMatch `json:"matches"`
+}
+
+// ProjectSearchResults is published as a project search progresses
+type ProjectSearchResults struct {
+	RequestID     string               `json:"requestId"`
+	Files         []ProjectFileMatches `json:"files"` // new since the previous event
+	FilesSearched int                  `json:"filesSearched"`
+	Matches       int                  `json:"matches"` // found so far
+	Done          bool                 `json:"done"`
+	Truncated     bool                 `json:"truncated"` // stopped at maxProjectMatches
+	Error         string               `json:"error,omitempty"`
+}
+
+// ReplacePreview shows what a replace will do to one file
+type ReplacePreview struct {
+	Path         string `json:"path"`
+	RelPath      string `json:"relPath"`
+	Replacements int    `json:"replacements"`
+	Diff         string `json:"diff"`
+}
+
+// ReplacePlan is a previewed replace, applied with ApplyReplace
+type ReplacePlan struct {
+	PlanID       string           `json:"planId"`
+	Files        []ReplacePreview `json:"files"`
+	Replacements int              `json:"replacements"`
+}
+
+// ReplaceResult reports an applied or undone replace
+type ReplaceResult struct {
+	Files        []string `json:"files"`
+	Replacements int      `json:"replacements"`
+	CanUndo      bool     `json:"canUndo"` // another replace is left to undo
+}
+
+// plannedFile is a file a replace plan will rewrite
+type plannedFile struct {
+	path         string
+	hash         string // of the bytes the plan was made from
+	data         []byte // the new bytes
+	replacements int
+	encoding     string // kept by the replace, for file history
+	lineEnding   string
+}
+
+// replacePlan is a replace waiting to be applied
+type replacePlan struct {
+	files   []*plannedFile
+	created time.Time
+}
+
+// replaceUndo restores the files of an applied replace
+type replaceUndo struct {
+	files        []undoFile
+	replacements int
+}
+
+// undoFile is a file as it was before a replace, and the hash of what the
+// replace wrote, so an undo won't lose later edits
+type undoFile struct {
+	path       string
+	original   []byte
+	written    string
+	encoding   string
+	lineEnding string
+}
+
+// ProjectSearch finds and replaces text across the files of the workspace
+type ProjectSearch struct {
+	app      *App
+	mu       sync.Mutex
+	searches map[string]context.CancelFunc // by request ID
+	plans    map[string]*replacePlan       // by plan ID
+	undo     []*replaceUndo                // most recent last
+	nextPlan int
+}
+
+// NewProjectSearch creates the project search service
+func NewProjectSearch(app *App) *ProjectSearch {
+	return &ProjectSearch{
+		app:      app,
+		searches: make(map[string]context.CancelFunc),
+		plans:    make(map[string]*replacePlan),
+	}
+}
+
+// compileProjectSearch builds the pattern for a search
+func compileProjectSearch(options ProjectSearchOptions) (*regexp.Regexp, error) {
+	query := options.Query
+	if query == "" {
+		return nil, fmt.Errorf("empty search")
+	}
+	if !options.Regex {
+		query = regexp.QuoteMeta(query)
+	}
+	if options.WholeWord {
+		// Word boundaries only make sense next to word characters
+		first, _ := utf8.DecodeRuneInString(options.Query)
+		last, _ := utf8.DecodeLastRuneInString(options.Query)
+		if options.Regex || isWordRune(first) {
+			query = `\b` + query
+		}
+		if options.Regex || isWordRune(last) {
+			query += `\b`
+		}
+		query = "(?:" + query + ")"
+	}
+	return compileSearch(query, options.CaseSensitive, true)
+}
+
+// isWordRune reports whether r is matched by \w
+func isWordRune(r rune) bool {
+	return r == '_' || (r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+// projectFilter decides which workspace files a search covers
+type projectFilter struct {
+	include *ignoreRules
+	exclude *ignoreRules
+}
+
+// newProjectFilter parses a search's include and exclude globs
+func newProjectFilter(options ProjectSearchOptions) projectFilter {
+	filter := projectFilter{exclude: parseIgnoreRules("", options.Exclude)}
+	if len(options.Include) > 0 {
+		filter.include = parseIgnoreRules("", options.Include)
+	}
+	return filter
+}
+
+// covers reports whether a file is searched
+func (f projectFilter) covers(rel string) bool {
+	if f.include != nil && !f.include.matchesPath(rel) {
+		return false
+	}
+	return !f.exclude.matchesPath(rel)
+}
+
+// readSearchable reads and decodes a workspace file for searching. ok is
+// false for large files, binary files and files that can't be read.
+func (ps *ProjectSearch) readSearchable(path string) (data []byte, content string, encoding string, layout *lineLayout, ok bool) {
+	if ps.app.FileManager.isLargeFile(path) {
+		return nil, "", "", nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || detectBinary(data).Binary {
+		return nil, "", "", nil, false
+	}
+	content, detection, layout, err := ps.app.FileManager.readWithDetection(data, "")
+	if err != nil {
+		return nil, "", "", nil, false
+	}
+	return data, content, detection.Encoding, layout, true
+}
+
+// findInContent lists the matches in decoded file content
+func findInContent(re *regexp.Regexp, content string, limit int) []LineMatch {
+	var matches []LineMatch
+	for line, text := range strings.Split(content, "\n") {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue // empty matches can't be shown or replaced usefully
+			}
+			excerpt, column := matchExcerpt(text, loc[0], loc[1])
+			matches = append(matches, LineMatch{
+				Line:       line,
+				Column:     column,
+				LineColumn: utf8.RuneCountInString(text[:loc[0]]),
+				Length:     utf8.RuneCountInString(text[loc[0]:loc[1]]),
+				Text:       excerpt,
+			})
+			if len(matches) >= limit {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// Search searches the workspace in the background, publishing
+// EventWorkspaceSearchResults in batches until every file is searched
+func (ps *ProjectSearch) Search(requestID string, options ProjectSearchOptions) error {
+	ws, err := ps.app.FileManager.Workspace()
+	if err != nil {
+		return err
+	}
+	re, err := compileProjectSearch(options)
+	if err != nil {
+		return err
+	}
+	filter := newProjectFilter(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ps.mu.Lock()
+	if old, ok := ps.searches[requestID]; ok {
+		old()
+	}
+	ps.searches[requestID] = cancel
+	ps.mu.Unlock()
+
+	go func() {
+		defer func() {
+			ps.mu.Lock()
+			delete(ps.searches, requestID)
+			ps.mu.Unlock()
+			cancel()
+		}()
+
+		results := ProjectSearchResults{RequestID: requestID}
+		pending := 0
+		lastEvent := time.Now()
+		send := func() {
+			ps.app.EventBus.Emit(EventWorkspaceSearchResults, results)
+			results.Files = nil
+			pending = 0
+			lastEvent = time.Now()
+		}
+
+		err := ws.walkFiles(ctx, "", func(rel string) bool {
+			if !filter.covers(rel) {
+				return true
+			}
+			path := ws.absPath(rel)
+			_, content, _, _, ok := ps.readSearchable(path)
+			results.FilesSearched++
+			if !ok {
+				return true
+			}
+
+			matches := findInContent(re, content, maxProjectMatches-results.Matches)
+			if len(matches) > 0 {
+				results.Files = append(results.Files, ProjectFileMatches{Path: path, RelPath: rel, Matches: matches})
+				results.Matches += len(matches)
+				pending += len(matches)
+			}
+			if results.Matches >= maxProjectMatches {
+				results.Truncated = true
+				return false
+			}
+			if pending >= projectSearchBatch || time.Since(lastEvent) >= projectEventInterval {
+				send()
+			}
+			return true
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			results.Error = err.Error()
+		}
+		results.Done = true
+		send()
+	}()
+
+	return nil
+}
+
+// CancelSearch stops a running project search
+func (ps *ProjectSearch) CancelSearch(requestID string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if cancel, ok := ps.searches[requestID]; ok {
+		cancel()
+		delete(ps.searches, requestID)
+	}
+}
+
+// PrepareReplace works out a replace across the workspace without writing
+// anything, returning a diff of every file it would change. With Regex set,
+// replacement can refer to groups as $1 or ${name}.
+func (ps *ProjectSearch) PrepareReplace(options ProjectSearchOptions, replacement string) (*ReplacePlan, error) {
+	ws, err := ps.app.FileManager.Workspace()
+	if err != nil {
+		return nil, err
+	}
+	re, err := compileProjectSearch(options)
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString("") {
+		return nil, fmt.Errorf("the search matches empty text, so there is nothing to replace")
+	}
+	filter := newProjectFilter(options)
+
+	plan := &replacePlan{created: time.Now()}
+	result := &ReplacePlan{Files: []ReplacePreview{}}
+	var planErr error
+	err = ws.walkFiles(context.Background(), "", func(rel string) bool {
+		if !filter.covers(rel) {
+			return true
+		}
+		path := ws.absPath(rel)
+		data, content, encoding, layout, ok := ps.readSearchable(path)
+		if !ok {
+			return true
+		}
+
+		count := 0
+		lines := strings.Split(content, "\n")
+		for i, line := range lines {
+			found := 0
+			for _, loc := range re.FindAllStringIndex(line, -1) {
+				if loc[0] != loc[1] {
+					found++
+				}
+			}
+			if found == 0 {
+				continue
+			}
+			count += found
+			if options.Regex {
+				lines[i] = re.ReplaceAllString(line, replacement)
+			} else {
+				lines[i] = re.ReplaceAllLiteralString(line, replacement)
+			}
+		}
+		if count == 0 {
+			return true
+		}
+
+		updated := strings.Join(lines, "\n")
+		if updated == content {
+			return true
+		}
+		encoded, err := encodeText(applyLineLayout(updated, layout), encoding)
+		if err != nil {
+			planErr = fmt.Errorf("%s: %w", rel, err)
+			return false
+		}
+
+		plan.files = append(plan.files, &plannedFile{
+			path:         path,
+			hash:         hashBytes(data),
+			data:         encoded,
+			replacements: count,
+			encoding:     encoding,
+			lineEnding:   layoutStyle(layout),
+		})
+		result.Files = append(result.Files, ReplacePreview{
+			Path:         path,
+			RelPath:      rel,
+			Replacements: count,
+			Diff:         unifiedDiff(content, updated, rel, rel),
+		})
+		result.Replacements += count
+		return true
+	})
+	if planErr != nil {
+		return nil, planErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for id, old := range ps.plans {
+		if time.Since(old.created) > projectReplacePlanTTL {
+			delete(ps.plans, id)
+		}
+	}
+	ps.nextPlan++
+	result.PlanID = strconv.Itoa(ps.nextPlan)
+	ps.plans[result.PlanID] = plan
+	return result, nil
+}
+
+// DiscardReplace forgets a replace plan that won't be applied
+func (ps *ProjectSearch) DiscardReplace(planID string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	delete(ps.plans, planID)
+}
+
+// ApplyReplace writes a prepared replace to the given files, or to every
+// file in the plan if paths is empty. Either every file is written or
+// none is: nothing is written if any file changed since the preview, and
+// files already written are put back if a later one fails.
+func (ps *ProjectSearch) ApplyReplace(planID string, paths []string) (*ReplaceResult, error) {
+	ps.mu.Lock()
+	plan, ok := ps.plans[planID]
+	delete(ps.plans, planID)
+	ps.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("the replace preview has expired; preview it again")
+	}
+
+	selected := plan.files
+	if len(paths) > 0 {
+		wanted := make(map[string]bool, len(paths))
+		for _, path := range paths {
+			wanted[filepath.Clean(path)] = true
+		}
+		selected = nil
+		for _, file := range plan.files {
+			if wanted[file.path] {
+				selected = append(selected, file)
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no files to replace in")
+	}
+
+	undo := &replaceUndo{}
+	var writes []fileWrite
+	for _, file := range selected {
+		original, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(file.path), err)
+		}
+		if hashBytes(original) != file.hash {
+			return nil, fmt.Errorf("%s: %w since the preview; preview the replace again", filepath.Base(file.path), ErrExternalChange)
+		}
+		writes = append(writes, fileWrite{path: file.path, data: file.data, encoding: file.encoding, lineEnding: file.lineEnding})
+		undo.files = append(undo.files, undoFile{
+			path:       file.path,
+			original:   original,
+			written:    hashBytes(file.data),
+			encoding:   file.encoding,
+			lineEnding: file.lineEnding,
+		})
+		undo.replacements += file.replacements
+	}
+
+	if err := ps.writeAll(writes, undo.files); err != nil {
+		return nil, err
+	}
+
+	ps.mu.Lock()
+	ps.undo = append(ps.undo, undo)
+	if len(ps.undo) > maxReplaceUndo {
+		ps.undo = ps.undo[len(ps.undo)-maxReplaceUndo:]
+	}
+	ps.mu.Unlock()
+
+	result := &ReplaceResult{Replacements: undo.replacements, CanUndo: true}
+	for _, file := range undo.files {
+		result.Files = append(result.Files, file.path)
+	}
+	return result, nil
+}
+
+// UndoReplace puts back every file changed by the most recent replace.
+// It refuses if any of them was edited after the replace.
+func (ps *ProjectSearch) UndoReplace() (*ReplaceResult, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if len(ps.undo) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	undo := ps.undo[len(ps.undo)-1]
+
+	var writes []fileWrite
+	current := make([]undoFile, 0, len(undo.files))
+	for _, file := range undo.files {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(file.path), err)
+		}
+		if hashBytes(data) != file.written {
+			return nil, fmt.Errorf("%s was changed after the replace; undoing would lose those changes", filepath.Base(file.path))
+		}
+		writes = append(writes, fileWrite{path: file.path, data: file.original, encoding: file.encoding, lineEnding: file.lineEnding})
+		current = append(current, undoFile{path: file.path, original: data})
+	}
+
+	if err := ps.writeAll(writes, current); err != nil {
+		return nil, err
+	}
+	ps.undo = ps.undo[:len(ps.undo)-1]
+
+	result := &ReplaceResult{Replacements: undo.replacements, CanUndo: len(ps.undo) > 0}
+	for _, file := range undo.files {
+		result.Files = append(result.Files, file.path)
+	}
+	return result, nil
+}
+
+// CanUndoReplace reports whether there is a replace to undo
+func (ps *ProjectSearch) CanUndoReplace() bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return len(ps.undo) > 0
+}
+
+// fileWrite is new content for a file, with the encoding and line ending
+// it is in for file history
+type fileWrite struct {
+	path       string
+	data       []byte
+	encoding   string
+	lineEnding string
+}
+
+// writeAll writes every file, restoring those already written from
+// previous if one fails. Each file is written atomically, and open tabs
+// pick the change up through the file watcher.
+func (ps *ProjectSearch) writeAll(writes []fileWrite, previous []undoFile) error {
+	fm := ps.app.FileManager
+	for i, write := range writes {
+		if err := writeFileAtomic(write.path, write.data, fm.backupMode()); err != nil {
+			for _, done := range previous[:i] {
+				if restoreErr := writeFileAtomic(done.path, done.original, BackupOnSaveNone); restoreErr != nil {
+					fmt.Printf("Failed to restore %s: %v\n", done.path, restoreErr)
+				}
+			}
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(write.path), err)
+		}
+	}
+
+	for _, write := range writes {
+		if stat, err := os.Stat(write.path); err == nil {
+			fm.recordHistory(write.path, write.data, write.encoding, write.lineEnding, stat.ModTime())
+		}
+	}
+	return nil
+}
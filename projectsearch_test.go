@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encodeAs encodes text the way a file in the named encoding stores it
+func encodeAs(t *testing.T, name, text string) []byte {
+	t.Helper()
+	data, err := encodeText(text, name)
+	if err != nil {
+		t.Fatalf("failed to encode %q as %s: %v", text, name, err)
+	}
+	return data
+}
+
+// replaceFixture is a workspace file before and after replacing "foo" with "bar"
+type replaceFixture struct {
+	name   string
+	before []byte
+	after  []byte
+}
+
+// replaceFixtures covers several encodings and line ending styles
+func replaceFixtures(t *testing.T) []replaceFixture {
+	const french = "Le café était déjà fermé, à côté de l'hôtel."
+	return []replaceFixture{
+		{
+			name:   "utf16.txt",
+			before: encodeAs(t, "UTF-16LE BOM", "one foo\r\ntwo\r\nfoo three foo\r\n"),
+			after:  encodeAs(t, "UTF-16LE BOM", "one bar\r\ntwo\r\nbar three bar\r\n"),
+		},
+		{
+			name:   "legacy.txt",
+			before: encodeAs(t, "Windows-1252", french+" foo\r\n"+french+"\nfoo\r"),
+			after:  encodeAs(t, "Windows-1252", french+" bar\r\n"+french+"\nbar\r"),
+		},
+		{
+			name:   "src/plain.go",
+			before: []byte("package foo\n\n// foo does nothing\nfunc foo() {}\n"),
+			after:  []byte("package bar\n\n// bar does nothing\nfunc bar() {}\n"),
+		},
+		{
+			name:   "untouched.txt",
+			before: []byte("nothing to see here\n"),
+			after:  []byte("nothing to see here\n"),
+		},
+	}
+}
+
+// newTestProjectSearch opens a workspace holding the fixtures
+func newTestProjectSearch(t *testing.T, fixtures []replaceFixture) (*ProjectSearch, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	root := t.TempDir()
+	for _, f := range fixtures {
+		path := filepath.Join(root, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, f.before, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := &App{EventBus: NewEventBus(), SettingsManager: NewSettingsManager()}
+	app.FileManager = NewFileManager(app)
+	app.Search = NewProjectSearch(app)
+	if _, err := app.FileManager.OpenFolder(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.FileManager.CloseFolder)
+	return app.Search, root
+}
+
+// checkFixtures compares every fixture file with its before or after bytes
+func checkFixtures(t *testing.T, root string, fixtures []replaceFixture, replaced bool) {
+	t.Helper()
+	for _, f := range fixtures {
+		got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f.name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := f.before
+		if replaced {
+			want = f.after
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s = %q, want %q", f.name, got, want)
+		}
+	}
+}
+
+func TestApplyReplaceAcrossFiles(t *testing.T) {
+	fixtures := replaceFixtures(t)
+	ps, root := newTestProjectSearch(t, fixtures)
+
+	plan, err := ps.PrepareReplace(ProjectSearchOptions{Query: "foo", CaseSensitive: true}, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Files) != 3 || plan.Replacements != 8 {
+		t.Fatalf("plan covers %d files and %d replacements, want 3 and 8", len(plan.Files), plan.Replacements)
+	}
+	checkFixtures(t, root, fixtures, false) // previewing writes nothing
+
+	result, err := ps.ApplyReplace(plan.PlanID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 3 || result.Replacements != 8 || !result.CanUndo {
+		t.Errorf("result = %+v", result)
+	}
+	checkFixtures(t, root, fixtures, true)
+
+	if _, err := ps.ApplyReplace(plan.PlanID, nil); err == nil {
+		t.Error("a plan was applied twice")
+	}
+}
+
+func TestApplyReplaceSelectedFiles(t *testing.T) {
+	fixtures := replaceFixtures(t)
+	ps, root := newTestProjectSearch(t, fixtures)
+
+	plan, err := ps.PrepareReplace(ProjectSearchOptions{Query: "foo", CaseSensitive: true}, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(root, "src", "plain.go")
+	result, err := ps.ApplyReplace(plan.PlanID, []string{plain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || result.Files[0] != plain || result.Replacements != 3 {
+		t.Errorf("result = %+v", result)
+	}
+	checkFixtures(t, root, []replaceFixture{fixtures[0], fixtures[1]}, false)
+	checkFixtures(t, root, []replaceFixture{fixtures[2]}, true)
+}
+
+func TestApplyReplaceRefusesChangedFile(t *testing.T) {
+	fixtures := replaceFixtures(t)
+	ps, root := newTestProjectSearch(t, fixtures)
+
+	plan, err := ps.PrepareReplace(ProjectSearchOptions{Query: "foo", CaseSensitive: true}, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another program edits one of the files after the preview
+	edited := append(append([]byte(nil), fixtures[2].before...), "// edited\n"...)
+	if err := os.WriteFile(filepath.Join(root, "src", "plain.go"), edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ps.ApplyReplace(plan.PlanID, nil); !errors.Is(err, ErrExternalChange) {
+		t.Fatalf("ApplyReplace = %v, want ErrExternalChange", err)
+	}
+	checkFixtures(t, root, fixtures[:2], false)
+	if ps.CanUndoReplace() {
+		t.Error("a refused replace can be undone")
+	}
+}
+
+func TestWriteAllRollsBack(t *testing.T) {
+	fixtures := replaceFixtures(t)
+	ps, root := newTestProjectSearch(t, fixtures)
+
+	first := filepath.Join(root, fixtures[0].name)
+	second := filepath.Join(root, fixtures[1].name)
+	writes := []fileWrite{
+		{path: first, data: fixtures[0].after},
+		{path: second, data: fixtures[1].after},
+		{path: filepath.Join(root, "missing", "folder.txt"), data: []byte("can't be written")},
+	}
+	previous := []undoFile{
+		{path: first, original: fixtures[0].before},
+		{path: second, original: fixtures[1].before},
+		{path: filepath.Join(root, "missing", "folder.txt")},
+	}
+
+	if err := ps.writeAll(writes, previous); err == nil {
+		t.Fatal("writeAll succeeded with an unwritable file")
+	}
+	checkFixtures(t, root, fixtures, false)
+}
+
+func TestUndoReplaceRestoresEveryFile(t *testing.T) {
+	fixtures := replaceFixtures(t)
+	ps, root := newTestProjectSearch(t, fixtures)
+
+	plan, err := ps.PrepareReplace(ProjectSearchOptions{Query: "foo", CaseSensitive: true}, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.ApplyReplace(plan.PlanID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ps.UndoReplace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 3 || result.Replacements != 8 || result.CanUndo {
+		t.Errorf("result = %+v", result)
+	}
+	// Byte for byte, so encodings, byte order marks and every line ending
+	// are back as they were
+	checkFixtures(t, root, fixtures, false)
+
+	if _, err := ps.UndoReplace(); err == nil {
+		t.Error("a replace was undone twice")
+	}
+}
+
+func TestUndoReplaceRefusesLaterEdits(t *testing.T) {
+	fixtures := replaceFixtures(t)
+	ps, root := newTestProjectSearch(t, fixtures)
+
+	plan, err := ps.PrepareReplace(ProjectSearchOptions{Query: "foo", CaseSensitive: true}, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.ApplyReplace(plan.PlanID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := filepath.Join(root, "src", "plain.go")
+	edited := append(append([]byte(nil), fixtures[2].after...), "// edited\n"...)
+	if err := os.WriteFile(plain, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ps.UndoReplace(); err == nil {
+		t.Fatal("UndoReplace discarded an edit made after the replace")
+	}
+	checkFixtures(t, root, fixtures[:2], true)
+	if !ps.CanUndoReplace() {
+		t.Error("a refused undo was dropped")
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recoverySnapshotInterval is how often changed buffers are written to the recovery directory
+const recoverySnapshotInterval = 5 * time.Second
+
+// validBufferID keeps buffer IDs safe to use as file names
+var validBufferID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// RecoveryInfo describes an unsaved editor buffer kept for crash recovery
+type RecoveryInfo struct {
+	BufferID   string `json:"bufferId"`
+	Path       string `json:"path"` // empty for untitled buffers
+	Name       string `json:"name"`
+	Encoding   string `json:"encoding"`
+	LineEnding string `json:"lineEnding"`
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+// RecoverableBuffer is a snapshot left behind by a previous run
+type RecoverableBuffer struct {
+	Info        RecoveryInfo `json:"info"`
+	Content     string       `json:"content"`
+	Diff        string       `json:"diff"`        // unified diff from the file on disk; empty if unchanged or untitled
+	DiskMissing bool         `json:"diskMissing"` // the file no longer exists
+}
+
+// AutoSaveEventData is published after AutoSave writes a buffer to its file
+type AutoSaveEventData struct {
+	BufferID string    `json:"bufferId"`
+	Revision int       `json:"revision"` // the buffer revision that was saved
+	FileInfo *FileInfo `json:"fileInfo,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// recoverySnapshot is the file stored for each buffer
+type recoverySnapshot struct {
+	Info    RecoveryInfo `json:"info"`
+	Content string       `json:"content"`
+}
+
+// recoveryBuffer is the latest state of an unsaved buffer in this session
+type recoveryBuffer struct {
+	info     RecoveryInfo
+	content  string
+	revision int
+	written  bool // the snapshot on disk is up to date
+	autoSave *time.Timer
+
+	encrypted bool // an encrypted note, which is never snapshotted; checked when the path is first seen
+}
+
+// RecoveryService snapshots unsaved buffers so they survive a crash, and
+// writes them through to their files when AutoSave is on
+type RecoveryService struct {
+	app      *App
+	dir      string
+	mu       sync.Mutex
+	buffers  map[string]*recoveryBuffer // this session's buffers by ID
+	previous map[string]bool            // snapshots left by earlier runs
+	done     chan struct{}
+}
+
+// NewRecoveryService creates a recovery service storing snapshots in ~/.akashic/recovery
+func NewRecoveryService(app *App) *RecoveryService {
+	return &RecoveryService{
+		app:      app,
+		dir:      filepath.Join(getSettingsDir(), "recovery"),
+		buffers:  make(map[string]*recoveryBuffer),
+		previous: make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start notes the snapshots left by earlier runs and begins snapshotting
+func (rs *RecoveryService) Start() {
+	if err := os.MkdirAll(rs.dir, 0700); err != nil {
+		fmt.Printf("Failed to create recovery directory: %v\n", err)
+	}
+
+	entries, err := os.ReadDir(rs.dir)
+	if err != nil {
+		fmt.Printf("Failed to list recovery snapshots: %v\n", err)
+	}
+	rs.mu.Lock()
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && validBufferID.MatchString(id) {
+			rs.previous[id] = true
+		}
+	}
+	rs.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(recoverySnapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-rs.done:
+				return
+			case <-ticker.C:
+				rs.flush()
+			}
+		}
+	}()
+}
+
+// Stop writes any pending snapshots and stops the service
+func (rs *RecoveryService) Stop() {
+	close(rs.done)
+	rs.flush()
+}
+
+// snapshotPath returns the snapshot file for a buffer
+func (rs *RecoveryService) snapshotPath(bufferID string) string {
+	return filepath.Join(rs.dir, bufferID+".json")
+}
+
+// UpdateBuffer records the current content of an unsaved buffer. It is
+// snapshotted on the next tick and, with AutoSave on, saved to its file
+// once it has been left alone for AutoSaveDelay seconds.
+func (rs *RecoveryService) UpdateBuffer(info RecoveryInfo, content string, revision int) error {
+	if !validBufferID.MatchString(info.BufferID) {
+		return fmt.Errorf("invalid buffer ID: %q", info.BufferID)
+	}
+	info.UpdatedAt = time.Now().Unix()
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	delete(rs.previous, info.BufferID) // a restored buffer carries on under its old ID
+	buffer, ok := rs.buffers[info.BufferID]
+	if !ok {
+		buffer = &recoveryBuffer{}
+		rs.buffers[info.BufferID] = buffer
+	}
+	// Checking reads the file, so it is done only when the buffer is new
+	// or saved under another name, not on every edit
+	encrypted := buffer.encrypted
+	if !ok || buffer.info.Path != info.Path {
+		encrypted = rs.app.FileManager.isEncrypted(info.Path)
+	}
+	buffer.info = info
+	buffer.content = content
+	buffer.revision = revision
+	buffer.written = false
+	if encrypted && !buffer.encrypted {
+		// A buffer saved as a note for the first time leaves no plaintext behind
+		os.Remove(rs.snapshotPath(info.BufferID))
+	}
+	buffer.encrypted = encrypted
+
+	if buffer.autoSave != nil {
+		buffer.autoSave.Stop()
+		buffer.autoSave = nil
+	}
+	settings := rs.app.SettingsManager.Get().Editor
+	if settings.AutoSave && info.Path != "" {
+		delay := time.Duration(max(settings.AutoSaveDelay, 1)) * time.Second
+		buffer.autoSave = time.AfterFunc(delay, func() {
+			rs.autoSave(info.BufferID, revision)
+		})
+	}
+	return nil
+}
+
+// DiscardBuffer forgets a buffer and deletes its snapshot, once it has
+// been saved or closed, or a previous run's snapshot has been recovered
+func (rs *RecoveryService) DiscardBuffer(bufferID string) error {
+	if !validBufferID.MatchString(bufferID) {
+		return fmt.Errorf("invalid buffer ID: %q", bufferID)
+	}
+
+	rs.mu.Lock()
+	if buffer, ok := rs.buffers[bufferID]; ok && buffer.autoSave != nil {
+		buffer.autoSave.Stop()
+	}
+	delete(rs.buffers, bufferID)
+	delete(rs.previous, bufferID)
+	rs.mu.Unlock()
+
+	if err := os.Remove(rs.snapshotPath(bufferID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete recovery snapshot: %w", err)
+	}
+	return nil
+}
+
+// flush writes a snapshot of every buffer changed since the last one
+func (rs *RecoveryService) flush() {
+	rs.mu.Lock()
+	var pending []recoverySnapshot
+	for _, buffer := range rs.buffers {
+		if !buffer.written && !buffer.encrypted {
+			pending = append(pending, recoverySnapshot{Info: buffer.info, Content: buffer.content})
+			buffer.written = true
+		}
+	}
+	rs.mu.Unlock()
+
+	for _, snapshot := range pending {
+		if err := rs.writeSnapshot(snapshot); err != nil {
+			fmt.Printf("Failed to write recovery snapshot for %s: %v\n", snapshot.Info.Name, err)
+		}
+	}
+}
+
+// writeSnapshot stores one snapshot, unless the buffer was discarded or
+// became an encrypted note meanwhile
+func (rs *RecoveryService) writeSnapshot(snapshot recoverySnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if buffer, ok := rs.buffers[snapshot.Info.BufferID]; !ok || buffer.encrypted {
+		return nil
+	}
+	if err := os.MkdirAll(rs.dir, 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(rs.snapshotPath(snapshot.Info.BufferID), data, BackupOnSaveNone)
+}
+
+// autoSave writes a buffer through to its file if it hasn't changed
+// since the timer was set
+func (rs *RecoveryService) autoSave(bufferID string, revision int) {
+	rs.mu.Lock()
+	buffer, ok := rs.buffers[bufferID]
+	if !ok || buffer.revision != revision {
+		rs.mu.Unlock()
+		return
+	}
+	info, content := buffer.info, buffer.content
+	buffer.autoSave = nil
+	rs.mu.Unlock()
+
+	event := AutoSaveEventData{BufferID: bufferID, Revision: revision}
+	fileInfo, err := rs.app.FileManager.WriteFile(info.Path, content, info.LineEnding, info.Encoding)
+	if err != nil {
+		event.Error = err.Error()
+	} else {
+		event.FileInfo = fileInfo
+		rs.app.EventBus.Publish(EventFileSave, FileEventData{FileInfo: fileInfo})
+
+		// The snapshot is only stale if the buffer changed during the save
+		rs.mu.Lock()
+		current := rs.buffers[bufferID] == buffer && buffer.revision == revision
+		rs.mu.Unlock()
+		if current {
+			rs.DiscardBuffer(bufferID)
+		}
+	}
+	rs.app.EventBus.Emit(EventFileAutoSave, event)
+}
+
+// Recoverable lists the snapshots left by earlier runs, oldest first, each
+// with a diff against the file it belongs to. Buffers of tabs in other
+// sessions are left for when those sessions are reopened.
+func (rs *RecoveryService) Recoverable() ([]RecoverableBuffer, error) {
+	var reserved map[string]bool
+	if rs.app.Sessions != nil {
+		reserved = rs.app.Sessions.inactiveBuffers()
+	}
+
+	rs.mu.Lock()
+	ids := make([]string, 0, len(rs.previous))
+	for id := range rs.previous {
+		if !reserved[id] {
+			ids = append(ids, id)
+		}
+	}
+	rs.mu.Unlock()
+
+	buffers := make([]RecoverableBuffer, 0, len(ids))
+	for _, id := range ids {
+		snapshot, err := rs.loadSnapshot(id)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot != nil {
+			buffers = append(buffers, rs.recoverable(*snapshot))
+		}
+	}
+
+	sort.Slice(buffers, func(i, j int) bool {
+		return buffers[i].Info.UpdatedAt < buffers[j].Info.UpdatedAt
+	})
+	return buffers, nil
+}
+
+// Buffers returns the unsaved content kept for the given buffers, from
+// this session or a snapshot on disk. Buffers with nothing kept are left out.
+func (rs *RecoveryService) Buffers(bufferIDs []string) ([]RecoverableBuffer, error) {
+	buffers := make([]RecoverableBuffer, 0, len(bufferIDs))
+	for _, id := range bufferIDs {
+		if !validBufferID.MatchString(id) {
+			return nil, fmt.Errorf("invalid buffer ID: %q", id)
+		}
+
+		rs.mu.Lock()
+		buffer, ok := rs.buffers[id]
+		var snapshot *recoverySnapshot
+		if ok {
+			snapshot = &recoverySnapshot{Info: buffer.info, Content: buffer.content}
+		}
+		rs.mu.Unlock()
+
+		if snapshot == nil {
+			var err error
+			if snapshot, err = rs.loadSnapshot(id); err != nil {
+				return nil, err
+			}
+		}
+		if snapshot != nil {
+			buffers = append(buffers, rs.recoverable(*snapshot))
+		}
+	}
+	return buffers, nil
+}
+
+// loadSnapshot reads a buffer's snapshot from disk, or returns nil if there
+// is none or it can't be parsed
+func (rs *RecoveryService) loadSnapshot(bufferID string) (*recoverySnapshot, error) {
+	data, err := os.ReadFile(rs.snapshotPath(bufferID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read recovery snapshot: %w", err)
+	}
+
+	var snapshot recoverySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		fmt.Printf("Skipping unreadable recovery snapshot %s: %v\n", bufferID, err)
+		return nil, nil
+	}
+	snapshot.Info.BufferID = bufferID
+	return &snapshot, nil
+}
+
+// recoverable compares a snapshot with the file it belongs to
+func (rs *RecoveryService) recoverable(snapshot recoverySnapshot) RecoverableBuffer {
+	buffer := RecoverableBuffer{Info: snapshot.Info, Content: snapshot.Content}
+	if snapshot.Info.Path == "" {
+		return buffer
+	}
+
+	disk, err := rs.app.FileManager.readPlain(snapshot.Info.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		buffer.DiskMissing = true
+	case err != nil:
+		fmt.Printf("Failed to read %s for recovery: %v\n", snapshot.Info.Path, err)
+	default:
+		diskContent, _, _, err := rs.app.FileManager.readWithDetection(disk, snapshot.Info.Encoding)
+		if err == nil {
+			buffer.Diff = unifiedDiff(diskContent, snapshot.Content, snapshot.Info.Name+" (on disk)", snapshot.Info.Name+" (recovered)")
+		}
+	}
+	return buffer
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSessionName is the session used until another is created
+const defaultSessionName = "Default"
+
+// sessionSaveDelay batches frequent cursor and scroll updates into one write
+const sessionSaveDelay = 2 * time.Second
+
+// maxSessionNameLength bounds session names
+const maxSessionNameLength = 64
+
+// SessionTab is an open tab as recorded in a session
+type SessionTab struct {
+	BufferID       string `json:"bufferId"`
+	Path           string `json:"path"` // empty for untitled tabs
+	Name           string `json:"name"`
+	Encoding       string `json:"encoding"`
+	LineEnding     string `json:"lineEnding"`
+	SelectionStart int    `json:"selectionStart"`
+	SelectionEnd   int    `json:"selectionEnd"`
+	ScrollTop      int    `json:"scrollTop"`
+	ScrollLeft     int    `json:"scrollLeft"`
+}
+
+// Session is a set of open tabs and the chat used with them
+type Session struct {
+	Name      string       `json:"name"`
+	Tabs      []SessionTab `json:"tabs"` // in tab order
+	ActiveTab string       `json:"activeTab"`
+	ChatID    int64        `json:"chatId"`
+	Folder    string       `json:"folder"` // the workspace folder, if one is open
+	UpdatedAt int64        `json:"updatedAt"`
+}
+
+// SessionSummary describes a saved session for the session list
+type SessionSummary struct {
+	Name      string `json:"name"`
+	TabCount  int    `json:"tabCount"`
+	UpdatedAt int64  `json:"updatedAt"`
+	Active    bool   `json:"active"`
+}
+
+// sessionFile is the stored form of all sessions
+type sessionFile struct {
+	Active   string              `json:"active"`
+	Sessions map[string]*Session `json:"sessions"`
+}
+
+// SessionStore keeps named sessions in ~/.akashic/sessions.json. The active
+// session follows the editor through UpdateTabs and editor events.
+type SessionStore struct {
+	path      string
+	mu        sync.Mutex
+	data      sessionFile
+	saveTimer *time.Timer
+}
+
+// NewSessionStore creates a session store
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		path: filepath.Join(getSettingsDir(), "sessions.json"),
+		data: sessionFile{
+			Active:   defaultSessionName,
+			Sessions: map[string]*Session{defaultSessionName: {Name: defaultSessionName}},
+		},
+	}
+}
+
+// Load reads the stored sessions
+func (ss *SessionStore) Load() error {
+	data, err := os.ReadFile(ss.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var loaded sessionFile
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse sessions: %w", err)
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if loaded.Sessions == nil {
+		loaded.Sessions = make(map[string]*Session)
+	}
+	if loaded.Sessions[loaded.Active] == nil {
+		loaded.Active = defaultSessionName
+		if loaded.Sessions[loaded.Active] == nil {
+			loaded.Sessions[loaded.Active] = &Session{Name: defaultSessionName}
+		}
+	}
+	ss.data = loaded
+	return nil
+}
+
+// save writes the sessions now. The caller holds ss.mu.
+func (ss *SessionStore) save() error {
+	if ss.saveTimer != nil {
+		ss.saveTimer.Stop()
+		ss.saveTimer = nil
+	}
+
+	data, err := json.MarshalIndent(ss.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ss.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(ss.path, data, BackupOnSaveNone)
+}
+
+// scheduleSave writes the sessions once updates pause. The caller holds ss.mu.
+func (ss *SessionStore) scheduleSave() {
+	ss.data.Sessions[ss.data.Active].UpdatedAt = time.Now().Unix()
+	if ss.saveTimer != nil {
+		return
+	}
+	ss.saveTimer = time.AfterFunc(sessionSaveDelay, func() {
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+
+		ss.saveTimer = nil
+		if err := ss.save(); err != nil {
+			fmt.Printf("Failed to save session: %v\n", err)
+		}
+	})
+}
+
+// Flush writes any pending changes
+func (ss *SessionStore) Flush() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return ss.save()
+}
+
+// copySession returns a copy the caller can't use to modify the store
+func copySession(session *Session) *Session {
+	clone := *session
+	clone.Tabs = append([]SessionTab(nil), session.Tabs...)
+	return &clone
+}
+
+// Current returns the active session
+func (ss *SessionStore) Current() *Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return copySession(ss.data.Sessions[ss.data.Active])
+}
+
+// UpdateTabs records the open tabs, in order, and the active one
+func (ss *SessionStore) UpdateTabs(tabs []SessionTab, activeTab string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	session := ss.data.Sessions[ss.data.Active]
+	session.Tabs = append([]SessionTab(nil), tabs...)
+	session.ActiveTab = activeTab
+	ss.scheduleSave()
+}
+
+// SetChat records the chat open alongside the session's tabs
+func (ss *SessionStore) SetChat(chatID int64) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	session := ss.data.Sessions[ss.data.Active]
+	if session.ChatID != chatID {
+		session.ChatID = chatID
+		ss.scheduleSave()
+	}
+}
+
+// SetFolder records the workspace folder open in the session
+func (ss *SessionStore) SetFolder(folder string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	session := ss.data.Sessions[ss.data.Active]
+	if session.Folder != folder {
+		session.Folder = folder
+		ss.scheduleSave()
+	}
+}
+
+// applyEditorEvent updates a tab's cursor, selection or scroll position,
+// or the active tab, from an editor event
+func (ss *SessionStore) applyEditorEvent(eventType string, data EditorEventData) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	session := ss.data.Sessions[ss.data.Active]
+	for i := range session.Tabs {
+		tab := &session.Tabs[i]
+		if tab.BufferID != data.BufferID {
+			continue
+		}
+
+		switch eventType {
+		case EventEditorCursor, EventEditorSelection:
+			tab.SelectionStart = data.SelectionStart
+			tab.SelectionEnd = data.SelectionEnd
+		case EventEditorScroll:
+			tab.ScrollTop = data.ScrollTop
+			tab.ScrollLeft = data.ScrollLeft
+		case EventEditorFocus:
+			session.ActiveTab = tab.BufferID
+		default:
+			return
+		}
+		ss.scheduleSave()
+		return
+	}
+}
+
+// validSessionName trims a session name and checks it is usable
+func validSessionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("session name is empty")
+	}
+	if len(name) > maxSessionNameLength {
+		return "", fmt.Errorf("session name is longer than %d characters", maxSessionNameLength)
+	}
+	return name, nil
+}
+
+// List returns the saved sessions, most recently used first
+func (ss *SessionStore) List() []SessionSummary {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	summaries := make([]SessionSummary, 0, len(ss.data.Sessions))
+	for name, session := range ss.data.Sessions {
+		summaries = append(summaries, SessionSummary{
+			Name:      name,
+			TabCount:  len(session.Tabs),
+			UpdatedAt: session.UpdatedAt,
+			Active:    name == ss.data.Active,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].UpdatedAt != summaries[j].UpdatedAt {
+			return summaries[i].UpdatedAt > summaries[j].UpdatedAt
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
+
+// SaveAs copies the active session under a new name and makes the copy active
+func (ss *SessionStore) SaveAs(name string) error {
+	name, err := validSessionName(name)
+	if err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if _, exists := ss.data.Sessions[name]; exists {
+		return fmt.Errorf("a session named %q already exists", name)
+	}
+	session := copySession(ss.data.Sessions[ss.data.Active])
+	session.Name = name
+	session.UpdatedAt = time.Now().Unix()
+	ss.data.Sessions[name] = session
+	ss.data.Active = name
+	return ss.save()
+}
+
+// Switch makes another session active, creating it empty if it doesn't exist
+func (ss *SessionStore) Switch(name string) (*Session, error) {
+	name, err := validSessionName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	session, ok := ss.data.Sessions[name]
+	if !ok {
+		session = &Session{Name: name}
+		ss.data.Sessions[name] = session
+	}
+	ss.data.Active = name
+	session.UpdatedAt = time.Now().Unix()
+	if err := ss.save(); err != nil {
+		return nil, err
+	}
+	return copySession(session), nil
+}
+
+// Delete removes a session other than the active one
+func (ss *SessionStore) Delete(name string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if name == ss.data.Active {
+		return fmt.Errorf("can't delete the active session")
+	}
+	if _, ok := ss.data.Sessions[name]; !ok {
+		return fmt.Errorf("no session named %q", name)
+	}
+	delete(ss.data.Sessions, name)
+	return ss.save()
+}
+
+// inactiveBuffers returns the buffer IDs of tabs in sessions other than
+// the active one
+func (ss *SessionStore) inactiveBuffers() map[string]bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ids := make(map[string]bool)
+	for name, session := range ss.data.Sessions {
+		if name == ss.data.Active {
+			continue
+		}
+		for _, tab := range session.Tabs {
+			ids[tab.BufferID] = true
+		}
+	}
+	return ids
+}
@@ -8,18 +8,24 @@ import (
 
 // EditorSettings contains editor configuration
 type EditorSettings struct {
-	FontFamily          string  `json:"fontFamily"`
-	FontSize            int     `json:"fontSize"`
-	LineHeight          float64 `json:"lineHeight"`
-	TabSize             int     `json:"tabSize"`
-	UseSpaces           bool    `json:"useSpaces"`
-	WordWrap            bool    `json:"wordWrap"`
-	LineNumbers         bool    `json:"lineNumbers"`
-	Minimap             bool    `json:"minimap"`
-	AutoSave            bool    `json:"autoSave"`
-	AutoSaveDelay       int     `json:"autoSaveDelay"` // seconds
-	ShowWhitespace      bool    `json:"showWhitespace"`
-	HighlightActiveLine bool    `json:"highlightActiveLine"`
+	FontFamily              string  `json:"fontFamily"`
+	FontSize                int     `json:"fontSize"`
+	LineHeight              float64 `json:"lineHeight"`
+	TabSize                 int     `json:"tabSize"`
+	UseSpaces               bool    `json:"useSpaces"`
+	WordWrap                bool    `json:"wordWrap"`
+	LineNumbers             bool    `json:"lineNumbers"`
+	Minimap                 bool    `json:"minimap"`
+	AutoSave                bool    `json:"autoSave"`
+	AutoSaveDelay           int     `json:"autoSaveDelay"` // seconds
+	ShowWhitespace          bool    `json:"showWhitespace"`
+	HighlightActiveLine     bool    `json:"highlightActiveLine"`
+	BackupOnSave            string  `json:"backupOnSave"`            // "none", "bak" or "timestamped"
+	LargeFileThresholdMB    int     `json:"largeFileThresholdMB"`    // larger files open read-only in large-file mode
+	FileHistory             bool    `json:"fileHistory"`             // keep a local copy of every saved version
+	FileHistoryMaxRevisions int     `json:"fileHistoryMaxRevisions"` // per file; 0 disables the count rule
+	FileHistoryMaxAgeDays   int     `json:"fileHistoryMaxAgeDays"`   // 0 disables the age rule
+	FileHistoryMaxSizeMB    int     `json:"fileHistoryMaxSizeMB"`    // all files together; 0 disables the size rule
 }
 
 // UISettings contains UI configuration
@@ -31,6 +37,9 @@ type UISettings struct {
 	SidebarPosition string `json:"sidebarPosition"` // "left" or "right"
 }
 
+// defaultAIEndpoint is the address of a local Ollama server
+const defaultAIEndpoint = "http://localhost:11434"
+
 // AISettings contains AI service configuration
 type AISettings struct {
 	Enabled         bool     `json:"enabled"`
@@ -39,31 +48,61 @@ type AISettings struct {
 	Temperature     float64  `json:"temperature"`
 	MaxTokens       int      `json:"maxTokens"`
 	AvailableModels []string `json:"availableModels"`
+	TitleModel      string   `json:"titleModel"` // small model for chat titles; empty truncates the first message
+}
+
+// SecuritySettings contains privacy configuration
+type SecuritySettings struct {
+	AutoLockMinutes int `json:"autoLockMinutes"` // 0 disables auto-lock
+}
+
+// HistorySettings contains chat history backup and retention configuration
+type HistorySettings struct {
+	BackupIntervalHours int    `json:"backupIntervalHours"` // 0 disables scheduled backups
+	MaxBackups          int    `json:"maxBackups"`
+	RetentionDays       int    `json:"retentionDays"`     // 0 disables the age rule
+	RetentionMaxChats   int    `json:"retentionMaxChats"` // 0 disables the count rule
+	RetentionAction     string `json:"retentionAction"`   // "archive" or "delete"
+}
+
+// WorkspaceSettings contains folder workspace configuration
+type WorkspaceSettings struct {
+	Excludes        []string `json:"excludes"`        // .gitignore-style patterns hidden from every workspace
+	MaxIndexedFiles int      `json:"maxIndexedFiles"` // the "go to file" index stops here
 }
 
 // Settings is the main configuration structure
 type Settings struct {
-	Editor EditorSettings `json:"editor"`
-	UI     UISettings     `json:"ui"`
-	AI     AISettings     `json:"ai"`
+	Editor    EditorSettings    `json:"editor"`
+	UI        UISettings        `json:"ui"`
+	AI        AISettings        `json:"ai"`
+	Security  SecuritySettings  `json:"security"`
+	History   HistorySettings   `json:"history"`
+	Workspace WorkspaceSettings `json:"workspace"`
 }
 
 // DefaultSettings returns the default configuration
 func DefaultSettings() *Settings {
 	return &Settings{
 		Editor: EditorSettings{
-			FontFamily:          "Consolas, 'Courier New', monospace",
-			FontSize:            14,
-			LineHeight:          1.5,
-			TabSize:             4,
-			UseSpaces:           true,
-			WordWrap:            true,
-			LineNumbers:         true,
-			Minimap:             false,
-			AutoSave:            false,
-			AutoSaveDelay:       5,
-			ShowWhitespace:      false,
-			HighlightActiveLine: true,
+			FontFamily:              "Consolas, 'Courier New', monospace",
+			FontSize:                14,
+			LineHeight:              1.5,
+			TabSize:                 4,
+			UseSpaces:               true,
+			WordWrap:                true,
+			LineNumbers:             true,
+			Minimap:                 false,
+			AutoSave:                false,
+			AutoSaveDelay:           5,
+			ShowWhitespace:          false,
+			HighlightActiveLine:     true,
+			BackupOnSave:            BackupOnSaveNone,
+			LargeFileThresholdMB:    defaultLargeFileThresholdMB,
+			FileHistory:             true,
+			FileHistoryMaxRevisions: defaultFileHistoryMaxRevisions,
+			FileHistoryMaxAgeDays:   defaultFileHistoryMaxAgeDays,
+			FileHistoryMaxSizeMB:    defaultFileHistoryMaxSizeMB,
 		},
 		UI: UISettings{
 			Theme:           "default-dark",
@@ -74,11 +113,26 @@ func DefaultSettings() *Settings {
 		},
 		AI: AISettings{
 			Enabled:         true,
-			Endpoint:        "http://localhost:11434",
+			Endpoint:        defaultAIEndpoint,
 			DefaultModel:    "mistral",
 			Temperature:     0.7,
 			MaxTokens:       2048,
 			AvailableModels: []string{"mistral", "llama3", "gemma", "deepseek-coder"},
+			TitleModel:      "",
+		},
+		Security: SecuritySettings{
+			AutoLockMinutes: 15,
+		},
+		History: HistorySettings{
+			BackupIntervalHours: 24,
+			MaxBackups:          10,
+			RetentionDays:       0,
+			RetentionMaxChats:   0,
+			RetentionAction:     "archive",
+		},
+		Workspace: WorkspaceSettings{
+			Excludes:        append([]string(nil), defaultWorkspaceExcludes...),
+			MaxIndexedFiles: defaultMaxIndexedFiles,
 		},
 	}
 }
@@ -117,13 +171,13 @@ func (sm *SettingsManager) Load() error {
 		return err
 	}
 
-	// Parse settings
-	var loadedSettings Settings
-	if err := json.Unmarshal(data, &loadedSettings); err != nil {
+	// Parse settings over the defaults so newly added fields keep sane values
+	loadedSettings := DefaultSettings()
+	if err := json.Unmarshal(data, loadedSettings); err != nil {
 		return err
 	}
 
-	sm.settings = &loadedSettings
+	sm.settings = loadedSettings
 	return nil
 }
 
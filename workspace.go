@@ -0,0 +1,678 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Workspace defaults
+const (
+	defaultMaxIndexedFiles = 100000
+	defaultFileMatchLimit  = 50
+)
+
+// defaultWorkspaceExcludes are hidden from every workspace, on top of its .gitignore files
+var defaultWorkspaceExcludes = []string{".git/", "node_modules/", ".DS_Store", "Thumbs.db"}
+
+// WorkspaceInfo describes the open folder and its file index
+type WorkspaceInfo struct {
+	Root      string `json:"root"`
+	Name      string `json:"name"`
+	Files     int    `json:"files"`
+	Indexed   bool   `json:"indexed"`   // the background index is complete
+	Truncated bool   `json:"truncated"` // indexing stopped at MaxIndexedFiles
+}
+
+// WorkspaceEntry is a file or folder in the workspace tree
+type WorkspaceEntry struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	RelPath string `json:"relPath"` // slash-separated, relative to the workspace root
+	IsDir   bool   `json:"isDir"`
+}
+
+// FileMatch is a "go to file" result
+type FileMatch struct {
+	Path      string `json:"path"`
+	RelPath   string `json:"relPath"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"` // indexes of the matched characters in RelPath
+}
+
+// WorkspaceEventData is published when a file or folder in the workspace
+// is created, renamed, moved or deleted
+type WorkspaceEventData struct {
+	Path    string `json:"path"`
+	OldPath string `json:"oldPath,omitempty"` // for renames and moves
+	IsDir   bool   `json:"isDir"`
+}
+
+// Workspace is a folder opened in the editor. It lists the folder lazily
+// for the file tree and indexes its files in the background for "go to
+// file", leaving out what .gitignore files and the configured excludes hide.
+type Workspace struct {
+	root     string
+	excludes *ignoreRules
+	maxFiles int
+
+	ignoreMu sync.Mutex
+	ignores  map[string]*ignoreRules // .gitignore rules by directory, loaded on first use
+
+	mu        sync.Mutex
+	files     map[string]bool // indexed files by slash-separated relative path
+	indexed   bool
+	truncated bool
+	cancel    context.CancelFunc
+}
+
+// newWorkspace opens a folder as a workspace
+func newWorkspace(root string, settings WorkspaceSettings) (*Workspace, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve folder: %w", err)
+	}
+	stat, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open folder: %w", err)
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", filepath.Base(root))
+	}
+
+	maxFiles := settings.MaxIndexedFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxIndexedFiles
+	}
+	return &Workspace{
+		root:     root,
+		excludes: parseIgnoreRules("", settings.Excludes),
+		maxFiles: maxFiles,
+		ignores:  make(map[string]*ignoreRules),
+		files:    make(map[string]bool),
+	}, nil
+}
+
+// Info describes the workspace
+func (w *Workspace) Info() WorkspaceInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return WorkspaceInfo{
+		Root:      w.root,
+		Name:      filepath.Base(w.root),
+		Files:     len(w.files),
+		Indexed:   w.indexed,
+		Truncated: w.truncated,
+	}
+}
+
+// relPath converts an absolute path inside the workspace to a
+// slash-separated relative one, refusing paths outside it
+func (w *Workspace) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(w.root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%s is outside the workspace", path)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// absPath converts a slash-separated relative path to an absolute one
+func (w *Workspace) absPath(rel string) string {
+	return filepath.Join(w.root, filepath.FromSlash(rel))
+}
+
+// gitignore returns the rules of the .gitignore in a directory, with
+// .git/info/exclude added for the root
+func (w *Workspace) gitignore(dir string) *ignoreRules {
+	w.ignoreMu.Lock()
+	defer w.ignoreMu.Unlock()
+
+	if rules, ok := w.ignores[dir]; ok {
+		return rules
+	}
+
+	var lines []string
+	sources := []string{filepath.Join(w.absPath(dir), ".gitignore")}
+	if dir == "" {
+		sources = append([]string{filepath.Join(w.root, ".git", "info", "exclude")}, sources...)
+	}
+	for _, source := range sources {
+		if data, err := os.ReadFile(source); err == nil {
+			lines = append(lines, strings.Split(string(data), "\n")...)
+		}
+	}
+
+	var rules *ignoreRules
+	if len(lines) > 0 {
+		rules = parseIgnoreRules(dir, lines)
+	}
+	w.ignores[dir] = rules
+	return rules
+}
+
+// forgetIgnores drops the cached .gitignore rules so edits to them take effect
+func (w *Workspace) forgetIgnores() {
+	w.ignoreMu.Lock()
+	defer w.ignoreMu.Unlock()
+
+	w.ignores = make(map[string]*ignoreRules)
+}
+
+// ignored reports whether a path is hidden by the excludes or by a
+// .gitignore in any directory above it. Deeper .gitignore files win.
+func (w *Workspace) ignored(rel string, isDir bool) bool {
+	if matched, ignored := w.excludes.match(rel, isDir); matched && ignored {
+		return true
+	}
+
+	ignored := false
+	dir, rest := "", rel
+	for {
+		if matched, ig := w.gitignore(dir).match(rel, isDir); matched {
+			ignored = ig
+		}
+		next, remaining, found := strings.Cut(rest, "/")
+		if !found {
+			return ignored
+		}
+		if dir == "" {
+			dir = next
+		} else {
+			dir += "/" + next
+		}
+		rest = remaining
+	}
+}
+
+// hidden reports whether a path or any folder above it is ignored
+func (w *Workspace) hidden(rel string, isDir bool) bool {
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' && w.ignored(rel[:i], true) {
+			return true
+		}
+	}
+	return w.ignored(rel, isDir)
+}
+
+// List returns the visible entries of a directory, folders first
+func (w *Workspace) List(dir string) ([]WorkspaceEntry, error) {
+	rel, err := w.relPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(w.absPath(rel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folder: %w", err)
+	}
+
+	list := make([]WorkspaceEntry, 0, len(entries))
+	for _, entry := range entries {
+		childRel := entry.Name()
+		if rel != "" {
+			childRel = rel + "/" + childRel
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if stat, err := os.Stat(w.absPath(childRel)); err == nil {
+				isDir = stat.IsDir()
+			}
+		}
+		if w.ignored(childRel, isDir) {
+			continue
+		}
+		list = append(list, WorkspaceEntry{
+			Name:    entry.Name(),
+			Path:    w.absPath(childRel),
+			RelPath: childRel,
+			IsDir:   isDir,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].IsDir != list[j].IsDir {
+			return list[i].IsDir
+		}
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list, nil
+}
+
+// Index rebuilds the file index in the background and calls done when it
+// finishes. An index already running is cancelled.
+func (w *Workspace) Index(done func(WorkspaceInfo)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w.mu.Lock()
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.cancel = cancel
+	w.indexed = false
+	w.mu.Unlock()
+
+	w.forgetIgnores()
+
+	go func() {
+		files := make(map[string]bool)
+		truncated := false
+		err := w.walkFiles(ctx, "", func(rel string) bool {
+			if len(files) >= w.maxFiles {
+				truncated = true
+				return false
+			}
+			files[rel] = true
+			return true
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Printf("Failed to index %s: %v\n", w.root, err)
+		}
+
+		w.mu.Lock()
+		w.files = files
+		w.indexed = true
+		w.truncated = truncated
+		w.mu.Unlock()
+
+		done(w.Info())
+	}()
+}
+
+// walkFiles calls visit with the relative path of every file under the
+// folder dir that isn't ignored, until visit returns false or ctx is done.
+// Unreadable folders are skipped.
+func (w *Workspace) walkFiles(ctx context.Context, dir string, visit func(rel string) bool) error {
+	if dir != "" && w.hidden(dir, true) {
+		return nil
+	}
+	return filepath.WalkDir(w.absPath(dir), func(path string, entry fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, _ := w.relPath(path)
+		if rel == "" {
+			return nil
+		}
+		if w.ignored(rel, entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if !visit(rel) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+}
+
+// Close stops any indexing in progress
+func (w *Workspace) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// FindFiles ranks the indexed files by how well their relative paths
+// fuzzy-match query, best first. An empty query lists the shortest paths first.
+func (w *Workspace) FindFiles(query string, limit int) []FileMatch {
+	if limit <= 0 {
+		limit = defaultFileMatchLimit
+	}
+	var needle []rune
+	for _, r := range strings.ReplaceAll(query, "\\", "/") {
+		if !unicode.IsSpace(r) {
+			needle = append(needle, unicode.ToLower(r))
+		}
+	}
+
+	w.mu.Lock()
+	paths := make([]string, 0, len(w.files))
+	for rel := range w.files {
+		paths = append(paths, rel)
+	}
+	w.mu.Unlock()
+
+	var matches []FileMatch
+	for _, rel := range paths {
+		score, positions, ok := fuzzyMatch(needle, rel)
+		if ok {
+			matches = append(matches, FileMatch{Path: w.absPath(rel), RelPath: rel, Score: score, Positions: positions})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if len(matches[i].RelPath) != len(matches[j].RelPath) {
+			return len(matches[i].RelPath) < len(matches[j].RelPath)
+		}
+		return matches[i].RelPath < matches[j].RelPath
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// indexAdd records a file or the files under a folder in the index after
+// they are created or moved in
+func (w *Workspace) indexAdd(rel string, isDir bool) {
+	if !isDir {
+		if w.hidden(rel, false) {
+			return
+		}
+		w.mu.Lock()
+		w.files[rel] = true
+		w.mu.Unlock()
+		return
+	}
+
+	w.walkFiles(context.Background(), rel, func(childRel string) bool {
+		w.mu.Lock()
+		w.files[childRel] = true
+		w.mu.Unlock()
+		return true
+	})
+}
+
+// indexRemove drops a file or everything under a folder from the index
+func (w *Workspace) indexRemove(rel string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.files, rel)
+	for path := range w.files {
+		if strings.HasPrefix(path, rel+"/") {
+			delete(w.files, path)
+		}
+	}
+}
+
+// validEntryName checks a new file or folder name has no path in it
+func validEntryName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%q is not a valid name", name)
+	}
+	return name, nil
+}
+
+// Create makes an empty file, or a folder, named name in dir
+func (w *Workspace) Create(dir string, name string, isDir bool) (string, error) {
+	name, err := validEntryName(name)
+	if err != nil {
+		return "", err
+	}
+	rel, err := w.relPath(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	path := w.absPath(rel)
+
+	if isDir {
+		if err := os.Mkdir(path, 0755); err != nil {
+			return "", fmt.Errorf("failed to create folder: %w", err)
+		}
+	} else {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to create file: %w", err)
+		}
+		file.Close()
+		if name == ".gitignore" {
+			w.forgetIgnores()
+		}
+		w.indexAdd(rel, false)
+	}
+	return path, nil
+}
+
+// Move renames a file or folder to newPath, which must not exist yet
+func (w *Workspace) Move(oldPath string, newPath string) error {
+	oldRel, err := w.relPath(oldPath)
+	if err != nil {
+		return err
+	}
+	newRel, err := w.relPath(newPath)
+	if err != nil {
+		return err
+	}
+	if oldRel == "" {
+		return fmt.Errorf("can't move the workspace folder")
+	}
+	if newRel == oldRel || strings.HasPrefix(newRel, oldRel+"/") {
+		return fmt.Errorf("can't move %s into itself", filepath.Base(oldPath))
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("%s already exists", filepath.Base(newPath))
+	}
+
+	stat, err := os.Stat(oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to move: %w", err)
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to move: %w", err)
+	}
+
+	if filepath.Base(oldPath) == ".gitignore" || filepath.Base(newPath) == ".gitignore" {
+		w.forgetIgnores()
+	}
+	w.indexRemove(oldRel)
+	w.indexAdd(newRel, stat.IsDir())
+	return nil
+}
+
+// Delete removes a file, or a folder and everything in it
+func (w *Workspace) Delete(path string) error {
+	rel, err := w.relPath(path)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return fmt.Errorf("can't delete the workspace folder")
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+
+	if filepath.Base(path) == ".gitignore" {
+		w.forgetIgnores()
+	}
+	w.indexRemove(rel)
+	return nil
+}
+
+// OpenFolder makes a folder the workspace, replacing any open one, and
+// starts indexing it. "workspace.index" reports when the index is ready.
+func (fm *FileManager) OpenFolder(root string) (*WorkspaceInfo, error) {
+	ws, err := newWorkspace(root, fm.app.SettingsManager.Get().Workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	fm.workspaceMu.Lock()
+	if fm.workspace != nil {
+		fm.workspace.Close()
+	}
+	fm.workspace = ws
+	fm.workspaceMu.Unlock()
+
+	fm.indexWorkspace(ws)
+	info := ws.Info()
+	return &info, nil
+}
+
+// indexWorkspace rebuilds a workspace's file index and reports it
+func (fm *FileManager) indexWorkspace(ws *Workspace) {
+	ws.Index(func(info WorkspaceInfo) {
+		fm.emit(EventWorkspaceIndex, info)
+	})
+}
+
+// CloseFolder closes the workspace
+func (fm *FileManager) CloseFolder() {
+	fm.workspaceMu.Lock()
+	defer fm.workspaceMu.Unlock()
+
+	if fm.workspace != nil {
+		fm.workspace.Close()
+		fm.workspace = nil
+	}
+}
+
+// Workspace returns the open workspace
+func (fm *FileManager) Workspace() (*Workspace, error) {
+	fm.workspaceMu.Lock()
+	defer fm.workspaceMu.Unlock()
+
+	if fm.workspace == nil {
+		return nil, fmt.Errorf("no folder is open")
+	}
+	return fm.workspace, nil
+}
+
+// RefreshWorkspace reindexes the workspace, picking up changes made by
+// other programs and edits to .gitignore files
+func (fm *FileManager) RefreshWorkspace() error {
+	ws, err := fm.Workspace()
+	if err != nil {
+		return err
+	}
+	fm.indexWorkspace(ws)
+	return nil
+}
+
+// CreateWorkspaceEntry creates an empty file or a folder in the workspace
+func (fm *FileManager) CreateWorkspaceEntry(dir string, name string, isDir bool) (string, error) {
+	ws, err := fm.Workspace()
+	if err != nil {
+		return "", err
+	}
+	path, err := ws.Create(dir, name, isDir)
+	if err != nil {
+		return "", err
+	}
+	fm.emit(EventFileCreated, WorkspaceEventData{Path: path, IsDir: isDir})
+	return path, nil
+}
+
+// MoveWorkspaceEntry renames or moves a file or folder in the workspace.
+// Open files under it carry on at their new paths.
+func (fm *FileManager) MoveWorkspaceEntry(oldPath string, newPath string) error {
+	ws, err := fm.Workspace()
+	if err != nil {
+		return err
+	}
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	stat, err := os.Stat(oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to move: %w", err)
+	}
+
+	open, err := fm.detachOpenFiles(oldPath)
+	if err != nil {
+		return err
+	}
+	if err := ws.Move(oldPath, newPath); err != nil {
+		fm.attachOpenFiles(open, oldPath, oldPath)
+		return err
+	}
+	fm.attachOpenFiles(open, oldPath, newPath)
+
+	fm.emit(EventFileRenamed, WorkspaceEventData{Path: newPath, OldPath: oldPath, IsDir: stat.IsDir()})
+	return nil
+}
+
+// DeleteWorkspaceEntry deletes a file or folder in the workspace
+func (fm *FileManager) DeleteWorkspaceEntry(path string) error {
+	ws, err := fm.Workspace()
+	if err != nil {
+		return err
+	}
+	path = filepath.Clean(path)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+
+	open, err := fm.detachOpenFiles(path)
+	if err != nil {
+		return err
+	}
+	if err := ws.Delete(path); err != nil {
+		fm.attachOpenFiles(open, path, path)
+		return err
+	}
+
+	fm.emit(EventFileDeleted, WorkspaceEventData{Path: path, IsDir: stat.IsDir()})
+	return nil
+}
+
+// detachOpenFiles stops tracking the open files at or under path, so the
+// watcher doesn't report a move or delete made by the editor itself
+func (fm *FileManager) detachOpenFiles(path string) (map[string]*openFileState, error) {
+	under := func(p string) bool {
+		return p == path || strings.HasPrefix(p, path+string(filepath.Separator))
+	}
+
+	fm.largeMu.Lock()
+	for p := range fm.largeFiles {
+		if under(p) {
+			fm.largeMu.Unlock()
+			return nil, fmt.Errorf("close %s before moving or deleting it", filepath.Base(p))
+		}
+	}
+	fm.largeMu.Unlock()
+
+	fm.openMu.Lock()
+	detached := make(map[string]*openFileState)
+	for p, state := range fm.openFiles {
+		if under(p) {
+			detached[p] = state
+			delete(fm.openFiles, p)
+		}
+	}
+	fm.openMu.Unlock()
+
+	if fm.watcher != nil {
+		for p := range detached {
+			fm.watcher.Unwatch(p)
+		}
+	}
+	return detached, nil
+}
+
+// attachOpenFiles tracks detached open files again, at their new paths
+// if they moved from under oldPath to under newPath
+func (fm *FileManager) attachOpenFiles(detached map[string]*openFileState, oldPath string, newPath string) {
+	for p, state := range detached {
+		fm.setOpenFile(newPath+strings.TrimPrefix(p, oldPath), state)
+	}
+}